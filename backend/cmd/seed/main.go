@@ -3,10 +3,12 @@ package main
 import (
 	"fmt"
 	"log"
+	"time"
 
 	"github.com/huy1235588/fashion-e-commerce/internal/config"
 	"github.com/huy1235588/fashion-e-commerce/internal/database"
 	"github.com/huy1235588/fashion-e-commerce/internal/models"
+	"github.com/huy1235588/fashion-e-commerce/internal/repositories"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
@@ -28,19 +30,44 @@ func main() {
 	log.Println("Dropping existing tables...")
 	// Drop all tables in order (respecting foreign keys)
 	if err := db.Migrator().DropTable(
+		&models.SearchLog{},
+		&models.RestockSubscription{},
+		&models.Wishlist{},
 		&models.Review{},
+		&models.PromotionUsage{},
+		"promotion_categories",
+		"promotion_products",
+		&models.Promotion{},
+		&models.StockReservation{},
+		&models.InventoryMovement{},
+		&models.ReconciliationItem{},
+		&models.ReconciliationReport{},
+		&models.Refund{},
+		&models.PaymentCallbackEvent{},
 		&models.Payment{},
 		&models.OrderItem{},
 		&models.Order{},
 		&models.Address{},
+		&models.ShippingZone{},
 		&models.CartItem{},
 		&models.Cart{},
 		&models.ProductVariant{},
 		&models.ProductImage{},
 		&models.Product{},
 		&models.Category{},
+		&models.RefreshToken{},
+		&models.EmailVerificationToken{},
+		&models.LoginThrottle{},
+		&models.TwoFactorChallenge{},
+		&models.TwoFactorBackupCode{},
+		&models.TwoFactorAuth{},
+		&models.OAuthState{},
+		&models.UserIdentity{},
 		&models.PasswordResetCode{},
 		&models.User{},
+		"role_permissions",
+		&models.Role{},
+		&models.Permission{},
 	); err != nil {
 		log.Fatalf("Failed to drop tables: %v", err)
 	}
@@ -66,10 +93,22 @@ func main() {
 		log.Fatalf("Failed to seed products: %v", err)
 	}
 
+	if err := repositories.NewProductRepository(db).RefreshSearchVectors(); err != nil {
+		log.Fatalf("Failed to build product search index: %v", err)
+	}
+
+	if err := seedInventoryLedger(db); err != nil {
+		log.Fatalf("Failed to seed inventory ledger: %v", err)
+	}
+
 	if err := seedAddresses(db); err != nil {
 		log.Fatalf("Failed to seed addresses: %v", err)
 	}
 
+	if err := seedShipping(db); err != nil {
+		log.Fatalf("Failed to seed shipping: %v", err)
+	}
+
 	log.Println("✅ Data seeding completed successfully!")
 }
 
@@ -101,7 +140,7 @@ func seedUsers(db *gorm.DB) error {
 			Password: string(hashedPassword),
 			FullName: "Nguyễn Văn A",
 			Phone:    "0912345678",
-			Role:     "user",
+			Role:     "customer",
 			IsActive: true,
 		},
 		{
@@ -109,12 +148,17 @@ func seedUsers(db *gorm.DB) error {
 			Password: string(hashedPassword),
 			FullName: "Trần Thị B",
 			Phone:    "0923456789",
-			Role:     "user",
+			Role:     "customer",
 			IsActive: true,
 		},
 	}
 
-	return db.Create(&users).Error
+	if err := db.Create(&users).Error; err != nil {
+		return err
+	}
+
+	// Demo accounts skip email verification
+	return db.Model(&models.User{}).Where("verified_at IS NULL").Update("verified_at", time.Now()).Error
 }
 
 func seedCategories(db *gorm.DB) error {
@@ -152,10 +196,42 @@ func seedCategories(db *gorm.DB) error {
 			Name:        "Phụ kiện",
 			Slug:        "phu-kien",
 			Description: "Các loại phụ kiện thời trang",
+			Position:    3,
 		},
 	}
 
-	return db.Create(&categories).Error
+	if err := db.Create(&categories).Error; err != nil {
+		return err
+	}
+
+	// Group the men's and women's categories under a parent each
+	parents := []models.Category{
+		{
+			Name:        "Nam",
+			Slug:        "nam",
+			Description: "Thời trang nam",
+			Position:    1,
+		},
+		{
+			Name:        "Nữ",
+			Slug:        "nu",
+			Description: "Thời trang nữ",
+			Position:    2,
+		},
+	}
+	if err := db.Create(&parents).Error; err != nil {
+		return err
+	}
+
+	for i, parent := range parents {
+		children := []uint{categories[2*i].ID, categories[2*i+1].ID}
+		if err := db.Model(&models.Category{}).Where("id IN ?", children).
+			Update("parent_id", parent.ID).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 func seedProducts(db *gorm.DB) error {
@@ -170,7 +246,7 @@ func seedProducts(db *gorm.DB) error {
 
 	// Get categories
 	var categories []models.Category
-	if err := db.Find(&categories).Error; err != nil {
+	if err := db.Order("id").Find(&categories).Error; err != nil {
 		return err
 	}
 	if len(categories) < 5 {
@@ -428,6 +504,17 @@ func seedProducts(db *gorm.DB) error {
 	return db.Create(&products).Error
 }
 
+// seedInventoryLedger writes an opening ledger entry for every seeded variant
+func seedInventoryLedger(db *gorm.DB) error {
+	log.Println("Seeding inventory ledger...")
+
+	return db.Exec(`
+		INSERT INTO inventory_movements (variant_id, delta, stock_after, reason, note, created_at)
+		SELECT id, stock_quantity, stock_quantity, ?, 'Seed data', NOW()
+		FROM product_variants
+		WHERE stock_quantity <> 0`, models.MovementReasonInitial).Error
+}
+
 func seedAddresses(db *gorm.DB) error {
 	log.Println("Seeding addresses...")
 
@@ -505,3 +592,30 @@ func makeImages(primary string, others ...string) []models.ProductImage {
 	}
 	return images
 }
+
+// seedShipping seeds shipping zones and gives seeded variants a shipping weight
+func seedShipping(db *gorm.DB) error {
+	log.Println("Seeding shipping zones...")
+
+	zones := []models.ShippingZone{
+		{Province: "Hồ Chí Minh", Fee: 20000},
+		{Province: "Hồ Chí Minh", District: "Quận 1", Fee: 15000},
+		{Province: "Hồ Chí Minh", District: "Quận 3", Fee: 15000},
+		{Province: "Hà Nội", Fee: 30000},
+	}
+	if err := db.Create(&zones).Error; err != nil {
+		return err
+	}
+
+	// Approximate packed weights in grams: trousers are heavier, accessories lighter
+	return db.Exec(`
+		UPDATE product_variants SET weight = CASE categories.slug
+			WHEN 'quan-nam' THEN 500
+			WHEN 'quan-nu' THEN 450
+			WHEN 'phu-kien' THEN 200
+			ELSE 300
+		END
+		FROM products
+		JOIN categories ON categories.id = products.category_id
+		WHERE products.id = product_variants.product_id`).Error
+}
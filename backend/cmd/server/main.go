@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
@@ -15,6 +16,7 @@ import (
 	"github.com/huy1235588/fashion-e-commerce/internal/database"
 	"github.com/huy1235588/fashion-e-commerce/internal/handlers"
 	"github.com/huy1235588/fashion-e-commerce/internal/middleware"
+	"github.com/huy1235588/fashion-e-commerce/internal/models"
 	"github.com/huy1235588/fashion-e-commerce/internal/repositories"
 	"github.com/huy1235588/fashion-e-commerce/internal/services"
 	"github.com/huy1235588/fashion-e-commerce/internal/utils"
@@ -45,7 +47,17 @@ func main() {
 	db := database.GetDB()
 
 	// Initialize JWT utility
-	jwtUtil := utils.NewJWTUtil(cfg.App.JWTSecret, time.Duration(cfg.App.JWTExpiresHours)*time.Hour)
+	jwtUtil := utils.NewJWTUtil(cfg.App.JWTSecret, time.Duration(cfg.App.JWTExpiresMinutes)*time.Minute)
+
+	// Initialize encryption for stored TOTP secrets
+	twoFactorKey := cfg.Auth.TwoFactorEncryptionKey
+	if twoFactorKey == "" {
+		twoFactorKey = cfg.App.JWTSecret
+	}
+	twoFactorEncryptor, err := utils.NewEncryptor(twoFactorKey)
+	if err != nil {
+		log.Fatalf("Failed to configure two-factor encryption: %v", err)
+	}
 
 	// Initialize shared utilities
 	emailService := utils.NewEmailService(
@@ -64,6 +76,7 @@ func main() {
 		HashSecret: cfg.Payment.VNPay.HashSecret,
 		PaymentURL: cfg.Payment.VNPay.PaymentURL,
 		ReturnURL:  cfg.Payment.VNPay.ReturnURL,
+		APIURL:     cfg.Payment.VNPay.APIURL,
 	})
 
 	momoHelper := utils.NewMoMoHelper(utils.MoMoConfig{
@@ -71,6 +84,8 @@ func main() {
 		AccessKey:   cfg.Payment.MoMo.AccessKey,
 		SecretKey:   cfg.Payment.MoMo.SecretKey,
 		PaymentURL:  cfg.Payment.MoMo.PaymentURL,
+		RefundURL:   cfg.Payment.MoMo.RefundURL,
+		QueryURL:    cfg.Payment.MoMo.QueryURL,
 		IPNUrl:      cfg.Payment.MoMo.IPNUrl,
 		ReturnURL:   cfg.Payment.MoMo.ReturnURL,
 	})
@@ -78,6 +93,9 @@ func main() {
 	// Initialize repositories
 	userRepo := repositories.NewUserRepository(db)
 	resetCodeRepo := repositories.NewPasswordResetCodeRepository(db)
+	refreshTokenRepo := repositories.NewRefreshTokenRepository(db)
+	verificationRepo := repositories.NewEmailVerificationRepository(db)
+	loginThrottleRepo := repositories.NewLoginThrottleRepository(db)
 	categoryRepo := repositories.NewCategoryRepository(db)
 	productRepo := repositories.NewProductRepository(db)
 	cartRepo := repositories.NewCartRepository(db)
@@ -86,34 +104,97 @@ func main() {
 	paymentRepo := repositories.NewPaymentRepository(db)
 	reviewRepo := repositories.NewReviewRepository(db)
 	statsRepo := repositories.NewStatisticsRepository(db)
+	inventoryRepo := repositories.NewInventoryRepository(db)
+	promotionRepo := repositories.NewPromotionRepository(db)
+	shippingZoneRepo := repositories.NewShippingZoneRepository(db)
+	refundRepo := repositories.NewRefundRepository(db)
+	reconciliationRepo := repositories.NewReconciliationRepository(db)
+	roleRepo := repositories.NewRoleRepository(db)
+	identityRepo := repositories.NewUserIdentityRepository(db)
+	twoFactorRepo := repositories.NewTwoFactorRepository(db)
+	wishlistRepo := repositories.NewWishlistRepository(db)
+	searchRepo := repositories.NewSearchRepository(db)
+
+	// Initialize shipping fee calculator
+	shippingCalculator, err := newShippingCalculator(cfg.Shipping, shippingZoneRepo)
+	if err != nil {
+		log.Fatalf("Failed to configure shipping: %v", err)
+	}
 
 	// Initialize services
-	authService := services.NewAuthService(userRepo, resetCodeRepo, jwtUtil, emailService)
-	categoryService := services.NewCategoryService(categoryRepo)
-	productService := services.NewProductService(productRepo, categoryRepo, uploadService)
-	cartService := services.NewCartService(cartRepo, productRepo)
+	inventoryService := services.NewInventoryService(inventoryRepo, db)
+	promotionService := services.NewPromotionService(promotionRepo, db)
+	shippingService := services.NewShippingService(shippingCalculator, cfg.Shipping.FreeShippingThreshold, shippingZoneRepo, cartRepo, addressRepo)
+	reservationService := services.NewReservationService(inventoryService, db, time.Duration(cfg.Inventory.ReservationTTLMinutes)*time.Minute)
+	loginThrottleService := services.NewLoginThrottleService(loginThrottleRepo, emailService, services.LoginThrottleSettings{
+		MaxAccountFailures: cfg.Auth.LoginMaxFailures,
+		MaxIPFailures:      cfg.Auth.LoginIPMaxFailures,
+		Window:             time.Duration(cfg.Auth.LoginFailureWindowMinutes) * time.Minute,
+		LockoutDuration:    time.Duration(cfg.Auth.LoginLockoutMinutes) * time.Minute,
+		DelayAfter:         cfg.Auth.LoginDelayAfterFailures,
+		MaxDelay:           time.Duration(cfg.Auth.LoginMaxDelaySeconds) * time.Second,
+		UnlockURL:          strings.TrimRight(cfg.Auth.FrontendURL, "/") + "/unlock-account",
+	})
+	twoFactorService := services.NewTwoFactorService(twoFactorRepo, userRepo, roleRepo, twoFactorEncryptor, db, services.TwoFactorSettings{
+		Issuer:               cfg.Auth.TwoFactorIssuer,
+		ChallengeTTL:         time.Duration(cfg.Auth.TwoFactorChallengeTTLMinutes) * time.Minute,
+		MaxChallengeAttempts: cfg.Auth.TwoFactorMaxAttempts,
+		RequiredForStaff:     cfg.Auth.RequireTwoFactorForStaff,
+	})
+	authService := services.NewAuthService(userRepo, resetCodeRepo, refreshTokenRepo, verificationRepo, loginThrottleService, twoFactorService, jwtUtil, emailService, db, services.AuthSettings{
+		RefreshTokenTTL:            time.Duration(cfg.App.RefreshTokenExpiresDays) * 24 * time.Hour,
+		VerificationURL:            strings.TrimRight(cfg.Auth.FrontendURL, "/") + "/verify-email",
+		VerificationTTL:            time.Duration(cfg.Auth.EmailVerificationTTLHours) * time.Hour,
+		VerificationResendCooldown: time.Duration(cfg.Auth.VerificationResendCooldownSecs) * time.Second,
+		VerificationMaxPerDay:      cfg.Auth.VerificationMaxPerDay,
+		ResetCodeTTL:               time.Duration(cfg.Auth.ResetCodeTTLMinutes) * time.Minute,
+		ResetCodeMaxAttempts:       cfg.Auth.ResetCodeMaxAttempts,
+		ResetCodeMaxPerHour:        cfg.Auth.ResetCodeMaxPerHour,
+		ResetCodeMaxPerIPPerHour:   cfg.Auth.ResetCodeMaxPerIPPerHour,
+	})
+	oauthService := services.NewOAuthService(oauthProviders(cfg.OAuth), identityRepo, authService, db)
+	categoryService := services.NewCategoryService(categoryRepo, productRepo)
+	cartService := services.NewCartService(cartRepo, productRepo, reservationService, utils.NewCartTokenSigner(cfg.App.JWTSecret), db)
+	wishlistService := services.NewWishlistService(wishlistRepo, productRepo, cartService, emailService, strings.TrimRight(cfg.Auth.FrontendURL, "/")+"/products")
+	searchService := services.NewSearchService(searchRepo)
+	productService := services.NewProductService(productRepo, categoryRepo, inventoryService, uploadService, wishlistService, searchService, db)
 	addressService := services.NewAddressService(addressRepo)
-	orderService := services.NewOrderService(orderRepo, cartRepo, addressRepo, productRepo, db, emailService)
-	paymentService := services.NewPaymentService(paymentRepo, orderRepo, vnpayHelper, momoHelper, db)
-	reviewService := services.NewReviewService(reviewRepo, orderRepo)
-	adminService := services.NewAdminService(db, userRepo, productRepo, orderRepo)
+	refundService := services.NewRefundService(refundRepo, orderRepo, vnpayHelper, momoHelper, db)
+	orderService := services.NewOrderService(orderRepo, cartRepo, addressRepo, productRepo, userRepo, inventoryService, reservationService, promotionService, shippingService, refundService, db, emailService, cfg.Auth.RequireVerifiedEmailForCheckout)
+	paymentService := services.NewPaymentService(paymentRepo, orderRepo, reservationService, vnpayHelper, momoHelper, db)
+	reconciliationService := services.NewReconciliationService(reconciliationRepo, paymentRepo, reservationService, refundService, vnpayHelper, momoHelper, db, time.Duration(cfg.Payment.Reconciliation.PendingAfterMinutes)*time.Minute)
+	reviewService := services.NewReviewService(reviewRepo, orderRepo, productRepo)
+	adminService := services.NewAdminService(db, userRepo, productRepo, orderRepo, roleRepo, orderService)
+	roleService := services.NewRoleService(roleRepo, db)
 	statisticsService := services.NewStatisticsService(statsRepo)
 
 	// Initialize middleware
-	authMiddleware := middleware.NewAuthMiddleware(jwtUtil)
+	authMiddleware := middleware.NewAuthMiddleware(jwtUtil, authService, roleService)
 
 	// Initialize handlers
-	authHandler := handlers.NewAuthHandler(authService)
+	authHandler := handlers.NewAuthHandler(authService, cartService)
+	oauthHandler := handlers.NewOAuthHandler(oauthService, cartService)
+	twoFactorHandler := handlers.NewTwoFactorHandler(twoFactorService)
 	categoryHandler := handlers.NewCategoryHandler(categoryService)
 	productHandler := handlers.NewProductHandler(productService)
+	searchHandler := handlers.NewSearchHandler(searchService)
 	cartHandler := handlers.NewCartHandler(cartService)
+	wishlistHandler := handlers.NewWishlistHandler(wishlistService)
 	addressHandler := handlers.NewAddressHandler(addressService)
 	orderHandler := handlers.NewOrderHandler(orderService)
+	guestHandler := handlers.NewGuestHandler(cartService, orderService, paymentService)
 	paymentHandler := handlers.NewPaymentHandler(paymentService)
 	reviewHandler := handlers.NewReviewHandler(reviewService)
 	adminHandler := handlers.NewAdminHandler(adminService)
 	statisticsHandler := handlers.NewStatisticsHandler(statisticsService)
 	uploadHandler := handlers.NewUploadHandler(uploadService)
+	inventoryHandler := handlers.NewInventoryHandler(inventoryService)
+	promotionHandler := handlers.NewPromotionHandler(promotionService)
+	shippingHandler := handlers.NewShippingHandler(shippingService)
+	refundHandler := handlers.NewRefundHandler(refundService)
+	reconciliationHandler := handlers.NewReconciliationHandler(reconciliationService)
+	roleHandler := handlers.NewRoleHandler(roleService)
+	lockoutHandler := handlers.NewLockoutHandler(loginThrottleService)
 
 	// Initialize Gin router
 	router := gin.New()
@@ -143,6 +224,13 @@ func main() {
 			auth.POST("/login", authHandler.Login)
 			auth.POST("/forgot-password", authHandler.SendResetCode)
 			auth.POST("/reset-password", authHandler.ResetPassword)
+			auth.POST("/refresh", authHandler.Refresh)
+			auth.POST("/verify-email", authHandler.VerifyEmail)
+			auth.POST("/unlock-account", authHandler.UnlockAccount)
+			auth.GET("/oauth/providers", oauthHandler.ListProviders)
+			auth.GET("/oauth/:provider/authorize", oauthHandler.Authorize)
+			auth.POST("/oauth/:provider/callback", oauthHandler.Callback)
+			auth.POST("/2fa/verify", authHandler.VerifyTwoFactor)
 
 			// Protected auth routes
 			authProtected := auth.Group("")
@@ -150,6 +238,14 @@ func main() {
 			{
 				authProtected.GET("/profile", authHandler.GetProfile)
 				authProtected.PUT("/profile", authHandler.UpdateProfile)
+				authProtected.POST("/logout", authHandler.Logout)
+				authProtected.POST("/resend-verification", authHandler.ResendVerification)
+				authProtected.POST("/logout-all", authHandler.LogoutAll)
+				authProtected.GET("/2fa", twoFactorHandler.GetStatus)
+				authProtected.POST("/2fa/setup", twoFactorHandler.Setup)
+				authProtected.POST("/2fa/enable", twoFactorHandler.Enable)
+				authProtected.POST("/2fa/disable", twoFactorHandler.Disable)
+				authProtected.POST("/2fa/backup-codes", twoFactorHandler.RegenerateBackupCodes)
 			}
 		}
 
@@ -157,6 +253,7 @@ func main() {
 		categories := api.Group("/categories")
 		{
 			categories.GET("", categoryHandler.ListCategories)
+			categories.GET("/tree", categoryHandler.GetCategoryTree)
 			categories.GET("/:id", categoryHandler.GetCategory)
 			categories.GET("/slug/:slug", categoryHandler.GetCategoryBySlug)
 		}
@@ -165,6 +262,9 @@ func main() {
 		products := api.Group("/products")
 		{
 			products.GET("", productHandler.ListProducts)
+			products.GET("/search", productHandler.SearchProducts)
+			products.GET("/facets", productHandler.GetProductFacets)
+			products.GET("/suggest", searchHandler.Suggest)
 			products.GET("/:id", productHandler.GetProduct)
 			products.GET("/slug/:slug", productHandler.GetProductBySlug)
 			// Review routes for products (public read)
@@ -181,6 +281,53 @@ func main() {
 			cart.PUT("/items/:id", cartHandler.UpdateCartItem)
 			cart.DELETE("/items/:id", cartHandler.RemoveCartItem)
 			cart.POST("/clear", cartHandler.ClearCart)
+			cart.POST("/validate", cartHandler.ValidateCart)
+			cart.POST("/items/:id/move", cartHandler.MoveCartItem)
+			cart.POST("/items/:id/save-for-later", cartHandler.SaveForLater)
+		}
+
+		// Saved lists (protected): named carts next to the primary cart
+		carts := api.Group("/carts")
+		carts.Use(authMiddleware.ValidateJWT())
+		{
+			carts.GET("", cartHandler.ListCarts)
+			carts.POST("", cartHandler.CreateSavedCart)
+			carts.GET("/:id", cartHandler.GetCartByID)
+			carts.PUT("/:id", cartHandler.RenameCart)
+			carts.DELETE("/:id", cartHandler.DeleteCart)
+			carts.POST("/:id/items", cartHandler.AddToCartByID)
+			carts.PUT("/:id/items/:item_id", cartHandler.UpdateCartItemByID)
+			carts.DELETE("/:id/items/:item_id", cartHandler.RemoveCartItemByID)
+			carts.POST("/:id/validate", cartHandler.ValidateCartByID)
+			carts.POST("/:id/checkout", orderHandler.CheckoutCart)
+		}
+
+		// Wishlist routes (protected)
+		wishlist := api.Group("/wishlist")
+		wishlist.Use(authMiddleware.ValidateJWT())
+		{
+			wishlist.GET("", wishlistHandler.GetWishlist)
+			wishlist.POST("", wishlistHandler.AddToWishlist)
+			wishlist.DELETE("/:id", wishlistHandler.RemoveFromWishlist)
+			wishlist.POST("/:id/move-to-cart", wishlistHandler.MoveToCart)
+			wishlist.GET("/restock-alerts", wishlistHandler.GetRestockAlerts)
+			wishlist.POST("/restock-alerts", wishlistHandler.SubscribeRestock)
+			wishlist.DELETE("/restock-alerts/:variant_id", wishlistHandler.UnsubscribeRestock)
+		}
+
+		// Guest routes (public): anonymous cart identified by the X-Cart-Token header
+		guest := api.Group("/guest")
+		{
+			guest.POST("/cart", guestHandler.CreateCart)
+			guest.GET("/cart", guestHandler.GetCart)
+			guest.POST("/cart/items", guestHandler.AddToCart)
+			guest.PUT("/cart/items/:id", guestHandler.UpdateCartItem)
+			guest.DELETE("/cart/items/:id", guestHandler.RemoveCartItem)
+			guest.POST("/cart/clear", guestHandler.ClearCart)
+			guest.POST("/cart/validate", guestHandler.ValidateCart)
+			guest.POST("/checkout", guestHandler.Checkout)
+			guest.POST("/orders/lookup", guestHandler.LookupOrder)
+			guest.POST("/orders/pay", guestHandler.InitiatePayment)
 		}
 
 		// Address routes (protected)
@@ -201,6 +348,7 @@ func main() {
 		{
 			orders.POST("", orderHandler.CreateOrder)
 			orders.GET("", orderHandler.GetMyOrders)
+			orders.POST("/claim", orderHandler.ClaimGuestOrders)
 			orders.GET("/:id", orderHandler.GetOrderByID)
 			orders.POST("/:id/cancel", orderHandler.CancelOrder)
 		}
@@ -233,34 +381,75 @@ func main() {
 			users.GET("/me/reviews", reviewHandler.GetUserReviews)
 		}
 
-		// Admin routes
+		// Admin routes, each guarded by the permission it needs
 		admin := api.Group("/admin")
-		admin.Use(authMiddleware.ValidateJWT(), authMiddleware.RequireAdmin())
+		admin.Use(authMiddleware.ValidateJWT())
+		if cfg.Auth.RequireTwoFactorForStaff {
+			admin.Use(authMiddleware.RequireTwoFactor())
+		}
 		{
 			// Dashboard
-			admin.GET("/dashboard/stats", adminHandler.GetDashboardStats)
+			admin.GET("/dashboard/stats", authMiddleware.RequirePermission(models.PermissionStatsRead), adminHandler.GetDashboardStats)
 
 			// Statistics routes
 			statistics := admin.Group("/statistics")
+			statistics.Use(authMiddleware.RequirePermission(models.PermissionStatsRead))
 			{
 				statistics.GET("/dashboard", statisticsHandler.GetDashboardStats)
 				statistics.GET("/revenue", statisticsHandler.GetRevenue)
 				statistics.GET("/products/top", statisticsHandler.GetTopProducts)
+				statistics.GET("/products/wishlist", statisticsHandler.GetWishlistProducts)
 				statistics.GET("/orders", statisticsHandler.GetOrderStats)
 				statistics.GET("/customers", statisticsHandler.GetCustomerStats)
 				statistics.GET("/categories/revenue", statisticsHandler.GetCategoryRevenue)
+				statistics.GET("/search/top", searchHandler.GetTopQueries)
+				statistics.GET("/search/zero-results", searchHandler.GetZeroResultQueries)
 			}
 
 			// User management
-			admin.GET("/users", adminHandler.ListAllUsers)
-			admin.PUT("/users/:id/role", adminHandler.UpdateUserRole)
+			admin.GET("/users", authMiddleware.RequirePermission(models.PermissionUsersRead), adminHandler.ListAllUsers)
+			admin.PUT("/users/:id/role", authMiddleware.RequirePermission(models.PermissionUsersManage), adminHandler.UpdateUserRole)
+			admin.PUT("/users/:id/status", authMiddleware.RequirePermission(models.PermissionUsersManage), adminHandler.UpdateUserStatus)
+			admin.DELETE("/users/:id/two-factor", authMiddleware.RequirePermission(models.PermissionUsersManage), twoFactorHandler.ResetUserTwoFactor)
+
+			// Login lockouts
+			admin.GET("/lockouts", authMiddleware.RequirePermission(models.PermissionUsersRead), lockoutHandler.ListLockouts)
+			admin.DELETE("/lockouts/:id", authMiddleware.RequirePermission(models.PermissionUsersManage), lockoutHandler.ClearLockout)
+
+			// Roles and permissions
+			adminRoles := admin.Group("")
+			adminRoles.Use(authMiddleware.RequirePermission(models.PermissionRolesManage))
+			{
+				adminRoles.GET("/roles", roleHandler.ListRoles)
+				adminRoles.POST("/roles", roleHandler.CreateRole)
+				adminRoles.PUT("/roles/:id", roleHandler.UpdateRole)
+				adminRoles.DELETE("/roles/:id", roleHandler.DeleteRole)
+				adminRoles.GET("/permissions", roleHandler.ListPermissions)
+			}
 
 			// Order management
-			admin.GET("/orders", adminHandler.ListAllOrders)
-			admin.PUT("/orders/:id/status", adminHandler.UpdateOrderStatus)
+			admin.GET("/orders", authMiddleware.RequirePermission(models.PermissionOrdersRead), adminHandler.ListAllOrders)
+			admin.PUT("/orders/:id/status", authMiddleware.RequirePermission(models.PermissionOrdersUpdate), adminHandler.UpdateOrderStatus)
+
+			// Refunds
+			admin.POST("/orders/:id/refunds", authMiddleware.RequirePermission(models.PermissionRefundsManage), refundHandler.CreateRefund)
+			admin.GET("/orders/:id/refunds", authMiddleware.RequirePermission(models.PermissionOrdersRead), refundHandler.GetOrderRefunds)
+			admin.GET("/refunds", authMiddleware.RequirePermission(models.PermissionOrdersRead), refundHandler.ListRefunds)
+			admin.POST("/refunds/:id/settle", authMiddleware.RequirePermission(models.PermissionRefundsManage), refundHandler.SettleRefund)
+
+			// Payment reconciliation
+			adminReconciliation := admin.Group("/payments/reconciliation")
+			adminReconciliation.Use(authMiddleware.RequirePermission(models.PermissionPaymentsReconcile))
+			{
+				adminReconciliation.POST("/run", reconciliationHandler.ReconcilePending)
+				adminReconciliation.GET("/reports", reconciliationHandler.ListReports)
+				adminReconciliation.GET("/reports/:date", reconciliationHandler.GetReport)
+				adminReconciliation.POST("/reports/:date", reconciliationHandler.BuildReport)
+			}
 
 			// Category management
 			adminCategories := admin.Group("/categories")
+			adminCategories.Use(authMiddleware.RequirePermission(models.PermissionCategoriesWrite))
 			{
 				adminCategories.POST("", categoryHandler.CreateCategory)
 				adminCategories.PUT("/:id", categoryHandler.UpdateCategory)
@@ -269,6 +458,7 @@ func main() {
 
 			// Product management
 			adminProducts := admin.Group("/products")
+			adminProducts.Use(authMiddleware.RequirePermission(models.PermissionProductsWrite))
 			{
 				adminProducts.POST("", productHandler.CreateProduct)
 				adminProducts.PUT("/:id", productHandler.UpdateProduct)
@@ -283,6 +473,35 @@ func main() {
 				adminProducts.PUT("/:id/variants/:variant_id", productHandler.UpdateProductVariant)
 				adminProducts.DELETE("/:id/variants/:variant_id", productHandler.DeleteProductVariant)
 			}
+
+			// Promotion management
+			adminPromotions := admin.Group("/promotions")
+			adminPromotions.Use(authMiddleware.RequirePermission(models.PermissionPromotionsManage))
+			{
+				adminPromotions.GET("", promotionHandler.ListPromotions)
+				adminPromotions.GET("/:id", promotionHandler.GetPromotion)
+				adminPromotions.POST("", promotionHandler.CreatePromotion)
+				adminPromotions.PUT("/:id", promotionHandler.UpdatePromotion)
+				adminPromotions.DELETE("/:id", promotionHandler.DeletePromotion)
+			}
+
+			// Shipping zones
+			adminShipping := admin.Group("/shipping")
+			adminShipping.Use(authMiddleware.RequirePermission(models.PermissionShippingManage))
+			{
+				adminShipping.GET("/zones", shippingHandler.ListZones)
+				adminShipping.POST("/zones", shippingHandler.SaveZone)
+				adminShipping.DELETE("/zones/:id", shippingHandler.DeleteZone)
+			}
+
+			// Inventory ledger
+			adminInventory := admin.Group("/inventory")
+			{
+				adminInventory.GET("/variants/:variant_id/movements", authMiddleware.RequirePermission(models.PermissionInventoryRead), inventoryHandler.GetMovements)
+				adminInventory.POST("/variants/:variant_id/movements", authMiddleware.RequirePermission(models.PermissionInventoryWrite), inventoryHandler.AdjustStock)
+				adminInventory.GET("/reconcile", authMiddleware.RequirePermission(models.PermissionInventoryRead), inventoryHandler.Reconcile)
+				adminInventory.POST("/reconcile/:variant_id", authMiddleware.RequirePermission(models.PermissionInventoryWrite), inventoryHandler.RecordReconciliation)
+			}
 		}
 	}
 
@@ -299,6 +518,21 @@ func main() {
 		Handler: router,
 	}
 
+	// Start background workers
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+
+	go reservationService.StartSweeper(workerCtx, time.Duration(cfg.Inventory.ReservationSweepIntervalSecs)*time.Second)
+	go loginThrottleService.StartCleanup(workerCtx, time.Hour)
+	go oauthService.StartStateCleanup(workerCtx, time.Hour)
+	go wishlistService.StartRestockNotifier(workerCtx, time.Duration(cfg.Inventory.RestockNotifyIntervalMinutes)*time.Minute)
+	go cartService.StartGuestCartCleanup(workerCtx, time.Hour, time.Duration(cfg.Cart.GuestCartTTLDays)*24*time.Hour)
+	go productService.StartSalesStatsRefresher(workerCtx, time.Duration(cfg.Catalog.SalesStatsIntervalMinutes)*time.Minute)
+	go searchService.StartLogCleanup(workerCtx, time.Hour, time.Duration(cfg.Catalog.SearchLogRetentionDays)*24*time.Hour)
+	go twoFactorService.StartCleanup(workerCtx, time.Hour)
+	go authService.StartResetCodeCleanup(workerCtx, time.Duration(cfg.Auth.ResetCodeCleanupIntervalMinutes)*time.Minute)
+	go reconciliationService.StartWorker(workerCtx, time.Duration(cfg.Payment.Reconciliation.IntervalMinutes)*time.Minute, cfg.Payment.Reconciliation.ReportHour)
+
 	// Start server in a goroutine
 	go func() {
 		log.Printf("Server starting on port %s", addr)
@@ -314,6 +548,9 @@ func main() {
 
 	log.Println("Server shutting down gracefully...")
 
+	// Stop background workers
+	stopWorkers()
+
 	// Give outstanding requests 5 seconds to complete
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -324,3 +561,49 @@ func main() {
 
 	log.Println("Server exited")
 }
+
+// newShippingCalculator builds the shipping fee calculator selected by SHIPPING_CALCULATOR,
+// wrapped with the free shipping threshold when one is configured
+func newShippingCalculator(cfg config.ShippingConfig, zoneRepo repositories.ShippingZoneRepository) (services.ShippingCalculator, error) {
+	var calculator services.ShippingCalculator
+
+	switch cfg.Calculator {
+	case "flat":
+		calculator = services.NewFlatRateCalculator(cfg.FlatFee)
+	case "zone":
+		calculator = services.NewZoneCalculator(zoneRepo, cfg.FlatFee)
+	case "weight":
+		tiers, err := services.ParseWeightTiers(cfg.WeightTiers)
+		if err != nil {
+			return nil, err
+		}
+		calculator, err = services.NewWeightTierCalculator(tiers, cfg.WeightExtraFeePerKg)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown shipping calculator %q", cfg.Calculator)
+	}
+
+	if cfg.FreeShippingThreshold > 0 {
+		calculator = services.NewFreeShippingCalculator(cfg.FreeShippingThreshold, calculator)
+	}
+
+	return calculator, nil
+}
+
+// oauthProviders returns the social login providers that have credentials configured
+func oauthProviders(cfg config.OAuthConfig) []services.IdentityProvider {
+	var providers []services.IdentityProvider
+
+	if cfg.Google.ClientID != "" {
+		providers = append(providers, utils.NewOIDCProvider(
+			utils.GoogleProviderConfig(cfg.Google.ClientID, cfg.Google.ClientSecret, cfg.Google.RedirectURL), nil))
+	}
+	if cfg.Facebook.ClientID != "" {
+		providers = append(providers, utils.NewOIDCProvider(
+			utils.FacebookProviderConfig(cfg.Facebook.ClientID, cfg.Facebook.ClientSecret, cfg.Facebook.RedirectURL), nil))
+	}
+
+	return providers
+}
@@ -10,13 +10,19 @@ import (
 
 // Config holds all application configuration
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
-	App      AppConfig
-	Payment  PaymentConfig
-	Email    EmailConfig
-	Upload   UploadConfig
-	CORS     CORSConfig
+	Server    ServerConfig
+	Database  DatabaseConfig
+	App       AppConfig
+	Auth      AuthConfig
+	OAuth     OAuthConfig
+	Payment   PaymentConfig
+	Inventory InventoryConfig
+	Cart      CartConfig
+	Catalog   CatalogConfig
+	Shipping  ShippingConfig
+	Email     EmailConfig
+	Upload    UploadConfig
+	CORS      CORSConfig
 }
 
 // ServerConfig holds server-related configuration
@@ -37,15 +43,56 @@ type DatabaseConfig struct {
 
 // AppConfig holds application-level configuration
 type AppConfig struct {
-	Environment     string
-	JWTSecret       string
-	JWTExpiresHours int
+	Environment             string
+	JWTSecret               string
+	JWTExpiresMinutes       int // access token lifetime
+	RefreshTokenExpiresDays int
+}
+
+// AuthConfig holds account security configuration
+type AuthConfig struct {
+	FrontendURL                     string // base URL for links sent by email
+	EmailVerificationTTLHours       int
+	VerificationResendCooldownSecs  int
+	VerificationMaxPerDay           int
+	RequireVerifiedEmailForCheckout bool
+	ResetCodeTTLMinutes             int
+	ResetCodeMaxAttempts            int // wrong guesses before a reset code is burned
+	ResetCodeMaxPerHour             int // per email address
+	ResetCodeMaxPerIPPerHour        int
+	ResetCodeCleanupIntervalMinutes int
+	LoginMaxFailures                int // per email address before it is locked
+	LoginIPMaxFailures              int
+	LoginFailureWindowMinutes       int
+	LoginLockoutMinutes             int
+	LoginDelayAfterFailures         int // failures before attempts are slowed down
+	LoginMaxDelaySeconds            int
+	TwoFactorIssuer                 string // account name shown in authenticator apps
+	TwoFactorEncryptionKey          string // encrypts stored TOTP secrets; JWT_SECRET if empty
+	TwoFactorChallengeTTLMinutes    int
+	TwoFactorMaxAttempts            int  // wrong codes before the login must start over
+	RequireTwoFactorForStaff        bool // roles with admin permissions need 2FA for admin routes
+}
+
+// OAuthConfig holds social login providers. A provider is enabled when its
+// client ID is set.
+type OAuthConfig struct {
+	Google   OAuthProviderConfig
+	Facebook OAuthProviderConfig
+}
+
+// OAuthProviderConfig holds the app credentials registered with a provider
+type OAuthProviderConfig struct {
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string // storefront page the provider sends the user back to
 }
 
 // PaymentConfig holds payment gateway configuration
 type PaymentConfig struct {
-	VNPay VNPayConfig
-	MoMo  MoMoConfig
+	VNPay          VNPayConfig
+	MoMo           MoMoConfig
+	Reconciliation ReconciliationConfig
 }
 
 // VNPayConfig holds VNPay configuration
@@ -54,6 +101,7 @@ type VNPayConfig struct {
 	HashSecret string
 	PaymentURL string
 	ReturnURL  string
+	APIURL     string // merchant API for refund and querydr
 }
 
 // MoMoConfig holds MoMo configuration
@@ -62,10 +110,46 @@ type MoMoConfig struct {
 	AccessKey   string
 	SecretKey   string
 	PaymentURL  string
+	RefundURL   string
+	QueryURL    string
 	IPNUrl      string
 	ReturnURL   string
 }
 
+// ReconciliationConfig holds payment reconciliation configuration
+type ReconciliationConfig struct {
+	IntervalMinutes     int
+	PendingAfterMinutes int // how long a payment may wait for its IPN before we ask the gateway
+	ReportHour          int // hour of day (server time) to build the report for the previous day
+}
+
+// InventoryConfig holds stock reservation configuration
+type InventoryConfig struct {
+	ReservationTTLMinutes        int
+	ReservationSweepIntervalSecs int
+	RestockNotifyIntervalMinutes int
+}
+
+// CartConfig holds shopping cart configuration
+type CartConfig struct {
+	GuestCartTTLDays int // anonymous carts untouched this long are deleted
+}
+
+// CatalogConfig holds product catalog configuration
+type CatalogConfig struct {
+	SalesStatsIntervalMinutes int // how often sold counts and ratings used for sorting are recomputed
+	SearchLogRetentionDays    int // logged searches older than this are deleted
+}
+
+// ShippingConfig holds shipping fee configuration
+type ShippingConfig struct {
+	Calculator            string // flat, zone or weight
+	FlatFee               float64
+	FreeShippingThreshold float64 // 0 disables free shipping
+	WeightTiers           string  // "maxGrams:fee" pairs, e.g. "500:25000,1000:30000"
+	WeightExtraFeePerKg   float64
+}
+
 // EmailConfig holds email service configuration
 type EmailConfig struct {
 	Host     string
@@ -105,9 +189,45 @@ func Load() (*Config, error) {
 			SSLMode:  getEnv("DB_SSLMODE", "disable"),
 		},
 		App: AppConfig{
-			Environment:     getEnv("APP_ENV", "development"),
-			JWTSecret:       getEnv("JWT_SECRET", "your-secret-key-change-in-production"),
-			JWTExpiresHours: getEnvAsInt("JWT_EXPIRES_HOURS", 24),
+			Environment:             getEnv("APP_ENV", "development"),
+			JWTSecret:               getEnv("JWT_SECRET", "your-secret-key-change-in-production"),
+			JWTExpiresMinutes:       getEnvAsInt("JWT_EXPIRES_MINUTES", 15),
+			RefreshTokenExpiresDays: getEnvAsInt("REFRESH_TOKEN_EXPIRES_DAYS", 30),
+		},
+		Auth: AuthConfig{
+			FrontendURL:                     getEnv("FRONTEND_URL", "http://localhost:3000"),
+			EmailVerificationTTLHours:       getEnvAsInt("EMAIL_VERIFICATION_TTL_HOURS", 24),
+			VerificationResendCooldownSecs:  getEnvAsInt("EMAIL_VERIFICATION_RESEND_COOLDOWN_SECONDS", 60),
+			VerificationMaxPerDay:           getEnvAsInt("EMAIL_VERIFICATION_MAX_PER_DAY", 5),
+			RequireVerifiedEmailForCheckout: getEnvAsBool("REQUIRE_VERIFIED_EMAIL_FOR_CHECKOUT", false),
+			ResetCodeTTLMinutes:             getEnvAsInt("RESET_CODE_TTL_MINUTES", 15),
+			ResetCodeMaxAttempts:            getEnvAsInt("RESET_CODE_MAX_ATTEMPTS", 5),
+			ResetCodeMaxPerHour:             getEnvAsInt("RESET_CODE_MAX_PER_HOUR", 3),
+			ResetCodeMaxPerIPPerHour:        getEnvAsInt("RESET_CODE_MAX_PER_IP_PER_HOUR", 10),
+			ResetCodeCleanupIntervalMinutes: getEnvAsInt("RESET_CODE_CLEANUP_INTERVAL_MINUTES", 60),
+			LoginMaxFailures:                getEnvAsInt("LOGIN_MAX_FAILURES", 5),
+			LoginIPMaxFailures:              getEnvAsInt("LOGIN_IP_MAX_FAILURES", 50),
+			LoginFailureWindowMinutes:       getEnvAsInt("LOGIN_FAILURE_WINDOW_MINUTES", 15),
+			LoginLockoutMinutes:             getEnvAsInt("LOGIN_LOCKOUT_MINUTES", 15),
+			LoginDelayAfterFailures:         getEnvAsInt("LOGIN_DELAY_AFTER_FAILURES", 3),
+			LoginMaxDelaySeconds:            getEnvAsInt("LOGIN_MAX_DELAY_SECONDS", 30),
+			TwoFactorIssuer:                 getEnv("TWO_FACTOR_ISSUER", "Fashion E-Commerce"),
+			TwoFactorEncryptionKey:          getEnv("TWO_FACTOR_ENCRYPTION_KEY", ""),
+			TwoFactorChallengeTTLMinutes:    getEnvAsInt("TWO_FACTOR_CHALLENGE_TTL_MINUTES", 5),
+			TwoFactorMaxAttempts:            getEnvAsInt("TWO_FACTOR_MAX_ATTEMPTS", 5),
+			RequireTwoFactorForStaff:        getEnvAsBool("REQUIRE_TWO_FACTOR_FOR_STAFF", true),
+		},
+		OAuth: OAuthConfig{
+			Google: OAuthProviderConfig{
+				ClientID:     getEnv("GOOGLE_CLIENT_ID", ""),
+				ClientSecret: getEnv("GOOGLE_CLIENT_SECRET", ""),
+				RedirectURL:  getEnv("GOOGLE_REDIRECT_URL", "http://localhost:3000/auth/callback/google"),
+			},
+			Facebook: OAuthProviderConfig{
+				ClientID:     getEnv("FACEBOOK_CLIENT_ID", ""),
+				ClientSecret: getEnv("FACEBOOK_CLIENT_SECRET", ""),
+				RedirectURL:  getEnv("FACEBOOK_REDIRECT_URL", "http://localhost:3000/auth/callback/facebook"),
+			},
 		},
 		Payment: PaymentConfig{
 			VNPay: VNPayConfig{
@@ -115,15 +235,42 @@ func Load() (*Config, error) {
 				HashSecret: getEnv("VNPAY_HASH_SECRET", ""),
 				PaymentURL: getEnv("VNPAY_PAYMENT_URL", "https://sandbox.vnpayment.vn/paymentv2/vpcpay.html"),
 				ReturnURL:  getEnv("VNPAY_RETURN_URL", "http://localhost:3000/payment/vnpay/return"),
+				APIURL:     getEnv("VNPAY_API_URL", "https://sandbox.vnpayment.vn/merchant_webapi/api/transaction"),
 			},
 			MoMo: MoMoConfig{
 				PartnerCode: getEnv("MOMO_PARTNER_CODE", ""),
 				AccessKey:   getEnv("MOMO_ACCESS_KEY", ""),
 				SecretKey:   getEnv("MOMO_SECRET_KEY", ""),
 				PaymentURL:  getEnv("MOMO_PAYMENT_URL", "https://test-payment.momo.vn/v2/gateway/api/create"),
+				RefundURL:   getEnv("MOMO_REFUND_URL", "https://test-payment.momo.vn/v2/gateway/api/refund"),
+				QueryURL:    getEnv("MOMO_QUERY_URL", "https://test-payment.momo.vn/v2/gateway/api/query"),
 				IPNUrl:      getEnv("MOMO_IPN_URL", "http://localhost:8080/api/payments/momo/ipn"),
 				ReturnURL:   getEnv("MOMO_RETURN_URL", "http://localhost:3000/payment/momo/return"),
 			},
+			Reconciliation: ReconciliationConfig{
+				IntervalMinutes:     getEnvAsInt("PAYMENT_RECONCILE_INTERVAL_MINUTES", 10),
+				PendingAfterMinutes: getEnvAsInt("PAYMENT_RECONCILE_PENDING_AFTER_MINUTES", 15),
+				ReportHour:          getEnvAsInt("PAYMENT_RECONCILE_REPORT_HOUR", 2),
+			},
+		},
+		Inventory: InventoryConfig{
+			ReservationTTLMinutes:        getEnvAsInt("RESERVATION_TTL_MINUTES", 30),
+			ReservationSweepIntervalSecs: getEnvAsInt("RESERVATION_SWEEP_INTERVAL_SECONDS", 60),
+			RestockNotifyIntervalMinutes: getEnvAsInt("RESTOCK_NOTIFY_INTERVAL_MINUTES", 5),
+		},
+		Cart: CartConfig{
+			GuestCartTTLDays: getEnvAsInt("GUEST_CART_TTL_DAYS", 30),
+		},
+		Catalog: CatalogConfig{
+			SalesStatsIntervalMinutes: getEnvAsInt("PRODUCT_STATS_INTERVAL_MINUTES", 15),
+			SearchLogRetentionDays:    getEnvAsInt("SEARCH_LOG_RETENTION_DAYS", 180),
+		},
+		Shipping: ShippingConfig{
+			Calculator:            getEnv("SHIPPING_CALCULATOR", "flat"),
+			FlatFee:               getEnvAsFloat("SHIPPING_FLAT_FEE", 30000),
+			FreeShippingThreshold: getEnvAsFloat("SHIPPING_FREE_THRESHOLD", 0),
+			WeightTiers:           getEnv("SHIPPING_WEIGHT_TIERS", "500:25000,1000:30000,2000:40000,5000:60000"),
+			WeightExtraFeePerKg:   getEnvAsFloat("SHIPPING_WEIGHT_EXTRA_FEE_PER_KG", 10000),
 		},
 		Email: EmailConfig{
 			Host:     getEnv("SMTP_HOST", "smtp.gmail.com"),
@@ -201,6 +348,32 @@ func getEnvAsInt64(key string, defaultValue int64) int64 {
 	return value
 }
 
+// getEnvAsBool gets an environment variable as a bool or returns a default value
+func getEnvAsBool(key string, defaultValue bool) bool {
+	valueStr := os.Getenv(key)
+	if valueStr == "" {
+		return defaultValue
+	}
+	value, err := strconv.ParseBool(valueStr)
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+// getEnvAsFloat gets an environment variable as a float64 or returns a default value
+func getEnvAsFloat(key string, defaultValue float64) float64 {
+	valueStr := os.Getenv(key)
+	if valueStr == "" {
+		return defaultValue
+	}
+	value, err := strconv.ParseFloat(valueStr, 64)
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
 // getEnvAsSlice gets an environment variable as a slice of strings (comma-separated) or returns a default value
 func getEnvAsSlice(key string, defaultValue []string) []string {
 	valueStr := os.Getenv(key)
@@ -1,9 +1,13 @@
 package database
 
 import (
+	"errors"
 	"log"
 
 	"github.com/huy1235588/fashion-e-commerce/internal/models"
+	"github.com/huy1235588/fashion-e-commerce/internal/repositories"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // RunMigrations runs database auto-migrations for all models
@@ -14,10 +18,43 @@ func RunMigrations() error {
 		return nil
 	}
 
+	// Reset codes used to be stored in plaintext; they are short-lived, so the
+	// old table is simply dropped and recreated
+	if DB.Migrator().HasColumn(&models.PasswordResetCode{}, "code") {
+		if err := DB.Migrator().DropTable(&models.PasswordResetCode{}); err != nil {
+			log.Printf("Migration failed: %v", err)
+			return err
+		}
+	}
+
+	// Accounts created before email verification existed count as verified
+	backfillVerified := !DB.Migrator().HasColumn(&models.User{}, "verified_at")
+
+	// Products from before full-text search need their search documents built
+	backfillSearch := !DB.Migrator().HasColumn(&models.Product{}, "search_vector")
+
+	// Products from before listing sorts need their sold counts and ratings
+	backfillSalesStats := !DB.Migrator().HasColumn(&models.Product{}, "sold_count")
+
+	if err := createSearchConfig(DB); err != nil {
+		log.Printf("Migration failed: %v", err)
+		return err
+	}
+
 	// Auto-migrate all models
 	err := DB.AutoMigrate(
+		&models.Permission{},
+		&models.Role{},
 		&models.User{},
 		&models.PasswordResetCode{},
+		&models.RefreshToken{},
+		&models.EmailVerificationToken{},
+		&models.LoginThrottle{},
+		&models.UserIdentity{},
+		&models.OAuthState{},
+		&models.TwoFactorAuth{},
+		&models.TwoFactorBackupCode{},
+		&models.TwoFactorChallenge{},
 		&models.Category{},
 		&models.Product{},
 		&models.ProductImage{},
@@ -25,10 +62,22 @@ func RunMigrations() error {
 		&models.Cart{},
 		&models.CartItem{},
 		&models.Address{},
+		&models.ShippingZone{},
 		&models.Order{},
 		&models.OrderItem{},
 		&models.Payment{},
+		&models.PaymentCallbackEvent{},
+		&models.Refund{},
+		&models.ReconciliationReport{},
+		&models.ReconciliationItem{},
+		&models.StockReservation{},
+		&models.InventoryMovement{},
+		&models.Promotion{},
+		&models.PromotionUsage{},
 		&models.Review{},
+		&models.Wishlist{},
+		&models.RestockSubscription{},
+		&models.SearchLog{},
 	)
 
 	if err != nil {
@@ -36,6 +85,180 @@ func RunMigrations() error {
 		return err
 	}
 
+	// Payments waiting for the gateway have no transaction ID yet, so the old
+	// unique index on transaction_id rejected every pending payment after the first
+	if DB.Migrator().HasIndex(&models.Payment{}, "idx_payments_transaction_id") {
+		if err := DB.Migrator().DropIndex(&models.Payment{}, "idx_payments_transaction_id"); err != nil {
+			log.Printf("Migration failed: %v", err)
+			return err
+		}
+	}
+
+	// Users can now keep saved lists next to their cart, so user_id is only
+	// unique together with the list name
+	if DB.Migrator().HasIndex(&models.Cart{}, "idx_carts_user_id") {
+		if err := DB.Migrator().DropIndex(&models.Cart{}, "idx_carts_user_id"); err != nil {
+			log.Printf("Migration failed: %v", err)
+			return err
+		}
+	}
+
+	// Deleted promotions are soft-deleted, so promotion codes are now only
+	// unique among promotions that still exist
+	if DB.Migrator().HasIndex(&models.Promotion{}, "idx_promotions_code") {
+		if err := DB.Migrator().DropIndex(&models.Promotion{}, "idx_promotions_code"); err != nil {
+			log.Printf("Migration failed: %v", err)
+			return err
+		}
+	}
+
+	if backfillVerified {
+		if err := DB.Model(&models.User{}).Where("verified_at IS NULL").
+			Update("verified_at", gorm.Expr("created_at")).Error; err != nil {
+			log.Printf("Migration failed: %v", err)
+			return err
+		}
+	}
+
+	if backfillSearch {
+		if err := repositories.NewProductRepository(DB).RefreshSearchVectors(); err != nil {
+			log.Printf("Migration failed: %v", err)
+			return err
+		}
+	}
+
+	if backfillSalesStats {
+		if err := repositories.NewProductRepository(DB).RefreshSalesStats(); err != nil {
+			log.Printf("Migration failed: %v", err)
+			return err
+		}
+	}
+
+	if err := createListingIndexes(DB); err != nil {
+		log.Printf("Migration failed: %v", err)
+		return err
+	}
+
+	if err := createSuggestIndexes(DB); err != nil {
+		log.Printf("Migration failed: %v", err)
+		return err
+	}
+
+	if err := syncRoles(DB); err != nil {
+		log.Printf("Migration failed: %v", err)
+		return err
+	}
+
 	log.Println("Database migrations completed successfully")
 	return nil
 }
+
+// createSearchConfig creates the text search configuration used by product
+// search: the simple configuration, which suits Vietnamese as it has no
+// stemming, with accents removed first so that "ao thun" finds "Áo thun"
+func createSearchConfig(db *gorm.DB) error {
+	if err := db.Exec("CREATE EXTENSION IF NOT EXISTS unaccent").Error; err != nil {
+		return err
+	}
+	return db.Exec(`
+		DO $$
+		BEGIN
+			IF NOT EXISTS (SELECT 1 FROM pg_ts_config WHERE cfgname = '` + repositories.SearchConfig + `') THEN
+				CREATE TEXT SEARCH CONFIGURATION ` + repositories.SearchConfig + ` (COPY = simple);
+				ALTER TEXT SEARCH CONFIGURATION ` + repositories.SearchConfig + `
+					ALTER MAPPING FOR hword, hword_part, word WITH unaccent, simple;
+			END IF;
+		END
+		$$`).Error
+}
+
+// createListingIndexes creates the indexes behind each product listing
+// sort, on the sort key and ID, so cursor pages seek instead of scanning.
+// The price index is on an expression, which model tags cannot declare.
+func createListingIndexes(db *gorm.DB) error {
+	indexes := []string{
+		"CREATE INDEX IF NOT EXISTS idx_products_listing_newest ON products (created_at, id)",
+		"CREATE INDEX IF NOT EXISTS idx_products_listing_price ON products ((LEAST(price, discount_price)), id)",
+		"CREATE INDEX IF NOT EXISTS idx_products_listing_sold ON products (sold_count, id)",
+		"CREATE INDEX IF NOT EXISTS idx_products_listing_rating ON products (rating_average, id)",
+	}
+	for _, index := range indexes {
+		if err := db.Exec(index).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createSuggestIndexes creates the trigram indexes search suggestions match
+// product names, category names and logged queries with. They index the
+// text lowercased without accents, through an immutable unaccent wrapper
+// since unaccent itself cannot be used in an index.
+func createSuggestIndexes(db *gorm.DB) error {
+	statements := []string{
+		"CREATE EXTENSION IF NOT EXISTS pg_trgm",
+		`CREATE OR REPLACE FUNCTION ` + repositories.UnaccentFunction + `(text) RETURNS text
+			LANGUAGE sql IMMUTABLE PARALLEL SAFE STRICT
+			AS $$ SELECT public.unaccent('public.unaccent'::regdictionary, $1) $$`,
+		"CREATE INDEX IF NOT EXISTS idx_products_name_trgm ON products USING gin (" + repositories.UnaccentFunction + "(lower(name)) gin_trgm_ops)",
+		"CREATE INDEX IF NOT EXISTS idx_categories_name_trgm ON categories USING gin (" + repositories.UnaccentFunction + "(lower(name)) gin_trgm_ops)",
+		"CREATE INDEX IF NOT EXISTS idx_search_logs_query_trgm ON search_logs USING gin (" + repositories.UnaccentFunction + "(lower(query)) gin_trgm_ops)",
+	}
+	for _, statement := range statements {
+		if err := db.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// syncRoles creates every known permission and the built-in roles. System
+// roles always get their default permissions back; other built-in roles are
+// only created when missing so admins can change them.
+func syncRoles(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		for code, description := range models.PermissionDescriptions {
+			permission := models.Permission{Code: code, Description: description}
+			if err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "code"}},
+				DoUpdates: clause.AssignmentColumns([]string{"description"}),
+			}).Create(&permission).Error; err != nil {
+				return err
+			}
+		}
+
+		for _, builtIn := range models.BuiltInRoles {
+			var role models.Role
+			err := tx.Where("name = ?", builtIn.Name).First(&role).Error
+			switch {
+			case errors.Is(err, gorm.ErrRecordNotFound):
+				role = models.Role{
+					Name:        builtIn.Name,
+					DisplayName: builtIn.DisplayName,
+					Description: builtIn.Description,
+					IsSystem:    builtIn.IsSystem,
+				}
+				if err := tx.Create(&role).Error; err != nil {
+					return err
+				}
+			case err != nil:
+				return err
+			case !builtIn.IsSystem:
+				continue
+			}
+
+			var permissions []models.Permission
+			if len(builtIn.Permissions) > 0 {
+				if err := tx.Where("code IN ?", builtIn.Permissions).Find(&permissions).Error; err != nil {
+					return err
+				}
+			}
+			if err := tx.Model(&role).Association("Permissions").Replace(permissions); err != nil {
+				return err
+			}
+		}
+
+		// Older versions gave regular accounts the "user" role
+		return tx.Model(&models.User{}).Where("role = ?", "user").Update("role", models.RoleCustomer).Error
+	})
+}
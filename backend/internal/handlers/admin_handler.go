@@ -5,6 +5,7 @@ import (
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/huy1235588/fashion-e-commerce/internal/middleware"
 	"github.com/huy1235588/fashion-e-commerce/internal/services"
 )
 
@@ -72,7 +73,7 @@ func (h *AdminHandler) UpdateUserRole(c *gin.Context) {
 	}
 
 	var req struct {
-		Role string `json:"role" binding:"required,oneof=user admin"`
+		Role string `json:"role" binding:"required"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -80,14 +81,49 @@ func (h *AdminHandler) UpdateUserRole(c *gin.Context) {
 		return
 	}
 
+	if adminID, _ := middleware.GetUserID(c); adminID == uint(userID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "you cannot change your own role"})
+		return
+	}
+
 	if err := h.adminService.UpdateUserRole(uint(userID), req.Role); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update user role"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "User role updated successfully"})
 }
 
+// UpdateUserStatus handles PUT /api/admin/users/:id/status
+func (h *AdminHandler) UpdateUserStatus(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	var req struct {
+		IsActive *bool `json:"is_active" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if adminID, _ := middleware.GetUserID(c); adminID == uint(userID) && !*req.IsActive {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "you cannot deactivate your own account"})
+		return
+	}
+
+	if err := h.adminService.UpdateUserStatus(uint(userID), *req.IsActive); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User status updated successfully"})
+}
+
 // ListAllOrders handles GET /api/admin/orders
 func (h *AdminHandler) ListAllOrders(c *gin.Context) {
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
@@ -136,7 +172,7 @@ func (h *AdminHandler) UpdateOrderStatus(c *gin.Context) {
 	}
 
 	if err := h.adminService.UpdateOrderStatus(uint(orderID), req.Status); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update order status"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
@@ -1,9 +1,13 @@
 package handlers
 
 import (
+	"errors"
+	"log"
 	"net/http"
+	"strconv"
 
 	"github.com/huy1235588/fashion-e-commerce/internal/middleware"
+	"github.com/huy1235588/fashion-e-commerce/internal/models"
 	"github.com/huy1235588/fashion-e-commerce/internal/services"
 	"github.com/huy1235588/fashion-e-commerce/internal/utils"
 
@@ -13,13 +17,15 @@ import (
 // AuthHandler handles authentication endpoints
 type AuthHandler struct {
 	authService services.AuthService
+	cartService *services.CartService
 	validator   *utils.Validator
 }
 
 // NewAuthHandler creates a new auth handler
-func NewAuthHandler(authService services.AuthService) *AuthHandler {
+func NewAuthHandler(authService services.AuthService, cartService *services.CartService) *AuthHandler {
 	return &AuthHandler{
 		authService: authService,
+		cartService: cartService,
 		validator:   utils.NewValidator(),
 	}
 }
@@ -38,6 +44,11 @@ type LoginRequest struct {
 	Password string `json:"password" binding:"required"`
 }
 
+// RefreshTokenRequest represents refresh token request body
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
 // UpdateProfileRequest represents update profile request body
 type UpdateProfileRequest struct {
 	FullName string `json:"full_name" binding:"required"`
@@ -49,9 +60,26 @@ type SendResetCodeRequest struct {
 	Email string `json:"email" binding:"required,email"`
 }
 
+// UnlockAccountRequest represents unlock account request body
+type UnlockAccountRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// VerifyEmailRequest represents verify email request body
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// VerifyTwoFactorRequest represents the second login step request body
+type VerifyTwoFactorRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code" binding:"required"`
+}
+
 // VerifyResetCodeRequest represents verify reset code request
 type VerifyResetCodeRequest struct {
-	Code        string `json:"code" binding:"required"`
+	Email       string `json:"email" binding:"required,email"`
+	Code        string `json:"code" binding:"required,len=6,numeric"`
 	NewPassword string `json:"new_password" binding:"required,min=8"`
 }
 
@@ -94,8 +122,8 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
-	// Auto-login: generate token for newly registered user
-	_, token, err := h.authService.Login(req.Email, req.Password)
+	// Auto-login: generate tokens for newly registered user
+	_, tokens, err := h.authService.Login(req.Email, req.Password, clientInfo(c))
 	if err != nil {
 		// Registration succeeded but auto-login failed
 		c.JSON(http.StatusCreated, gin.H{
@@ -105,11 +133,10 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{
-		"message": "User registered successfully",
-		"token":   token,
-		"user":    user.ToResponse(),
-	})
+	// Carry over the cart the visitor filled before signing up
+	response := loginResponse(c, h.cartService, user, tokens)
+	response["message"] = "User registered successfully"
+	c.JSON(http.StatusCreated, response)
 }
 
 // Login handles user login
@@ -123,8 +150,16 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	user, token, err := h.authService.Login(req.Email, req.Password)
+	user, tokens, err := h.authService.Login(req.Email, req.Password, clientInfo(c))
 	if err != nil {
+		if respondTwoFactorRequired(c, err) {
+			return
+		}
+
+		if respondLoginThrottled(c, err) {
+			return
+		}
+
 		status := http.StatusUnauthorized
 		if err.Error() == "account is deactivated" {
 			status = http.StatusForbidden
@@ -135,10 +170,129 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
+	c.JSON(http.StatusOK, loginResponse(c, h.cartService, user, tokens))
+}
+
+// respondLoginThrottled answers 429 with Retry-After if err is a
+// *services.LoginThrottledError
+func respondLoginThrottled(c *gin.Context, err error) bool {
+	var throttled *services.LoginThrottledError
+	if !errors.As(err, &throttled) {
+		return false
+	}
+
+	retryAfter := int(throttled.RetryAfter.Seconds()) + 1
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error":       err.Error(),
+		"locked":      throttled.Locked,
+		"retry_after": retryAfter,
+	})
+	return true
+}
+
+// VerifyTwoFactor handles the second login step for users with two-factor
+// authentication, using the challenge token returned by login
+// POST /api/auth/2fa/verify
+func (h *AuthHandler) VerifyTwoFactor(c *gin.Context) {
+	var req VerifyTwoFactorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	user, tokens, err := h.authService.VerifyTwoFactor(req.ChallengeToken, req.Code, clientInfo(c))
+	if err != nil {
+		if respondLoginThrottled(c, err) {
+			return
+		}
+
+		status := http.StatusUnauthorized
+		if err.Error() == "account is deactivated" {
+			status = http.StatusForbidden
+		}
+		c.JSON(status, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, loginResponse(c, h.cartService, user, tokens))
+}
+
+// Refresh handles exchanging a refresh token for a new token pair
+// POST /api/auth/refresh
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	var req RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	tokens, err := h.authService.Refresh(req.RefreshToken, clientInfo(c))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Login successful",
-		"token":   token,
-		"user":    user.ToResponse(),
+		"message":            "Token refreshed",
+		"token":              tokens.AccessToken,
+		"refresh_token":      tokens.RefreshToken,
+		"expires_in":         tokens.ExpiresIn,
+		"refresh_expires_at": tokens.RefreshExpiresAt,
+	})
+}
+
+// Logout handles ending the current session
+// POST /api/auth/logout
+func (h *AuthHandler) Logout(c *gin.Context) {
+	sessionID, err := middleware.GetSessionID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Authentication required",
+		})
+		return
+	}
+
+	if err := h.authService.Logout(sessionID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to log out",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Logged out successfully",
+	})
+}
+
+// LogoutAll handles ending every session of the current user
+// POST /api/auth/logout-all
+func (h *AuthHandler) LogoutAll(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Authentication required",
+		})
+		return
+	}
+
+	if err := h.authService.LogoutAll(userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to log out",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Logged out of all sessions",
 	})
 }
 
@@ -226,7 +380,13 @@ func (h *AuthHandler) SendResetCode(c *gin.Context) {
 		return
 	}
 
-	if err := h.authService.SendResetCode(req.Email); err != nil {
+	if err := h.authService.SendResetCode(req.Email, c.ClientIP()); err != nil {
+		if errors.Is(err, services.ErrResetRateLimited) {
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to send reset code",
 		})
@@ -254,17 +414,82 @@ func (h *AuthHandler) ResetPassword(c *gin.Context) {
 		return
 	}
 
-	// Verify code and get user ID
-	userID, err := h.authService.VerifyResetCode(req.Code)
+	if err := h.authService.ResetPassword(req.Email, req.Code, req.NewPassword); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Password reset successfully",
+	})
+}
+
+// VerifyEmail handles confirming an email address with the emailed token
+// POST /api/auth/verify-email
+func (h *AuthHandler) VerifyEmail(c *gin.Context) {
+	var req VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	user, err := h.authService.VerifyEmail(req.Token)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Email verified successfully",
+		"user":    user.ToResponse(),
+	})
+}
+
+// ResendVerification handles sending a new verification email
+// POST /api/auth/resend-verification
+func (h *AuthHandler) ResendVerification(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
 	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Authentication required",
+		})
+		return
+	}
+
+	if err := h.authService.ResendVerification(userID); err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, services.ErrVerificationRateLimited) {
+			status = http.StatusTooManyRequests
+		}
+		c.JSON(status, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Verification email sent",
+	})
+}
+
+// UnlockAccount handles lifting a login lockout with the emailed token
+// POST /api/auth/unlock-account
+func (h *AuthHandler) UnlockAccount(c *gin.Context) {
+	var req UnlockAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": err.Error(),
 		})
 		return
 	}
 
-	// Reset password
-	if err := h.authService.ResetPassword(userID, req.NewPassword); err != nil {
+	if err := h.authService.UnlockAccount(req.Token); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": err.Error(),
 		})
@@ -272,6 +497,58 @@ func (h *AuthHandler) ResetPassword(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Password reset successfully",
+		"message": "Account unlocked successfully",
+	})
+}
+
+// respondTwoFactorRequired answers a login that passed the first step but
+// needs a second factor, and reports whether err was such a login
+func respondTwoFactorRequired(c *gin.Context, err error) bool {
+	var required *services.TwoFactorRequiredError
+	if !errors.As(err, &required) {
+		return false
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":             "Two-factor authentication required",
+		"two_factor_required": true,
+		"challenge_token":     required.ChallengeToken,
+		"expires_in":          int(required.ExpiresIn.Seconds()),
 	})
+	return true
+}
+
+// loginResponse builds the body of a successful login. A request carrying
+// the token of an anonymous cart gets that cart merged into the user's
+// cart, and the merged cart and any adjusted lines are included.
+func loginResponse(c *gin.Context, cartService *services.CartService, user *models.User, tokens *services.TokenPair) gin.H {
+	response := gin.H{
+		"message":            "Login successful",
+		"token":              tokens.AccessToken,
+		"refresh_token":      tokens.RefreshToken,
+		"expires_in":         tokens.ExpiresIn,
+		"refresh_expires_at": tokens.RefreshExpiresAt,
+		"user":               user.ToResponse(),
+	}
+
+	if token := c.GetHeader(cartTokenHeader); token != "" {
+		cart, adjustments, err := cartService.MergeGuestCart(user.ID, token)
+		switch {
+		case err == nil:
+			response["cart"] = cart.ToResponse()
+			response["cart_adjustments"] = adjustments
+		case !errors.Is(err, utils.ErrInvalidCartToken) && !errors.Is(err, services.ErrGuestCartNotFound):
+			// The login itself succeeded; the guest cart is still there to retry
+			log.Printf("Failed to merge guest cart for user %d: %v", user.ID, err)
+		}
+	}
+	return response
+}
+
+// clientInfo describes the client making the request, for session records
+func clientInfo(c *gin.Context) services.ClientInfo {
+	return services.ClientInfo{
+		UserAgent: c.Request.UserAgent(),
+		IPAddress: c.ClientIP(),
+	}
 }
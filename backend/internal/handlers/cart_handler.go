@@ -1,11 +1,13 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/huy1235588/fashion-e-commerce/internal/middleware"
+	"github.com/huy1235588/fashion-e-commerce/internal/models"
 	"github.com/huy1235588/fashion-e-commerce/internal/services"
 )
 
@@ -168,3 +170,421 @@ func (h *CartHandler) ClearCart(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"data": cart.ToResponse()})
 }
+
+// ValidateCart handles checking the cart against current stock and prices
+// before checkout; changed lines are updated and reported
+// @Summary Validate cart before checkout
+// @Tags cart
+// @Produce json
+// @Success 200 {object} models.CartResponse
+// @Router /cart/validate [post]
+func (h *CartHandler) ValidateCart(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	cart, adjustments, err := h.service.ValidateCart(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to validate cart"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":        cart.ToResponse(),
+		"adjustments": adjustments,
+		"changed":     len(adjustments) > 0,
+	})
+}
+
+// SavedCartRequest represents the request body for creating or renaming a saved list
+type SavedCartRequest struct {
+	Name string `json:"name" binding:"required,max=100"`
+}
+
+// MoveCartItemRequest represents the request body for moving an item to another cart
+type MoveCartItemRequest struct {
+	CartID uint `json:"cart_id" binding:"required"`
+}
+
+// ListCarts handles listing the user's primary cart and saved lists
+// @Summary List user's carts
+// @Tags cart
+// @Produce json
+// @Success 200 {array} models.CartResponse
+// @Router /carts [get]
+func (h *CartHandler) ListCarts(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	carts, err := h.service.ListCarts(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to retrieve carts"})
+		return
+	}
+
+	responses := make([]models.CartResponse, len(carts))
+	for i := range carts {
+		responses[i] = carts[i].ToResponse()
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": responses})
+}
+
+// CreateSavedCart handles creating a named saved list
+// @Summary Create saved list
+// @Tags cart
+// @Accept json
+// @Produce json
+// @Param cart body SavedCartRequest true "List name"
+// @Success 201 {object} models.CartResponse
+// @Router /carts [post]
+func (h *CartHandler) CreateSavedCart(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req SavedCartRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	cart, err := h.service.CreateSavedCart(userID, req.Name)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": cart.ToResponse()})
+}
+
+// GetCartByID handles retrieving one of the user's carts
+// @Summary Get a cart or saved list
+// @Tags cart
+// @Produce json
+// @Param id path int true "Cart ID"
+// @Success 200 {object} models.CartResponse
+// @Router /carts/{id} [get]
+func (h *CartHandler) GetCartByID(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	cartID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cart ID"})
+		return
+	}
+
+	cart, err := h.service.GetUserCart(userID, uint(cartID))
+	if err != nil {
+		respondCartError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": cart.ToResponse()})
+}
+
+// RenameCart handles renaming a saved list
+// @Summary Rename saved list
+// @Tags cart
+// @Accept json
+// @Produce json
+// @Param id path int true "Cart ID"
+// @Param cart body SavedCartRequest true "List name"
+// @Success 200 {object} models.CartResponse
+// @Router /carts/{id} [put]
+func (h *CartHandler) RenameCart(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	cartID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cart ID"})
+		return
+	}
+
+	var req SavedCartRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	cart, err := h.service.RenameCart(userID, uint(cartID), req.Name)
+	if err != nil {
+		respondCartError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": cart.ToResponse()})
+}
+
+// DeleteCart handles deleting a saved list with its items
+// @Summary Delete saved list
+// @Tags cart
+// @Param id path int true "Cart ID"
+// @Success 200 {object} map[string]interface{}
+// @Router /carts/{id} [delete]
+func (h *CartHandler) DeleteCart(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	cartID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cart ID"})
+		return
+	}
+
+	if err := h.service.DeleteCart(userID, uint(cartID)); err != nil {
+		respondCartError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "cart deleted"})
+}
+
+// AddToCartByID handles adding an item to one of the user's carts
+// @Summary Add item to a cart or saved list
+// @Tags cart
+// @Accept json
+// @Produce json
+// @Param id path int true "Cart ID"
+// @Param item body AddToCartRequest true "Item data"
+// @Success 200 {object} models.CartResponse
+// @Router /carts/{id}/items [post]
+func (h *CartHandler) AddToCartByID(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	cartID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cart ID"})
+		return
+	}
+
+	var req AddToCartRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	cart, err := h.service.AddToUserCart(userID, uint(cartID), req.ProductID, req.VariantID, req.Quantity)
+	if err != nil {
+		respondCartError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": cart.ToResponse()})
+}
+
+// UpdateCartItemByID handles updating the quantity of an item in one of the user's carts
+// @Summary Update item quantity in a cart or saved list
+// @Tags cart
+// @Accept json
+// @Produce json
+// @Param id path int true "Cart ID"
+// @Param item_id path int true "Cart Item ID"
+// @Param item body UpdateCartItemRequest true "Update data"
+// @Success 200 {object} models.CartResponse
+// @Router /carts/{id}/items/{item_id} [put]
+func (h *CartHandler) UpdateCartItemByID(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	cartID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cart ID"})
+		return
+	}
+
+	itemID, err := strconv.ParseUint(c.Param("item_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid item ID"})
+		return
+	}
+
+	var req UpdateCartItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	cart, err := h.service.UpdateUserCartItem(userID, uint(cartID), uint(itemID), req.Quantity)
+	if err != nil {
+		respondCartError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": cart.ToResponse()})
+}
+
+// RemoveCartItemByID handles removing an item from one of the user's carts
+// @Summary Remove item from a cart or saved list
+// @Tags cart
+// @Param id path int true "Cart ID"
+// @Param item_id path int true "Cart Item ID"
+// @Success 200 {object} models.CartResponse
+// @Router /carts/{id}/items/{item_id} [delete]
+func (h *CartHandler) RemoveCartItemByID(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	cartID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cart ID"})
+		return
+	}
+
+	itemID, err := strconv.ParseUint(c.Param("item_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid item ID"})
+		return
+	}
+
+	cart, err := h.service.RemoveUserCartItem(userID, uint(cartID), uint(itemID))
+	if err != nil {
+		respondCartError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": cart.ToResponse()})
+}
+
+// ValidateCartByID handles checking one of the user's carts against current
+// stock and prices before checkout
+// @Summary Validate a cart or saved list before checkout
+// @Tags cart
+// @Produce json
+// @Param id path int true "Cart ID"
+// @Success 200 {object} models.CartResponse
+// @Router /carts/{id}/validate [post]
+func (h *CartHandler) ValidateCartByID(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	cartID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cart ID"})
+		return
+	}
+
+	cart, adjustments, err := h.service.ValidateUserCart(userID, uint(cartID))
+	if err != nil {
+		respondCartError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":        cart.ToResponse(),
+		"adjustments": adjustments,
+		"changed":     len(adjustments) > 0,
+	})
+}
+
+// MoveCartItem handles moving an item from any of the user's carts to another
+// @Summary Move item to another cart or saved list
+// @Tags cart
+// @Accept json
+// @Produce json
+// @Param id path int true "Cart Item ID"
+// @Param target body MoveCartItemRequest true "Target cart"
+// @Success 200 {object} map[string]interface{}
+// @Router /cart/items/{id}/move [post]
+func (h *CartHandler) MoveCartItem(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	itemID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid item ID"})
+		return
+	}
+
+	var req MoveCartItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	from, to, err := h.service.MoveItem(userID, uint(itemID), req.CartID)
+	if err != nil {
+		respondCartError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{
+		"from": from.ToResponse(),
+		"to":   to.ToResponse(),
+	}})
+}
+
+// SaveForLater handles moving an item to the user's "Saved for later" list
+// @Summary Save cart item for later
+// @Tags cart
+// @Produce json
+// @Param id path int true "Cart Item ID"
+// @Success 200 {object} map[string]interface{}
+// @Router /cart/items/{id}/save-for-later [post]
+func (h *CartHandler) SaveForLater(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	itemID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid item ID"})
+		return
+	}
+
+	from, to, err := h.service.SaveForLater(userID, uint(itemID))
+	if err != nil {
+		respondCartError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{
+		"from": from.ToResponse(),
+		"to":   to.ToResponse(),
+	}})
+}
+
+// respondCartError answers a failed request on one of the user's carts
+func respondCartError(c *gin.Context, err error) {
+	if errors.Is(err, services.ErrCartNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+}
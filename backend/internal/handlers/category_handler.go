@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -115,11 +116,14 @@ func (h *CategoryHandler) UpdateCategory(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"data": category.ToResponse()})
 }
 
-// DeleteCategory handles category deletion (admin only)
+// DeleteCategory handles category deletion (admin only). A category with
+// subcategories or products needs reassign_to, the category to move them to.
 // @Summary Delete a category
 // @Tags categories
 // @Param id path int true "Category ID"
+// @Param reassign_to query int false "Category to move subcategories and products to"
 // @Success 204
+// @Failure 409 {object} map[string]interface{}
 // @Router /admin/categories/{id} [delete]
 func (h *CategoryHandler) DeleteCategory(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
@@ -128,7 +132,22 @@ func (h *CategoryHandler) DeleteCategory(c *gin.Context) {
 		return
 	}
 
-	if err := h.service.DeleteCategory(uint(id)); err != nil {
+	var reassignTo *uint
+	if value := c.Query("reassign_to"); value != "" {
+		targetID, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid reassign_to category ID"})
+			return
+		}
+		target := uint(targetID)
+		reassignTo = &target
+	}
+
+	if err := h.service.DeleteCategory(uint(id), reassignTo); err != nil {
+		if errors.Is(err, services.ErrCategoryInUse) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -156,3 +175,20 @@ func (h *CategoryHandler) ListCategories(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"data": responses})
 }
+
+// GetCategoryTree handles retrieving all categories nested under their
+// parents, siblings in their set order
+// @Summary Get the category tree
+// @Tags categories
+// @Produce json
+// @Success 200 {array} models.CategoryTreeResponse
+// @Router /categories/tree [get]
+func (h *CategoryHandler) GetCategoryTree(c *gin.Context) {
+	tree, err := h.service.GetCategoryTree()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to retrieve categories"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": tree})
+}
@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/huy1235588/fashion-e-commerce/internal/services"
+	"github.com/huy1235588/fashion-e-commerce/internal/utils"
+)
+
+// cartTokenHeader carries the token of an anonymous cart
+const cartTokenHeader = "X-Cart-Token"
+
+// GuestHandler handles shopping and checkout for visitors without an account
+type GuestHandler struct {
+	cartService    *services.CartService
+	orderService   services.OrderService
+	paymentService services.PaymentService
+}
+
+// NewGuestHandler creates a new guest handler
+func NewGuestHandler(cartService *services.CartService, orderService services.OrderService, paymentService services.PaymentService) *GuestHandler {
+	return &GuestHandler{
+		cartService:    cartService,
+		orderService:   orderService,
+		paymentService: paymentService,
+	}
+}
+
+// GuestOrderRequest identifies a guest order
+type GuestOrderRequest struct {
+	OrderCode string `json:"order_code" binding:"required"`
+	Email     string `json:"email" binding:"required,email"`
+}
+
+// CreateCart handles starting an anonymous cart; the returned cart_token
+// must be sent in the X-Cart-Token header of the other guest requests
+// POST /api/v1/guest/cart
+func (h *GuestHandler) CreateCart(c *gin.Context) {
+	cart, token, err := h.cartService.CreateGuestCart()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create cart"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"data":       cart.ToResponse(),
+		"cart_token": token,
+	})
+}
+
+// GetCart handles retrieving an anonymous cart
+// GET /api/v1/guest/cart
+func (h *GuestHandler) GetCart(c *gin.Context) {
+	cart, err := h.cartService.GetGuestCart(c.GetHeader(cartTokenHeader))
+	if err != nil {
+		respondGuestCartError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": cart.ToResponse()})
+}
+
+// AddToCart handles adding an item to an anonymous cart
+// POST /api/v1/guest/cart/items
+func (h *GuestHandler) AddToCart(c *gin.Context) {
+	var req AddToCartRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	cart, err := h.cartService.AddToGuestCart(c.GetHeader(cartTokenHeader), req.ProductID, req.VariantID, req.Quantity)
+	if err != nil {
+		respondGuestCartError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": cart.ToResponse()})
+}
+
+// UpdateCartItem handles changing the quantity of an item in an anonymous cart
+// PUT /api/v1/guest/cart/items/:id
+func (h *GuestHandler) UpdateCartItem(c *gin.Context) {
+	itemID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid item ID"})
+		return
+	}
+
+	var req UpdateCartItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	cart, err := h.cartService.UpdateGuestCartItem(c.GetHeader(cartTokenHeader), uint(itemID), req.Quantity)
+	if err != nil {
+		respondGuestCartError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": cart.ToResponse()})
+}
+
+// RemoveCartItem handles removing an item from an anonymous cart
+// DELETE /api/v1/guest/cart/items/:id
+func (h *GuestHandler) RemoveCartItem(c *gin.Context) {
+	itemID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid item ID"})
+		return
+	}
+
+	cart, err := h.cartService.RemoveGuestCartItem(c.GetHeader(cartTokenHeader), uint(itemID))
+	if err != nil {
+		respondGuestCartError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": cart.ToResponse()})
+}
+
+// ClearCart handles removing all items from an anonymous cart
+// POST /api/v1/guest/cart/clear
+func (h *GuestHandler) ClearCart(c *gin.Context) {
+	cart, err := h.cartService.ClearGuestCart(c.GetHeader(cartTokenHeader))
+	if err != nil {
+		respondGuestCartError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": cart.ToResponse()})
+}
+
+// ValidateCart handles checking an anonymous cart against current stock and
+// prices before checkout
+// POST /api/v1/guest/cart/validate
+func (h *GuestHandler) ValidateCart(c *gin.Context) {
+	cart, adjustments, err := h.cartService.ValidateGuestCart(c.GetHeader(cartTokenHeader))
+	if err != nil {
+		respondGuestCartError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":        cart.ToResponse(),
+		"adjustments": adjustments,
+		"changed":     len(adjustments) > 0,
+	})
+}
+
+// Checkout handles placing an order from an anonymous cart
+// POST /api/v1/guest/checkout
+func (h *GuestHandler) Checkout(c *gin.Context) {
+	var req services.GuestCheckoutRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	cart, err := h.cartService.GetGuestCart(c.GetHeader(cartTokenHeader))
+	if err != nil {
+		respondGuestCartError(c, err)
+		return
+	}
+
+	order, err := h.orderService.CreateGuestOrder(cart.ID, req)
+	if err != nil {
+		if respondCartChanged(c, err) {
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"message": "Order created successfully",
+		"data":    order.ToResponse(),
+	})
+}
+
+// LookupOrder handles finding a guest order by its code and checkout email
+// POST /api/v1/guest/orders/lookup
+func (h *GuestHandler) LookupOrder(c *gin.Context) {
+	var req GuestOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	order, err := h.orderService.LookupGuestOrder(req.OrderCode, req.Email)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    order.ToResponse(),
+	})
+}
+
+// InitiatePayment handles starting the online payment of a guest order
+// POST /api/v1/guest/orders/pay
+func (h *GuestHandler) InitiatePayment(c *gin.Context) {
+	var req GuestOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := h.paymentService.InitiateGuestPayment(req.OrderCode, req.Email, c.ClientIP())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if result.PaymentURL == "" {
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "COD payment confirmed",
+		})
+		return
+	}
+
+	response := gin.H{
+		"success":     true,
+		"payment_url": result.PaymentURL,
+	}
+	if result.Deeplink != "" {
+		response["deeplink"] = result.Deeplink
+	}
+	if result.QRCodeURL != "" {
+		response["qr_code_url"] = result.QRCodeURL
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// respondGuestCartError answers a failed anonymous cart request, telling
+// clients with a bad or stale token to start a new cart
+func respondGuestCartError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, utils.ErrInvalidCartToken):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrGuestCartNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/huy1235588/fashion-e-commerce/internal/middleware"
+	"github.com/huy1235588/fashion-e-commerce/internal/models"
+	"github.com/huy1235588/fashion-e-commerce/internal/services"
+)
+
+// InventoryHandler handles inventory ledger HTTP requests
+type InventoryHandler struct {
+	inventoryService services.InventoryService
+}
+
+// NewInventoryHandler creates a new InventoryHandler
+func NewInventoryHandler(inventoryService services.InventoryService) *InventoryHandler {
+	return &InventoryHandler{inventoryService: inventoryService}
+}
+
+// GetMovements handles GET /api/v1/admin/inventory/variants/:variant_id/movements
+func (h *InventoryHandler) GetMovements(c *gin.Context) {
+	variantID, err := strconv.ParseUint(c.Param("variant_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid variant ID"})
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	movements, total, err := h.inventoryService.GetMovements(uint(variantID), limit, (page-1)*limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch inventory movements"})
+		return
+	}
+
+	responses := make([]models.InventoryMovementResponse, len(movements))
+	for i := range movements {
+		responses[i] = movements[i].ToResponse()
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": responses,
+		"pagination": gin.H{
+			"page":        page,
+			"limit":       limit,
+			"total":       total,
+			"total_pages": (total + int64(limit) - 1) / int64(limit),
+		},
+	})
+}
+
+// AdjustStock handles POST /api/v1/admin/inventory/variants/:variant_id/movements
+func (h *InventoryHandler) AdjustStock(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	variantID, err := strconv.ParseUint(c.Param("variant_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid variant ID"})
+		return
+	}
+
+	var req services.AdjustStockRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	movement, err := h.inventoryService.AdjustStock(uint(variantID), userID, req)
+	if err != nil {
+		if errors.Is(err, services.ErrInsufficientStock) || errors.Is(err, services.ErrStockBelowReserved) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Stock adjusted successfully",
+		"data":    movement.ToResponse(),
+	})
+}
+
+// Reconcile handles GET /api/v1/admin/inventory/reconcile
+func (h *InventoryHandler) Reconcile(c *gin.Context) {
+	var variantID *uint
+	if v := c.Query("variant_id"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid variant ID"})
+			return
+		}
+		uid := uint(id)
+		variantID = &uid
+	}
+
+	mismatchedOnly := c.DefaultQuery("mismatched_only", "true") == "true"
+
+	rows, err := h.inventoryService.Reconcile(variantID, mismatchedOnly)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to reconcile inventory"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": rows})
+}
+
+// RecordReconciliation handles POST /api/v1/admin/inventory/reconcile/:variant_id
+func (h *InventoryHandler) RecordReconciliation(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	variantID, err := strconv.ParseUint(c.Param("variant_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid variant ID"})
+		return
+	}
+
+	var req struct {
+		Note string `json:"note"`
+	}
+	// Body is optional
+	_ = c.ShouldBindJSON(&req)
+
+	movement, err := h.inventoryService.RecordReconciliation(uint(variantID), userID, req.Note)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Ledger reconciled successfully",
+		"data":    movement.ToResponse(),
+	})
+}
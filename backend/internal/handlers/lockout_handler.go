@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/huy1235588/fashion-e-commerce/internal/models"
+	"github.com/huy1235588/fashion-e-commerce/internal/services"
+)
+
+// LockoutHandler handles login lockout HTTP requests
+type LockoutHandler struct {
+	loginThrottleService services.LoginThrottleService
+}
+
+// NewLockoutHandler creates a new LockoutHandler
+func NewLockoutHandler(loginThrottleService services.LoginThrottleService) *LockoutHandler {
+	return &LockoutHandler{loginThrottleService: loginThrottleService}
+}
+
+// ListLockouts handles GET /api/v1/admin/lockouts
+func (h *LockoutHandler) ListLockouts(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	lockedOnly := c.DefaultQuery("locked", "true") == "true"
+	scope := c.Query("scope")
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+	if scope != "" && scope != string(models.LoginThrottleScopeAccount) && scope != string(models.LoginThrottleScopeIP) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "scope must be account or ip"})
+		return
+	}
+
+	throttles, total, err := h.loginThrottleService.ListLockouts(lockedOnly, scope, limit, (page-1)*limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch lockouts"})
+		return
+	}
+
+	responses := make([]models.LoginThrottleResponse, len(throttles))
+	for i := range throttles {
+		responses[i] = throttles[i].ToResponse()
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": responses,
+		"pagination": gin.H{
+			"page":        page,
+			"limit":       limit,
+			"total":       total,
+			"total_pages": (total + int64(limit) - 1) / int64(limit),
+		},
+	})
+}
+
+// ClearLockout handles DELETE /api/v1/admin/lockouts/:id
+func (h *LockoutHandler) ClearLockout(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid lockout ID"})
+		return
+	}
+
+	if err := h.loginThrottleService.ClearLockout(uint(id)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Lockout cleared successfully"})
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/huy1235588/fashion-e-commerce/internal/services"
+)
+
+// OAuthHandler handles social login endpoints
+type OAuthHandler struct {
+	oauthService services.OAuthService
+	cartService  *services.CartService
+}
+
+// NewOAuthHandler creates a new OAuth handler
+func NewOAuthHandler(oauthService services.OAuthService, cartService *services.CartService) *OAuthHandler {
+	return &OAuthHandler{oauthService: oauthService, cartService: cartService}
+}
+
+// OAuthCallbackRequest carries the parameters the provider redirected back with
+type OAuthCallbackRequest struct {
+	Code  string `json:"code" binding:"required"`
+	State string `json:"state" binding:"required"`
+}
+
+// ListProviders handles listing the enabled social login providers
+// GET /api/auth/oauth/providers
+func (h *OAuthHandler) ListProviders(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"data": h.oauthService.Providers(),
+	})
+}
+
+// Authorize handles starting a social login; the client sends the user to
+// the returned URL
+// GET /api/auth/oauth/:provider/authorize
+func (h *OAuthHandler) Authorize(c *gin.Context) {
+	authURL, err := h.oauthService.StartLogin(c.Param("provider"))
+	if err != nil {
+		if errors.Is(err, services.ErrUnknownProvider) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to start login",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{"authorization_url": authURL},
+	})
+}
+
+// Callback handles finishing a social login with the code and state the
+// provider redirected back with
+// POST /api/auth/oauth/:provider/callback
+func (h *OAuthHandler) Callback(c *gin.Context) {
+	var req OAuthCallbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	user, tokens, err := h.oauthService.CompleteLogin(c.Request.Context(), c.Param("provider"), req.Code, req.State, clientInfo(c))
+	if err != nil {
+		if respondTwoFactorRequired(c, err) {
+			return
+		}
+
+		status := http.StatusUnauthorized
+		switch {
+		case errors.Is(err, services.ErrUnknownProvider):
+			status = http.StatusNotFound
+		case errors.Is(err, services.ErrProviderEmailNotVerified):
+			status = http.StatusBadRequest
+		case err.Error() == "account is deactivated":
+			status = http.StatusForbidden
+		}
+		c.JSON(status, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, loginResponse(c, h.cartService, user, tokens))
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -29,6 +30,33 @@ func NewOrderHandler(orderService services.OrderService) *OrderHandler {
 // @Success 201 {object} map[string]interface{}
 // @Router /orders [post]
 func (h *OrderHandler) CreateOrder(c *gin.Context) {
+	h.createOrder(c, nil)
+}
+
+// CheckoutCart creates a new order from one of the user's saved lists
+// @Summary Check out a saved cart
+// @Description Create a new order from a specific cart of the user
+// @Tags orders
+// @Accept json
+// @Produce json
+// @Param id path int true "Cart ID"
+// @Param request body services.CreateOrderRequest true "Create Order Request"
+// @Success 201 {object} map[string]interface{}
+// @Router /carts/{id}/checkout [post]
+func (h *OrderHandler) CheckoutCart(c *gin.Context) {
+	cartID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cart ID"})
+		return
+	}
+
+	id := uint(cartID)
+	h.createOrder(c, &id)
+}
+
+// createOrder places an order from the given cart, or from the primary
+// cart when cartID is nil
+func (h *OrderHandler) createOrder(c *gin.Context, cartID *uint) {
 	userID, exists := c.Get("user_id")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
@@ -41,9 +69,16 @@ func (h *OrderHandler) CreateOrder(c *gin.Context) {
 		return
 	}
 
-	order, err := h.orderService.CreateFromCart(userID.(uint), req)
+	order, err := h.orderService.CreateFromCart(userID.(uint), cartID, req)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		if respondCartChanged(c, err) {
+			return
+		}
+		status := http.StatusBadRequest
+		if errors.Is(err, services.ErrEmailNotVerified) {
+			status = http.StatusForbidden
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
 		return
 	}
 
@@ -181,6 +216,51 @@ func (h *OrderHandler) CancelOrder(c *gin.Context) {
 	})
 }
 
+// ClaimGuestOrders links orders placed as a guest with the user's email to their account
+// @Summary Claim guest orders
+// @Description Move guest orders placed with the account's verified email into the account
+// @Tags orders
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /orders/claim [post]
+func (h *OrderHandler) ClaimGuestOrders(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	claimed, err := h.orderService.ClaimGuestOrders(userID.(uint))
+	if err != nil {
+		if errors.Is(err, services.ErrClaimRequiresVerifiedEmail) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to claim orders"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    gin.H{"claimed": claimed},
+	})
+}
+
+// respondCartChanged answers a checkout refused because the cart no longer
+// matches current stock and prices, and reports whether err was one
+func respondCartChanged(c *gin.Context, err error) bool {
+	var changed *services.CartChangedError
+	if !errors.As(err, &changed) {
+		return false
+	}
+
+	c.JSON(http.StatusConflict, gin.H{
+		"error":       err.Error(),
+		"adjustments": changed.Adjustments,
+	})
+	return true
+}
+
 // Admin Handlers
 
 // GetAllOrders returns all orders (admin only)
@@ -1,6 +1,9 @@
 package handlers
 
 import (
+	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -42,14 +45,14 @@ func (h *PaymentHandler) InitiatePayment(c *gin.Context) {
 	// Get client IP
 	req.IPAddr = c.ClientIP()
 
-	paymentURL, err := h.paymentService.InitiatePayment(userID.(uint), req)
+	result, err := h.paymentService.InitiatePayment(userID.(uint), req)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
 	// If no payment URL (COD), return success
-	if paymentURL == "" {
+	if result.PaymentURL == "" {
 		c.JSON(http.StatusOK, gin.H{
 			"success": true,
 			"message": "COD payment confirmed",
@@ -57,15 +60,23 @@ func (h *PaymentHandler) InitiatePayment(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	response := gin.H{
 		"success":     true,
-		"payment_url": paymentURL,
-	})
+		"payment_url": result.PaymentURL,
+	}
+	if result.Deeplink != "" {
+		response["deeplink"] = result.Deeplink
+	}
+	if result.QRCodeURL != "" {
+		response["qr_code_url"] = result.QRCodeURL
+	}
+
+	c.JSON(http.StatusOK, response)
 }
 
-// VNPayCallback handles VNPay payment callback
-// @Summary VNPay callback
-// @Description Process VNPay payment return callback
+// VNPayCallback handles the VNPay IPN
+// @Summary VNPay IPN
+// @Description Process the VNPay IPN and answer with a VNPay RspCode
 // @Tags payments
 // @Produce json
 // @Success 200 {object} map[string]interface{}
@@ -80,12 +91,23 @@ func (h *PaymentHandler) VNPayCallback(c *gin.Context) {
 	}
 
 	err := h.paymentService.ProcessVNPayCallback(queryParams)
-	if err != nil {
-		c.Redirect(http.StatusTemporaryRedirect, "/payment/failure?error="+err.Error())
-		return
-	}
 
-	c.Redirect(http.StatusTemporaryRedirect, "/payment/success")
+	// VNPay expects HTTP 200 with an RspCode for every IPN it sends
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, gin.H{"RspCode": "00", "Message": "Confirm Success"})
+	case errors.Is(err, services.ErrCallbackOrderNotFound):
+		c.JSON(http.StatusOK, gin.H{"RspCode": "01", "Message": "Order not found"})
+	case errors.Is(err, services.ErrCallbackAlreadyConfirmed):
+		c.JSON(http.StatusOK, gin.H{"RspCode": "02", "Message": "Order already confirmed"})
+	case errors.Is(err, services.ErrCallbackInvalidAmount):
+		c.JSON(http.StatusOK, gin.H{"RspCode": "04", "Message": "Invalid amount"})
+	case errors.Is(err, services.ErrCallbackInvalidSignature):
+		c.JSON(http.StatusOK, gin.H{"RspCode": "97", "Message": "Invalid signature"})
+	default:
+		log.Printf("Failed to process VNPay IPN for order %s: %v", queryParams["vnp_TxnRef"], err)
+		c.JSON(http.StatusOK, gin.H{"RspCode": "99", "Message": "Unknown error"})
+	}
 }
 
 // VNPayReturn handles VNPay return URL (user-facing)
@@ -125,14 +147,17 @@ func (h *PaymentHandler) VNPayReturn(c *gin.Context) {
 // @Success 200 {object} map[string]interface{}
 // @Router /payments/momo/ipn [post]
 func (h *PaymentHandler) MoMoCallback(c *gin.Context) {
+	// Keep numbers as json.Number so the signature is checked against the exact values MoMo sent
 	var params map[string]interface{}
-	if err := c.ShouldBindJSON(&params); err != nil {
+	decoder := json.NewDecoder(c.Request.Body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&params); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
 	err := h.paymentService.ProcessMoMoCallback(params)
-	if err != nil {
+	if err != nil && !errors.Is(err, services.ErrCallbackAlreadyConfirmed) {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"resultCode": 1,
 			"message":    err.Error(),
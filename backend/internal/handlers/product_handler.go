@@ -1,15 +1,21 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/huy1235588/fashion-e-commerce/internal/middleware"
 	"github.com/huy1235588/fashion-e-commerce/internal/models"
 	"github.com/huy1235588/fashion-e-commerce/internal/repositories"
 	"github.com/huy1235588/fashion-e-commerce/internal/services"
 )
 
+// maxProductPageSize caps how many products one listing page returns
+const maxProductPageSize = 100
+
 // ProductHandler handles product HTTP requests
 type ProductHandler struct {
 	service *services.ProductService
@@ -36,13 +42,19 @@ type CreateProductRequest struct {
 // @Success 201 {object} models.ProductResponse
 // @Router /admin/products [post]
 func (h *ProductHandler) CreateProduct(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
 	var req CreateProductRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	if err := h.service.CreateProduct(&req.Product, req.Variants, req.Images); err != nil {
+	if err := h.service.CreateProduct(&req.Product, req.Variants, req.Images, userID); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -146,19 +158,141 @@ func (h *ProductHandler) DeleteProduct(c *gin.Context) {
 	c.JSON(http.StatusNoContent, nil)
 }
 
-// ListProducts handles retrieving products with filters
+// ListProducts handles retrieving products with filters. Passing cursor,
+// empty for the first page, switches to cursor pagination for infinite
+// scroll; the response then has next_cursor instead of page totals.
 // @Summary List products
 // @Tags products
 // @Produce json
 // @Param category_id query int false "Category ID"
 // @Param min_price query number false "Minimum price"
 // @Param max_price query number false "Maximum price"
+// @Param sizes query string false "Comma-separated variant sizes"
+// @Param colors query string false "Comma-separated variant colors"
+// @Param in_stock query bool false "Only products in stock"
+// @Param on_sale query bool false "Only discounted products"
+// @Param min_rating query number false "Minimum average rating"
 // @Param search query string false "Search query"
+// @Param sort query string false "newest, price_asc, price_desc, best_selling, top_rated or relevance"
+// @Param cursor query string false "Cursor of the next page"
 // @Param page query int false "Page number" default(1)
 // @Param page_size query int false "Page size" default(20)
 // @Success 200 {object} map[string]interface{}
 // @Router /products [get]
 func (h *ProductHandler) ListProducts(c *gin.Context) {
+	filters := parseProductFilters(c)
+	filters.SearchQuery = c.Query("search")
+	filters.Sort = c.Query("sort")
+
+	if cursor, ok := c.GetQuery("cursor"); ok {
+		items, nextCursor, err := h.service.ListProductsByCursor(filters, cursor)
+		if err != nil {
+			if errors.Is(err, repositories.ErrInvalidCursor) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to retrieve products"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"data": productListResponses(items),
+			"pagination": gin.H{
+				"page_size":   filters.PageSize,
+				"next_cursor": nextCursor,
+				"has_more":    nextCursor != "",
+			},
+		})
+		return
+	}
+
+	items, total, err := h.service.ListProducts(filters)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to retrieve products"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":       productListResponses(items),
+		"pagination": paginationResponse(total, filters.Page, filters.PageSize),
+	})
+}
+
+// SearchProducts handles full-text product search, ignoring accents, with
+// the best matches first and matched words highlighted
+// @Summary Search products
+// @Tags products
+// @Produce json
+// @Param q query string true "Search text"
+// @Param category_id query int false "Category ID"
+// @Param min_price query number false "Minimum price"
+// @Param max_price query number false "Maximum price"
+// @Param sizes query string false "Comma-separated variant sizes"
+// @Param colors query string false "Comma-separated variant colors"
+// @Param in_stock query bool false "Only products in stock"
+// @Param on_sale query bool false "Only discounted products"
+// @Param min_rating query number false "Minimum average rating"
+// @Param page query int false "Page number" default(1)
+// @Param page_size query int false "Page size" default(20)
+// @Success 200 {object} map[string]interface{}
+// @Router /products/search [get]
+func (h *ProductHandler) SearchProducts(c *gin.Context) {
+	filters := parseProductFilters(c)
+	filters.SearchQuery = c.Query("q")
+
+	hits, total, err := h.service.SearchProducts(filters)
+	if err != nil {
+		if errors.Is(err, services.ErrSearchQueryRequired) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to search products"})
+		return
+	}
+
+	responses := make([]models.ProductSearchResponse, len(hits))
+	for i := range hits {
+		responses[i] = hits[i].ToResponse()
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":       responses,
+		"pagination": paginationResponse(total, filters.Page, filters.PageSize),
+	})
+}
+
+// GetProductFacets handles counting the products per size, color, category
+// and price range for the current filters, for the storefront sidebar
+// @Summary Get product filter facets
+// @Tags products
+// @Produce json
+// @Param q query string false "Search text"
+// @Param category_id query int false "Category ID"
+// @Param min_price query number false "Minimum price"
+// @Param max_price query number false "Maximum price"
+// @Param sizes query string false "Comma-separated variant sizes"
+// @Param colors query string false "Comma-separated variant colors"
+// @Param in_stock query bool false "Only products in stock"
+// @Param on_sale query bool false "Only discounted products"
+// @Param min_rating query number false "Minimum average rating"
+// @Success 200 {object} repositories.ProductFacets
+// @Router /products/facets [get]
+func (h *ProductHandler) GetProductFacets(c *gin.Context) {
+	filters := parseProductFilters(c)
+	filters.SearchQuery = c.DefaultQuery("q", c.Query("search"))
+
+	facets, err := h.service.GetProductFacets(filters)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to retrieve product facets"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": facets})
+}
+
+// parseProductFilters reads the storefront listing filters from the query
+// string; only active products are shown
+func parseProductFilters(c *gin.Context) repositories.ProductFilters {
 	var filters repositories.ProductFilters
 
 	// Parse query parameters
@@ -184,38 +318,72 @@ func (h *ProductHandler) ListProducts(c *gin.Context) {
 		}
 	}
 
-	filters.SearchQuery = c.Query("search")
+	filters.Sizes = queryList(c, "sizes")
+	filters.Colors = queryList(c, "colors")
+	filters.InStock, _ = strconv.ParseBool(c.Query("in_stock"))
+	filters.OnSale, _ = strconv.ParseBool(c.Query("on_sale"))
+
+	if minRating := c.Query("min_rating"); minRating != "" {
+		rating, err := strconv.ParseFloat(minRating, 64)
+		if err == nil {
+			filters.MinRating = &rating
+		}
+	}
 
 	// Only show active products for non-admin users
 	isActive := true
 	filters.IsActive = &isActive
 
 	// Pagination
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
-	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
-	filters.Page = page
-	filters.PageSize = pageSize
+	filters.Page, _ = strconv.Atoi(c.DefaultQuery("page", "1"))
+	filters.PageSize, _ = strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if filters.Page < 1 {
+		filters.Page = 1
+	}
+	if filters.PageSize < 1 {
+		filters.PageSize = 20
+	} else if filters.PageSize > maxProductPageSize {
+		filters.PageSize = maxProductPageSize
+	}
 
-	products, total, err := h.service.ListProducts(filters)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to retrieve products"})
-		return
+	return filters
+}
+
+// queryList reads a list query parameter given either comma-separated or
+// repeated, e.g. sizes=S,M or sizes=S&sizes=M
+func queryList(c *gin.Context, key string) []string {
+	var values []string
+	for _, param := range c.QueryArray(key) {
+		for _, value := range strings.Split(param, ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				values = append(values, value)
+			}
+		}
 	}
+	return values
+}
 
-	responses := make([]models.ProductResponse, len(products))
-	for i, product := range products {
-		responses[i] = product.ToResponse()
+// productListResponses converts listing rows to their response DTOs
+func productListResponses(items []repositories.ProductListItem) []models.ProductListItemResponse {
+	responses := make([]models.ProductListItemResponse, len(items))
+	for i := range items {
+		responses[i] = items[i].ToResponse()
 	}
+	return responses
+}
 
-	c.JSON(http.StatusOK, gin.H{
-		"data": responses,
-		"pagination": gin.H{
-			"total":     total,
-			"page":      page,
-			"page_size": pageSize,
-			"pages":     (total + int64(pageSize) - 1) / int64(pageSize),
-		},
-	})
+// paginationResponse describes a page of a listing
+func paginationResponse(total int64, page, pageSize int) gin.H {
+	pages := int64(0)
+	if pageSize > 0 {
+		pages = (total + int64(pageSize) - 1) / int64(pageSize)
+	}
+	return gin.H{
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+		"pages":     pages,
+	}
 }
 
 // AddProductImage handles adding an image to a product (admin only)
@@ -306,6 +474,12 @@ func (h *ProductHandler) DeleteProductImage(c *gin.Context) {
 // @Success 201 {object} models.ProductVariantResponse
 // @Router /admin/products/{id}/variants [post]
 func (h *ProductHandler) AddProductVariant(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product ID"})
@@ -319,7 +493,7 @@ func (h *ProductHandler) AddProductVariant(c *gin.Context) {
 	}
 
 	variant.ProductID = uint(id)
-	if err := h.service.AddProductVariant(&variant); err != nil {
+	if err := h.service.AddProductVariant(&variant, userID); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -338,6 +512,12 @@ func (h *ProductHandler) AddProductVariant(c *gin.Context) {
 // @Success 200 {object} models.ProductVariantResponse
 // @Router /admin/products/{id}/variants/{variant_id} [put]
 func (h *ProductHandler) UpdateProductVariant(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product ID"})
@@ -357,7 +537,7 @@ func (h *ProductHandler) UpdateProductVariant(c *gin.Context) {
 	}
 
 	updates.ProductID = uint(id)
-	if err := h.service.UpdateProductVariant(uint(variantID), &updates); err != nil {
+	if err := h.service.UpdateProductVariant(uint(variantID), &updates, userID); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/huy1235588/fashion-e-commerce/internal/models"
+	"github.com/huy1235588/fashion-e-commerce/internal/services"
+)
+
+// PromotionHandler handles promotion HTTP requests
+type PromotionHandler struct {
+	promotionService services.PromotionService
+}
+
+// NewPromotionHandler creates a new PromotionHandler
+func NewPromotionHandler(promotionService services.PromotionService) *PromotionHandler {
+	return &PromotionHandler{promotionService: promotionService}
+}
+
+// ListPromotions handles GET /api/v1/admin/promotions
+func (h *PromotionHandler) ListPromotions(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	filters := map[string]interface{}{
+		"type":   c.Query("type"),
+		"search": c.Query("search"),
+	}
+	if isActive := c.Query("is_active"); isActive != "" {
+		filters["is_active"] = isActive == "true"
+	}
+
+	promotions, total, err := h.promotionService.ListPromotions(filters, limit, (page-1)*limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch promotions"})
+		return
+	}
+
+	responses := make([]models.PromotionResponse, len(promotions))
+	for i := range promotions {
+		responses[i] = promotions[i].ToResponse()
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": responses,
+		"pagination": gin.H{
+			"page":        page,
+			"limit":       limit,
+			"total":       total,
+			"total_pages": (total + int64(limit) - 1) / int64(limit),
+		},
+	})
+}
+
+// GetPromotion handles GET /api/v1/admin/promotions/:id
+func (h *PromotionHandler) GetPromotion(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid promotion ID"})
+		return
+	}
+
+	promotion, err := h.promotionService.GetPromotion(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "promotion not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": promotion.ToResponse()})
+}
+
+// CreatePromotion handles POST /api/v1/admin/promotions
+func (h *PromotionHandler) CreatePromotion(c *gin.Context) {
+	var req services.PromotionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	promotion, err := h.promotionService.CreatePromotion(req)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, services.ErrPromotionCodeExists) {
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Promotion created successfully",
+		"data":    promotion.ToResponse(),
+	})
+}
+
+// UpdatePromotion handles PUT /api/v1/admin/promotions/:id
+func (h *PromotionHandler) UpdatePromotion(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid promotion ID"})
+		return
+	}
+
+	var req services.PromotionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	promotion, err := h.promotionService.UpdatePromotion(uint(id), req)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, services.ErrPromotionCodeExists) {
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Promotion updated successfully",
+		"data":    promotion.ToResponse(),
+	})
+}
+
+// DeletePromotion handles DELETE /api/v1/admin/promotions/:id
+func (h *PromotionHandler) DeletePromotion(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid promotion ID"})
+		return
+	}
+
+	if err := h.promotionService.DeletePromotion(uint(id)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Promotion deleted successfully"})
+}
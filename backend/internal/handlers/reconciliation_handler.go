@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/huy1235588/fashion-e-commerce/internal/services"
+)
+
+// ReconciliationHandler handles payment reconciliation HTTP requests
+type ReconciliationHandler struct {
+	reconciliationService services.ReconciliationService
+}
+
+// NewReconciliationHandler creates a new ReconciliationHandler
+func NewReconciliationHandler(reconciliationService services.ReconciliationService) *ReconciliationHandler {
+	return &ReconciliationHandler{reconciliationService: reconciliationService}
+}
+
+// ListReports handles GET /api/v1/admin/payments/reconciliation/reports
+func (h *ReconciliationHandler) ListReports(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	reports, total, err := h.reconciliationService.ListReports(limit, (page-1)*limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch reconciliation reports"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": reports,
+		"pagination": gin.H{
+			"page":        page,
+			"limit":       limit,
+			"total":       total,
+			"total_pages": (total + int64(limit) - 1) / int64(limit),
+		},
+	})
+}
+
+// GetReport handles GET /api/v1/admin/payments/reconciliation/reports/:date
+func (h *ReconciliationHandler) GetReport(c *gin.Context) {
+	day, err := time.ParseInLocation("2006-01-02", c.Param("date"), time.Local)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid date, expected YYYY-MM-DD"})
+		return
+	}
+
+	report, err := h.reconciliationService.GetReport(day)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch reconciliation report"})
+		return
+	}
+	if report == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "reconciliation report not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": report})
+}
+
+// BuildReport handles POST /api/v1/admin/payments/reconciliation/reports/:date
+func (h *ReconciliationHandler) BuildReport(c *gin.Context) {
+	day, err := time.ParseInLocation("2006-01-02", c.Param("date"), time.Local)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid date, expected YYYY-MM-DD"})
+		return
+	}
+	if !day.Before(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "date must be in the past"})
+		return
+	}
+
+	report, err := h.reconciliationService.BuildDailyReport(day)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Reconciliation report built successfully",
+		"data":    report,
+	})
+}
+
+// ReconcilePending handles POST /api/v1/admin/payments/reconciliation/run
+func (h *ReconciliationHandler) ReconcilePending(c *gin.Context) {
+	count, err := h.reconciliationService.ReconcilePending()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Pending payments reconciled",
+		"data":    gin.H{"settled": count},
+	})
+}
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/huy1235588/fashion-e-commerce/internal/middleware"
+	"github.com/huy1235588/fashion-e-commerce/internal/services"
+)
+
+// RefundHandler handles refund HTTP requests
+type RefundHandler struct {
+	refundService services.RefundService
+}
+
+// NewRefundHandler creates a new RefundHandler
+func NewRefundHandler(refundService services.RefundService) *RefundHandler {
+	return &RefundHandler{refundService: refundService}
+}
+
+// CreateRefund handles POST /api/v1/admin/orders/:id/refunds
+func (h *RefundHandler) CreateRefund(c *gin.Context) {
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid order ID"})
+		return
+	}
+
+	var req services.RefundRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	adminID, _ := middleware.GetUserID(c)
+
+	refund, err := h.refundService.RequestRefund(uint(orderID), &adminID, req, c.ClientIP())
+	if errors.Is(err, services.ErrRefundPending) {
+		// The gateway did not answer clearly; reconciliation settles it later
+		c.JSON(http.StatusAccepted, gin.H{
+			"message": err.Error(),
+			"data":    refund,
+		})
+		return
+	}
+	if err != nil {
+		// A refund rejected by the gateway is still recorded; return it so
+		// the admin can see why it failed
+		if refund != nil {
+			c.JSON(http.StatusBadGateway, gin.H{
+				"error": err.Error(),
+				"data":  refund,
+			})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Refund created successfully",
+		"data":    refund,
+	})
+}
+
+// GetOrderRefunds handles GET /api/v1/admin/orders/:id/refunds
+func (h *RefundHandler) GetOrderRefunds(c *gin.Context) {
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid order ID"})
+		return
+	}
+
+	refunds, err := h.refundService.GetOrderRefunds(uint(orderID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch refunds"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": refunds})
+}
+
+// ListRefunds handles GET /api/v1/admin/refunds
+func (h *RefundHandler) ListRefunds(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	filters := map[string]interface{}{
+		"status":         c.Query("status"),
+		"payment_method": c.Query("payment_method"),
+	}
+
+	refunds, total, err := h.refundService.ListRefunds(filters, limit, (page-1)*limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch refunds"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": refunds,
+		"pagination": gin.H{
+			"page":        page,
+			"limit":       limit,
+			"total":       total,
+			"total_pages": (total + int64(limit) - 1) / int64(limit),
+		},
+	})
+}
+
+// SettleRefund handles POST /api/v1/admin/refunds/:id/settle
+func (h *RefundHandler) SettleRefund(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid refund ID"})
+		return
+	}
+
+	var req services.SettleRefundRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	adminID, _ := middleware.GetUserID(c)
+
+	refund, err := h.refundService.SettleRefund(uint(id), adminID, req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Refund settled successfully",
+		"data":    refund,
+	})
+}
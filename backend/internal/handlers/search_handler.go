@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/huy1235588/fashion-e-commerce/internal/services"
+)
+
+// SearchHandler handles search autocomplete and search report HTTP requests
+type SearchHandler struct {
+	service services.SearchService
+}
+
+// NewSearchHandler creates a new search handler
+func NewSearchHandler(service services.SearchService) *SearchHandler {
+	return &SearchHandler{service: service}
+}
+
+// Suggest handles autocompleting a search box
+// @Summary Suggest search completions
+// @Tags products
+// @Produce json
+// @Param q query string true "Typed prefix"
+// @Param limit query int false "Suggestions per group" default(5)
+// @Success 200 {object} services.SearchSuggestions
+// @Router /products/suggest [get]
+func (h *SearchHandler) Suggest(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "5"))
+
+	suggestions, err := h.service.Suggest(c.Query("q"), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to retrieve suggestions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": suggestions})
+}
+
+// GetTopQueries handles retrieving the most searched queries
+// GET /api/admin/statistics/search/top?days=30&limit=20
+func (h *SearchHandler) GetTopQueries(c *gin.Context) {
+	days, _ := strconv.Atoi(c.DefaultQuery("days", "30"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+	data, err := h.service.GetTopQueries(days, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to retrieve top search queries",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": data,
+	})
+}
+
+// GetZeroResultQueries handles retrieving the searches that find nothing
+// GET /api/admin/statistics/search/zero-results?days=30&limit=20
+func (h *SearchHandler) GetZeroResultQueries(c *gin.Context) {
+	days, _ := strconv.Atoi(c.DefaultQuery("days", "30"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+	data, err := h.service.GetZeroResultQueries(days, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to retrieve zero-result search queries",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": data,
+	})
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/huy1235588/fashion-e-commerce/internal/middleware"
+	"github.com/huy1235588/fashion-e-commerce/internal/models"
+	"github.com/huy1235588/fashion-e-commerce/internal/services"
+)
+
+// ShippingHandler handles shipping fee HTTP requests
+type ShippingHandler struct {
+	shippingService services.ShippingService
+}
+
+// NewShippingHandler creates a new ShippingHandler
+func NewShippingHandler(shippingService services.ShippingService) *ShippingHandler {
+	return &ShippingHandler{shippingService: shippingService}
+}
+
+// QuoteShipping returns the shipping fee for the user's cart
+// @Summary Quote shipping fee
+// @Description Calculate the shipping fee for the current cart before creating an order
+// @Tags shipping
+// @Accept json
+// @Produce json
+// @Param request body services.ShippingQuoteRequest true "Destination"
+// @Success 200 {object} services.ShippingQuote
+// @Router /shipping/quote [post]
+func (h *ShippingHandler) QuoteShipping(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req services.ShippingQuoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	quote, err := h.shippingService.QuoteCart(userID, req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": quote})
+}
+
+// ListZones handles GET /api/v1/admin/shipping/zones
+func (h *ShippingHandler) ListZones(c *gin.Context) {
+	zones, err := h.shippingService.ListZones()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch shipping zones"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": zones})
+}
+
+// SaveZone handles POST /api/v1/admin/shipping/zones
+func (h *ShippingHandler) SaveZone(c *gin.Context) {
+	var zone models.ShippingZone
+	if err := c.ShouldBindJSON(&zone); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.shippingService.SaveZone(&zone); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Shipping zone saved successfully",
+		"data":    zone,
+	})
+}
+
+// DeleteZone handles DELETE /api/v1/admin/shipping/zones/:id
+func (h *ShippingHandler) DeleteZone(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid zone ID"})
+		return
+	}
+
+	if err := h.shippingService.DeleteZone(uint(id)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete shipping zone"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Shipping zone deleted successfully"})
+}
@@ -107,6 +107,24 @@ func (h *StatisticsHandler) GetTopProducts(c *gin.Context) {
 	})
 }
 
+// GetWishlistProducts handles retrieving the products saved to the most wishlists
+// GET /api/admin/statistics/products/wishlist?limit=10
+func (h *StatisticsHandler) GetWishlistProducts(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+
+	data, err := h.service.GetMostWishlistedProducts(limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to retrieve wishlist statistics",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": data,
+	})
+}
+
 // GetOrderStats handles retrieving order statistics by status
 // GET /api/admin/statistics/orders
 func (h *StatisticsHandler) GetOrderStats(c *gin.Context) {
@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/huy1235588/fashion-e-commerce/internal/middleware"
+	"github.com/huy1235588/fashion-e-commerce/internal/services"
+)
+
+// TwoFactorHandler handles two-factor authentication enrolment
+type TwoFactorHandler struct {
+	twoFactorService services.TwoFactorService
+}
+
+// NewTwoFactorHandler creates a new two-factor handler
+func NewTwoFactorHandler(twoFactorService services.TwoFactorService) *TwoFactorHandler {
+	return &TwoFactorHandler{twoFactorService: twoFactorService}
+}
+
+// TwoFactorCodeRequest carries a TOTP or backup code
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// GetStatus handles getting the current user's two-factor status
+// GET /api/auth/2fa
+func (h *TwoFactorHandler) GetStatus(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	status, err := h.twoFactorService.Status(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch two-factor status"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": status})
+}
+
+// Setup handles generating a TOTP secret to add to an authenticator app
+// POST /api/auth/2fa/setup
+func (h *TwoFactorHandler) Setup(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	setup, err := h.twoFactorService.BeginSetup(userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Scan the code with your authenticator app, then confirm with a code from it",
+		"data":    setup,
+	})
+}
+
+// Enable handles confirming enrolment with a first code
+// POST /api/auth/2fa/enable
+func (h *TwoFactorHandler) Enable(c *gin.Context) {
+	userID, req, ok := h.bindCode(c)
+	if !ok {
+		return
+	}
+
+	backupCodes, err := h.twoFactorService.Enable(userID, req.Code)
+	if err != nil {
+		c.JSON(codeErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Two-factor authentication enabled. Store the backup codes somewhere safe; they are shown only once",
+		"data":    gin.H{"backup_codes": backupCodes},
+	})
+}
+
+// Disable handles turning two-factor authentication off
+// POST /api/auth/2fa/disable
+func (h *TwoFactorHandler) Disable(c *gin.Context) {
+	userID, req, ok := h.bindCode(c)
+	if !ok {
+		return
+	}
+
+	if err := h.twoFactorService.Disable(userID, req.Code); err != nil {
+		c.JSON(codeErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+// RegenerateBackupCodes handles replacing the backup codes
+// POST /api/auth/2fa/backup-codes
+func (h *TwoFactorHandler) RegenerateBackupCodes(c *gin.Context) {
+	userID, req, ok := h.bindCode(c)
+	if !ok {
+		return
+	}
+
+	backupCodes, err := h.twoFactorService.RegenerateBackupCodes(userID, req.Code)
+	if err != nil {
+		c.JSON(codeErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Backup codes regenerated; the old ones no longer work",
+		"data":    gin.H{"backup_codes": backupCodes},
+	})
+}
+
+// ResetUserTwoFactor handles DELETE /api/v1/admin/users/:id/two-factor
+func (h *TwoFactorHandler) ResetUserTwoFactor(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	if err := h.twoFactorService.Reset(uint(id)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication reset; the user has been logged out"})
+}
+
+// bindCode reads the current user and the code from the request
+func (h *TwoFactorHandler) bindCode(c *gin.Context) (uint, TwoFactorCodeRequest, bool) {
+	var req TwoFactorCodeRequest
+
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return 0, req, false
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return 0, req, false
+	}
+	return userID, req, true
+}
+
+func codeErrorStatus(err error) int {
+	if errors.Is(err, services.ErrInvalidTwoFactorCode) {
+		return http.StatusUnauthorized
+	}
+	return http.StatusBadRequest
+}
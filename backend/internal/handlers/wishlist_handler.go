@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/huy1235588/fashion-e-commerce/internal/middleware"
+	"github.com/huy1235588/fashion-e-commerce/internal/models"
+	"github.com/huy1235588/fashion-e-commerce/internal/services"
+)
+
+// WishlistHandler handles wishlist and back-in-stock alert HTTP requests
+type WishlistHandler struct {
+	service services.WishlistService
+}
+
+// NewWishlistHandler creates a new wishlist handler
+func NewWishlistHandler(service services.WishlistService) *WishlistHandler {
+	return &WishlistHandler{service: service}
+}
+
+// RestockAlertRequest represents the request body for subscribing to a restock
+type RestockAlertRequest struct {
+	VariantID uint `json:"variant_id" binding:"required"`
+}
+
+// GetWishlist handles retrieving the user's wishlist
+// @Summary Get user's wishlist
+// @Tags wishlist
+// @Produce json
+// @Success 200 {array} models.WishlistResponse
+// @Router /wishlist [get]
+func (h *WishlistHandler) GetWishlist(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	items, err := h.service.List(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to retrieve wishlist"})
+		return
+	}
+
+	responses := make([]models.WishlistResponse, len(items))
+	for i := range items {
+		responses[i] = items[i].ToResponse()
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": responses})
+}
+
+// AddToWishlist handles saving a product to the wishlist
+// @Summary Add product to wishlist
+// @Tags wishlist
+// @Accept json
+// @Produce json
+// @Param item body services.AddToWishlistRequest true "Product data"
+// @Success 200 {object} models.WishlistResponse
+// @Router /wishlist [post]
+func (h *WishlistHandler) AddToWishlist(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req services.AddToWishlistRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	item, err := h.service.Add(userID, req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": item.ToResponse()})
+}
+
+// RemoveFromWishlist handles removing an item from the wishlist
+// @Summary Remove item from wishlist
+// @Tags wishlist
+// @Param id path int true "Wishlist Item ID"
+// @Success 200 {object} map[string]interface{}
+// @Router /wishlist/{id} [delete]
+func (h *WishlistHandler) RemoveFromWishlist(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	itemID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid item ID"})
+		return
+	}
+
+	if err := h.service.Remove(userID, uint(itemID)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "item removed from wishlist"})
+}
+
+// MoveToCart handles moving a wishlist item to the cart
+// @Summary Move wishlist item to cart
+// @Tags wishlist
+// @Accept json
+// @Produce json
+// @Param id path int true "Wishlist Item ID"
+// @Param item body services.MoveToCartRequest false "Variant and quantity"
+// @Success 200 {object} models.CartResponse
+// @Router /wishlist/{id}/move-to-cart [post]
+func (h *WishlistHandler) MoveToCart(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	itemID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid item ID"})
+		return
+	}
+
+	var req services.MoveToCartRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	cart, err := h.service.MoveToCart(userID, uint(itemID), req)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, services.ErrVariantRequired) {
+			status = http.StatusUnprocessableEntity
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": cart.ToResponse()})
+}
+
+// GetRestockAlerts handles listing the variants the user waits for
+// @Summary Get back-in-stock alerts
+// @Tags wishlist
+// @Produce json
+// @Success 200 {array} models.RestockSubscriptionResponse
+// @Router /wishlist/restock-alerts [get]
+func (h *WishlistHandler) GetRestockAlerts(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	subscriptions, err := h.service.ListRestockAlerts(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to retrieve restock alerts"})
+		return
+	}
+
+	responses := make([]models.RestockSubscriptionResponse, len(subscriptions))
+	for i := range subscriptions {
+		responses[i] = subscriptions[i].ToResponse()
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": responses})
+}
+
+// SubscribeRestock handles asking for an email when a sold-out variant is back
+// @Summary Subscribe to a back-in-stock alert
+// @Tags wishlist
+// @Accept json
+// @Produce json
+// @Param alert body RestockAlertRequest true "Variant"
+// @Success 200 {object} models.RestockSubscriptionResponse
+// @Router /wishlist/restock-alerts [post]
+func (h *WishlistHandler) SubscribeRestock(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req RestockAlertRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	subscription, err := h.service.SubscribeRestock(userID, req.VariantID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": subscription.ToResponse()})
+}
+
+// UnsubscribeRestock handles cancelling a back-in-stock alert
+// @Summary Unsubscribe from a back-in-stock alert
+// @Tags wishlist
+// @Param variant_id path int true "Variant ID"
+// @Success 200 {object} map[string]interface{}
+// @Router /wishlist/restock-alerts/{variant_id} [delete]
+func (h *WishlistHandler) UnsubscribeRestock(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	variantID, err := strconv.ParseUint(c.Param("variant_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid variant ID"})
+		return
+	}
+
+	if err := h.service.UnsubscribeRestock(userID, uint(variantID)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to remove restock alert"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "restock alert removed"})
+}
@@ -10,14 +10,30 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// TokenRevocationChecker reports whether a validly signed access token has been revoked
+type TokenRevocationChecker interface {
+	IsTokenRevoked(claims *utils.JWTClaims) (bool, error)
+}
+
+// PermissionChecker reports whether a role grants a permission
+type PermissionChecker interface {
+	HasPermission(role string, permission string) (bool, error)
+}
+
 // AuthMiddleware validates JWT tokens
 type AuthMiddleware struct {
-	jwtUtil *utils.JWTUtil
+	jwtUtil           *utils.JWTUtil
+	revocationChecker TokenRevocationChecker
+	permissionChecker PermissionChecker
 }
 
 // NewAuthMiddleware creates a new auth middleware
-func NewAuthMiddleware(jwtUtil *utils.JWTUtil) *AuthMiddleware {
-	return &AuthMiddleware{jwtUtil: jwtUtil}
+func NewAuthMiddleware(jwtUtil *utils.JWTUtil, revocationChecker TokenRevocationChecker, permissionChecker PermissionChecker) *AuthMiddleware {
+	return &AuthMiddleware{
+		jwtUtil:           jwtUtil,
+		revocationChecker: revocationChecker,
+		permissionChecker: permissionChecker,
+	}
 }
 
 // ValidateJWT middleware validates JWT token from Authorization header
@@ -61,10 +77,29 @@ func (m *AuthMiddleware) ValidateJWT() gin.HandlerFunc {
 			return
 		}
 
+		// Reject tokens of logged out sessions and deactivated users
+		revoked, err := m.revocationChecker.IsTokenRevoked(claims)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to validate token",
+			})
+			c.Abort()
+			return
+		}
+		if revoked {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "Token revoked",
+			})
+			c.Abort()
+			return
+		}
+
 		// Set user information in context
 		c.Set("user_id", claims.UserID)
+		c.Set("session_id", claims.SessionID)
 		c.Set("user_email", claims.Email)
 		c.Set("user_role", claims.Role)
+		c.Set("two_factor", claims.TwoFactor)
 
 		c.Next()
 	}
@@ -110,6 +145,59 @@ func (m *AuthMiddleware) RequireAdmin() gin.HandlerFunc {
 	return m.RequireRole("admin")
 }
 
+// RequirePermission middleware checks that the user's role grants every
+// given permission
+func (m *AuthMiddleware) RequirePermission(permissions ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userRole, err := GetUserRole(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "Authentication required",
+			})
+			c.Abort()
+			return
+		}
+
+		for _, permission := range permissions {
+			allowed, err := m.permissionChecker.HasPermission(userRole, permission)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": "Failed to check permissions",
+				})
+				c.Abort()
+				return
+			}
+			if !allowed {
+				c.JSON(http.StatusForbidden, gin.H{
+					"error": "Permission " + permission + " required",
+				})
+				c.Abort()
+				return
+			}
+		}
+
+		c.Next()
+	}
+}
+
+// RequireTwoFactor middleware rejects sessions that did not pass two-factor
+// authentication. The user can still reach the enrolment endpoints, which are
+// not behind it.
+func (m *AuthMiddleware) RequireTwoFactor() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if twoFactor, _ := c.Get("two_factor"); twoFactor != true {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":               "Two-factor authentication required. Enable it and log in again",
+				"two_factor_required": true,
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
 // GetUserID extracts user ID from context
 func GetUserID(c *gin.Context) (uint, error) {
 	userID, exists := c.Get("user_id")
@@ -125,6 +213,21 @@ func GetUserID(c *gin.Context) (uint, error) {
 	return id, nil
 }
 
+// GetSessionID extracts the session ID of the access token from context
+func GetSessionID(c *gin.Context) (string, error) {
+	sessionID, exists := c.Get("session_id")
+	if !exists {
+		return "", errors.New("session ID not found in context")
+	}
+
+	id, ok := sessionID.(string)
+	if !ok || id == "" {
+		return "", errors.New("invalid session ID")
+	}
+
+	return id, nil
+}
+
 // GetUserRole extracts user role from context
 func GetUserRole(c *gin.Context) (string, error) {
 	role, exists := c.Get("user_role")
@@ -4,16 +4,35 @@ import (
 	"time"
 )
 
-// Cart represents a shopping cart
+// Cart represents a shopping cart. A user has one primary cart, which has
+// no name, and any number of named saved lists.
 type Cart struct {
 	ID        uint       `gorm:"primaryKey" json:"id"`
-	UserID    uint       `gorm:"not null;uniqueIndex" json:"user_id"`
+	UserID    *uint      `gorm:"uniqueIndex:idx_carts_user_name" json:"user_id,omitempty"` // nil for guest carts
+	Name      string     `gorm:"size:100;not null;default:'';uniqueIndex:idx_carts_user_name" json:"name"`
+	GuestKey  *string    `gorm:"type:varchar(36);uniqueIndex" json:"-"`
 	User      *User      `gorm:"foreignKey:UserID" json:"user,omitempty"`
 	Items     []CartItem `gorm:"foreignKey:CartID" json:"items,omitempty"`
 	CreatedAt time.Time  `json:"created_at"`
 	UpdatedAt time.Time  `json:"updated_at"`
 }
 
+// IsGuest reports whether the cart belongs to a visitor without an account
+func (c *Cart) IsGuest() bool {
+	return c.UserID == nil
+}
+
+// IsPrimary reports whether the cart is a user's main cart rather than a
+// saved list
+func (c *Cart) IsPrimary() bool {
+	return c.UserID != nil && c.Name == ""
+}
+
+// BelongsTo reports whether the cart is one of the user's carts
+func (c *Cart) BelongsTo(userID uint) bool {
+	return c.UserID != nil && *c.UserID == userID
+}
+
 // CartItem represents an item in the shopping cart
 type CartItem struct {
 	ID        uint            `gorm:"primaryKey" json:"id"`
@@ -31,7 +50,9 @@ type CartItem struct {
 // CartResponse is the response DTO for cart
 type CartResponse struct {
 	ID        uint               `json:"id"`
-	UserID    uint               `json:"user_id"`
+	UserID    *uint              `json:"user_id,omitempty"`
+	Name      string             `json:"name,omitempty"`
+	IsPrimary bool               `json:"is_primary"`
 	Items     []CartItemResponse `json:"items"`
 	Subtotal  float64            `json:"subtotal"`
 	Total     float64            `json:"total"`
@@ -60,6 +81,8 @@ func (c *Cart) ToResponse() CartResponse {
 	response := CartResponse{
 		ID:        c.ID,
 		UserID:    c.UserID,
+		Name:      c.Name,
+		IsPrimary: c.IsPrimary(),
 		CreatedAt: c.CreatedAt.Format(time.RFC3339),
 		UpdatedAt: c.UpdatedAt.Format(time.RFC3339),
 	}
@@ -4,12 +4,16 @@ import (
 	"time"
 )
 
-// Category represents a product category
+// Category represents a product category. Categories form a tree through
+// ParentID, e.g. "Men > Shirts > Polo"; root categories have no parent.
 type Category struct {
 	ID          uint      `gorm:"primaryKey" json:"id"`
+	ParentID    *uint     `gorm:"index" json:"parent_id"`
+	Parent      *Category `gorm:"foreignKey:ParentID;constraint:OnDelete:RESTRICT" json:"-"`
 	Name        string    `gorm:"size:255;not null" json:"name" binding:"required"`
 	Description string    `gorm:"type:text" json:"description"`
 	Slug        string    `gorm:"size:255;uniqueIndex;not null" json:"slug" binding:"required"`
+	Position    int       `gorm:"not null;default:0" json:"position"` // order among its siblings
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
 }
@@ -17,21 +21,80 @@ type Category struct {
 // CategoryResponse is the response DTO for category
 type CategoryResponse struct {
 	ID          uint   `json:"id"`
+	ParentID    *uint  `json:"parent_id"`
 	Name        string `json:"name"`
 	Description string `json:"description"`
 	Slug        string `json:"slug"`
+	Position    int    `json:"position"`
 	CreatedAt   string `json:"created_at"`
 	UpdatedAt   string `json:"updated_at"`
 }
 
+// CategoryTreeResponse is a category with its subcategories
+type CategoryTreeResponse struct {
+	CategoryResponse
+	Children []CategoryTreeResponse `json:"children"`
+}
+
+// BreadcrumbResponse is one step of the path from a root category down to
+// a product's category
+type BreadcrumbResponse struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+}
+
 // ToResponse converts Category to CategoryResponse
 func (c *Category) ToResponse() CategoryResponse {
 	return CategoryResponse{
 		ID:          c.ID,
+		ParentID:    c.ParentID,
 		Name:        c.Name,
 		Description: c.Description,
 		Slug:        c.Slug,
+		Position:    c.Position,
 		CreatedAt:   c.CreatedAt.Format(time.RFC3339),
 		UpdatedAt:   c.UpdatedAt.Format(time.RFC3339),
 	}
 }
+
+// ToBreadcrumb converts Category to BreadcrumbResponse
+func (c *Category) ToBreadcrumb() BreadcrumbResponse {
+	return BreadcrumbResponse{
+		ID:   c.ID,
+		Name: c.Name,
+		Slug: c.Slug,
+	}
+}
+
+// CategoryTree nests categories under their parents, keeping the order they
+// are given in. Categories whose parent is not in the list become roots.
+func CategoryTree(categories []Category) []CategoryTreeResponse {
+	known := make(map[uint]bool, len(categories))
+	for _, category := range categories {
+		known[category.ID] = true
+	}
+
+	children := make(map[uint][]Category)
+	var roots []Category
+	for _, category := range categories {
+		if category.ParentID != nil && known[*category.ParentID] {
+			children[*category.ParentID] = append(children[*category.ParentID], category)
+		} else {
+			roots = append(roots, category)
+		}
+	}
+
+	var build func(nodes []Category) []CategoryTreeResponse
+	build = func(nodes []Category) []CategoryTreeResponse {
+		tree := make([]CategoryTreeResponse, len(nodes))
+		for i := range nodes {
+			tree[i] = CategoryTreeResponse{
+				CategoryResponse: nodes[i].ToResponse(),
+				Children:         build(children[nodes[i].ID]),
+			}
+		}
+		return tree
+	}
+	return build(roots)
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"time"
+)
+
+type MovementReason string
+
+const (
+	MovementReasonInitial        MovementReason = "initial"
+	MovementReasonSale           MovementReason = "sale"
+	MovementReasonCancel         MovementReason = "cancel"
+	MovementReasonRestock        MovementReason = "restock"
+	MovementReasonAdjustment     MovementReason = "adjustment"
+	MovementReasonReconciliation MovementReason = "reconciliation"
+)
+
+// InventoryMovement is an append-only ledger entry for a change in variant stock.
+// The sum of Delta for a variant should always equal its StockQuantity.
+type InventoryMovement struct {
+	ID         uint           `gorm:"primarykey" json:"id"`
+	VariantID  uint           `gorm:"not null;index" json:"variant_id"`
+	Delta      int            `gorm:"not null" json:"delta"`
+	StockAfter int            `gorm:"not null" json:"stock_after"`
+	Reason     MovementReason `gorm:"type:varchar(20);not null;index" json:"reason"`
+	OrderID    *uint          `gorm:"index" json:"order_id,omitempty"`
+	UserID     *uint          `gorm:"index" json:"user_id,omitempty"`
+	Note       string         `gorm:"type:text" json:"note,omitempty"`
+	CreatedAt  time.Time      `gorm:"index" json:"created_at"`
+}
+
+// TableName specifies the table name for InventoryMovement
+func (InventoryMovement) TableName() string {
+	return "inventory_movements"
+}
+
+// InventoryMovementResponse is the DTO for inventory movement responses
+type InventoryMovementResponse struct {
+	ID         uint           `json:"id"`
+	VariantID  uint           `json:"variant_id"`
+	Delta      int            `json:"delta"`
+	StockAfter int            `json:"stock_after"`
+	Reason     MovementReason `json:"reason"`
+	OrderID    *uint          `json:"order_id,omitempty"`
+	UserID     *uint          `json:"user_id,omitempty"`
+	Note       string         `json:"note,omitempty"`
+	CreatedAt  time.Time      `json:"created_at"`
+}
+
+// ToResponse converts InventoryMovement to InventoryMovementResponse
+func (m *InventoryMovement) ToResponse() InventoryMovementResponse {
+	return InventoryMovementResponse{
+		ID:         m.ID,
+		VariantID:  m.VariantID,
+		Delta:      m.Delta,
+		StockAfter: m.StockAfter,
+		Reason:     m.Reason,
+		OrderID:    m.OrderID,
+		UserID:     m.UserID,
+		Note:       m.Note,
+		CreatedAt:  m.CreatedAt,
+	}
+}
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"time"
+)
+
+// LoginThrottleScope is what a login throttle counts failures for
+type LoginThrottleScope string
+
+const (
+	LoginThrottleScopeAccount LoginThrottleScope = "account" // Identifier is the lowercased email
+	LoginThrottleScopeIP      LoginThrottleScope = "ip"      // Identifier is the client IP address
+)
+
+// LoginThrottle counts recent failed logins for an email address or an IP
+// address. It lives in the database so every server instance sees the same
+// counts and lockouts.
+type LoginThrottle struct {
+	ID              uint               `gorm:"primarykey" json:"id"`
+	CreatedAt       time.Time          `json:"created_at"`
+	UpdatedAt       time.Time          `json:"updated_at"`
+	Scope           LoginThrottleScope `gorm:"type:varchar(10);not null;uniqueIndex:idx_login_throttle_key" json:"scope"`
+	Identifier      string             `gorm:"type:varchar(255);not null;uniqueIndex:idx_login_throttle_key" json:"identifier"`
+	FailedCount     int                `gorm:"not null;default:0" json:"failed_count"`
+	LastFailedAt    time.Time          `gorm:"not null;index" json:"last_failed_at"`
+	LockedUntil     *time.Time         `gorm:"index" json:"locked_until,omitempty"`
+	UnlockTokenHash string             `gorm:"type:varchar(64);index" json:"-"`
+}
+
+// TableName specifies the table name for LoginThrottle
+func (LoginThrottle) TableName() string {
+	return "login_throttles"
+}
+
+// IsLocked reports whether the throttle blocks logins at the given time
+func (t *LoginThrottle) IsLocked(now time.Time) bool {
+	return t.LockedUntil != nil && t.LockedUntil.After(now)
+}
+
+// LoginThrottleResponse is the DTO for lockout listings
+type LoginThrottleResponse struct {
+	ID           uint               `json:"id"`
+	Scope        LoginThrottleScope `json:"scope"`
+	Identifier   string             `json:"identifier"`
+	FailedCount  int                `json:"failed_count"`
+	LastFailedAt time.Time          `json:"last_failed_at"`
+	LockedUntil  *time.Time         `json:"locked_until,omitempty"`
+	Locked       bool               `json:"locked"`
+}
+
+// ToResponse converts LoginThrottle to LoginThrottleResponse
+func (t *LoginThrottle) ToResponse() LoginThrottleResponse {
+	return LoginThrottleResponse{
+		ID:           t.ID,
+		Scope:        t.Scope,
+		Identifier:   t.Identifier,
+		FailedCount:  t.FailedCount,
+		LastFailedAt: t.LastFailedAt,
+		LockedUntil:  t.LockedUntil,
+		Locked:       t.IsLocked(time.Now()),
+	}
+}
@@ -31,6 +31,7 @@ const (
 	PaymentStatusPaid      PaymentStatus = "paid"
 	PaymentStatusFailed    PaymentStatus = "failed"
 	PaymentStatusRefunded  PaymentStatus = "refunded"
+	PaymentStatusPartiallyRefunded PaymentStatus = "partially_refunded"
 )
 
 // Order represents a customer order
@@ -40,12 +41,16 @@ type Order struct {
 	UpdatedAt       time.Time      `json:"updated_at"`
 	DeletedAt       gorm.DeletedAt `gorm:"index" json:"-"`
 	OrderCode       string         `gorm:"uniqueIndex;not null" json:"order_code"`
-	UserID          uint           `gorm:"not null;index" json:"user_id"`
+	UserID          *uint          `gorm:"index" json:"user_id"` // nil for guest orders until claimed
+	GuestEmail      string         `gorm:"type:varchar(255);index" json:"guest_email,omitempty"`
 	Status          OrderStatus    `gorm:"type:varchar(20);not null;default:'pending'" json:"status"`
 	PaymentMethod   PaymentMethod  `gorm:"type:varchar(20);not null" json:"payment_method"`
 	PaymentStatus   PaymentStatus  `gorm:"type:varchar(20);not null;default:'pending'" json:"payment_status"`
 	SubtotalAmount  float64        `gorm:"type:decimal(10,2);not null" json:"subtotal_amount"`
 	ShippingFee     float64        `gorm:"type:decimal(10,2);not null;default:0" json:"shipping_fee"`
+	DiscountAmount  float64        `gorm:"type:decimal(10,2);not null;default:0" json:"discount_amount"`
+	PromotionID     *uint          `gorm:"index" json:"promotion_id,omitempty"`
+	PromotionCode   string         `gorm:"type:varchar(50)" json:"promotion_code,omitempty"`
 	TotalAmount     float64        `gorm:"type:decimal(10,2);not null" json:"total_amount"`
 	Note            string         `gorm:"type:text" json:"note"`
 	CancelReason    string         `gorm:"type:text" json:"cancel_reason,omitempty"`
@@ -88,12 +93,15 @@ type OrderItem struct {
 type OrderResponse struct {
 	ID                    uint            `json:"id"`
 	OrderCode             string          `json:"order_code"`
-	UserID                uint            `json:"user_id"`
+	UserID                *uint           `json:"user_id"`
+	GuestEmail            string          `json:"guest_email,omitempty"`
 	Status                OrderStatus     `json:"status"`
 	PaymentMethod         PaymentMethod   `json:"payment_method"`
 	PaymentStatus         PaymentStatus   `json:"payment_status"`
 	SubtotalAmount        float64         `json:"subtotal_amount"`
 	ShippingFee           float64         `json:"shipping_fee"`
+	DiscountAmount        float64         `json:"discount_amount"`
+	PromotionCode         string          `json:"promotion_code,omitempty"`
 	TotalAmount           float64         `json:"total_amount"`
 	Note                  string          `json:"note,omitempty"`
 	CancelReason          string          `json:"cancel_reason,omitempty"`
@@ -120,17 +128,42 @@ type OrderItemResponse struct {
 	Subtotal    float64 `json:"subtotal"`
 }
 
+// BelongsTo reports whether the order is owned by the given user
+func (o *Order) BelongsTo(userID uint) bool {
+	return o.UserID != nil && *o.UserID == userID
+}
+
+// ContactEmail returns the address order emails are sent to: the account
+// email for registered customers, or the one given at guest checkout
+func (o *Order) ContactEmail() string {
+	if o.UserID != nil && o.User.Email != "" {
+		return o.User.Email
+	}
+	return o.GuestEmail
+}
+
+// ContactName returns the name order emails are addressed to
+func (o *Order) ContactName() string {
+	if o.UserID != nil && o.User.FullName != "" {
+		return o.User.FullName
+	}
+	return o.ShippingFullName
+}
+
 // ToResponse converts Order to OrderResponse
 func (o *Order) ToResponse() OrderResponse {
 	response := OrderResponse{
 		ID:                    o.ID,
 		OrderCode:             o.OrderCode,
 		UserID:                o.UserID,
+		GuestEmail:            o.GuestEmail,
 		Status:                o.Status,
 		PaymentMethod:         o.PaymentMethod,
 		PaymentStatus:         o.PaymentStatus,
 		SubtotalAmount:        o.SubtotalAmount,
 		ShippingFee:           o.ShippingFee,
+		DiscountAmount:        o.DiscountAmount,
+		PromotionCode:         o.PromotionCode,
 		TotalAmount:           o.TotalAmount,
 		Note:                  o.Note,
 		CancelReason:          o.CancelReason,
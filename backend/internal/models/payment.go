@@ -16,8 +16,11 @@ type Payment struct {
 	PaymentMethod   PaymentMethod  `gorm:"type:varchar(20);not null" json:"payment_method"`
 	PaymentStatus   PaymentStatus  `gorm:"type:varchar(20);not null;default:'pending'" json:"payment_status"`
 	Amount          float64        `gorm:"type:decimal(10,2);not null" json:"amount"`
-	TransactionID   string         `gorm:"type:varchar(100);uniqueIndex" json:"transaction_id,omitempty"`
+	RefundedAmount  float64        `gorm:"type:decimal(10,2);not null;default:0" json:"refunded_amount"`
+	TransactionID   string         `gorm:"type:varchar(100);uniqueIndex:idx_payments_transaction_id_set,where:transaction_id <> ''" json:"transaction_id,omitempty"`
+	RequestID       string         `gorm:"type:varchar(100);index" json:"request_id,omitempty"`
 	GatewayResponse string         `gorm:"type:text" json:"gateway_response,omitempty"`
+	InitiatedAt     *time.Time     `gorm:"index" json:"initiated_at,omitempty"` // last time the customer was sent to the gateway
 	PaidAt          *time.Time     `json:"paid_at,omitempty"`
 
 	// Relations
@@ -31,7 +34,9 @@ type PaymentResponse struct {
 	PaymentMethod   PaymentMethod `json:"payment_method"`
 	PaymentStatus   PaymentStatus `json:"payment_status"`
 	Amount          float64       `json:"amount"`
+	RefundedAmount  float64       `json:"refunded_amount"`
 	TransactionID   string        `json:"transaction_id,omitempty"`
+	RequestID       string        `json:"request_id,omitempty"`
 	PaidAt          *time.Time    `json:"paid_at,omitempty"`
 	CreatedAt       time.Time     `json:"created_at"`
 	UpdatedAt       time.Time     `json:"updated_at"`
@@ -45,7 +50,9 @@ func (p *Payment) ToResponse() PaymentResponse {
 		PaymentMethod: p.PaymentMethod,
 		PaymentStatus: p.PaymentStatus,
 		Amount:        p.Amount,
+		RefundedAmount: p.RefundedAmount,
 		TransactionID: p.TransactionID,
+		RequestID:     p.RequestID,
 		PaidAt:        p.PaidAt,
 		CreatedAt:     p.CreatedAt,
 		UpdatedAt:     p.UpdatedAt,
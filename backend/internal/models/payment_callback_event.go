@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+)
+
+type CallbackOutcome string
+
+const (
+	CallbackOutcomePaid    CallbackOutcome = "paid"
+	CallbackOutcomeFailed  CallbackOutcome = "failed"
+	CallbackOutcomePending CallbackOutcome = "pending" // non-terminal result code, nothing applied
+	CallbackOutcomeIgnored CallbackOutcome = "ignored" // payment was already settled
+	// CallbackOutcomeRejected is a signed callback we refused, e.g. for a wrong amount.
+	// Rejected events are kept for finance but do not count as deliveries.
+	CallbackOutcomeRejected CallbackOutcome = "rejected"
+)
+
+// PaymentCallbackEvent records every IPN delivery that was signed by a gateway.
+// A redelivery of the same transaction and result code hits the unique index
+// and is acknowledged without touching the payment again.
+type PaymentCallbackEvent struct {
+	ID            uint            `gorm:"primarykey" json:"id"`
+	CreatedAt     time.Time       `json:"created_at"`
+	Gateway       PaymentMethod   `gorm:"type:varchar(20);not null;uniqueIndex:idx_callback_event_delivery,where:outcome <> 'rejected'" json:"gateway"`
+	OrderCode     string          `gorm:"type:varchar(50);not null;index;uniqueIndex:idx_callback_event_delivery" json:"order_code"`
+	TransactionID string          `gorm:"type:varchar(100);not null;uniqueIndex:idx_callback_event_delivery" json:"transaction_id"`
+	ResultCode    string          `gorm:"type:varchar(20);not null;uniqueIndex:idx_callback_event_delivery" json:"result_code"`
+	PaymentID     *uint           `gorm:"index" json:"payment_id,omitempty"`
+	Amount        float64         `gorm:"type:decimal(10,2)" json:"amount"`
+	Outcome       CallbackOutcome `gorm:"type:varchar(20);not null" json:"outcome"`
+	Detail        string          `gorm:"type:text" json:"detail,omitempty"`
+	Payload       string          `gorm:"type:text" json:"-"`
+}
+
+// TableName specifies the table name for PaymentCallbackEvent
+func (PaymentCallbackEvent) TableName() string {
+	return "payment_callback_events"
+}
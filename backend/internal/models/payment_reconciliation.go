@@ -0,0 +1,69 @@
+package models
+
+import (
+	"time"
+)
+
+type ReconciliationSource string
+
+const (
+	// ReconciliationSourcePending is a stale pending payment resolved by asking the gateway
+	ReconciliationSourcePending ReconciliationSource = "pending_check"
+	// ReconciliationSourceDaily is a mismatch found by the daily comparison
+	ReconciliationSourceDaily ReconciliationSource = "daily_check"
+	// ReconciliationSourceCallback is a gateway callback that could not be applied
+	ReconciliationSourceCallback ReconciliationSource = "callback"
+)
+
+type ReconciliationIssue string
+
+const (
+	ReconciliationIssueStatus       ReconciliationIssue = "status_mismatch"
+	ReconciliationIssueAmount       ReconciliationIssue = "amount_mismatch"
+	ReconciliationIssuePaidCanceled ReconciliationIssue = "paid_after_cancel"
+	ReconciliationIssueGatewayError ReconciliationIssue = "gateway_error"
+)
+
+// ReconciliationReport summarises the comparison of one day's online payments
+// with what the gateways report
+type ReconciliationReport struct {
+	ID            uint      `gorm:"primarykey" json:"id"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	ReportDate    time.Time `gorm:"type:date;uniqueIndex;not null" json:"report_date"`
+	CheckedCount  int       `gorm:"not null;default:0" json:"checked_count"`
+	MismatchCount int       `gorm:"not null;default:0" json:"mismatch_count"`
+
+	// Relations
+	Items []ReconciliationItem `gorm:"foreignKey:ReportID" json:"items,omitempty"`
+}
+
+// TableName specifies the table name for ReconciliationReport
+func (ReconciliationReport) TableName() string {
+	return "reconciliation_reports"
+}
+
+// ReconciliationItem is a difference between our payments table and a gateway.
+// Items found by the pending check are attached to the next daily report.
+type ReconciliationItem struct {
+	ID            uint                 `gorm:"primarykey" json:"id"`
+	CreatedAt     time.Time            `gorm:"index" json:"created_at"`
+	ReportID      *uint                `gorm:"index" json:"report_id,omitempty"`
+	Source        ReconciliationSource `gorm:"type:varchar(20);not null" json:"source"`
+	Issue         ReconciliationIssue  `gorm:"type:varchar(30);not null" json:"issue"`
+	PaymentID     uint                 `gorm:"not null;index" json:"payment_id"`
+	OrderID       uint                 `gorm:"not null" json:"order_id"`
+	OrderCode     string               `gorm:"type:varchar(50)" json:"order_code"`
+	PaymentMethod PaymentMethod        `gorm:"type:varchar(20);not null" json:"payment_method"`
+	LocalStatus   PaymentStatus        `gorm:"type:varchar(20)" json:"local_status"`
+	GatewayStatus string               `gorm:"type:varchar(50)" json:"gateway_status"`
+	LocalAmount   float64              `gorm:"type:decimal(10,2)" json:"local_amount"`
+	GatewayAmount float64              `gorm:"type:decimal(10,2)" json:"gateway_amount"`
+	Resolved      bool                 `gorm:"not null" json:"resolved"` // the worker already fixed our side
+	Detail        string               `gorm:"type:text" json:"detail"`
+}
+
+// TableName specifies the table name for ReconciliationItem
+func (ReconciliationItem) TableName() string {
+	return "reconciliation_items"
+}
@@ -19,6 +19,20 @@ type Product struct {
 	Variants      []ProductVariant `gorm:"foreignKey:ProductID" json:"variants,omitempty"`
 	CreatedAt     time.Time        `json:"created_at"`
 	UpdatedAt     time.Time        `json:"updated_at"`
+
+	// Breadcrumbs is the path from the root category down to the product's
+	// category, loaded for the product detail page
+	Breadcrumbs []Category `gorm:"-" json:"-"`
+
+	// SoldCount, RatingAverage and ReviewCount are denormalized for sorting
+	// listings and kept up to date by ProductRepository.RefreshSalesStats
+	SoldCount     int64   `gorm:"not null;default:0;<-:false" json:"sold_count"`
+	RatingAverage float64 `gorm:"type:decimal(3,2);not null;default:0;<-:false" json:"rating_average"`
+	ReviewCount   int64   `gorm:"not null;default:0;<-:false" json:"review_count"`
+
+	// SearchVector is the full-text search document of the product, kept up
+	// to date by ProductRepository.RefreshSearchVectors
+	SearchVector string `gorm:"type:tsvector;index:idx_products_search_vector,type:gin;->:false;<-:false" json:"-"`
 }
 
 // ProductImage represents a product image
@@ -38,6 +52,7 @@ type ProductVariant struct {
 	Color         string    `gorm:"size:50;not null" json:"color" binding:"required"`
 	StockQuantity int       `gorm:"not null;default:0" json:"stock_quantity" binding:"min=0"`
 	SKU           string    `gorm:"size:100;uniqueIndex;not null" json:"sku" binding:"required"`
+	Weight        int       `gorm:"not null;default:0" json:"weight" binding:"min=0"` // grams, used for shipping
 	CreatedAt     time.Time `json:"created_at"`
 	UpdatedAt     time.Time `json:"updated_at"`
 }
@@ -55,10 +70,42 @@ type ProductResponse struct {
 	IsActive      bool                    `json:"is_active"`
 	Images        []ProductImageResponse  `json:"images,omitempty"`
 	Variants      []ProductVariantResponse `json:"variants,omitempty"`
+	Breadcrumbs   []BreadcrumbResponse    `json:"breadcrumbs,omitempty"`
+	SoldCount     int64                   `json:"sold_count"`
+	RatingAverage float64                 `json:"rating_average"`
+	ReviewCount   int64                   `json:"review_count"`
 	CreatedAt     string                  `json:"created_at"`
 	UpdatedAt     string                  `json:"updated_at"`
 }
 
+// ProductListItemResponse is the compact DTO of a product in a listing,
+// with its primary image and total stock instead of every image and variant
+type ProductListItemResponse struct {
+	ID            uint              `json:"id"`
+	CategoryID    uint              `json:"category_id"`
+	Category      *CategoryResponse `json:"category,omitempty"`
+	Name          string            `json:"name"`
+	Price         float64           `json:"price"`
+	DiscountPrice *float64          `json:"discount_price"`
+	Slug          string            `json:"slug"`
+	ImageURL      string            `json:"image_url,omitempty"`
+	TotalStock    int64             `json:"total_stock"`
+	InStock       bool              `json:"in_stock"`
+	SoldCount     int64             `json:"sold_count"`
+	RatingAverage float64           `json:"rating_average"`
+	ReviewCount   int64             `json:"review_count"`
+	CreatedAt     string            `json:"created_at"`
+}
+
+// ProductSearchResponse is a product found by a full-text search, with the
+// matched words of its name and description wrapped in <mark> tags
+type ProductSearchResponse struct {
+	ProductResponse
+	Rank          float64 `json:"rank"`
+	NameHighlight string  `json:"name_highlight"`
+	Snippet       string  `json:"snippet"`
+}
+
 // ProductImageResponse is the response DTO for product image
 type ProductImageResponse struct {
 	ID        uint   `json:"id"`
@@ -76,6 +123,7 @@ type ProductVariantResponse struct {
 	Color         string `json:"color"`
 	StockQuantity int    `json:"stock_quantity"`
 	SKU           string `json:"sku"`
+	Weight        int    `json:"weight"`
 	CreatedAt     string `json:"created_at"`
 	UpdatedAt     string `json:"updated_at"`
 }
@@ -91,6 +139,9 @@ func (p *Product) ToResponse() ProductResponse {
 		DiscountPrice: p.DiscountPrice,
 		Slug:          p.Slug,
 		IsActive:      p.IsActive,
+		SoldCount:     p.SoldCount,
+		RatingAverage: p.RatingAverage,
+		ReviewCount:   p.ReviewCount,
 		CreatedAt:     p.CreatedAt.Format(time.RFC3339),
 		UpdatedAt:     p.UpdatedAt.Format(time.RFC3339),
 	}
@@ -114,6 +165,13 @@ func (p *Product) ToResponse() ProductResponse {
 		}
 	}
 
+	if len(p.Breadcrumbs) > 0 {
+		response.Breadcrumbs = make([]BreadcrumbResponse, len(p.Breadcrumbs))
+		for i, category := range p.Breadcrumbs {
+			response.Breadcrumbs[i] = category.ToBreadcrumb()
+		}
+	}
+
 	return response
 }
 
@@ -137,6 +195,7 @@ func (pv *ProductVariant) ToResponse() ProductVariantResponse {
 		Color:         pv.Color,
 		StockQuantity: pv.StockQuantity,
 		SKU:           pv.SKU,
+		Weight:        pv.Weight,
 		CreatedAt:     pv.CreatedAt.Format(time.RFC3339),
 		UpdatedAt:     pv.UpdatedAt.Format(time.RFC3339),
 	}
@@ -0,0 +1,117 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type PromotionType string
+
+const (
+	PromotionTypePercentage   PromotionType = "percentage"
+	PromotionTypeFixedAmount  PromotionType = "fixed_amount"
+	PromotionTypeFreeShipping PromotionType = "free_shipping"
+)
+
+// Promotion is a coupon code that discounts an order at checkout.
+// When Categories or Products are set, only matching items count towards
+// the minimum order amount and the discount.
+type Promotion struct {
+	ID                uint           `gorm:"primarykey" json:"id"`
+	CreatedAt         time.Time      `json:"created_at"`
+	UpdatedAt         time.Time      `json:"updated_at"`
+	DeletedAt         gorm.DeletedAt `gorm:"index" json:"-"`
+	Code              string         `gorm:"type:varchar(50);not null;uniqueIndex:idx_promotions_code_active,where:deleted_at IS NULL" json:"code"` // deleted promotions free their code
+	Name              string         `gorm:"type:varchar(255);not null" json:"name"`
+	Description       string         `gorm:"type:text" json:"description"`
+	Type              PromotionType  `gorm:"type:varchar(20);not null" json:"type"`
+	Value             float64        `gorm:"type:decimal(10,2);not null;default:0" json:"value"`
+	MaxDiscountAmount *float64       `gorm:"type:decimal(10,2)" json:"max_discount_amount"`
+	MinOrderAmount    float64        `gorm:"type:decimal(10,2);not null;default:0" json:"min_order_amount"`
+	UsageLimit        *int           `json:"usage_limit"`
+	UsageLimitPerUser *int           `json:"usage_limit_per_user"`
+	UsedCount         int            `gorm:"not null;default:0" json:"used_count"`
+	StartsAt          *time.Time     `json:"starts_at"`
+	EndsAt            *time.Time     `json:"ends_at"`
+	IsActive          bool           `gorm:"not null;index" json:"is_active"`
+
+	// Relations
+	Categories []Category `gorm:"many2many:promotion_categories" json:"categories,omitempty"`
+	Products   []Product  `gorm:"many2many:promotion_products" json:"products,omitempty"`
+}
+
+// TableName specifies the table name for Promotion
+func (Promotion) TableName() string {
+	return "promotions"
+}
+
+// PromotionUsage records a promotion redeemed by an order
+type PromotionUsage struct {
+	ID             uint      `gorm:"primarykey" json:"id"`
+	PromotionID    uint      `gorm:"not null;index" json:"promotion_id"`
+	UserID         uint      `gorm:"not null;index" json:"user_id"`
+	OrderID        uint      `gorm:"not null;uniqueIndex" json:"order_id"`
+	DiscountAmount float64   `gorm:"type:decimal(10,2);not null" json:"discount_amount"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// TableName specifies the table name for PromotionUsage
+func (PromotionUsage) TableName() string {
+	return "promotion_usages"
+}
+
+// PromotionResponse is the DTO for promotion responses
+type PromotionResponse struct {
+	ID                uint          `json:"id"`
+	Code              string        `json:"code"`
+	Name              string        `json:"name"`
+	Description       string        `json:"description"`
+	Type              PromotionType `json:"type"`
+	Value             float64       `json:"value"`
+	MaxDiscountAmount *float64      `json:"max_discount_amount"`
+	MinOrderAmount    float64       `json:"min_order_amount"`
+	UsageLimit        *int          `json:"usage_limit"`
+	UsageLimitPerUser *int          `json:"usage_limit_per_user"`
+	UsedCount         int           `json:"used_count"`
+	StartsAt          *time.Time    `json:"starts_at"`
+	EndsAt            *time.Time    `json:"ends_at"`
+	IsActive          bool          `json:"is_active"`
+	CategoryIDs       []uint        `json:"category_ids"`
+	ProductIDs        []uint        `json:"product_ids"`
+	CreatedAt         time.Time     `json:"created_at"`
+	UpdatedAt         time.Time     `json:"updated_at"`
+}
+
+// ToResponse converts Promotion to PromotionResponse
+func (p *Promotion) ToResponse() PromotionResponse {
+	response := PromotionResponse{
+		ID:                p.ID,
+		Code:              p.Code,
+		Name:              p.Name,
+		Description:       p.Description,
+		Type:              p.Type,
+		Value:             p.Value,
+		MaxDiscountAmount: p.MaxDiscountAmount,
+		MinOrderAmount:    p.MinOrderAmount,
+		UsageLimit:        p.UsageLimit,
+		UsageLimitPerUser: p.UsageLimitPerUser,
+		UsedCount:         p.UsedCount,
+		StartsAt:          p.StartsAt,
+		EndsAt:            p.EndsAt,
+		IsActive:          p.IsActive,
+		CategoryIDs:       make([]uint, len(p.Categories)),
+		ProductIDs:        make([]uint, len(p.Products)),
+		CreatedAt:         p.CreatedAt,
+		UpdatedAt:         p.UpdatedAt,
+	}
+
+	for i, category := range p.Categories {
+		response.CategoryIDs[i] = category.ID
+	}
+	for i, product := range p.Products {
+		response.ProductIDs[i] = product.ID
+	}
+
+	return response
+}
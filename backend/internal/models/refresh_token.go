@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+)
+
+// RefreshToken is a server-side refresh token. Only the SHA-256 hash of the
+// token is stored. Every refresh replaces the token with a new one in the same
+// session (SessionID); presenting a replaced token again revokes the session.
+type RefreshToken struct {
+	ID           uint       `gorm:"primarykey" json:"id"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UserID       uint       `gorm:"not null;index" json:"user_id"`
+	SessionID    string     `gorm:"type:varchar(36);not null;index" json:"session_id"`
+	TokenHash    string     `gorm:"type:varchar(64);uniqueIndex;not null" json:"-"`
+	ExpiresAt    time.Time  `gorm:"not null" json:"expires_at"`
+	RevokedAt    *time.Time `json:"revoked_at,omitempty"`
+	ReplacedByID *uint      `json:"replaced_by_id,omitempty"`
+	UserAgent    string     `gorm:"type:varchar(255)" json:"user_agent"`
+	IPAddress    string     `gorm:"type:varchar(45)" json:"ip_address"`
+	TwoFactor    bool       `gorm:"not null;default:false" json:"two_factor"` // the session passed two-factor authentication
+}
+
+// TableName specifies the table name for RefreshToken
+func (RefreshToken) TableName() string {
+	return "refresh_tokens"
+}
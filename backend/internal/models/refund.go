@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+)
+
+type RefundStatus string
+
+const (
+	// RefundStatusPending is a refund waiting on the gateway, or a COD refund
+	// waiting for the cash to be handed back and settled by an admin
+	RefundStatusPending   RefundStatus = "pending"
+	RefundStatusSucceeded RefundStatus = "succeeded"
+	RefundStatusFailed    RefundStatus = "failed"
+)
+
+// Refund is a full or partial refund of a paid order
+type Refund struct {
+	ID                   uint          `gorm:"primarykey" json:"id"`
+	CreatedAt            time.Time     `json:"created_at"`
+	UpdatedAt            time.Time     `json:"updated_at"`
+	OrderID              uint          `gorm:"not null;index" json:"order_id"`
+	PaymentID            uint          `gorm:"not null;index" json:"payment_id"`
+	PaymentMethod        PaymentMethod `gorm:"type:varchar(20);not null" json:"payment_method"`
+	Amount               float64       `gorm:"type:decimal(10,2);not null" json:"amount"`
+	Reason               string        `gorm:"type:text" json:"reason"`
+	Status               RefundStatus  `gorm:"type:varchar(20);not null;default:'pending';index" json:"status"`
+	RequestID            string        `gorm:"type:varchar(100);index" json:"request_id,omitempty"`
+	GatewayTransactionID string        `gorm:"type:varchar(100)" json:"gateway_transaction_id,omitempty"`
+	GatewayResponse      string        `gorm:"type:text" json:"-"`
+	FailureReason        string        `gorm:"type:text" json:"failure_reason,omitempty"`
+	RequestedBy          *uint         `json:"requested_by,omitempty"`
+
+	// Manual settlement (COD)
+	SettledBy           *uint  `json:"settled_by,omitempty"`
+	SettlementReference string `gorm:"type:varchar(255)" json:"settlement_reference,omitempty"`
+	SettlementNote      string `gorm:"type:text" json:"settlement_note,omitempty"`
+
+	ProcessedAt *time.Time `json:"processed_at,omitempty"`
+
+	// Relations
+	Order   Order   `gorm:"foreignKey:OrderID" json:"-"`
+	Payment Payment `gorm:"foreignKey:PaymentID" json:"-"`
+}
+
+// TableName specifies the table name for Refund
+func (Refund) TableName() string {
+	return "refunds"
+}
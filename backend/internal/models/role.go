@@ -0,0 +1,183 @@
+package models
+
+import (
+	"time"
+)
+
+// Permission codes checked by the admin API
+const (
+	PermissionStatsRead         = "stats:read"
+	PermissionUsersRead         = "users:read"
+	PermissionUsersManage       = "users:manage"
+	PermissionRolesManage       = "roles:manage"
+	PermissionOrdersRead        = "orders:read"
+	PermissionOrdersUpdate      = "orders:update"
+	PermissionRefundsManage     = "refunds:manage"
+	PermissionPaymentsReconcile = "payments:reconcile"
+	PermissionProductsWrite     = "products:write"
+	PermissionCategoriesWrite   = "categories:write"
+	PermissionInventoryRead     = "inventory:read"
+	PermissionInventoryWrite    = "inventory:write"
+	PermissionPromotionsManage  = "promotions:manage"
+	PermissionShippingManage    = "shipping:manage"
+)
+
+// PermissionDescriptions lists every permission the application knows about
+var PermissionDescriptions = map[string]string{
+	PermissionStatsRead:         "View dashboard and sales statistics",
+	PermissionUsersRead:         "View customer and staff accounts",
+	PermissionUsersManage:       "Activate, deactivate and assign roles to accounts",
+	PermissionRolesManage:       "Create and edit roles",
+	PermissionOrdersRead:        "View orders and their refunds",
+	PermissionOrdersUpdate:      "Change order status",
+	PermissionRefundsManage:     "Issue and settle refunds",
+	PermissionPaymentsReconcile: "Run payment reconciliation and view reports",
+	PermissionProductsWrite:     "Create, edit and delete products",
+	PermissionCategoriesWrite:   "Create, edit and delete categories",
+	PermissionInventoryRead:     "View stock movements",
+	PermissionInventoryWrite:    "Adjust and reconcile stock",
+	PermissionPromotionsManage:  "Manage coupon codes",
+	PermissionShippingManage:    "Manage shipping zones",
+}
+
+// Built-in role names
+const (
+	RoleCustomer        = "customer"
+	RoleAdmin           = "admin"
+	RoleWarehouseStaff  = "warehouse_staff"
+	RoleCustomerSupport = "customer_support"
+	RoleContentEditor   = "content_editor"
+)
+
+// Permission is a single capability that can be granted to roles
+type Permission struct {
+	ID          uint   `gorm:"primarykey" json:"id"`
+	Code        string `gorm:"type:varchar(50);uniqueIndex;not null" json:"code"`
+	Description string `gorm:"type:varchar(255)" json:"description"`
+}
+
+// TableName specifies the table name for Permission
+func (Permission) TableName() string {
+	return "permissions"
+}
+
+// Role is a named set of permissions assigned to users through User.Role.
+// System roles are created by migrations and cannot be edited or deleted.
+type Role struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	Name        string    `gorm:"type:varchar(50);uniqueIndex;not null" json:"name"`
+	DisplayName string    `gorm:"type:varchar(100);not null" json:"display_name"`
+	Description string    `gorm:"type:text" json:"description"`
+	IsSystem    bool      `gorm:"not null" json:"is_system"`
+
+	// Relations
+	Permissions []Permission `gorm:"many2many:role_permissions" json:"permissions,omitempty"`
+}
+
+// TableName specifies the table name for Role
+func (Role) TableName() string {
+	return "roles"
+}
+
+// RoleResponse is the DTO for role responses
+type RoleResponse struct {
+	ID          uint      `json:"id"`
+	Name        string    `json:"name"`
+	DisplayName string    `json:"display_name"`
+	Description string    `json:"description"`
+	IsSystem    bool      `json:"is_system"`
+	Permissions []string  `json:"permissions"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// ToResponse converts Role to RoleResponse
+func (r *Role) ToResponse() RoleResponse {
+	response := RoleResponse{
+		ID:          r.ID,
+		Name:        r.Name,
+		DisplayName: r.DisplayName,
+		Description: r.Description,
+		IsSystem:    r.IsSystem,
+		Permissions: make([]string, len(r.Permissions)),
+		CreatedAt:   r.CreatedAt,
+		UpdatedAt:   r.UpdatedAt,
+	}
+
+	for i, permission := range r.Permissions {
+		response.Permissions[i] = permission.Code
+	}
+
+	return response
+}
+
+// BuiltInRole describes a role created by migrations
+type BuiltInRole struct {
+	Name        string
+	DisplayName string
+	Description string
+	Permissions []string
+	IsSystem    bool
+}
+
+// BuiltInRoles are created on startup. System roles are reset to these
+// permissions every time; the staff roles are only created if missing, so
+// admins can tailor them.
+var BuiltInRoles = []BuiltInRole{
+	{
+		Name:        RoleCustomer,
+		DisplayName: "Customer",
+		Description: "Shop customer without access to the admin area",
+		IsSystem:    true,
+	},
+	{
+		Name:        RoleAdmin,
+		DisplayName: "Administrator",
+		Description: "Full access to the admin area",
+		Permissions: allPermissionCodes(),
+		IsSystem:    true,
+	},
+	{
+		Name:        RoleWarehouseStaff,
+		DisplayName: "Warehouse staff",
+		Description: "Picks, packs and ships orders and keeps stock counts right",
+		Permissions: []string{
+			PermissionOrdersRead,
+			PermissionOrdersUpdate,
+			PermissionInventoryRead,
+			PermissionInventoryWrite,
+		},
+	},
+	{
+		Name:        RoleCustomerSupport,
+		DisplayName: "Customer support",
+		Description: "Helps customers with their orders and refunds",
+		Permissions: []string{
+			PermissionUsersRead,
+			PermissionOrdersRead,
+			PermissionOrdersUpdate,
+			PermissionRefundsManage,
+		},
+	},
+	{
+		Name:        RoleContentEditor,
+		DisplayName: "Content editor",
+		Description: "Maintains the catalogue and promotions",
+		Permissions: []string{
+			PermissionProductsWrite,
+			PermissionCategoriesWrite,
+			PermissionPromotionsManage,
+			PermissionInventoryRead,
+		},
+	},
+}
+
+func allPermissionCodes() []string {
+	codes := make([]string, 0, len(PermissionDescriptions))
+	for code := range PermissionDescriptions {
+		codes = append(codes, code)
+	}
+	return codes
+}
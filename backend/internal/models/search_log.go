@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+)
+
+// SearchLog records a product search a shopper ran. It feeds the popular
+// query suggestions and the admin search reports.
+type SearchLog struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	Query       string    `gorm:"size:255;not null" json:"query"` // lowercased, spaces collapsed
+	ResultCount int64     `gorm:"not null;default:0" json:"result_count"`
+	CreatedAt   time.Time `gorm:"index" json:"created_at"`
+}
+
+// TableName specifies the table name for SearchLog
+func (SearchLog) TableName() string {
+	return "search_logs"
+}
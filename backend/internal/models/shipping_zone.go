@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+)
+
+// ShippingZone is a shipping fee for a province, or for one district within it.
+// An empty District applies to the whole province.
+type ShippingZone struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Province  string    `gorm:"size:100;not null;uniqueIndex:idx_shipping_zone_area" json:"province" binding:"required"`
+	District  string    `gorm:"size:100;not null;default:'';uniqueIndex:idx_shipping_zone_area" json:"district"`
+	Fee       float64   `gorm:"type:decimal(10,2);not null" json:"fee" binding:"min=0"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName specifies the table name for ShippingZone
+func (ShippingZone) TableName() string {
+	return "shipping_zones"
+}
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"time"
+)
+
+type ReservationStatus string
+
+const (
+	ReservationStatusActive    ReservationStatus = "active"
+	ReservationStatusCommitted ReservationStatus = "committed"
+	ReservationStatusReleased  ReservationStatus = "released"
+)
+
+// StockReservation holds variant stock for an unpaid order item until the
+// payment succeeds (committed) or fails/expires (released)
+type StockReservation struct {
+	ID          uint              `gorm:"primarykey" json:"id"`
+	OrderID     uint              `gorm:"not null;index" json:"order_id"`
+	OrderItemID uint              `gorm:"not null;index" json:"order_item_id"`
+	VariantID   uint              `gorm:"not null;index:idx_stock_reservations_variant_status" json:"variant_id"`
+	Quantity    int               `gorm:"not null" json:"quantity"`
+	Status      ReservationStatus `gorm:"type:varchar(20);not null;default:'active';index:idx_stock_reservations_variant_status" json:"status"`
+	ExpiresAt   time.Time         `gorm:"not null;index" json:"expires_at"`
+	ResolvedAt  *time.Time        `json:"resolved_at,omitempty"`
+	Reason      string            `gorm:"type:varchar(100)" json:"reason,omitempty"`
+	CreatedAt   time.Time         `json:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+}
+
+// TableName specifies the table name for StockReservation
+func (StockReservation) TableName() string {
+	return "stock_reservations"
+}
+
+// StockReservationResponse is the DTO for stock reservation responses
+type StockReservationResponse struct {
+	ID          uint              `json:"id"`
+	OrderID     uint              `json:"order_id"`
+	OrderItemID uint              `json:"order_item_id"`
+	VariantID   uint              `json:"variant_id"`
+	Quantity    int               `json:"quantity"`
+	Status      ReservationStatus `json:"status"`
+	ExpiresAt   time.Time         `json:"expires_at"`
+	ResolvedAt  *time.Time        `json:"resolved_at,omitempty"`
+	Reason      string            `json:"reason,omitempty"`
+	CreatedAt   time.Time         `json:"created_at"`
+}
+
+// ToResponse converts StockReservation to StockReservationResponse
+func (r *StockReservation) ToResponse() StockReservationResponse {
+	return StockReservationResponse{
+		ID:          r.ID,
+		OrderID:     r.OrderID,
+		OrderItemID: r.OrderItemID,
+		VariantID:   r.VariantID,
+		Quantity:    r.Quantity,
+		Status:      r.Status,
+		ExpiresAt:   r.ExpiresAt,
+		ResolvedAt:  r.ResolvedAt,
+		Reason:      r.Reason,
+		CreatedAt:   r.CreatedAt,
+	}
+}
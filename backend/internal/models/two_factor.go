@@ -0,0 +1,54 @@
+package models
+
+import (
+	"time"
+)
+
+// TwoFactorAuth is a user's TOTP authenticator. The secret is stored
+// encrypted because it must be read back to check codes. EnabledAt stays nil
+// until the user confirms enrolment with a first valid code.
+type TwoFactorAuth struct {
+	ID           uint       `gorm:"primarykey" json:"id"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	UserID       uint       `gorm:"not null;uniqueIndex" json:"user_id"`
+	Secret       string     `gorm:"type:varchar(255);not null" json:"-"`
+	EnabledAt    *time.Time `json:"enabled_at,omitempty"`
+	LastUsedStep int64      `gorm:"not null;default:0" json:"-"` // time step of the last accepted code; codes are single use
+}
+
+// TableName specifies the table name for TwoFactorAuth
+func (TwoFactorAuth) TableName() string {
+	return "two_factor_auths"
+}
+
+// TwoFactorBackupCode is a single-use code for when the authenticator is not
+// at hand. Only the SHA-256 hash of the code is stored.
+type TwoFactorBackupCode struct {
+	ID        uint       `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	UserID    uint       `gorm:"not null;index" json:"user_id"`
+	CodeHash  string     `gorm:"type:varchar(64);not null" json:"-"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+}
+
+// TableName specifies the table name for TwoFactorBackupCode
+func (TwoFactorBackupCode) TableName() string {
+	return "two_factor_backup_codes"
+}
+
+// TwoFactorChallenge is a login that passed the password check and waits for
+// the second factor. The client holds the token; only its hash is stored.
+type TwoFactorChallenge struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UserID    uint      `gorm:"not null;index" json:"user_id"`
+	TokenHash string    `gorm:"type:varchar(64);uniqueIndex;not null" json:"-"`
+	Attempts  int       `gorm:"not null;default:0" json:"attempts"`
+	ExpiresAt time.Time `gorm:"not null;index" json:"expires_at"`
+}
+
+// TableName specifies the table name for TwoFactorChallenge
+func (TwoFactorChallenge) TableName() string {
+	return "two_factor_challenges"
+}
@@ -8,16 +8,18 @@ import (
 
 // User represents a user in the system (customer or admin)
 type User struct {
-	ID        uint           `gorm:"primarykey" json:"id"`
-	Email     string         `gorm:"uniqueIndex;not null" json:"email" validate:"required,email"`
-	Password  string         `gorm:"not null" json:"-"` // Never expose password in JSON
-	FullName  string         `gorm:"not null" json:"full_name" validate:"required"`
-	Phone     string         `json:"phone"`
-	Role      string         `gorm:"not null;default:'customer'" json:"role"` // customer, admin
-	IsActive  bool           `gorm:"default:true" json:"is_active"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+	ID           uint           `gorm:"primarykey" json:"id"`
+	Email        string         `gorm:"uniqueIndex;not null" json:"email" validate:"required,email"`
+	Password     string         `gorm:"not null" json:"-"` // Never expose password in JSON
+	FullName     string         `gorm:"not null" json:"full_name" validate:"required"`
+	Phone        string         `json:"phone"`
+	Role         string         `gorm:"not null;default:'customer'" json:"role"` // name of a Role, e.g. customer, admin
+	IsActive     bool           `gorm:"default:true" json:"is_active"`
+	TokenVersion int            `gorm:"not null;default:0" json:"-"` // Bumped to revoke every issued access token
+	VerifiedAt   *time.Time     `json:"verified_at,omitempty"`       // When the email address was confirmed
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
 // TableName specifies the table name for User model
@@ -33,6 +35,7 @@ type UserResponse struct {
 	Phone     string    `json:"phone"`
 	Role      string    `json:"role"`
 	IsActive  bool      `json:"is_active"`
+	Verified  bool      `json:"email_verified"`
 	CreatedAt time.Time `json:"created_at"`
 }
 
@@ -45,18 +48,23 @@ func (u *User) ToResponse() UserResponse {
 		Phone:     u.Phone,
 		Role:      u.Role,
 		IsActive:  u.IsActive,
+		Verified:  u.VerifiedAt != nil,
 		CreatedAt: u.CreatedAt,
 	}
 }
 
-// PasswordResetCode stores password reset verification codes
+// PasswordResetCode stores password reset verification codes. Only a bcrypt
+// hash of the code is kept; a code is bound to one user and stops working
+// once used, expired or guessed wrong too many times.
 type PasswordResetCode struct {
 	ID        uint      `gorm:"primarykey" json:"id"`
-	UserID    uint      `gorm:"not null" json:"user_id"`
-	Code      string    `gorm:"not null" json:"code"`
+	UserID    uint      `gorm:"not null;index" json:"user_id"`
+	CodeHash  string    `gorm:"type:varchar(100);not null" json:"-"`
+	Attempts  int       `gorm:"not null;default:0" json:"attempts"`
+	IPAddress string    `gorm:"type:varchar(45);index" json:"ip_address"`
 	ExpiresAt time.Time `gorm:"not null" json:"expires_at"`
 	Used      bool      `gorm:"default:false" json:"used"`
-	CreatedAt time.Time `json:"created_at"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
 }
 
 // TableName specifies the table name for PasswordResetCode
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+)
+
+// UserIdentity links a user to an account at an external identity provider
+// (Google, Facebook, ...). A provider account is linked to at most one user.
+type UserIdentity struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	UserID    uint      `gorm:"not null;index" json:"user_id"`
+	Provider  string    `gorm:"type:varchar(30);not null;uniqueIndex:idx_user_identity_subject" json:"provider"`
+	Subject   string    `gorm:"type:varchar(255);not null;uniqueIndex:idx_user_identity_subject" json:"-"` // account ID at the provider
+	Email     string    `json:"email"`                                                                     // email reported by the provider at the last login
+}
+
+// TableName specifies the table name for UserIdentity
+func (UserIdentity) TableName() string {
+	return "user_identities"
+}
+
+// OAuthState is a social login in progress. It ties the provider's callback
+// to the browser that started it (State, stored hashed) and keeps the PKCE
+// verifier and ID token nonce until the code is exchanged.
+type OAuthState struct {
+	ID           uint      `gorm:"primarykey" json:"id"`
+	CreatedAt    time.Time `json:"created_at"`
+	StateHash    string    `gorm:"type:varchar(64);uniqueIndex;not null" json:"-"`
+	Provider     string    `gorm:"type:varchar(30);not null" json:"provider"`
+	CodeVerifier string    `gorm:"type:varchar(128);not null" json:"-"`
+	Nonce        string    `gorm:"type:varchar(64);not null" json:"-"`
+	ExpiresAt    time.Time `gorm:"not null;index" json:"expires_at"`
+}
+
+// TableName specifies the table name for OAuthState
+func (OAuthState) TableName() string {
+	return "oauth_states"
+}
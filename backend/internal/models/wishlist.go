@@ -0,0 +1,106 @@
+package models
+
+import (
+	"time"
+)
+
+// Wishlist is a product a user saved for later, optionally with the
+// variant they had picked
+type Wishlist struct {
+	ID        uint            `gorm:"primaryKey" json:"id"`
+	UserID    uint            `gorm:"not null;uniqueIndex:idx_wishlist_user_product" json:"user_id"`
+	ProductID uint            `gorm:"not null;uniqueIndex:idx_wishlist_user_product;index" json:"product_id"`
+	Product   *Product        `gorm:"foreignKey:ProductID;constraint:OnDelete:CASCADE" json:"product,omitempty"`
+	VariantID *uint           `gorm:"index" json:"variant_id,omitempty"`
+	Variant   *ProductVariant `gorm:"foreignKey:VariantID;constraint:OnDelete:SET NULL" json:"variant,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// RestockSubscription asks for an email when an out-of-stock variant is
+// back in stock. It is deleted once the email is sent.
+type RestockSubscription struct {
+	ID        uint            `gorm:"primaryKey" json:"id"`
+	UserID    uint            `gorm:"not null;uniqueIndex:idx_restock_user_variant" json:"user_id"`
+	User      *User           `gorm:"foreignKey:UserID" json:"-"`
+	VariantID uint            `gorm:"not null;uniqueIndex:idx_restock_user_variant;index" json:"variant_id"`
+	Variant   *ProductVariant `gorm:"foreignKey:VariantID;constraint:OnDelete:CASCADE" json:"variant,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// WishlistResponse is the response DTO for a wishlist entry
+type WishlistResponse struct {
+	ID        uint                    `json:"id"`
+	ProductID uint                    `json:"product_id"`
+	Product   *ProductResponse        `json:"product,omitempty"`
+	VariantID *uint                   `json:"variant_id,omitempty"`
+	Variant   *ProductVariantResponse `json:"variant,omitempty"`
+	InStock   bool                    `json:"in_stock"`
+	CreatedAt string                  `json:"created_at"`
+}
+
+// RestockSubscriptionResponse is the response DTO for a restock subscription
+type RestockSubscriptionResponse struct {
+	ID        uint                    `json:"id"`
+	VariantID uint                    `json:"variant_id"`
+	Variant   *ProductVariantResponse `json:"variant,omitempty"`
+	CreatedAt string                  `json:"created_at"`
+}
+
+// ToResponse converts Wishlist to WishlistResponse. An entry is in stock
+// when its variant, or any variant if none was picked, has stock left.
+func (w *Wishlist) ToResponse() WishlistResponse {
+	response := WishlistResponse{
+		ID:        w.ID,
+		ProductID: w.ProductID,
+		VariantID: w.VariantID,
+		CreatedAt: w.CreatedAt.Format(time.RFC3339),
+	}
+
+	if w.Product != nil {
+		prod := w.Product.ToResponse()
+		response.Product = &prod
+		if w.VariantID == nil {
+			for _, v := range w.Product.Variants {
+				if v.StockQuantity > 0 {
+					response.InStock = true
+					break
+				}
+			}
+		}
+	}
+
+	if w.Variant != nil {
+		variant := w.Variant.ToResponse()
+		response.Variant = &variant
+		response.InStock = w.Variant.StockQuantity > 0
+	}
+
+	return response
+}
+
+// ToResponse converts RestockSubscription to RestockSubscriptionResponse
+func (r *RestockSubscription) ToResponse() RestockSubscriptionResponse {
+	response := RestockSubscriptionResponse{
+		ID:        r.ID,
+		VariantID: r.VariantID,
+		CreatedAt: r.CreatedAt.Format(time.RFC3339),
+	}
+
+	if r.Variant != nil {
+		variant := r.Variant.ToResponse()
+		response.Variant = &variant
+	}
+
+	return response
+}
+
+// TableName specifies the table name for Wishlist
+func (Wishlist) TableName() string {
+	return "wishlists"
+}
+
+// TableName specifies the table name for RestockSubscription
+func (RestockSubscription) TableName() string {
+	return "restock_subscriptions"
+}
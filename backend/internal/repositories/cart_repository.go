@@ -1,6 +1,8 @@
 package repositories
 
 import (
+	"time"
+
 	"github.com/huy1235588/fashion-e-commerce/internal/models"
 	"gorm.io/gorm"
 )
@@ -8,8 +10,15 @@ import (
 // CartRepository defines the interface for cart data access
 type CartRepository interface {
 	GetByUserID(userID uint) (*models.Cart, error)
+	ListByUserID(userID uint) ([]models.Cart, error)
+	FindByName(userID uint, name string) (*models.Cart, error)
+	GetByID(id uint) (*models.Cart, error)
+	GetByGuestKey(guestKey string) (*models.Cart, error)
 	Create(cart *models.Cart) error
 	Update(cart *models.Cart) error
+	Touch(cartID uint) error
+	Rename(cartID uint, name string) error
+	Delete(cartID uint) error
 	
 	// Cart item operations
 	AddItem(item *models.CartItem) error
@@ -17,7 +26,10 @@ type CartRepository interface {
 	RemoveItem(itemID uint) error
 	FindItemByID(itemID uint) (*models.CartItem, error)
 	FindItemByVariant(cartID uint, variantID uint) (*models.CartItem, error)
+	MoveItem(itemID uint, cartID uint) error
 	ClearCart(cartID uint) error
+	DeleteGuestCart(cartID uint) (bool, error)
+	DeleteGuestCartsBefore(before time.Time) (int64, error)
 }
 
 type cartRepository struct {
@@ -29,12 +41,44 @@ func NewCartRepository(db *gorm.DB) CartRepository {
 	return &cartRepository{db: db}
 }
 
+// GetByUserID finds the user's primary cart
 func (r *cartRepository) GetByUserID(userID uint) (*models.Cart, error) {
-	var cart models.Cart
+	return r.findOne("user_id = ? AND name = ?", userID, "")
+}
+
+// ListByUserID returns the user's primary cart and saved lists, the
+// primary cart first
+func (r *cartRepository) ListByUserID(userID uint) ([]models.Cart, error) {
+	var carts []models.Cart
 	err := r.db.Preload("Items.Product.Images").
 		Preload("Items.Product.Category").
 		Preload("Items.Variant").
 		Where("user_id = ?", userID).
+		Order("name").
+		Find(&carts).Error
+	return carts, err
+}
+
+// FindByName finds one of the user's saved lists by name
+func (r *cartRepository) FindByName(userID uint, name string) (*models.Cart, error) {
+	return r.findOne("user_id = ? AND name = ?", userID, name)
+}
+
+func (r *cartRepository) GetByID(id uint) (*models.Cart, error) {
+	return r.findOne("id = ?", id)
+}
+
+// GetByGuestKey finds the anonymous cart with the given guest key
+func (r *cartRepository) GetByGuestKey(guestKey string) (*models.Cart, error) {
+	return r.findOne("guest_key = ?", guestKey)
+}
+
+func (r *cartRepository) findOne(query string, args ...interface{}) (*models.Cart, error) {
+	var cart models.Cart
+	err := r.db.Preload("Items.Product.Images").
+		Preload("Items.Product.Category").
+		Preload("Items.Variant").
+		Where(query, args...).
 		First(&cart).Error
 	
 	if err != nil {
@@ -51,6 +95,23 @@ func (r *cartRepository) Update(cart *models.Cart) error {
 	return r.db.Save(cart).Error
 }
 
+// Touch marks a cart as changed now, so active guest carts are not cleaned up
+func (r *cartRepository) Touch(cartID uint) error {
+	return r.db.Model(&models.Cart{}).Where("id = ?", cartID).Update("updated_at", time.Now()).Error
+}
+
+func (r *cartRepository) Rename(cartID uint, name string) error {
+	return r.db.Model(&models.Cart{}).Where("id = ?", cartID).Update("name", name).Error
+}
+
+// Delete deletes a cart and its items
+func (r *cartRepository) Delete(cartID uint) error {
+	if err := r.db.Where("cart_id = ?", cartID).Delete(&models.CartItem{}).Error; err != nil {
+		return err
+	}
+	return r.db.Delete(&models.Cart{}, cartID).Error
+}
+
 func (r *cartRepository) AddItem(item *models.CartItem) error {
 	return r.db.Create(item).Error
 }
@@ -84,6 +145,33 @@ func (r *cartRepository) FindItemByVariant(cartID uint, variantID uint) (*models
 	return &item, nil
 }
 
+// MoveItem moves a line to another cart as it is
+func (r *cartRepository) MoveItem(itemID uint, cartID uint) error {
+	return r.db.Model(&models.CartItem{}).Where("id = ?", itemID).Update("cart_id", cartID).Error
+}
+
 func (r *cartRepository) ClearCart(cartID uint) error {
 	return r.db.Where("cart_id = ?", cartID).Delete(&models.CartItem{}).Error
 }
+
+// DeleteGuestCart deletes an anonymous cart and its items, and reports
+// whether it was still there
+func (r *cartRepository) DeleteGuestCart(cartID uint) (bool, error) {
+	if err := r.db.Where("cart_id = ?", cartID).Delete(&models.CartItem{}).Error; err != nil {
+		return false, err
+	}
+	result := r.db.Where("id = ? AND user_id IS NULL", cartID).Delete(&models.Cart{})
+	return result.RowsAffected > 0, result.Error
+}
+
+// DeleteGuestCartsBefore deletes anonymous carts, and their items, that
+// have not been touched since the given time
+func (r *cartRepository) DeleteGuestCartsBefore(before time.Time) (int64, error) {
+	stale := r.db.Model(&models.Cart{}).Select("id").
+		Where("user_id IS NULL AND updated_at < ?", before)
+	if err := r.db.Where("cart_id IN (?)", stale).Delete(&models.CartItem{}).Error; err != nil {
+		return 0, err
+	}
+	result := r.db.Where("user_id IS NULL AND updated_at < ?", before).Delete(&models.Cart{})
+	return result.RowsAffected, result.Error
+}
@@ -3,8 +3,27 @@ package repositories
 import (
 	"github.com/huy1235588/fashion-e-commerce/internal/models"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// categorySubtreeSQL selects the ID of a category and of all categories
+// below it. UNION rather than UNION ALL stops at a cycle.
+const categorySubtreeSQL = `WITH RECURSIVE subtree AS (
+	SELECT id FROM categories WHERE id = ?
+	UNION
+	SELECT categories.id FROM categories JOIN subtree ON categories.parent_id = subtree.id
+) SELECT id FROM subtree`
+
+// categoryAncestorsSQL selects a category and its ancestors, root first
+const categoryAncestorsSQL = `WITH RECURSIVE ancestors AS (
+	SELECT categories.*, 0 AS depth FROM categories WHERE id = ?
+	UNION ALL
+	SELECT categories.*, ancestors.depth + 1 FROM categories
+	JOIN ancestors ON categories.id = ancestors.parent_id
+	WHERE ancestors.depth < 32
+) SELECT id, parent_id, name, description, slug, position, created_at, updated_at
+FROM ancestors ORDER BY depth DESC`
+
 // CategoryRepository defines the interface for category data access
 type CategoryRepository interface {
 	Create(category *models.Category) error
@@ -12,7 +31,16 @@ type CategoryRepository interface {
 	FindBySlug(slug string) (*models.Category, error)
 	Update(category *models.Category) error
 	Delete(id uint) error
+	DeleteIfUnused(id uint) (bool, error)
+	DeleteAndReassign(id, toID uint) error
 	List() ([]models.Category, error)
+
+	// Hierarchy
+	Ancestors(id uint) ([]models.Category, error)
+	SubtreeIDs(id uint) ([]uint, error)
+	CountChildren(id uint) (int64, error)
+	CountProducts(id uint) (int64, error)
+	CountPromotions(id uint) (int64, error)
 }
 
 type categoryRepository struct {
@@ -54,8 +82,105 @@ func (r *categoryRepository) Delete(id uint) error {
 	return r.db.Delete(&models.Category{}, id).Error
 }
 
+// DeleteIfUnused deletes a category that has no subcategories, products or
+// promotions scoped to it, and reports whether it did. The category row is
+// locked first, so nothing can be added under it between the checks and the
+// delete.
+func (r *categoryRepository) DeleteIfUnused(id uint) (bool, error) {
+	deleted := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockCategory(tx, id); err != nil {
+			return err
+		}
+
+		repo := NewCategoryRepository(tx)
+		for _, count := range []func(uint) (int64, error){repo.CountChildren, repo.CountProducts, repo.CountPromotions} {
+			n, err := count(id)
+			if err != nil || n > 0 {
+				return err
+			}
+		}
+
+		deleted = true
+		return tx.Delete(&models.Category{}, id).Error
+	})
+	return deleted, err
+}
+
+// DeleteAndReassign moves the subcategories, products and promotion scopes
+// of a category to another category, then deletes it
+func (r *categoryRepository) DeleteAndReassign(id, toID uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockCategory(tx, id); err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Category{}).Where("parent_id = ?", id).Update("parent_id", toID).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Product{}).Where("category_id = ?", id).Update("category_id", toID).Error; err != nil {
+			return err
+		}
+
+		// A promotion may already target the new category
+		if err := tx.Exec(`INSERT INTO promotion_categories (promotion_id, category_id)
+			SELECT promotion_id, ? FROM promotion_categories WHERE category_id = ?
+			ON CONFLICT DO NOTHING`, toID, id).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM promotion_categories WHERE category_id = ?", id).Error; err != nil {
+			return err
+		}
+
+		return tx.Delete(&models.Category{}, id).Error
+	})
+}
+
+// lockCategory locks a category row for the rest of the transaction. Rows
+// referencing it cannot be inserted until the transaction ends.
+func lockCategory(tx *gorm.DB, id uint) error {
+	var category models.Category
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&category, id).Error
+}
+
+// List returns all categories, siblings in their set order
 func (r *categoryRepository) List() ([]models.Category, error) {
 	var categories []models.Category
-	err := r.db.Order("name ASC").Find(&categories).Error
+	err := r.db.Order("position ASC, name ASC").Find(&categories).Error
 	return categories, err
 }
+
+// Ancestors returns the path from the root category down to a category,
+// the category itself last
+func (r *categoryRepository) Ancestors(id uint) ([]models.Category, error) {
+	var categories []models.Category
+	err := r.db.Raw(categoryAncestorsSQL, id).Scan(&categories).Error
+	return categories, err
+}
+
+// SubtreeIDs returns the ID of a category and of every category below it
+func (r *categoryRepository) SubtreeIDs(id uint) ([]uint, error) {
+	var ids []uint
+	err := r.db.Raw(categorySubtreeSQL, id).Scan(&ids).Error
+	return ids, err
+}
+
+// CountChildren returns how many categories sit directly under a category
+func (r *categoryRepository) CountChildren(id uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.Category{}).Where("parent_id = ?", id).Count(&count).Error
+	return count, err
+}
+
+// CountProducts returns how many products are directly in a category
+func (r *categoryRepository) CountProducts(id uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.Product{}).Where("category_id = ?", id).Count(&count).Error
+	return count, err
+}
+
+// CountPromotions returns how many promotions are scoped to a category
+func (r *categoryRepository) CountPromotions(id uint) (int64, error) {
+	var count int64
+	err := r.db.Table("promotion_categories").Where("category_id = ?", id).Count(&count).Error
+	return count, err
+}
@@ -0,0 +1,83 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"github.com/huy1235588/fashion-e-commerce/internal/models"
+	"gorm.io/gorm"
+)
+
+// EmailVerificationRepository handles database operations for email verification tokens
+type EmailVerificationRepository interface {
+	Create(token *models.EmailVerificationToken) error
+	FindByHash(tokenHash string) (*models.EmailVerificationToken, error)
+	FindLatestForUser(userID uint) (*models.EmailVerificationToken, error)
+	CountSince(userID uint, since time.Time) (int64, error)
+	MarkUsed(id uint) error
+	InvalidateForUser(userID uint) error
+}
+
+type emailVerificationRepository struct {
+	db *gorm.DB
+}
+
+// NewEmailVerificationRepository creates a new email verification repository
+func NewEmailVerificationRepository(db *gorm.DB) EmailVerificationRepository {
+	return &emailVerificationRepository{db: db}
+}
+
+// Create creates a new verification token
+func (r *emailVerificationRepository) Create(token *models.EmailVerificationToken) error {
+	return r.db.Create(token).Error
+}
+
+// FindByHash finds an unused, unexpired verification token by its hash
+func (r *emailVerificationRepository) FindByHash(tokenHash string) (*models.EmailVerificationToken, error) {
+	var token models.EmailVerificationToken
+	err := r.db.Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", tokenHash, time.Now()).
+		First(&token).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("invalid or expired verification link")
+		}
+		return nil, err
+	}
+	return &token, nil
+}
+
+// FindLatestForUser returns the most recently issued token of a user, or nil if there is none
+func (r *emailVerificationRepository) FindLatestForUser(userID uint) (*models.EmailVerificationToken, error) {
+	var token models.EmailVerificationToken
+	err := r.db.Where("user_id = ?", userID).Order("created_at DESC").First(&token).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &token, nil
+}
+
+// CountSince counts the tokens issued to a user since the given time
+func (r *emailVerificationRepository) CountSince(userID uint, since time.Time) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.EmailVerificationToken{}).
+		Where("user_id = ? AND created_at >= ?", userID, since).
+		Count(&count).Error
+	return count, err
+}
+
+// MarkUsed marks a token as used
+func (r *emailVerificationRepository) MarkUsed(id uint) error {
+	return r.db.Model(&models.EmailVerificationToken{}).
+		Where("id = ?", id).
+		Update("used_at", time.Now()).Error
+}
+
+// InvalidateForUser marks every outstanding token of a user as used
+func (r *emailVerificationRepository) InvalidateForUser(userID uint) error {
+	return r.db.Model(&models.EmailVerificationToken{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Update("used_at", time.Now()).Error
+}
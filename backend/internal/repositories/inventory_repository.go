@@ -0,0 +1,80 @@
+package repositories
+
+import (
+	"github.com/huy1235588/fashion-e-commerce/internal/models"
+	"gorm.io/gorm"
+)
+
+// InventoryReconciliation compares a variant's stock with the sum of its ledger
+type InventoryReconciliation struct {
+	VariantID     uint   `json:"variant_id"`
+	ProductID     uint   `json:"product_id"`
+	SKU           string `json:"sku"`
+	StockQuantity int    `json:"stock_quantity"`
+	LedgerTotal   int    `json:"ledger_total"`
+	Difference    int    `json:"difference"`
+}
+
+// InventoryRepository defines the interface for inventory ledger data access.
+// Movements are append-only: there is no update or delete.
+type InventoryRepository interface {
+	CreateMovement(movement *models.InventoryMovement) error
+	FindMovementsByVariant(variantID uint, limit, offset int) ([]models.InventoryMovement, int64, error)
+	Reconcile(variantID *uint, mismatchedOnly bool) ([]InventoryReconciliation, error)
+}
+
+type inventoryRepository struct {
+	db *gorm.DB
+}
+
+// NewInventoryRepository creates a new inventory repository
+func NewInventoryRepository(db *gorm.DB) InventoryRepository {
+	return &inventoryRepository{db: db}
+}
+
+func (r *inventoryRepository) CreateMovement(movement *models.InventoryMovement) error {
+	return r.db.Create(movement).Error
+}
+
+func (r *inventoryRepository) FindMovementsByVariant(variantID uint, limit, offset int) ([]models.InventoryMovement, int64, error) {
+	var movements []models.InventoryMovement
+	var total int64
+
+	query := r.db.Model(&models.InventoryMovement{}).Where("variant_id = ?", variantID)
+
+	// Count total
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	// Get paginated results
+	err := query.
+		Order("id DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&movements).Error
+
+	return movements, total, err
+}
+
+// Reconcile sums the ledger per variant and compares it with stock_quantity
+func (r *inventoryRepository) Reconcile(variantID *uint, mismatchedOnly bool) ([]InventoryReconciliation, error) {
+	var results []InventoryReconciliation
+
+	query := r.db.Table("product_variants").
+		Select("product_variants.id as variant_id, product_variants.product_id, product_variants.sku, product_variants.stock_quantity, " +
+			"COALESCE(SUM(inventory_movements.delta), 0) as ledger_total, " +
+			"product_variants.stock_quantity - COALESCE(SUM(inventory_movements.delta), 0) as difference").
+		Joins("LEFT JOIN inventory_movements ON inventory_movements.variant_id = product_variants.id").
+		Group("product_variants.id, product_variants.product_id, product_variants.sku, product_variants.stock_quantity")
+
+	if variantID != nil {
+		query = query.Where("product_variants.id = ?", *variantID)
+	}
+	if mismatchedOnly {
+		query = query.Having("product_variants.stock_quantity <> COALESCE(SUM(inventory_movements.delta), 0)")
+	}
+
+	err := query.Order("product_variants.id ASC").Scan(&results).Error
+	return results, err
+}
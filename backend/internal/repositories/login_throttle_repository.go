@@ -0,0 +1,156 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"github.com/huy1235588/fashion-e-commerce/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LoginThrottleRepository handles database operations for login throttles
+type LoginThrottleRepository interface {
+	Find(scope models.LoginThrottleScope, identifier string) (*models.LoginThrottle, error)
+	FindByID(id uint) (*models.LoginThrottle, error)
+	FindByUnlockToken(tokenHash string) (*models.LoginThrottle, error)
+	RecordFailure(scope models.LoginThrottleScope, identifier string, windowStart time.Time) (*models.LoginThrottle, error)
+	Lock(id uint, until time.Time, unlockTokenHash string) (bool, error)
+	Delete(id uint) error
+	DeleteByKey(scope models.LoginThrottleScope, identifier string) error
+	DeleteStale(before time.Time) (int64, error)
+	List(lockedOnly bool, scope string, limit, offset int) ([]models.LoginThrottle, int64, error)
+}
+
+type loginThrottleRepository struct {
+	db *gorm.DB
+}
+
+// NewLoginThrottleRepository creates a new login throttle repository
+func NewLoginThrottleRepository(db *gorm.DB) LoginThrottleRepository {
+	return &loginThrottleRepository{db: db}
+}
+
+// Find finds the throttle of an email or IP address, or returns nil if there is none
+func (r *loginThrottleRepository) Find(scope models.LoginThrottleScope, identifier string) (*models.LoginThrottle, error) {
+	var throttle models.LoginThrottle
+	err := r.db.Where("scope = ? AND identifier = ?", scope, identifier).First(&throttle).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &throttle, nil
+}
+
+// FindByID finds a throttle by ID
+func (r *loginThrottleRepository) FindByID(id uint) (*models.LoginThrottle, error) {
+	var throttle models.LoginThrottle
+	err := r.db.First(&throttle, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("lockout not found")
+		}
+		return nil, err
+	}
+	return &throttle, nil
+}
+
+// FindByUnlockToken finds a locked account throttle by the hash of its unlock token
+func (r *loginThrottleRepository) FindByUnlockToken(tokenHash string) (*models.LoginThrottle, error) {
+	var throttle models.LoginThrottle
+	err := r.db.Where("unlock_token_hash = ? AND scope = ? AND locked_until > ?",
+		tokenHash, models.LoginThrottleScopeAccount, time.Now()).
+		First(&throttle).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("invalid or expired unlock link")
+		}
+		return nil, err
+	}
+	return &throttle, nil
+}
+
+// RecordFailure atomically counts a failed login. The count starts over when
+// the previous failure is older than windowStart or an earlier lock has run out.
+func (r *loginThrottleRepository) RecordFailure(scope models.LoginThrottleScope, identifier string, windowStart time.Time) (*models.LoginThrottle, error) {
+	now := time.Now()
+	throttle := models.LoginThrottle{
+		Scope:        scope,
+		Identifier:   identifier,
+		FailedCount:  1,
+		LastFailedAt: now,
+	}
+
+	err := r.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "scope"}, {Name: "identifier"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"failed_count": gorm.Expr(
+				"CASE WHEN login_throttles.last_failed_at < ? OR login_throttles.locked_until <= ? THEN 1 ELSE login_throttles.failed_count + 1 END",
+				windowStart, now),
+			"locked_until":   gorm.Expr("CASE WHEN login_throttles.locked_until <= ? THEN NULL ELSE login_throttles.locked_until END", now),
+			"last_failed_at": now,
+			"updated_at":     now,
+		}),
+	}).Create(&throttle).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return r.Find(scope, identifier)
+}
+
+// Lock locks a throttle until the given time. It reports false if the
+// throttle was already locked, so only one caller sends the lockout email.
+func (r *loginThrottleRepository) Lock(id uint, until time.Time, unlockTokenHash string) (bool, error) {
+	result := r.db.Model(&models.LoginThrottle{}).
+		Where("id = ? AND (locked_until IS NULL OR locked_until <= ?)", id, time.Now()).
+		Updates(map[string]interface{}{
+			"locked_until":      until,
+			"unlock_token_hash": unlockTokenHash,
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+// Delete deletes a throttle, clearing its failures and lock
+func (r *loginThrottleRepository) Delete(id uint) error {
+	return r.db.Delete(&models.LoginThrottle{}, id).Error
+}
+
+// DeleteByKey deletes the throttle of an email or IP address
+func (r *loginThrottleRepository) DeleteByKey(scope models.LoginThrottleScope, identifier string) error {
+	return r.db.Where("scope = ? AND identifier = ?", scope, identifier).
+		Delete(&models.LoginThrottle{}).Error
+}
+
+// DeleteStale deletes throttles with no failure since the given time and no active lock
+func (r *loginThrottleRepository) DeleteStale(before time.Time) (int64, error) {
+	result := r.db.Where("last_failed_at < ? AND (locked_until IS NULL OR locked_until < ?)", before, time.Now()).
+		Delete(&models.LoginThrottle{})
+	return result.RowsAffected, result.Error
+}
+
+// List returns throttles, newest failure first
+func (r *loginThrottleRepository) List(lockedOnly bool, scope string, limit, offset int) ([]models.LoginThrottle, int64, error) {
+	var throttles []models.LoginThrottle
+	var total int64
+
+	query := r.db.Model(&models.LoginThrottle{})
+	if lockedOnly {
+		query = query.Where("locked_until > ?", time.Now())
+	}
+	if scope != "" {
+		query = query.Where("scope = ?", scope)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	if err := query.Order("last_failed_at DESC").Limit(limit).Offset(offset).Find(&throttles).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return throttles, total, nil
+}
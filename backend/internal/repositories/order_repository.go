@@ -10,6 +10,8 @@ type OrderRepository interface {
 	FindByID(id uint) (*models.Order, error)
 	FindByOrderCode(orderCode string) (*models.Order, error)
 	FindByUserID(userID uint, limit, offset int) ([]models.Order, int64, error)
+	FindGuestOrder(orderCode, email string) (*models.Order, error)
+	ClaimGuestOrders(userID uint, email string) (int64, error)
 	List(filters map[string]interface{}, limit, offset int) ([]models.Order, int64, error)
 	UpdateStatus(id uint, status models.OrderStatus) error
 	UpdatePaymentStatus(id uint, paymentStatus models.PaymentStatus) error
@@ -77,6 +79,28 @@ func (r *orderRepository) FindByUserID(userID uint, limit, offset int) ([]models
 	return orders, total, err
 }
 
+// FindGuestOrder finds a guest order by its code and the email given at checkout
+func (r *orderRepository) FindGuestOrder(orderCode, email string) (*models.Order, error) {
+	var order models.Order
+	err := r.db.Preload("OrderItems.Product").
+		Preload("OrderItems.Variant").
+		Where("order_code = ? AND guest_email <> '' AND LOWER(guest_email) = LOWER(?)", orderCode, email).
+		First(&order).Error
+	if err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// ClaimGuestOrders moves the unclaimed guest orders placed with an email
+// address to the user who owns it
+func (r *orderRepository) ClaimGuestOrders(userID uint, email string) (int64, error) {
+	result := r.db.Model(&models.Order{}).
+		Where("user_id IS NULL AND guest_email <> '' AND LOWER(guest_email) = LOWER(?)", email).
+		Update("user_id", userID)
+	return result.RowsAffected, result.Error
+}
+
 func (r *orderRepository) List(filters map[string]interface{}, limit, offset int) ([]models.Order, int64, error) {
 	var orders []models.Order
 	var total int64
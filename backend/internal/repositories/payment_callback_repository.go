@@ -0,0 +1,35 @@
+package repositories
+
+import (
+	"github.com/huy1235588/fashion-e-commerce/internal/models"
+	"gorm.io/gorm"
+)
+
+// PaymentCallbackRepository defines the interface for gateway callback event data access
+type PaymentCallbackRepository interface {
+	Create(event *models.PaymentCallbackEvent) error
+	IsDelivered(gateway models.PaymentMethod, orderCode, transactionID, resultCode string) (bool, error)
+}
+
+type paymentCallbackRepository struct {
+	db *gorm.DB
+}
+
+// NewPaymentCallbackRepository creates a new payment callback repository
+func NewPaymentCallbackRepository(db *gorm.DB) PaymentCallbackRepository {
+	return &paymentCallbackRepository{db: db}
+}
+
+func (r *paymentCallbackRepository) Create(event *models.PaymentCallbackEvent) error {
+	return r.db.Create(event).Error
+}
+
+// IsDelivered reports whether the same callback was already accepted
+func (r *paymentCallbackRepository) IsDelivered(gateway models.PaymentMethod, orderCode, transactionID, resultCode string) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.PaymentCallbackEvent{}).
+		Where("gateway = ? AND order_code = ? AND transaction_id = ? AND result_code = ? AND outcome <> ?",
+			gateway, orderCode, transactionID, resultCode, models.CallbackOutcomeRejected).
+		Count(&count).Error
+	return count > 0, err
+}
@@ -1,6 +1,8 @@
 package repositories
 
 import (
+	"time"
+
 	"github.com/huy1235588/fashion-e-commerce/internal/models"
 	"gorm.io/gorm"
 )
@@ -10,10 +12,15 @@ type PaymentRepository interface {
 	FindByID(id uint) (*models.Payment, error)
 	FindByOrderID(orderID uint) (*models.Payment, error)
 	FindByTransactionID(transactionID string) (*models.Payment, error)
+	FindStalePending(before time.Time, limit int) ([]models.Payment, error)
+	FindOnlineCreatedBetween(from, to time.Time) ([]models.Payment, error)
 	UpdateStatus(id uint, status models.PaymentStatus) error
 	Update(payment *models.Payment) error
 }
 
+// onlinePaymentMethods are the methods confirmed by a payment gateway
+var onlinePaymentMethods = []models.PaymentMethod{models.PaymentMethodVNPay, models.PaymentMethodMoMo}
+
 type paymentRepository struct {
 	db *gorm.DB
 }
@@ -53,6 +60,29 @@ func (r *paymentRepository) FindByTransactionID(transactionID string) (*models.P
 	return &payment, nil
 }
 
+// FindStalePending returns online payments still pending that were last sent
+// to the gateway before the given time, oldest first
+func (r *paymentRepository) FindStalePending(before time.Time, limit int) ([]models.Payment, error) {
+	var payments []models.Payment
+	err := r.db.Where("payment_status = ? AND payment_method IN ?", models.PaymentStatusPending, onlinePaymentMethods).
+		Where("COALESCE(initiated_at, created_at) < ?", before).
+		Preload("Order").
+		Order("COALESCE(initiated_at, created_at) ASC").
+		Limit(limit).
+		Find(&payments).Error
+	return payments, err
+}
+
+// FindOnlineCreatedBetween returns online payments created in [from, to)
+func (r *paymentRepository) FindOnlineCreatedBetween(from, to time.Time) ([]models.Payment, error) {
+	var payments []models.Payment
+	err := r.db.Where("payment_method IN ? AND created_at >= ? AND created_at < ?", onlinePaymentMethods, from, to).
+		Preload("Order").
+		Order("created_at ASC").
+		Find(&payments).Error
+	return payments, err
+}
+
 func (r *paymentRepository) UpdateStatus(id uint, status models.PaymentStatus) error {
 	return r.db.Model(&models.Payment{}).Where("id = ?", id).Update("payment_status", status).Error
 }
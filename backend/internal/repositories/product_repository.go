@@ -1,8 +1,17 @@
 package repositories
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
 	"github.com/huy1235588/fashion-e-commerce/internal/models"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // ProductFilters represents filters for product listing
@@ -12,10 +21,217 @@ type ProductFilters struct {
 	MaxPrice     *float64
 	SearchQuery  string
 	IsActive     *bool
+	Sizes        []string // products with a variant in any of these sizes
+	Colors       []string // products with a variant in any of these colors
+	InStock      bool     // products with a matching variant in stock
+	OnSale       bool     // products with a discount price
+	MinRating    *float64 // minimum stored rating average, see RefreshSalesStats
+	Sort         string   // one of the Sort* options; defaults to relevance when searching, newest otherwise
 	Page         int
 	PageSize     int
 }
 
+// SearchConfig is the Postgres text search configuration used for product
+// search. It is the simple configuration with accents removed first, so
+// "ao thun" finds "Áo thun"; RunMigrations creates it.
+const SearchConfig = "vietnamese_unaccent"
+
+// refreshSearchVectorSQL rebuilds products.search_vector. Name matches rank
+// highest, then category, colors and SKUs, then the description.
+const refreshSearchVectorSQL = `UPDATE products SET search_vector =
+	setweight(to_tsvector('` + SearchConfig + `', products.name), 'A') ||
+	setweight(to_tsvector('` + SearchConfig + `', COALESCE((SELECT name FROM categories WHERE categories.id = products.category_id), '')), 'B') ||
+	setweight(to_tsvector('` + SearchConfig + `', COALESCE((SELECT string_agg(color || ' ' || sku, ' ') FROM product_variants WHERE product_variants.product_id = products.id), '')), 'B') ||
+	setweight(to_tsvector('` + SearchConfig + `', COALESCE(products.description, '')), 'C')`
+
+// effectivePriceSQL is the price a customer pays for a product: the
+// discount price when there is one, otherwise the regular price
+const effectivePriceSQL = "LEAST(products.price, products.discount_price)"
+
+// priceBucketBounds split the price facet into ranges, in VND
+var priceBucketBounds = []float64{200000, 500000, 1000000, 2000000}
+
+// FacetValue counts the products having one value of a variant attribute
+type FacetValue struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+// CategoryFacet counts the products in one category
+type CategoryFacet struct {
+	CategoryID uint   `json:"category_id"`
+	Name       string `json:"name"`
+	Count      int64  `json:"count"`
+}
+
+// PriceBucket counts the products whose effective price is at least Min
+// and below Max; a nil bound is open
+type PriceBucket struct {
+	Min   *float64 `json:"min"`
+	Max   *float64 `json:"max"`
+	Count int64    `json:"count"`
+}
+
+// ProductFacets are the filter options of a product listing with the
+// number of products each one would show. Each facet ignores its own
+// filter, so choosing a size still shows how many products the other
+// sizes have.
+type ProductFacets struct {
+	Sizes       []FacetValue    `json:"sizes"`
+	Colors      []FacetValue    `json:"colors"`
+	Categories  []CategoryFacet `json:"categories"`
+	PriceRanges []PriceBucket   `json:"price_ranges"`
+}
+
+// Product listing sort options
+const (
+	SortRelevance   = "relevance"
+	SortNewest      = "newest"
+	SortPriceAsc    = "price_asc"
+	SortPriceDesc   = "price_desc"
+	SortBestSelling = "best_selling"
+	SortTopRated    = "top_rated"
+)
+
+// ErrInvalidCursor is returned for a listing cursor that is malformed or
+// was issued for another sort order
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// productSort is how a listing is ordered: by a key, ties broken by ID in
+// the same direction so that (key, id) is a keyset for cursor pagination
+type productSort struct {
+	name string
+	key  string        // SQL of the sort key
+	vars []interface{} // arguments of key
+	cast string        // SQL type the key is compared as in a cursor
+	desc bool
+}
+
+// productListColumns are the columns of a listing row: the product without
+// its description plus its primary image and total stock
+const productListColumns = "products.id, products.category_id, products.name, products.price, products.discount_price, " +
+	"products.slug, products.is_active, products.sold_count, products.rating_average, products.review_count, " +
+	"products.created_at, products.updated_at, " +
+	"COALESCE((SELECT image_url FROM product_images WHERE product_images.product_id = products.id ORDER BY is_primary DESC, id LIMIT 1), '') AS image_url, " +
+	"COALESCE((SELECT SUM(stock_quantity) FROM product_variants WHERE product_variants.product_id = products.id), 0) AS total_stock"
+
+// refreshSalesStatsSQL recomputes the sold count from delivered orders and
+// the rating from approved reviews, writing only the rows that changed
+const refreshSalesStatsSQL = `UPDATE products SET
+	sold_count = stats.sold_count,
+	rating_average = stats.rating_average,
+	review_count = stats.review_count
+FROM (
+	SELECT products.id,
+		COALESCE(sales.sold_count, 0) AS sold_count,
+		COALESCE(ratings.rating_average, 0) AS rating_average,
+		COALESCE(ratings.review_count, 0) AS review_count
+	FROM products
+	LEFT JOIN (
+		SELECT order_items.product_id, SUM(order_items.quantity) AS sold_count
+		FROM order_items
+		JOIN orders ON orders.id = order_items.order_id
+		WHERE orders.status = 'delivered' AND orders.deleted_at IS NULL AND order_items.deleted_at IS NULL
+		GROUP BY order_items.product_id
+	) AS sales ON sales.product_id = products.id
+	LEFT JOIN (
+		SELECT product_id, ROUND(AVG(rating), 2) AS rating_average, COUNT(*) AS review_count
+		FROM reviews
+		WHERE status = 'approved'
+		GROUP BY product_id
+	) AS ratings ON ratings.product_id = products.id
+) AS stats
+WHERE products.id = stats.id
+	AND (products.sold_count, products.rating_average, products.review_count)
+		IS DISTINCT FROM (stats.sold_count, stats.rating_average, stats.review_count)`
+
+// ProductListItem is a product in a listing, without its description,
+// images and variants
+type ProductListItem struct {
+	Product    models.Product
+	ImageURL   string // primary image, or the first one
+	TotalStock int64
+	sortKey    string
+}
+
+// ToResponse converts ProductListItem to ProductListItemResponse
+func (i *ProductListItem) ToResponse() models.ProductListItemResponse {
+	response := models.ProductListItemResponse{
+		ID:            i.Product.ID,
+		CategoryID:    i.Product.CategoryID,
+		Name:          i.Product.Name,
+		Price:         i.Product.Price,
+		DiscountPrice: i.Product.DiscountPrice,
+		Slug:          i.Product.Slug,
+		ImageURL:      i.ImageURL,
+		TotalStock:    i.TotalStock,
+		InStock:       i.TotalStock > 0,
+		SoldCount:     i.Product.SoldCount,
+		RatingAverage: i.Product.RatingAverage,
+		ReviewCount:   i.Product.ReviewCount,
+		CreatedAt:     i.Product.CreatedAt.Format(time.RFC3339),
+	}
+
+	if i.Product.Category != nil {
+		category := i.Product.Category.ToResponse()
+		response.Category = &category
+	}
+
+	return response
+}
+
+// productCursor is the position after the last product of a cursor page
+type productCursor struct {
+	Sort string `json:"s"`
+	Key  string `json:"k"`
+	ID   uint   `json:"id"`
+}
+
+func encodeProductCursor(cursor productCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeProductCursor(value string) (productCursor, error) {
+	var cursor productCursor
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || json.Unmarshal(data, &cursor) != nil || cursor.ID == 0 {
+		return cursor, ErrInvalidCursor
+	}
+	return cursor, nil
+}
+
+// ProductSearchHit is a product found by a full-text search
+type ProductSearchHit struct {
+	Product       models.Product
+	Rank          float64
+	NameHighlight string // name with the matched words in <mark> tags
+	Snippet       string // best matching fragments of the description
+}
+
+// ToResponse converts ProductSearchHit to ProductSearchResponse
+func (h *ProductSearchHit) ToResponse() models.ProductSearchResponse {
+	return models.ProductSearchResponse{
+		ProductResponse: h.Product.ToResponse(),
+		Rank:            h.Rank,
+		NameHighlight:   h.NameHighlight,
+		Snippet:         h.Snippet,
+	}
+}
+
+// searchTSQuery turns search text into a tsquery matching every word as a
+// prefix, e.g. "áo th" becomes "áo:* & th:*". Anything but letters and
+// digits separates words, so the text cannot inject tsquery operators.
+func searchTSQuery(text string) string {
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	for i, word := range words {
+		words[i] = word + ":*"
+	}
+	return strings.Join(words, " & ")
+}
+
 // ProductRepository defines the interface for product data access
 type ProductRepository interface {
 	Create(product *models.Product) error
@@ -23,8 +239,14 @@ type ProductRepository interface {
 	FindBySlug(slug string) (*models.Product, error)
 	Update(product *models.Product) error
 	Delete(id uint) error
-	List(filters ProductFilters) ([]models.Product, int64, error)
-	
+	List(filters ProductFilters) ([]ProductListItem, int64, error)
+	Count(filters ProductFilters) (int64, error)
+	ListByCursor(filters ProductFilters, cursor string) ([]ProductListItem, string, error)
+	Search(filters ProductFilters) ([]ProductSearchHit, int64, error)
+	RefreshSearchVectors(productIDs ...uint) error
+	RefreshCategorySearchVectors(categoryID uint) error
+	Facets(filters ProductFilters) (*ProductFacets, error)
+	RefreshSalesStats(productIDs ...uint) error
 	// Image operations
 	CreateImage(image *models.ProductImage) error
 	DeleteImage(id uint) error
@@ -37,6 +259,7 @@ type ProductRepository interface {
 	DeleteVariant(id uint) error
 	GetProductVariants(productID uint) ([]models.ProductVariant, error)
 	FindVariantBySKU(sku string) (*models.ProductVariant, error)
+	FindVariantByID(id uint) (*models.ProductVariant, error)
 }
 
 type productRepository struct {
@@ -85,52 +308,427 @@ func (r *productRepository) Delete(id uint) error {
 	return r.db.Delete(&models.Product{}, id).Error
 }
 
-func (r *productRepository) List(filters ProductFilters) ([]models.Product, int64, error) {
-	var products []models.Product
+// List returns a page of the products matching filters. The total is
+// counted on the bare filtered query.
+func (r *productRepository) List(filters ProductFilters) ([]ProductListItem, int64, error) {
+	total, err := r.Count(filters)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	query := r.listQuery(filters, productSortFor(filters))
+	if filters.Page > 0 && filters.PageSize > 0 {
+		query = query.Offset((filters.Page - 1) * filters.PageSize).Limit(filters.PageSize)
+	}
+
+	items, err := r.findListItems(query)
+	return items, total, err
+}
+
+// Count returns how many products match filters
+func (r *productRepository) Count(filters ProductFilters) (int64, error) {
 	var total int64
+	err := r.applyFilters(r.db.Model(&models.Product{}), filters).Count(&total).Error
+	return total, err
+}
+
+// ListByCursor returns the filters.PageSize products after cursor, or the
+// first ones for an empty cursor, and the cursor of the next page, empty
+// on the last page. Unlike List it costs the same on every page.
+func (r *productRepository) ListByCursor(filters ProductFilters, cursor string) ([]ProductListItem, string, error) {
+	sort := productSortFor(filters)
+	query := r.listQuery(filters, sort)
+
+	if cursor != "" {
+		after, err := decodeProductCursor(cursor)
+		if err != nil || after.Sort != sort.name {
+			return nil, "", ErrInvalidCursor
+		}
+		op := ">"
+		if sort.desc {
+			op = "<"
+		}
+		args := append(append([]interface{}{}, sort.vars...), after.Key, after.ID)
+		query = query.Where("("+sort.key+", products.id) "+op+" (CAST(? AS "+sort.cast+"), ?)", args...)
+	}
+
+	// One extra row tells whether there is a next page
+	items, err := r.findListItems(query.Limit(filters.PageSize + 1))
+	if err != nil {
+		return nil, "", err
+	}
+	if len(items) <= filters.PageSize {
+		return items, "", nil
+	}
+
+	items = items[:filters.PageSize]
+	last := items[len(items)-1]
+	return items, encodeProductCursor(productCursor{Sort: sort.name, Key: last.sortKey, ID: last.Product.ID}), nil
+}
+
+// productSortFor resolves filters.Sort, falling back to relevance when
+// searching and to newest otherwise
+func productSortFor(filters ProductFilters) productSort {
+	tsQuery := searchTSQuery(filters.SearchQuery)
+
+	switch filters.Sort {
+	case SortPriceAsc:
+		return productSort{name: SortPriceAsc, key: effectivePriceSQL, cast: "numeric"}
+	case SortPriceDesc:
+		return productSort{name: SortPriceDesc, key: effectivePriceSQL, cast: "numeric", desc: true}
+	case SortBestSelling:
+		return productSort{name: SortBestSelling, key: "products.sold_count", cast: "bigint", desc: true}
+	case SortTopRated:
+		return productSort{name: SortTopRated, key: "products.rating_average", cast: "numeric", desc: true}
+	case SortNewest:
+	default:
+		if tsQuery != "" {
+			return productSort{
+				name: SortRelevance,
+				key:  "ts_rank(products.search_vector, to_tsquery('" + SearchConfig + "', ?))",
+				vars: []interface{}{tsQuery},
+				cast: "real",
+				desc: true,
+			}
+		}
+	}
+	return productSort{name: SortNewest, key: "products.created_at", cast: "timestamptz", desc: true}
+}
+
+// listQuery selects the listing rows matching filters in sort order
+func (r *productRepository) listQuery(filters ProductFilters, sort productSort) *gorm.DB {
+	direction := " ASC"
+	if sort.desc {
+		direction = " DESC"
+	}
+
+	// The key is read back as text so the next cursor compares it exactly
+	return r.applyFilters(r.db.Model(&models.Product{}), filters).
+		Select(productListColumns+", CAST("+sort.key+" AS text) AS sort_key", sort.vars...).
+		Order(clause.OrderBy{Expression: clause.Expr{
+			SQL:                sort.key + direction + ", products.id" + direction,
+			Vars:               sort.vars,
+			WithoutParentheses: true,
+		}})
+}
+
+// findListItems runs a listing query and loads the categories of the rows
+func (r *productRepository) findListItems(query *gorm.DB) ([]ProductListItem, error) {
+	var rows []struct {
+		models.Product
+		ImageURL   string
+		TotalStock int64
+		SortKey    string
+	}
+	if err := query.Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	items := make([]ProductListItem, len(rows))
+	var categoryIDs []uint
+	seen := make(map[uint]bool)
+	for i, row := range rows {
+		items[i] = ProductListItem{
+			Product:    row.Product,
+			ImageURL:   row.ImageURL,
+			TotalStock: row.TotalStock,
+			sortKey:    row.SortKey,
+		}
+		if !seen[row.CategoryID] {
+			seen[row.CategoryID] = true
+			categoryIDs = append(categoryIDs, row.CategoryID)
+		}
+	}
+	if len(items) == 0 {
+		return items, nil
+	}
+
+	var categories []models.Category
+	if err := r.db.Where("id IN ?", categoryIDs).Find(&categories).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]*models.Category, len(categories))
+	for i := range categories {
+		byID[categories[i].ID] = &categories[i]
+	}
+	for i := range items {
+		items[i].Product.Category = byID[items[i].Product.CategoryID]
+	}
+
+	return items, nil
+}
 
-	query := r.db.Model(&models.Product{}).
-		Preload("Category").
+// Search finds products matching filters.SearchQuery, best matches first.
+// Every word of the query matches as a prefix, ignoring case and accents,
+// against the name, category, colors, SKUs and description.
+func (r *productRepository) Search(filters ProductFilters) ([]ProductSearchHit, int64, error) {
+	tsQuery := searchTSQuery(filters.SearchQuery)
+	if tsQuery == "" {
+		return []ProductSearchHit{}, 0, nil
+	}
+
+	query := r.applyFilters(r.db.Model(&models.Product{}), filters)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var rows []struct {
+		ID            uint
+		Rank          float64
+		NameHighlight string
+		Snippet       string
+	}
+	query = query.Select(
+		"products.id, "+
+			"ts_rank(products.search_vector, search_query) AS rank, "+
+			"ts_headline('"+SearchConfig+"', products.name, search_query, 'HighlightAll=true, StartSel=<mark>, StopSel=</mark>') AS name_highlight, "+
+			"ts_headline('"+SearchConfig+"', COALESCE(products.description, ''), search_query, 'StartSel=<mark>, StopSel=</mark>, MaxWords=35, MinWords=15, MaxFragments=2') AS snippet",
+	).
+		Joins("CROSS JOIN to_tsquery('"+SearchConfig+"', ?) AS search_query", tsQuery).
+		Order("rank DESC, products.created_at DESC, products.id DESC")
+
+	if filters.Page > 0 && filters.PageSize > 0 {
+		query = query.Offset((filters.Page - 1) * filters.PageSize).Limit(filters.PageSize)
+	}
+
+	if err := query.Scan(&rows).Error; err != nil {
+		return nil, 0, err
+	}
+	if len(rows) == 0 {
+		return []ProductSearchHit{}, total, nil
+	}
+
+	ids := make([]uint, len(rows))
+	for i, row := range rows {
+		ids[i] = row.ID
+	}
+
+	var products []models.Product
+	err := r.db.Preload("Category").
 		Preload("Images").
-		Preload("Variants")
+		Preload("Variants").
+		Where("id IN ?", ids).
+		Find(&products).Error
+	if err != nil {
+		return nil, 0, err
+	}
 
-	// Apply filters
+	byID := make(map[uint]models.Product, len(products))
+	for _, product := range products {
+		byID[product.ID] = product
+	}
+
+	hits := make([]ProductSearchHit, 0, len(rows))
+	for _, row := range rows {
+		product, ok := byID[row.ID]
+		if !ok {
+			continue
+		}
+		hits = append(hits, ProductSearchHit{
+			Product:       product,
+			Rank:          row.Rank,
+			NameHighlight: row.NameHighlight,
+			Snippet:       row.Snippet,
+		})
+	}
+	return hits, total, nil
+}
+
+// applyFilters narrows a product query down to the given filters
+func (r *productRepository) applyFilters(query *gorm.DB, filters ProductFilters) *gorm.DB {
+	// A category includes the products of all its subcategories
 	if filters.CategoryID != nil {
-		query = query.Where("category_id = ?", *filters.CategoryID)
+		query = query.Where("products.category_id IN ("+categorySubtreeSQL+")", *filters.CategoryID)
 	}
 
 	if filters.MinPrice != nil {
-		query = query.Where("price >= ?", *filters.MinPrice)
+		query = query.Where(effectivePriceSQL+" >= ?", *filters.MinPrice)
 	}
 
 	if filters.MaxPrice != nil {
-		query = query.Where("price <= ?", *filters.MaxPrice)
+		query = query.Where(effectivePriceSQL+" <= ?", *filters.MaxPrice)
 	}
 
 	if filters.SearchQuery != "" {
-		searchTerm := "%" + filters.SearchQuery + "%"
-		query = query.Where("name ILIKE ? OR description ILIKE ?", searchTerm, searchTerm)
+		if tsQuery := searchTSQuery(filters.SearchQuery); tsQuery != "" {
+			query = query.Where("products.search_vector @@ to_tsquery('"+SearchConfig+"', ?)", tsQuery)
+		} else {
+			// Nothing searchable, e.g. only punctuation
+			query = query.Where("1 = 0")
+		}
 	}
 
 	if filters.IsActive != nil {
-		query = query.Where("is_active = ?", *filters.IsActive)
+		query = query.Where("products.is_active = ?", *filters.IsActive)
 	}
 
-	// Count total before pagination
-	query.Count(&total)
+	// Size, color and stock must hold for the same variant: a product with
+	// a red S and a blue M in stock does not match "red, M"
+	if conditions, args := variantConditions(filters, "product_variants"); conditions != "" {
+		query = query.Where("EXISTS (SELECT 1 FROM product_variants WHERE product_variants.product_id = products.id AND "+conditions+")", args...)
+	}
 
-	// Apply pagination
-	if filters.Page > 0 && filters.PageSize > 0 {
-		offset := (filters.Page - 1) * filters.PageSize
-		query = query.Offset(offset).Limit(filters.PageSize)
+	if filters.OnSale {
+		query = query.Where("products.discount_price IS NOT NULL AND products.discount_price < products.price")
+	}
+
+	if filters.MinRating != nil {
+		query = query.Where("products.rating_average >= ?", *filters.MinRating)
+	}
+
+	return query
+}
+
+// variantConditions returns the SQL conditions a variant, named table in
+// the query, must meet for the size, color and stock filters
+func variantConditions(filters ProductFilters, table string) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+
+	if len(filters.Sizes) > 0 {
+		conditions = append(conditions, table+".size IN ?")
+		args = append(args, filters.Sizes)
+	}
+	if len(filters.Colors) > 0 {
+		conditions = append(conditions, table+".color IN ?")
+		args = append(args, filters.Colors)
+	}
+	if filters.InStock {
+		conditions = append(conditions, table+".stock_quantity > 0")
+	}
+
+	return strings.Join(conditions, " AND "), args
+}
+
+// Facets counts the products matching filters per size, color, category
+// and price range
+func (r *productRepository) Facets(filters ProductFilters) (*ProductFacets, error) {
+	facets := &ProductFacets{}
+
+	sizeFilters := filters
+	sizeFilters.Sizes = nil
+	sizes, err := r.variantFacet(sizeFilters, "size")
+	if err != nil {
+		return nil, err
+	}
+	facets.Sizes = sizes
+
+	colorFilters := filters
+	colorFilters.Colors = nil
+	colors, err := r.variantFacet(colorFilters, "color")
+	if err != nil {
+		return nil, err
+	}
+	facets.Colors = colors
+
+	categoryFilters := filters
+	categoryFilters.CategoryID = nil
+	facets.Categories = []CategoryFacet{}
+	err = r.applyFilters(r.db.Model(&models.Product{}), categoryFilters).
+		Select("categories.id AS category_id, categories.name AS name, COUNT(*) AS count").
+		Joins("JOIN categories ON categories.id = products.category_id").
+		Group("categories.id, categories.name").
+		Order("count DESC, categories.name").
+		Scan(&facets.Categories).Error
+	if err != nil {
+		return nil, err
+	}
+
+	priceFilters := filters
+	priceFilters.MinPrice = nil
+	priceFilters.MaxPrice = nil
+	prices, err := r.priceFacet(priceFilters)
+	if err != nil {
+		return nil, err
+	}
+	facets.PriceRanges = prices
+
+	return facets, nil
+}
+
+// variantFacet counts the products matching filters per value of a variant
+// column, counting only the variants that meet the other variant filters
+func (r *productRepository) variantFacet(filters ProductFilters, column string) ([]FacetValue, error) {
+	join := "JOIN product_variants AS facet_variants ON facet_variants.product_id = products.id"
+	conditions, args := variantConditions(filters, "facet_variants")
+	if conditions != "" {
+		join += " AND " + conditions
+	}
+
+	values := []FacetValue{}
+	err := r.applyFilters(r.db.Model(&models.Product{}), filters).
+		Select("facet_variants."+column+" AS value, COUNT(DISTINCT products.id) AS count").
+		Joins(join, args...).
+		Group("facet_variants." + column).
+		Order("count DESC, value").
+		Scan(&values).Error
+	return values, err
+}
+
+// priceFacet counts the products matching filters per price bucket
+func (r *productRepository) priceFacet(filters ProductFilters) ([]PriceBucket, error) {
+	buckets := make([]PriceBucket, len(priceBucketBounds)+1)
+	columns := make([]string, len(buckets))
+	var args []interface{}
+
+	for i := range buckets {
+		var conditions []string
+		if i > 0 {
+			lower := priceBucketBounds[i-1]
+			buckets[i].Min = &lower
+			conditions = append(conditions, effectivePriceSQL+" >= ?")
+			args = append(args, lower)
+		}
+		if i < len(priceBucketBounds) {
+			upper := priceBucketBounds[i]
+			buckets[i].Max = &upper
+			conditions = append(conditions, effectivePriceSQL+" < ?")
+			args = append(args, upper)
+		}
+		columns[i] = fmt.Sprintf("COUNT(*) FILTER (WHERE %s) AS bucket_%d", strings.Join(conditions, " AND "), i)
 	}
 
-	// Execute query
-	err := query.Order("created_at DESC").Find(&products).Error
-	return products, total, err
+	row := r.applyFilters(r.db.Model(&models.Product{}), filters).
+		Select(strings.Join(columns, ", "), args...).
+		Row()
+
+	counts := make([]interface{}, len(buckets))
+	for i := range buckets {
+		counts[i] = &buckets[i].Count
+	}
+	if err := row.Scan(counts...); err != nil {
+		return nil, err
+	}
+	return buckets, nil
+}
+
+// RefreshSearchVectors rebuilds the search document of the given products,
+// or of every product when none are given. It must run after any change to
+// a product's name, description, category or variants.
+func (r *productRepository) RefreshSearchVectors(productIDs ...uint) error {
+	if len(productIDs) == 0 {
+		return r.db.Exec(refreshSearchVectorSQL).Error
+	}
+	return r.db.Exec(refreshSearchVectorSQL+" WHERE products.id IN ?", productIDs).Error
+}
+
+// RefreshSalesStats recomputes the sold count and rating of the given
+// products, or of every product when none are given
+func (r *productRepository) RefreshSalesStats(productIDs ...uint) error {
+	if len(productIDs) == 0 {
+		return r.db.Exec(refreshSalesStatsSQL).Error
+	}
+	return r.db.Exec(refreshSalesStatsSQL+" AND products.id IN ?", productIDs).Error
+}
+
+// RefreshCategorySearchVectors rebuilds the search document of every
+// product in a category, e.g. after the category was renamed
+func (r *productRepository) RefreshCategorySearchVectors(categoryID uint) error {
+	return r.db.Exec(refreshSearchVectorSQL+" WHERE products.category_id = ?", categoryID).Error
 }
 
-// Image operations
 func (r *productRepository) CreateImage(image *models.ProductImage) error {
 	return r.db.Create(image).Error
 }
@@ -180,3 +778,15 @@ func (r *productRepository) FindVariantBySKU(sku string) (*models.ProductVariant
 	}
 	return &variant, nil
 }
+
+func (r *productRepository) FindVariantByID(id uint) (*models.ProductVariant, error) {
+	var variant models.ProductVariant
+	err := r.db.First(&variant, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("variant not found")
+		}
+		return nil, err
+	}
+	return &variant, nil
+}
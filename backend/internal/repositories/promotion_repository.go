@@ -0,0 +1,182 @@
+package repositories
+
+import (
+	"errors"
+
+	"github.com/huy1235588/fashion-e-commerce/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PromotionRepository defines the interface for promotion data access
+type PromotionRepository interface {
+	Create(promotion *models.Promotion) error
+	FindByID(id uint) (*models.Promotion, error)
+	FindByCode(code string) (*models.Promotion, error)
+	FindByCodeForUpdate(code string) (*models.Promotion, error)
+	List(filters map[string]interface{}, limit, offset int) ([]models.Promotion, int64, error)
+	Update(promotion *models.Promotion) error
+	ReplaceScope(promotion *models.Promotion, categoryIDs, productIDs []uint) error
+	Delete(id uint) error
+	CountUsagesByUser(promotionID, userID uint) (int64, error)
+	CreateUsage(usage *models.PromotionUsage) error
+	FindUsageByOrderID(orderID uint) (*models.PromotionUsage, error)
+	DeleteUsage(id uint) error
+	IncrementUsedCount(id uint, delta int) error
+}
+
+type promotionRepository struct {
+	db *gorm.DB
+}
+
+// NewPromotionRepository creates a new promotion repository
+func NewPromotionRepository(db *gorm.DB) PromotionRepository {
+	return &promotionRepository{db: db}
+}
+
+func (r *promotionRepository) Create(promotion *models.Promotion) error {
+	return r.db.Create(promotion).Error
+}
+
+func (r *promotionRepository) FindByID(id uint) (*models.Promotion, error) {
+	var promotion models.Promotion
+	err := r.db.Preload("Categories").Preload("Products").First(&promotion, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &promotion, nil
+}
+
+func (r *promotionRepository) FindByCode(code string) (*models.Promotion, error) {
+	var promotion models.Promotion
+	err := r.db.Preload("Categories").Preload("Products").
+		Where("UPPER(code) = UPPER(?)", code).
+		First(&promotion).Error
+	if err != nil {
+		return nil, err
+	}
+	return &promotion, nil
+}
+
+// FindByCodeForUpdate loads a promotion and locks its row so usage limits
+// can be checked and consumed atomically
+func (r *promotionRepository) FindByCodeForUpdate(code string) (*models.Promotion, error) {
+	var promotion models.Promotion
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("UPPER(code) = UPPER(?)", code).
+		First(&promotion).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.db.Model(&promotion).Association("Categories").Find(&promotion.Categories); err != nil {
+		return nil, err
+	}
+	if err := r.db.Model(&promotion).Association("Products").Find(&promotion.Products); err != nil {
+		return nil, err
+	}
+
+	return &promotion, nil
+}
+
+func (r *promotionRepository) List(filters map[string]interface{}, limit, offset int) ([]models.Promotion, int64, error) {
+	var promotions []models.Promotion
+	var total int64
+
+	query := r.db.Model(&models.Promotion{})
+
+	// Apply filters
+	if isActive, ok := filters["is_active"].(bool); ok {
+		query = query.Where("is_active = ?", isActive)
+	}
+	if promoType, ok := filters["type"].(string); ok && promoType != "" {
+		query = query.Where("type = ?", promoType)
+	}
+	if search, ok := filters["search"].(string); ok && search != "" {
+		query = query.Where("code ILIKE ? OR name ILIKE ?", "%"+search+"%", "%"+search+"%")
+	}
+
+	// Count total
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	// Get paginated results
+	err := query.
+		Preload("Categories").
+		Preload("Products").
+		Order("created_at DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&promotions).Error
+
+	return promotions, total, err
+}
+
+func (r *promotionRepository) Update(promotion *models.Promotion) error {
+	// used_count is only changed through IncrementUsedCount
+	return r.db.Omit("Categories", "Products", "UsedCount").Save(promotion).Error
+}
+
+// ReplaceScope sets the categories and products a promotion is limited to
+func (r *promotionRepository) ReplaceScope(promotion *models.Promotion, categoryIDs, productIDs []uint) error {
+	categories := []models.Category{}
+	if len(categoryIDs) > 0 {
+		if err := r.db.Where("id IN ?", categoryIDs).Find(&categories).Error; err != nil {
+			return err
+		}
+		if len(categories) != len(categoryIDs) {
+			return errors.New("one or more categories not found")
+		}
+	}
+
+	products := []models.Product{}
+	if len(productIDs) > 0 {
+		if err := r.db.Where("id IN ?", productIDs).Find(&products).Error; err != nil {
+			return err
+		}
+		if len(products) != len(productIDs) {
+			return errors.New("one or more products not found")
+		}
+	}
+
+	if err := r.db.Model(promotion).Association("Categories").Replace(categories); err != nil {
+		return err
+	}
+	return r.db.Model(promotion).Association("Products").Replace(products)
+}
+
+func (r *promotionRepository) Delete(id uint) error {
+	return r.db.Delete(&models.Promotion{}, id).Error
+}
+
+func (r *promotionRepository) CountUsagesByUser(promotionID, userID uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.PromotionUsage{}).
+		Where("promotion_id = ? AND user_id = ?", promotionID, userID).
+		Count(&count).Error
+	return count, err
+}
+
+func (r *promotionRepository) CreateUsage(usage *models.PromotionUsage) error {
+	return r.db.Create(usage).Error
+}
+
+func (r *promotionRepository) FindUsageByOrderID(orderID uint) (*models.PromotionUsage, error) {
+	var usage models.PromotionUsage
+	err := r.db.Where("order_id = ?", orderID).First(&usage).Error
+	if err != nil {
+		return nil, err
+	}
+	return &usage, nil
+}
+
+func (r *promotionRepository) DeleteUsage(id uint) error {
+	return r.db.Delete(&models.PromotionUsage{}, id).Error
+}
+
+func (r *promotionRepository) IncrementUsedCount(id uint, delta int) error {
+	return r.db.Model(&models.Promotion{}).
+		Where("id = ?", id).
+		UpdateColumn("used_count", gorm.Expr("GREATEST(used_count + ?, 0)", delta)).Error
+}
@@ -0,0 +1,105 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"github.com/huy1235588/fashion-e-commerce/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ReconciliationRepository defines the interface for payment reconciliation data access
+type ReconciliationRepository interface {
+	CreateItem(item *models.ReconciliationItem) error
+	HasUnresolvedItem(paymentID uint, issue models.ReconciliationIssue) (bool, error)
+	FindReportByDate(date time.Time) (*models.ReconciliationReport, error)
+	SaveReport(report *models.ReconciliationReport) error
+	DeleteReportItems(reportID uint, source models.ReconciliationSource) error
+	AttachUnreportedItems(reportID uint, before time.Time) error
+	CountReportItems(reportID uint) (int64, error)
+	ListReports(limit, offset int) ([]models.ReconciliationReport, int64, error)
+}
+
+type reconciliationRepository struct {
+	db *gorm.DB
+}
+
+// NewReconciliationRepository creates a new reconciliation repository
+func NewReconciliationRepository(db *gorm.DB) ReconciliationRepository {
+	return &reconciliationRepository{db: db}
+}
+
+func (r *reconciliationRepository) CreateItem(item *models.ReconciliationItem) error {
+	return r.db.Create(item).Error
+}
+
+// HasUnresolvedItem reports whether an open item for the payment and issue was already recorded
+func (r *reconciliationRepository) HasUnresolvedItem(paymentID uint, issue models.ReconciliationIssue) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.ReconciliationItem{}).
+		Where("payment_id = ? AND issue = ? AND resolved = ?", paymentID, issue, false).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// FindReportByDate returns the report for a day with its items, or nil if there is none
+func (r *reconciliationRepository) FindReportByDate(date time.Time) (*models.ReconciliationReport, error) {
+	var report models.ReconciliationReport
+	err := r.db.Where("report_date = ?", date.Format("2006-01-02")).
+		Preload("Items", func(db *gorm.DB) *gorm.DB {
+			return db.Order("created_at ASC")
+		}).
+		First(&report).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &report, nil
+}
+
+func (r *reconciliationRepository) SaveReport(report *models.ReconciliationReport) error {
+	return r.db.Omit(clause.Associations).Save(report).Error
+}
+
+func (r *reconciliationRepository) DeleteReportItems(reportID uint, source models.ReconciliationSource) error {
+	return r.db.Where("report_id = ? AND source = ?", reportID, source).
+		Delete(&models.ReconciliationItem{}).Error
+}
+
+// AttachUnreportedItems moves items created before the given time that are
+// not in any report yet into the report
+func (r *reconciliationRepository) AttachUnreportedItems(reportID uint, before time.Time) error {
+	return r.db.Model(&models.ReconciliationItem{}).
+		Where("report_id IS NULL AND created_at < ?", before).
+		Update("report_id", reportID).Error
+}
+
+func (r *reconciliationRepository) CountReportItems(reportID uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.ReconciliationItem{}).Where("report_id = ?", reportID).Count(&count).Error
+	return count, err
+}
+
+func (r *reconciliationRepository) ListReports(limit, offset int) ([]models.ReconciliationReport, int64, error) {
+	var reports []models.ReconciliationReport
+	var total int64
+
+	query := r.db.Model(&models.ReconciliationReport{})
+
+	// Count total
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	// Get paginated results
+	err := query.
+		Order("report_date DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&reports).Error
+
+	return reports, total, err
+}
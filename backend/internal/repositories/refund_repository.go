@@ -0,0 +1,106 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/huy1235588/fashion-e-commerce/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RefundRepository defines the interface for refund data access
+type RefundRepository interface {
+	Create(refund *models.Refund) error
+	FindByID(id uint) (*models.Refund, error)
+	FindByOrderID(orderID uint) ([]models.Refund, error)
+	List(filters map[string]interface{}, limit, offset int) ([]models.Refund, int64, error)
+	SumCommittedByPayment(paymentID uint) (float64, error)
+	FindStalePending(before time.Time, limit int) ([]models.Refund, error)
+	Update(refund *models.Refund) error
+}
+
+type refundRepository struct {
+	db *gorm.DB
+}
+
+// NewRefundRepository creates a new refund repository
+func NewRefundRepository(db *gorm.DB) RefundRepository {
+	return &refundRepository{db: db}
+}
+
+func (r *refundRepository) Create(refund *models.Refund) error {
+	return r.db.Omit(clause.Associations).Create(refund).Error
+}
+
+func (r *refundRepository) FindByID(id uint) (*models.Refund, error) {
+	var refund models.Refund
+	err := r.db.First(&refund, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &refund, nil
+}
+
+func (r *refundRepository) FindByOrderID(orderID uint) ([]models.Refund, error) {
+	var refunds []models.Refund
+	err := r.db.Where("order_id = ?", orderID).
+		Order("created_at DESC").
+		Find(&refunds).Error
+	return refunds, err
+}
+
+func (r *refundRepository) List(filters map[string]interface{}, limit, offset int) ([]models.Refund, int64, error) {
+	var refunds []models.Refund
+	var total int64
+
+	query := r.db.Model(&models.Refund{})
+
+	// Apply filters
+	if status, ok := filters["status"].(string); ok && status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if paymentMethod, ok := filters["payment_method"].(string); ok && paymentMethod != "" {
+		query = query.Where("payment_method = ?", paymentMethod)
+	}
+
+	// Count total
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	// Get paginated results
+	err := query.
+		Order("created_at DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&refunds).Error
+
+	return refunds, total, err
+}
+
+// SumCommittedByPayment returns the amount already refunded or still being refunded for a payment
+func (r *refundRepository) SumCommittedByPayment(paymentID uint) (float64, error) {
+	var total float64
+	err := r.db.Model(&models.Refund{}).
+		Where("payment_id = ? AND status IN ?", paymentID, []models.RefundStatus{models.RefundStatusPending, models.RefundStatusSucceeded}).
+		Select("COALESCE(SUM(amount), 0)").
+		Scan(&total).Error
+	return total, err
+}
+
+// FindStalePending returns gateway refunds still pending since before, oldest
+// first, with their order and payment loaded
+func (r *refundRepository) FindStalePending(before time.Time, limit int) ([]models.Refund, error) {
+	var refunds []models.Refund
+	err := r.db.Where("status = ? AND payment_method IN ? AND updated_at < ?", models.RefundStatusPending, onlinePaymentMethods, before).
+		Preload("Order").
+		Preload("Payment").
+		Order("updated_at ASC").
+		Limit(limit).
+		Find(&refunds).Error
+	return refunds, err
+}
+
+func (r *refundRepository) Update(refund *models.Refund) error {
+	return r.db.Omit(clause.Associations).Save(refund).Error
+}
@@ -0,0 +1,127 @@
+package repositories
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/huy1235588/fashion-e-commerce/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RoleRepository handles database operations for roles and permissions
+type RoleRepository interface {
+	Create(role *models.Role) error
+	FindByID(id uint) (*models.Role, error)
+	FindByName(name string) (*models.Role, error)
+	List() ([]models.Role, error)
+	Update(role *models.Role) error
+	Delete(id uint) error
+	ReplacePermissions(role *models.Role, codes []string) error
+	ListPermissions() ([]models.Permission, error)
+	FindPermissionCodes(roleName string) ([]string, error)
+	CountUsers(roleName string) (int64, error)
+}
+
+type roleRepository struct {
+	db *gorm.DB
+}
+
+// NewRoleRepository creates a new role repository
+func NewRoleRepository(db *gorm.DB) RoleRepository {
+	return &roleRepository{db: db}
+}
+
+// Create creates a new role
+func (r *roleRepository) Create(role *models.Role) error {
+	return r.db.Omit(clause.Associations).Create(role).Error
+}
+
+// FindByID finds a role by ID with its permissions
+func (r *roleRepository) FindByID(id uint) (*models.Role, error) {
+	var role models.Role
+	err := r.db.Preload("Permissions").First(&role, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("role not found")
+		}
+		return nil, err
+	}
+	return &role, nil
+}
+
+// FindByName finds a role by name with its permissions
+func (r *roleRepository) FindByName(name string) (*models.Role, error) {
+	var role models.Role
+	err := r.db.Preload("Permissions").Where("name = ?", name).First(&role).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("role not found")
+		}
+		return nil, err
+	}
+	return &role, nil
+}
+
+// List returns all roles with their permissions
+func (r *roleRepository) List() ([]models.Role, error) {
+	var roles []models.Role
+	err := r.db.Preload("Permissions", func(db *gorm.DB) *gorm.DB {
+		return db.Order("code ASC")
+	}).Order("id ASC").Find(&roles).Error
+	return roles, err
+}
+
+// Update updates a role's details (not its permissions)
+func (r *roleRepository) Update(role *models.Role) error {
+	return r.db.Omit(clause.Associations).Save(role).Error
+}
+
+// Delete deletes a role and its permission grants
+func (r *roleRepository) Delete(id uint) error {
+	return r.db.Select(clause.Associations).Delete(&models.Role{ID: id}).Error
+}
+
+// ReplacePermissions sets the permissions of a role to exactly the given codes
+func (r *roleRepository) ReplacePermissions(role *models.Role, codes []string) error {
+	var permissions []models.Permission
+	if len(codes) > 0 {
+		if err := r.db.Where("code IN ?", codes).Find(&permissions).Error; err != nil {
+			return err
+		}
+		if len(permissions) != len(codes) {
+			return fmt.Errorf("unknown permission in %v", codes)
+		}
+	}
+
+	if err := r.db.Model(role).Association("Permissions").Replace(permissions); err != nil {
+		return err
+	}
+	role.Permissions = permissions
+	return nil
+}
+
+// ListPermissions returns every permission
+func (r *roleRepository) ListPermissions() ([]models.Permission, error) {
+	var permissions []models.Permission
+	err := r.db.Order("code ASC").Find(&permissions).Error
+	return permissions, err
+}
+
+// FindPermissionCodes returns the permission codes granted to a role name
+func (r *roleRepository) FindPermissionCodes(roleName string) ([]string, error) {
+	var codes []string
+	err := r.db.Table("permissions").
+		Joins("JOIN role_permissions ON role_permissions.permission_id = permissions.id").
+		Joins("JOIN roles ON roles.id = role_permissions.role_id").
+		Where("roles.name = ?", roleName).
+		Pluck("permissions.code", &codes).Error
+	return codes, err
+}
+
+// CountUsers returns how many users have a role
+func (r *roleRepository) CountUsers(roleName string) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.User{}).Where("role = ?", roleName).Count(&count).Error
+	return count, err
+}
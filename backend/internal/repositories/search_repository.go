@@ -0,0 +1,181 @@
+package repositories
+
+import (
+	"strings"
+	"time"
+
+	"github.com/huy1235588/fashion-e-commerce/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// UnaccentFunction is an immutable wrapper around unaccent, which Postgres
+// only allows in index expressions when marked immutable; RunMigrations
+// creates it
+const UnaccentFunction = "immutable_unaccent"
+
+// ProductSuggestion is a product name completing a search prefix
+type ProductSuggestion struct {
+	ID       uint   `json:"id"`
+	Name     string `json:"name"`
+	Slug     string `json:"slug"`
+	ImageURL string `json:"image_url,omitempty"`
+}
+
+// CategorySuggestion is a category name completing a search prefix
+type CategorySuggestion struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+}
+
+// QuerySuggestion is a popular past search completing a search prefix
+type QuerySuggestion struct {
+	Query       string `json:"query"`
+	SearchCount int64  `json:"search_count"`
+}
+
+// SearchQueryStat summarizes the searches for one query
+type SearchQueryStat struct {
+	Query          string    `json:"query"`
+	SearchCount    int64     `json:"search_count"`
+	AverageResults float64   `json:"average_results"`
+	LastSearchedAt time.Time `json:"last_searched_at"`
+}
+
+// SearchRepository handles the search log and autocomplete lookups
+type SearchRepository interface {
+	CreateLog(entry *models.SearchLog) error
+	DeleteLogsBefore(before time.Time) (int64, error)
+
+	SuggestProducts(prefix string, limit int) ([]ProductSuggestion, error)
+	SuggestCategories(prefix string, limit int) ([]CategorySuggestion, error)
+	SuggestQueries(prefix string, since time.Time, limit int) ([]QuerySuggestion, error)
+
+	TopQueries(since time.Time, limit int) ([]SearchQueryStat, error)
+	ZeroResultQueries(since time.Time, limit int) ([]SearchQueryStat, error)
+}
+
+type searchRepository struct {
+	db *gorm.DB
+}
+
+// NewSearchRepository creates a new search repository
+func NewSearchRepository(db *gorm.DB) SearchRepository {
+	return &searchRepository{db: db}
+}
+
+// normalizedSQL is column lowercased without accents, as the trigram
+// indexes store it
+func normalizedSQL(column string) string {
+	return UnaccentFunction + "(lower(" + column + "))"
+}
+
+// suggestMatch returns the condition that column matches a prefix, ignoring
+// case and accents, and its arguments. A column matches when it contains
+// the prefix or, to forgive typos, one of its words is similar to it.
+func suggestMatch(column, prefix string) (string, []interface{}) {
+	pattern := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(prefix)
+	condition := "(" + normalizedSQL(column) + " LIKE '%' || " + normalizedSQL("?") + " || '%'" +
+		" OR " + normalizedSQL("?") + " <% " + normalizedSQL(column) + ")"
+	return condition, []interface{}{pattern, prefix}
+}
+
+// similarityOrder orders the closest matches of a prefix first, then by
+// the given tie-breakers
+func similarityOrder(column, prefix, tieBreakers string) clause.OrderBy {
+	return clause.OrderBy{Expression: clause.Expr{
+		SQL:                "word_similarity(" + normalizedSQL("?") + ", " + normalizedSQL(column) + ") DESC, " + tieBreakers,
+		Vars:               []interface{}{prefix},
+		WithoutParentheses: true,
+	}}
+}
+
+func (r *searchRepository) CreateLog(entry *models.SearchLog) error {
+	return r.db.Create(entry).Error
+}
+
+// DeleteLogsBefore deletes the searches logged before a time and returns
+// how many were deleted
+func (r *searchRepository) DeleteLogsBefore(before time.Time) (int64, error) {
+	result := r.db.Where("created_at < ?", before).Delete(&models.SearchLog{})
+	return result.RowsAffected, result.Error
+}
+
+// SuggestProducts returns active products whose name matches a prefix,
+// closest and then best-selling first
+func (r *searchRepository) SuggestProducts(prefix string, limit int) ([]ProductSuggestion, error) {
+	condition, args := suggestMatch("products.name", prefix)
+
+	suggestions := []ProductSuggestion{}
+	err := r.db.Table("products").
+		Select("products.id, products.name, products.slug, "+
+			"COALESCE((SELECT image_url FROM product_images WHERE product_images.product_id = products.id ORDER BY is_primary DESC, id LIMIT 1), '') AS image_url").
+		Where("products.is_active = ?", true).
+		Where(condition, args...).
+		Order(similarityOrder("products.name", prefix, "products.sold_count DESC, products.id")).
+		Limit(limit).
+		Scan(&suggestions).Error
+	return suggestions, err
+}
+
+// SuggestCategories returns categories whose name matches a prefix,
+// closest first
+func (r *searchRepository) SuggestCategories(prefix string, limit int) ([]CategorySuggestion, error) {
+	condition, args := suggestMatch("categories.name", prefix)
+
+	suggestions := []CategorySuggestion{}
+	err := r.db.Table("categories").
+		Select("categories.id, categories.name, categories.slug").
+		Where(condition, args...).
+		Order(similarityOrder("categories.name", prefix, "categories.name")).
+		Limit(limit).
+		Scan(&suggestions).Error
+	return suggestions, err
+}
+
+// SuggestQueries returns the queries matching a prefix searched most often
+// since a time, leaving out the ones that found nothing
+func (r *searchRepository) SuggestQueries(prefix string, since time.Time, limit int) ([]QuerySuggestion, error) {
+	condition, args := suggestMatch("search_logs.query", prefix)
+
+	suggestions := []QuerySuggestion{}
+	err := r.db.Table("search_logs").
+		Select("search_logs.query, COUNT(*) AS search_count").
+		Where("search_logs.created_at >= ? AND search_logs.result_count > 0", since).
+		Where(condition, args...).
+		Group("search_logs.query").
+		Order("search_count DESC, search_logs.query").
+		Limit(limit).
+		Scan(&suggestions).Error
+	return suggestions, err
+}
+
+// TopQueries returns the queries searched most often since a time
+func (r *searchRepository) TopQueries(since time.Time, limit int) ([]SearchQueryStat, error) {
+	stats := []SearchQueryStat{}
+	err := r.db.Table("search_logs").
+		Select("query, COUNT(*) AS search_count, AVG(result_count) AS average_results, MAX(created_at) AS last_searched_at").
+		Where("created_at >= ?", since).
+		Group("query").
+		Order("search_count DESC, query").
+		Limit(limit).
+		Scan(&stats).Error
+	return stats, err
+}
+
+// ZeroResultQueries returns the queries searched most often since a time
+// whose latest search found nothing, i.e. the gaps still open in the
+// catalog
+func (r *searchRepository) ZeroResultQueries(since time.Time, limit int) ([]SearchQueryStat, error) {
+	stats := []SearchQueryStat{}
+	err := r.db.Table("search_logs").
+		Select("query, COUNT(*) AS search_count, AVG(result_count) AS average_results, MAX(created_at) AS last_searched_at").
+		Where("created_at >= ?", since).
+		Group("query").
+		Having("(array_agg(result_count ORDER BY created_at DESC))[1] = 0").
+		Order("search_count DESC, query").
+		Limit(limit).
+		Scan(&stats).Error
+	return stats, err
+}
@@ -0,0 +1,60 @@
+package repositories
+
+import (
+	"errors"
+
+	"github.com/huy1235588/fashion-e-commerce/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ShippingZoneRepository defines the interface for shipping zone data access
+type ShippingZoneRepository interface {
+	FindMatch(province, district string) (*models.ShippingZone, error)
+	List() ([]models.ShippingZone, error)
+	Upsert(zone *models.ShippingZone) error
+	Delete(id uint) error
+}
+
+type shippingZoneRepository struct {
+	db *gorm.DB
+}
+
+// NewShippingZoneRepository creates a new shipping zone repository
+func NewShippingZoneRepository(db *gorm.DB) ShippingZoneRepository {
+	return &shippingZoneRepository{db: db}
+}
+
+// FindMatch returns the district zone if one exists, otherwise the province zone.
+// It returns nil without error when the address is not covered by any zone.
+func (r *shippingZoneRepository) FindMatch(province, district string) (*models.ShippingZone, error) {
+	var zone models.ShippingZone
+	err := r.db.Where("LOWER(province) = LOWER(?) AND (LOWER(district) = LOWER(?) OR district = '')", province, district).
+		Order("district DESC").
+		First(&zone).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &zone, nil
+}
+
+func (r *shippingZoneRepository) List() ([]models.ShippingZone, error) {
+	var zones []models.ShippingZone
+	err := r.db.Order("province ASC, district ASC").Find(&zones).Error
+	return zones, err
+}
+
+// Upsert creates the zone or updates the fee of the existing zone for the same area
+func (r *shippingZoneRepository) Upsert(zone *models.ShippingZone) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "province"}, {Name: "district"}},
+		DoUpdates: clause.AssignmentColumns([]string{"fee", "updated_at"}),
+	}).Create(zone).Error
+}
+
+func (r *shippingZoneRepository) Delete(id uint) error {
+	return r.db.Delete(&models.ShippingZone{}, id).Error
+}
@@ -20,6 +20,7 @@ type StatisticsRepository interface {
 	GetOrderStatsByStatus() ([]OrderStatusStats, error)
 	GetCustomerGrowthByPeriod(period string, limit int) ([]CustomerGrowth, error)
 	GetRevenueByCategory() ([]CategoryRevenue, error)
+	GetMostWishlistedProducts(limit int) ([]ProductWishlistCount, error)
 }
 
 type statisticsRepository struct {
@@ -46,6 +47,13 @@ type ProductSales struct {
 	TotalRevenue float64 `json:"total_revenue"`
 }
 
+// ProductWishlistCount represents how many users saved a product
+type ProductWishlistCount struct {
+	ProductID     uint   `json:"product_id"`
+	ProductName   string `json:"product_name"`
+	WishlistCount int64  `json:"wishlist_count"`
+}
+
 // OrderStatusStats represents order statistics by status
 type OrderStatusStats struct {
 	Status string `json:"status"`
@@ -214,3 +222,16 @@ func (r *statisticsRepository) GetRevenueByCategory() ([]CategoryRevenue, error)
 		Scan(&results).Error
 	return results, err
 }
+
+// GetMostWishlistedProducts returns the products saved to the most wishlists
+func (r *statisticsRepository) GetMostWishlistedProducts(limit int) ([]ProductWishlistCount, error) {
+	var results []ProductWishlistCount
+	err := r.db.Table("wishlists").
+		Select("wishlists.product_id, products.name as product_name, COUNT(*) as wishlist_count").
+		Joins("JOIN products ON products.id = wishlists.product_id").
+		Group("wishlists.product_id, products.name").
+		Order("wishlist_count DESC").
+		Limit(limit).
+		Scan(&results).Error
+	return results, err
+}
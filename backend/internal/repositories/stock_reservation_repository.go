@@ -0,0 +1,76 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/huy1235588/fashion-e-commerce/internal/models"
+	"gorm.io/gorm"
+)
+
+// StockReservationRepository defines the interface for stock reservation data access
+type StockReservationRepository interface {
+	Create(reservation *models.StockReservation) error
+	FindByOrderID(orderID uint) ([]models.StockReservation, error)
+	SumActiveByVariant(variantID uint) (int, error)
+	FindExpiredOrderIDs(now time.Time, limit int) ([]uint, error)
+	Resolve(id uint, status models.ReservationStatus, reason string) (bool, error)
+}
+
+type stockReservationRepository struct {
+	db *gorm.DB
+}
+
+// NewStockReservationRepository creates a new stock reservation repository
+func NewStockReservationRepository(db *gorm.DB) StockReservationRepository {
+	return &stockReservationRepository{db: db}
+}
+
+func (r *stockReservationRepository) Create(reservation *models.StockReservation) error {
+	return r.db.Create(reservation).Error
+}
+
+func (r *stockReservationRepository) FindByOrderID(orderID uint) ([]models.StockReservation, error) {
+	var reservations []models.StockReservation
+	err := r.db.Where("order_id = ?", orderID).
+		Order("id ASC").
+		Find(&reservations).Error
+	return reservations, err
+}
+
+// SumActiveByVariant returns the quantity currently held by active reservations for a variant
+func (r *stockReservationRepository) SumActiveByVariant(variantID uint) (int, error) {
+	var total int
+	err := r.db.Model(&models.StockReservation{}).
+		Where("variant_id = ? AND status = ?", variantID, models.ReservationStatusActive).
+		Select("COALESCE(SUM(quantity), 0)").
+		Scan(&total).Error
+	return total, err
+}
+
+// FindExpiredOrderIDs returns orders that still have active reservations past their expiry
+func (r *stockReservationRepository) FindExpiredOrderIDs(now time.Time, limit int) ([]uint, error) {
+	var orderIDs []uint
+	err := r.db.Model(&models.StockReservation{}).
+		Where("status = ? AND expires_at < ?", models.ReservationStatusActive, now).
+		Distinct("order_id").
+		Limit(limit).
+		Pluck("order_id", &orderIDs).Error
+	return orderIDs, err
+}
+
+// Resolve moves an active reservation to a terminal status.
+// It reports false when the reservation was already resolved by someone else.
+func (r *stockReservationRepository) Resolve(id uint, status models.ReservationStatus, reason string) (bool, error) {
+	now := time.Now()
+	result := r.db.Model(&models.StockReservation{}).
+		Where("id = ? AND status = ?", id, models.ReservationStatusActive).
+		Updates(map[string]interface{}{
+			"status":      status,
+			"reason":      reason,
+			"resolved_at": now,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
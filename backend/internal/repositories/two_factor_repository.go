@@ -0,0 +1,145 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"github.com/huy1235588/fashion-e-commerce/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// TwoFactorRepository handles TOTP authenticators, backup codes and pending
+// second-factor logins
+type TwoFactorRepository interface {
+	FindByUser(userID uint) (*models.TwoFactorAuth, error)
+	FindByUserForUpdate(userID uint) (*models.TwoFactorAuth, error)
+	Save(auth *models.TwoFactorAuth) error
+	DeleteForUser(userID uint) error
+	UpdateLastUsedStep(id uint, step int64) error
+	ReplaceBackupCodes(userID uint, codeHashes []string) error
+	UseBackupCode(userID uint, codeHash string) (bool, error)
+	CountUnusedBackupCodes(userID uint) (int64, error)
+	CreateChallenge(challenge *models.TwoFactorChallenge) error
+	FindChallengeForUpdate(tokenHash string) (*models.TwoFactorChallenge, error)
+	RecordChallengeFailure(id uint) error
+	DeleteChallenge(id uint) error
+	DeleteExpiredChallenges(before time.Time) (int64, error)
+}
+
+type twoFactorRepository struct {
+	db *gorm.DB
+}
+
+// NewTwoFactorRepository creates a new two-factor repository
+func NewTwoFactorRepository(db *gorm.DB) TwoFactorRepository {
+	return &twoFactorRepository{db: db}
+}
+
+// FindByUser returns the authenticator of a user, or nil if they have none
+func (r *twoFactorRepository) FindByUser(userID uint) (*models.TwoFactorAuth, error) {
+	return r.findByUser(r.db, userID)
+}
+
+// FindByUserForUpdate is FindByUser with the row locked, so a TOTP code
+// cannot be accepted twice by concurrent requests
+func (r *twoFactorRepository) FindByUserForUpdate(userID uint) (*models.TwoFactorAuth, error) {
+	return r.findByUser(r.db.Clauses(clause.Locking{Strength: "UPDATE"}), userID)
+}
+
+func (r *twoFactorRepository) findByUser(db *gorm.DB, userID uint) (*models.TwoFactorAuth, error) {
+	var auth models.TwoFactorAuth
+	err := db.Where("user_id = ?", userID).First(&auth).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &auth, nil
+}
+
+// Save creates or updates an authenticator
+func (r *twoFactorRepository) Save(auth *models.TwoFactorAuth) error {
+	return r.db.Save(auth).Error
+}
+
+// DeleteForUser removes the authenticator and backup codes of a user
+func (r *twoFactorRepository) DeleteForUser(userID uint) error {
+	if err := r.db.Where("user_id = ?", userID).Delete(&models.TwoFactorBackupCode{}).Error; err != nil {
+		return err
+	}
+	return r.db.Where("user_id = ?", userID).Delete(&models.TwoFactorAuth{}).Error
+}
+
+// UpdateLastUsedStep records the time step of an accepted TOTP code
+func (r *twoFactorRepository) UpdateLastUsedStep(id uint, step int64) error {
+	return r.db.Model(&models.TwoFactorAuth{}).Where("id = ?", id).Update("last_used_step", step).Error
+}
+
+// ReplaceBackupCodes deletes the backup codes of a user and stores new ones
+func (r *twoFactorRepository) ReplaceBackupCodes(userID uint, codeHashes []string) error {
+	if err := r.db.Where("user_id = ?", userID).Delete(&models.TwoFactorBackupCode{}).Error; err != nil {
+		return err
+	}
+
+	codes := make([]models.TwoFactorBackupCode, len(codeHashes))
+	for i, hash := range codeHashes {
+		codes[i] = models.TwoFactorBackupCode{UserID: userID, CodeHash: hash}
+	}
+	return r.db.Create(&codes).Error
+}
+
+// UseBackupCode marks an unused backup code as used and reports whether there was one
+func (r *twoFactorRepository) UseBackupCode(userID uint, codeHash string) (bool, error) {
+	result := r.db.Model(&models.TwoFactorBackupCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", time.Now())
+	return result.RowsAffected > 0, result.Error
+}
+
+// CountUnusedBackupCodes counts the backup codes a user has left
+func (r *twoFactorRepository) CountUnusedBackupCodes(userID uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.TwoFactorBackupCode{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Count(&count).Error
+	return count, err
+}
+
+// CreateChallenge stores a login waiting for its second factor
+func (r *twoFactorRepository) CreateChallenge(challenge *models.TwoFactorChallenge) error {
+	return r.db.Create(challenge).Error
+}
+
+// FindChallengeForUpdate finds and locks an unexpired challenge by its token hash
+func (r *twoFactorRepository) FindChallengeForUpdate(tokenHash string) (*models.TwoFactorChallenge, error) {
+	var challenge models.TwoFactorChallenge
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("token_hash = ? AND expires_at > ?", tokenHash, time.Now()).
+		First(&challenge).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("invalid or expired two-factor login, please log in again")
+		}
+		return nil, err
+	}
+	return &challenge, nil
+}
+
+// RecordChallengeFailure counts a wrong code for a challenge
+func (r *twoFactorRepository) RecordChallengeFailure(id uint) error {
+	return r.db.Model(&models.TwoFactorChallenge{}).Where("id = ?", id).
+		Update("attempts", gorm.Expr("attempts + 1")).Error
+}
+
+// DeleteChallenge deletes a challenge
+func (r *twoFactorRepository) DeleteChallenge(id uint) error {
+	return r.db.Delete(&models.TwoFactorChallenge{}, id).Error
+}
+
+// DeleteExpiredChallenges deletes challenges that expired before the given time
+func (r *twoFactorRepository) DeleteExpiredChallenges(before time.Time) (int64, error) {
+	result := r.db.Where("expires_at < ?", before).Delete(&models.TwoFactorChallenge{})
+	return result.RowsAffected, result.Error
+}
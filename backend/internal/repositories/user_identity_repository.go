@@ -0,0 +1,80 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"github.com/huy1235588/fashion-e-commerce/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// UserIdentityRepository handles external identities and social logins in progress
+type UserIdentityRepository interface {
+	Create(identity *models.UserIdentity) error
+	FindBySubject(provider, subject string) (*models.UserIdentity, error)
+	UpdateEmail(id uint, email string) error
+	CreateState(state *models.OAuthState) error
+	ConsumeState(stateHash string) (*models.OAuthState, error)
+	DeleteExpiredStates(before time.Time) (int64, error)
+}
+
+type userIdentityRepository struct {
+	db *gorm.DB
+}
+
+// NewUserIdentityRepository creates a new user identity repository
+func NewUserIdentityRepository(db *gorm.DB) UserIdentityRepository {
+	return &userIdentityRepository{db: db}
+}
+
+// Create links a provider account to a user
+func (r *userIdentityRepository) Create(identity *models.UserIdentity) error {
+	return r.db.Create(identity).Error
+}
+
+// FindBySubject finds the identity of a provider account, or returns nil if it
+// is not linked to any user
+func (r *userIdentityRepository) FindBySubject(provider, subject string) (*models.UserIdentity, error) {
+	var identity models.UserIdentity
+	err := r.db.Where("provider = ? AND subject = ?", provider, subject).First(&identity).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &identity, nil
+}
+
+// UpdateEmail records the email the provider reported at the latest login
+func (r *userIdentityRepository) UpdateEmail(id uint, email string) error {
+	return r.db.Model(&models.UserIdentity{}).Where("id = ?", id).Update("email", email).Error
+}
+
+// CreateState stores a social login in progress
+func (r *userIdentityRepository) CreateState(state *models.OAuthState) error {
+	return r.db.Create(state).Error
+}
+
+// ConsumeState deletes and returns an unexpired login state so each state can
+// complete at most one login
+func (r *userIdentityRepository) ConsumeState(stateHash string) (*models.OAuthState, error) {
+	var state models.OAuthState
+	result := r.db.Clauses(clause.Returning{}).
+		Where("state_hash = ? AND expires_at > ?", stateHash, time.Now()).
+		Delete(&state)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, errors.New("invalid or expired login state")
+	}
+	return &state, nil
+}
+
+// DeleteExpiredStates deletes abandoned logins that expired before the given time
+func (r *userIdentityRepository) DeleteExpiredStates(before time.Time) (int64, error) {
+	result := r.db.Where("expires_at < ?", before).Delete(&models.OAuthState{})
+	return result.RowsAffected, result.Error
+}
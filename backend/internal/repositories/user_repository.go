@@ -2,10 +2,12 @@ package repositories
 
 import (
 	"errors"
+	"time"
 
 	"github.com/huy1235588/fashion-e-commerce/internal/models"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // UserRepository handles database operations for users
@@ -16,6 +18,8 @@ type UserRepository interface {
 	Update(user *models.User) error
 	List(limit, offset int, filters map[string]interface{}) ([]models.User, int64, error)
 	UpdateActiveStatus(userID uint, isActive bool) error
+	IncrementTokenVersion(userID uint) error
+	MarkVerified(userID uint) error
 }
 
 type userRepository struct {
@@ -96,12 +100,28 @@ func (r *userRepository) UpdateActiveStatus(userID uint, isActive bool) error {
 	return r.db.Model(&models.User{}).Where("id = ?", userID).Update("is_active", isActive).Error
 }
 
+// IncrementTokenVersion invalidates every access token issued to a user
+func (r *userRepository) IncrementTokenVersion(userID uint) error {
+	return r.db.Model(&models.User{}).Where("id = ?", userID).
+		Update("token_version", gorm.Expr("token_version + 1")).Error
+}
+
+// MarkVerified records that a user confirmed their email address
+func (r *userRepository) MarkVerified(userID uint) error {
+	return r.db.Model(&models.User{}).Where("id = ? AND verified_at IS NULL", userID).
+		Update("verified_at", time.Now()).Error
+}
+
 // PasswordResetCodeRepository handles password reset codes
 type PasswordResetCodeRepository interface {
 	Create(code *models.PasswordResetCode) error
-	FindByCode(code string) (*models.PasswordResetCode, error)
+	FindActiveForUpdate(userID uint) (*models.PasswordResetCode, error)
+	RecordFailedAttempt(codeID uint, maxAttempts int) error
 	MarkAsUsed(codeID uint) error
-	DeleteExpired() error
+	InvalidateForUser(userID uint) error
+	CountSinceForUser(userID uint, since time.Time) (int64, error)
+	CountSinceForIP(ipAddress string, since time.Time) (int64, error)
+	DeleteExpired(before time.Time) (int64, error)
 }
 
 type passwordResetCodeRepository struct {
@@ -118,25 +138,64 @@ func (r *passwordResetCodeRepository) Create(code *models.PasswordResetCode) err
 	return r.db.Create(code).Error
 }
 
-// FindByCode finds a password reset code by code string
-func (r *passwordResetCodeRepository) FindByCode(code string) (*models.PasswordResetCode, error) {
+// FindActiveForUpdate finds the newest usable reset code of a user and locks
+// it, or returns nil if there is none
+func (r *passwordResetCodeRepository) FindActiveForUpdate(userID uint) (*models.PasswordResetCode, error) {
 	var resetCode models.PasswordResetCode
-	err := r.db.Where("code = ? AND used = ? AND expires_at > NOW()", code, false).First(&resetCode).Error
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id = ? AND used = ? AND expires_at > NOW()", userID, false).
+		Order("created_at DESC").
+		First(&resetCode).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New("invalid or expired reset code")
+			return nil, nil
 		}
 		return nil, err
 	}
 	return &resetCode, nil
 }
 
+// RecordFailedAttempt counts a wrong guess and burns the code once it reaches maxAttempts
+func (r *passwordResetCodeRepository) RecordFailedAttempt(codeID uint, maxAttempts int) error {
+	return r.db.Model(&models.PasswordResetCode{}).Where("id = ?", codeID).
+		Updates(map[string]interface{}{
+			"attempts": gorm.Expr("attempts + 1"),
+			"used":     gorm.Expr("attempts + 1 >= ?", maxAttempts),
+		}).Error
+}
+
 // MarkAsUsed marks a password reset code as used
 func (r *passwordResetCodeRepository) MarkAsUsed(codeID uint) error {
 	return r.db.Model(&models.PasswordResetCode{}).Where("id = ?", codeID).Update("used", true).Error
 }
 
-// DeleteExpired deletes expired password reset codes
-func (r *passwordResetCodeRepository) DeleteExpired() error {
-	return r.db.Where("expires_at < NOW()").Delete(&models.PasswordResetCode{}).Error
+// InvalidateForUser marks every outstanding reset code of a user as used
+func (r *passwordResetCodeRepository) InvalidateForUser(userID uint) error {
+	return r.db.Model(&models.PasswordResetCode{}).
+		Where("user_id = ? AND used = ?", userID, false).
+		Update("used", true).Error
+}
+
+// CountSinceForUser counts the reset codes issued to a user since the given time
+func (r *passwordResetCodeRepository) CountSinceForUser(userID uint, since time.Time) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.PasswordResetCode{}).
+		Where("user_id = ? AND created_at >= ?", userID, since).
+		Count(&count).Error
+	return count, err
+}
+
+// CountSinceForIP counts the reset codes requested from an IP address since the given time
+func (r *passwordResetCodeRepository) CountSinceForIP(ipAddress string, since time.Time) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.PasswordResetCode{}).
+		Where("ip_address = ? AND created_at >= ?", ipAddress, since).
+		Count(&count).Error
+	return count, err
+}
+
+// DeleteExpired deletes reset codes that expired before the given time
+func (r *passwordResetCodeRepository) DeleteExpired(before time.Time) (int64, error) {
+	result := r.db.Where("expires_at < ?", before).Delete(&models.PasswordResetCode{})
+	return result.RowsAffected, result.Error
 }
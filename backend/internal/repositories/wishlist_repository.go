@@ -0,0 +1,141 @@
+package repositories
+
+import (
+	"errors"
+
+	"github.com/huy1235588/fashion-e-commerce/internal/models"
+	"gorm.io/gorm"
+)
+
+// WishlistRepository handles saved products and restock subscriptions
+type WishlistRepository interface {
+	ListByUser(userID uint) ([]models.Wishlist, error)
+	FindByID(id uint) (*models.Wishlist, error)
+	FindByUserAndProduct(userID, productID uint) (*models.Wishlist, error)
+	Create(item *models.Wishlist) error
+	Update(item *models.Wishlist) error
+	Delete(id uint) error
+
+	ListSubscriptions(userID uint) ([]models.RestockSubscription, error)
+	FindSubscription(userID, variantID uint) (*models.RestockSubscription, error)
+	CreateSubscription(subscription *models.RestockSubscription) error
+	DeleteSubscription(userID, variantID uint) error
+	DeleteSubscriptionByID(id uint) error
+	FindDueSubscriptions(variantID *uint, limit int) ([]models.RestockSubscription, error)
+}
+
+type wishlistRepository struct {
+	db *gorm.DB
+}
+
+// NewWishlistRepository creates a new wishlist repository
+func NewWishlistRepository(db *gorm.DB) WishlistRepository {
+	return &wishlistRepository{db: db}
+}
+
+// ListByUser returns a user's saved products, newest first
+func (r *wishlistRepository) ListByUser(userID uint) ([]models.Wishlist, error) {
+	var items []models.Wishlist
+	err := r.db.Preload("Product.Images").
+		Preload("Product.Variants").
+		Preload("Variant").
+		Where("user_id = ?", userID).
+		Order("created_at DESC").
+		Find(&items).Error
+	return items, err
+}
+
+func (r *wishlistRepository) FindByID(id uint) (*models.Wishlist, error) {
+	var item models.Wishlist
+	err := r.db.Preload("Product.Images").
+		Preload("Product.Variants").
+		Preload("Variant").
+		First(&item, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("wishlist item not found")
+		}
+		return nil, err
+	}
+	return &item, nil
+}
+
+// FindByUserAndProduct returns the entry of a product in a user's wishlist,
+// or nil if it is not there
+func (r *wishlistRepository) FindByUserAndProduct(userID, productID uint) (*models.Wishlist, error) {
+	var item models.Wishlist
+	err := r.db.Where("user_id = ? AND product_id = ?", userID, productID).First(&item).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &item, nil
+}
+
+func (r *wishlistRepository) Create(item *models.Wishlist) error {
+	return r.db.Create(item).Error
+}
+
+func (r *wishlistRepository) Update(item *models.Wishlist) error {
+	return r.db.Model(item).Update("variant_id", item.VariantID).Error
+}
+
+func (r *wishlistRepository) Delete(id uint) error {
+	return r.db.Delete(&models.Wishlist{}, id).Error
+}
+
+// ListSubscriptions returns the variants a user waits for
+func (r *wishlistRepository) ListSubscriptions(userID uint) ([]models.RestockSubscription, error) {
+	var subscriptions []models.RestockSubscription
+	err := r.db.Preload("Variant").
+		Where("user_id = ?", userID).
+		Order("created_at DESC").
+		Find(&subscriptions).Error
+	return subscriptions, err
+}
+
+// FindSubscription returns a user's subscription to a variant, or nil
+func (r *wishlistRepository) FindSubscription(userID, variantID uint) (*models.RestockSubscription, error) {
+	var subscription models.RestockSubscription
+	err := r.db.Where("user_id = ? AND variant_id = ?", userID, variantID).First(&subscription).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &subscription, nil
+}
+
+func (r *wishlistRepository) CreateSubscription(subscription *models.RestockSubscription) error {
+	return r.db.Create(subscription).Error
+}
+
+func (r *wishlistRepository) DeleteSubscription(userID, variantID uint) error {
+	return r.db.Where("user_id = ? AND variant_id = ?", userID, variantID).
+		Delete(&models.RestockSubscription{}).Error
+}
+
+func (r *wishlistRepository) DeleteSubscriptionByID(id uint) error {
+	return r.db.Delete(&models.RestockSubscription{}, id).Error
+}
+
+// FindDueSubscriptions returns subscriptions whose variant has stock again,
+// for one variant or, when variantID is nil, for all of them
+func (r *wishlistRepository) FindDueSubscriptions(variantID *uint, limit int) ([]models.RestockSubscription, error) {
+	query := r.db.Joins("JOIN product_variants ON product_variants.id = restock_subscriptions.variant_id").
+		Where("product_variants.stock_quantity > 0")
+	if variantID != nil {
+		query = query.Where("restock_subscriptions.variant_id = ?", *variantID)
+	}
+
+	var subscriptions []models.RestockSubscription
+	err := query.Preload("User").
+		Preload("Variant").
+		Order("restock_subscriptions.created_at").
+		Limit(limit).
+		Find(&subscriptions).Error
+	return subscriptions, err
+}
@@ -10,10 +10,12 @@ import (
 
 // AdminService handles business logic for admin operations
 type AdminService struct {
-	db          *gorm.DB
-	userRepo    repositories.UserRepository
-	productRepo repositories.ProductRepository
-	orderRepo   repositories.OrderRepository
+	db           *gorm.DB
+	userRepo     repositories.UserRepository
+	productRepo  repositories.ProductRepository
+	orderRepo    repositories.OrderRepository
+	roleRepo     repositories.RoleRepository
+	orderService OrderService
 }
 
 // NewAdminService creates a new AdminService
@@ -22,12 +24,16 @@ func NewAdminService(
 	userRepo repositories.UserRepository,
 	productRepo repositories.ProductRepository,
 	orderRepo repositories.OrderRepository,
+	roleRepo repositories.RoleRepository,
+	orderService OrderService,
 ) *AdminService {
 	return &AdminService{
-		db:          db,
-		userRepo:    userRepo,
-		productRepo: productRepo,
-		orderRepo:   orderRepo,
+		db:           db,
+		userRepo:     userRepo,
+		productRepo:  productRepo,
+		orderRepo:    orderRepo,
+		roleRepo:     roleRepo,
+		orderService: orderService,
 	}
 }
 
@@ -148,24 +154,11 @@ func (s *AdminService) GetDashboardStats() (*DashboardStats, error) {
 	return stats, nil
 }
 
-// UpdateOrderStatus updates order status (admin only)
+// UpdateOrderStatus updates order status (admin only). Only allowed
+// transitions are accepted; cancelling restores stock and refunds paid
+// online orders like a customer cancel.
 func (s *AdminService) UpdateOrderStatus(orderID uint, status string) error {
-	// Validate status
-	validStatuses := []string{"pending", "processing", "shipping", "delivered", "cancelled"}
-	isValid := false
-	for _, v := range validStatuses {
-		if status == v {
-			isValid = true
-			break
-		}
-	}
-	if !isValid {
-		return gorm.ErrInvalidData
-	}
-
-	return s.db.Model(&models.Order{}).
-		Where("id = ?", orderID).
-		Update("status", status).Error
+	return s.orderService.UpdateOrderStatus(orderID, models.OrderStatus(status))
 }
 
 // ListAllUsers returns all users with pagination
@@ -191,10 +184,14 @@ func (s *AdminService) ListAllUsers(page, limit int) ([]models.User, int64, erro
 	return users, total, nil
 }
 
-// UpdateUserRole updates user role (admin only)
+// UpdateUserRole assigns one of the roles in the roles table to a user.
+// Access tokens issued for the old role stop working immediately.
 func (s *AdminService) UpdateUserRole(userID uint, role string) error {
-	if role != "user" && role != "admin" {
-		return gorm.ErrInvalidData
+	if _, err := s.roleRepo.FindByName(role); err != nil {
+		return err
+	}
+	if _, err := s.userRepo.FindByID(userID); err != nil {
+		return err
 	}
 
 	return s.db.Model(&models.User{}).
@@ -202,6 +199,24 @@ func (s *AdminService) UpdateUserRole(userID uint, role string) error {
 		Update("role", role).Error
 }
 
+// UpdateUserStatus activates or deactivates a user (admin only).
+// Deactivating a user also revokes all of their tokens.
+func (s *AdminService) UpdateUserStatus(userID uint, isActive bool) error {
+	if _, err := s.userRepo.FindByID(userID); err != nil {
+		return err
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := repositories.NewUserRepository(tx).UpdateActiveStatus(userID, isActive); err != nil {
+			return err
+		}
+		if isActive {
+			return nil
+		}
+		return revokeUserTokens(tx, userID)
+	})
+}
+
 // ListAllOrders returns all orders with pagination and optional status filter
 func (s *AdminService) ListAllOrders(page, limit int, status string) ([]models.Order, int64, error) {
 	var orders []models.Order
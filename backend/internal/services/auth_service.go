@@ -1,43 +1,120 @@
 package services
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
-	"math/rand"
+	"log"
+	"math/big"
+	"net/url"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/huy1235588/fashion-e-commerce/internal/models"
 	"github.com/huy1235588/fashion-e-commerce/internal/repositories"
 	"github.com/huy1235588/fashion-e-commerce/internal/utils"
+	"gorm.io/gorm"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
+// ClientInfo describes the device a session was created from
+type ClientInfo struct {
+	UserAgent string
+	IPAddress string
+}
+
+// TokenPair is a short-lived access token and the refresh token used to renew it
+type TokenPair struct {
+	AccessToken      string    `json:"token"`
+	RefreshToken     string    `json:"refresh_token"`
+	ExpiresIn        int       `json:"expires_in"` // seconds until the access token expires
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+}
+
+// ErrVerificationRateLimited is returned when verification emails are requested too often
+var ErrVerificationRateLimited = errors.New("too many verification emails requested")
+
+// ErrInvalidResetCode is returned for wrong, expired or used password reset codes
+var ErrInvalidResetCode = errors.New("invalid or expired reset code")
+
+// ErrResetRateLimited is returned when too many reset codes are requested from one IP address
+var ErrResetRateLimited = errors.New("too many password reset requests, please try again later")
+
+// AuthSettings holds the tunable parts of the authentication flows
+type AuthSettings struct {
+	RefreshTokenTTL            time.Duration
+	VerificationURL            string // link sent by email; the token is appended as ?token=
+	VerificationTTL            time.Duration
+	VerificationResendCooldown time.Duration
+	VerificationMaxPerDay      int
+	ResetCodeTTL               time.Duration
+	ResetCodeMaxAttempts       int
+	ResetCodeMaxPerHour        int // per email address
+	ResetCodeMaxPerIPPerHour   int
+}
+
 // AuthService handles authentication business logic
 type AuthService interface {
 	Register(email, password, fullName, phone string) (*models.User, error)
-	Login(email, password string) (*models.User, string, error)
+	Login(email, password string, client ClientInfo) (*models.User, *TokenPair, error)
+	StartSession(user *models.User, client ClientInfo) (*TokenPair, error)
+	VerifyTwoFactor(challengeToken, code string, client ClientInfo) (*models.User, *TokenPair, error)
+	UnlockAccount(token string) error
+	Refresh(refreshToken string, client ClientInfo) (*TokenPair, error)
+	Logout(sessionID string) error
+	LogoutAll(userID uint) error
+	IsTokenRevoked(claims *utils.JWTClaims) (bool, error)
 	GetProfile(userID uint) (*models.User, error)
 	UpdateProfile(userID uint, fullName, phone string) (*models.User, error)
-	SendResetCode(email string) error
-	VerifyResetCode(code string) (uint, error)
-	ResetPassword(userID uint, newPassword string) error
+	SendResetCode(email, ipAddress string) error
+	ResetPassword(email, code, newPassword string) error
+	StartResetCodeCleanup(ctx context.Context, interval time.Duration)
+	VerifyEmail(token string) (*models.User, error)
+	ResendVerification(userID uint) error
 }
 
 type authService struct {
-	userRepo      repositories.UserRepository
-	resetCodeRepo repositories.PasswordResetCodeRepository
-	jwtUtil       *utils.JWTUtil
-	emailService  *utils.EmailService
+	userRepo         repositories.UserRepository
+	resetCodeRepo    repositories.PasswordResetCodeRepository
+	refreshTokenRepo repositories.RefreshTokenRepository
+	verificationRepo repositories.EmailVerificationRepository
+	loginThrottle    LoginThrottleService
+	twoFactor        TwoFactorService
+	jwtUtil          *utils.JWTUtil
+	emailService     *utils.EmailService
+	db               *gorm.DB
+	settings         AuthSettings
 }
 
 // NewAuthService creates a new auth service
-func NewAuthService(userRepo repositories.UserRepository, resetCodeRepo repositories.PasswordResetCodeRepository, jwtUtil *utils.JWTUtil, emailService *utils.EmailService) AuthService {
+func NewAuthService(
+	userRepo repositories.UserRepository,
+	resetCodeRepo repositories.PasswordResetCodeRepository,
+	refreshTokenRepo repositories.RefreshTokenRepository,
+	verificationRepo repositories.EmailVerificationRepository,
+	loginThrottle LoginThrottleService,
+	twoFactor TwoFactorService,
+	jwtUtil *utils.JWTUtil,
+	emailService *utils.EmailService,
+	db *gorm.DB,
+	settings AuthSettings,
+) AuthService {
 	return &authService{
-		userRepo:      userRepo,
-		resetCodeRepo: resetCodeRepo,
-		jwtUtil:       jwtUtil,
-		emailService:  emailService,
+		userRepo:         userRepo,
+		resetCodeRepo:    resetCodeRepo,
+		refreshTokenRepo: refreshTokenRepo,
+		verificationRepo: verificationRepo,
+		loginThrottle:    loginThrottle,
+		twoFactor:        twoFactor,
+		jwtUtil:          jwtUtil,
+		emailService:     emailService,
+		db:               db,
+		settings:         settings,
 	}
 }
 
@@ -74,34 +151,287 @@ func (s *authService) Register(email, password, fullName, phone string) (*models
 		return nil, err
 	}
 
+	// The account works right away; the link only proves the address is real
+	if err := s.sendVerification(user); err != nil {
+		fmt.Printf("Failed to send verification email to %s: %v\n", user.Email, err)
+	}
+
 	return user, nil
 }
 
-// Login authenticates a user and returns JWT token
-func (s *authService) Login(email, password string) (*models.User, string, error) {
+// Login authenticates a user and starts a new session. Repeated failures for
+// the same email or IP address first slow down and then lock out further
+// attempts; see LoginThrottleService.
+func (s *authService) Login(email, password string, client ClientInfo) (*models.User, *TokenPair, error) {
+	if err := s.loginThrottle.Check(email, client.IPAddress); err != nil {
+		return nil, nil, err
+	}
+
 	// Find user by email
 	user, err := s.userRepo.FindByEmail(email)
 	if err != nil {
-		return nil, "", errors.New("invalid credentials")
+		s.recordLoginFailure(email, client, nil)
+		return nil, nil, errors.New("invalid credentials")
 	}
 
 	// Check if account is active
 	if !user.IsActive {
-		return nil, "", errors.New("account is deactivated")
+		return nil, nil, errors.New("account is deactivated")
 	}
 
 	// Verify password
 	if !s.VerifyPassword(password, user.Password) {
-		return nil, "", errors.New("invalid credentials")
+		s.recordLoginFailure(email, client, user)
+		return nil, nil, errors.New("invalid credentials")
+	}
+
+	// With two-factor authentication the failures are only forgotten once
+	// the second step passes, so wrong codes keep counting towards a lockout
+	tokens, err := s.StartSession(user, client)
+	if err != nil {
+		return nil, nil, err
+	}
+	s.recordLoginSuccess(user.Email)
+
+	return user, tokens, nil
+}
+
+// StartSession issues tokens for a new session of a user who has already been
+// authenticated, by password or by an external identity provider. Users with
+// two-factor authentication get a *TwoFactorRequiredError instead, and the
+// session starts in VerifyTwoFactor.
+func (s *authService) StartSession(user *models.User, client ClientInfo) (*TokenPair, error) {
+	enabled, err := s.twoFactor.IsEnabled(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if enabled {
+		challenge, err := s.twoFactor.CreateChallenge(user.ID)
+		if err != nil {
+			return nil, err
+		}
+		return nil, challenge
+	}
+
+	tokens, _, err := s.issueTokens(s.refreshTokenRepo, user, uuid.New().String(), false, client)
+	if err != nil {
+		return nil, errors.New("failed to generate token")
+	}
+	return tokens, nil
+}
+
+// VerifyTwoFactor completes a login with a TOTP or backup code. Wrong codes
+// count as failed logins for the account and IP address, so starting new
+// challenges does not give unlimited guesses, and a locked account cannot
+// finish logging in.
+func (s *authService) VerifyTwoFactor(challengeToken, code string, client ClientInfo) (*models.User, *TokenPair, error) {
+	userID, verifyErr := s.twoFactor.VerifyChallenge(challengeToken, code)
+	if userID == 0 {
+		return nil, nil, verifyErr
+	}
+
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if verifyErr != nil {
+		if errors.Is(verifyErr, ErrInvalidTwoFactorCode) {
+			s.recordLoginFailure(user.Email, client, user)
+		}
+		return nil, nil, verifyErr
+	}
+	if err := s.loginThrottle.Check(user.Email, client.IPAddress); err != nil {
+		return nil, nil, err
+	}
+	if !user.IsActive {
+		return nil, nil, errors.New("account is deactivated")
 	}
 
-	// Generate JWT token
-	token, err := s.jwtUtil.GenerateToken(user.ID, user.Email, user.Role)
+	tokens, _, err := s.issueTokens(s.refreshTokenRepo, user, uuid.New().String(), true, client)
 	if err != nil {
-		return nil, "", errors.New("failed to generate token")
+		return nil, nil, errors.New("failed to generate token")
+	}
+	s.recordLoginSuccess(user.Email)
+	return user, tokens, nil
+}
+
+// UnlockAccount lifts a login lockout with the token from the lockout email
+func (s *authService) UnlockAccount(token string) error {
+	return s.loginThrottle.Unlock(token)
+}
+
+// recordLoginFailure counts a failed login; throttling problems are logged
+// rather than failing the request
+func (s *authService) recordLoginFailure(email string, client ClientInfo, user *models.User) {
+	if err := s.loginThrottle.RecordFailure(email, client.IPAddress, user); err != nil {
+		log.Printf("Failed to record login failure for %s: %v", email, err)
+	}
+}
+
+// recordLoginSuccess forgets the failed logins of an account once a login
+// fully succeeded
+func (s *authService) recordLoginSuccess(email string) {
+	if err := s.loginThrottle.RecordSuccess(email); err != nil {
+		log.Printf("Failed to reset login failures for %s: %v", email, err)
+	}
+}
+
+// Refresh exchanges a refresh token for a new token pair. The presented token
+// is rotated out; presenting it again revokes the whole session, since that
+// means it was copied.
+func (s *authService) Refresh(refreshToken string, client ClientInfo) (*TokenPair, error) {
+	var tokens *TokenPair
+	var reused bool
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		refreshRepo := repositories.NewRefreshTokenRepository(tx)
+
+		current, err := refreshRepo.FindByHashForUpdate(hashToken(refreshToken))
+		if err != nil {
+			return errors.New("invalid refresh token")
+		}
+
+		if current.RevokedAt != nil {
+			if current.ReplacedByID != nil {
+				reused = true
+				return refreshRepo.RevokeSession(current.SessionID)
+			}
+			return errors.New("invalid refresh token")
+		}
+		if time.Now().After(current.ExpiresAt) {
+			return errors.New("refresh token expired")
+		}
+
+		user, err := repositories.NewUserRepository(tx).FindByID(current.UserID)
+		if err != nil {
+			return errors.New("invalid refresh token")
+		}
+		if !user.IsActive {
+			return errors.New("account is deactivated")
+		}
+
+		var next *models.RefreshToken
+		tokens, next, err = s.issueTokens(refreshRepo, user, current.SessionID, current.TwoFactor, client)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		current.RevokedAt = &now
+		current.ReplacedByID = &next.ID
+		return refreshRepo.Update(current)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if reused {
+		return nil, errors.New("refresh token was already used; please log in again")
+	}
+
+	return tokens, nil
+}
+
+// Logout ends a single session
+func (s *authService) Logout(sessionID string) error {
+	return s.refreshTokenRepo.RevokeSession(sessionID)
+}
+
+// LogoutAll ends every session of a user and invalidates their access tokens
+func (s *authService) LogoutAll(userID uint) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		return revokeUserTokens(tx, userID)
+	})
+}
+
+// IsTokenRevoked reports whether an otherwise valid access token must be
+// rejected: the user is gone or deactivated, their tokens were revoked, their
+// role changed since the token was issued, or the session was logged out
+func (s *authService) IsTokenRevoked(claims *utils.JWTClaims) (bool, error) {
+	user, err := s.userRepo.FindByID(claims.UserID)
+	if err != nil {
+		return true, nil
+	}
+	if !user.IsActive || user.TokenVersion != claims.TokenVersion {
+		return true, nil
+	}
+	// A role change takes effect on the next refresh
+	if user.Role != claims.Role {
+		return true, nil
+	}
+	if claims.SessionID == "" {
+		return true, nil
+	}
+
+	active, err := s.refreshTokenRepo.IsSessionActive(claims.SessionID)
+	if err != nil {
+		return false, err
+	}
+	return !active, nil
+}
+
+// issueTokens creates an access token and a new refresh token for a session.
+// twoFactor records whether the session passed two-factor authentication.
+func (s *authService) issueTokens(refreshRepo repositories.RefreshTokenRepository, user *models.User, sessionID string, twoFactor bool, client ClientInfo) (*TokenPair, *models.RefreshToken, error) {
+	accessToken, err := s.jwtUtil.GenerateToken(user.ID, user.Email, user.Role, sessionID, user.TokenVersion, twoFactor)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	refreshToken, err := generateSecureToken()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	record := &models.RefreshToken{
+		UserID:    user.ID,
+		SessionID: sessionID,
+		TokenHash: hashToken(refreshToken),
+		ExpiresAt: time.Now().Add(s.settings.RefreshTokenTTL),
+		UserAgent: truncate(client.UserAgent, 255),
+		IPAddress: client.IPAddress,
+		TwoFactor: twoFactor,
+	}
+	if err := refreshRepo.Create(record); err != nil {
+		return nil, nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:      accessToken,
+		RefreshToken:     refreshToken,
+		ExpiresIn:        int(s.jwtUtil.ExpiresIn().Seconds()),
+		RefreshExpiresAt: record.ExpiresAt,
+	}, record, nil
+}
+
+// revokeUserTokens invalidates every access and refresh token of a user.
+// Used on logout-all, password changes and deactivation.
+func revokeUserTokens(tx *gorm.DB, userID uint) error {
+	if err := repositories.NewUserRepository(tx).IncrementTokenVersion(userID); err != nil {
+		return err
+	}
+	return repositories.NewRefreshTokenRepository(tx).RevokeAllForUser(userID)
+}
+
+// generateSecureToken returns a random, URL-safe token
+func generateSecureToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
 	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// hashToken returns the SHA-256 hex digest stored in place of a token
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
 
-	return user, token, nil
+func truncate(value string, max int) string {
+	if len(value) > max {
+		return value[:max]
+	}
+	return value
 }
 
 // GetProfile retrieves user profile information
@@ -127,8 +457,22 @@ func (s *authService) UpdateProfile(userID uint, fullName, phone string) (*model
 	return user, nil
 }
 
-// SendResetCode generates and stores a password reset code
-func (s *authService) SendResetCode(email string) error {
+// SendResetCode emails a new password reset code. Unknown addresses and
+// addresses over their hourly limit get no email but the same response, so
+// the endpoint cannot be used to find out which accounts exist.
+func (s *authService) SendResetCode(email, ipAddress string) error {
+	since := time.Now().Add(-time.Hour)
+
+	if ipAddress != "" {
+		count, err := s.resetCodeRepo.CountSinceForIP(ipAddress, since)
+		if err != nil {
+			return err
+		}
+		if count >= int64(s.settings.ResetCodeMaxPerIPPerHour) {
+			return ErrResetRateLimited
+		}
+	}
+
 	// Find user
 	user, err := s.userRepo.FindByEmail(email)
 	if err != nil {
@@ -136,24 +480,43 @@ func (s *authService) SendResetCode(email string) error {
 		return nil
 	}
 
-	// Generate 6-digit code
-	code := s.generateResetCode()
+	count, err := s.resetCodeRepo.CountSinceForUser(user.ID, since)
+	if err != nil {
+		return err
+	}
+	if count >= int64(s.settings.ResetCodeMaxPerHour) {
+		return nil
+	}
 
-	// Create reset code record
-	resetCode := &models.PasswordResetCode{
-		UserID:    user.ID,
-		Code:      code,
-		ExpiresAt: time.Now().Add(15 * time.Minute),
-		Used:      false,
+	code, err := generateResetCode()
+	if err != nil {
+		return err
+	}
+	codeHash, err := bcrypt.GenerateFromPassword([]byte(code), bcrypt.DefaultCost)
+	if err != nil {
+		return err
 	}
 
-	if err := s.resetCodeRepo.Create(resetCode); err != nil {
+	// A new code replaces any earlier one
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		resetCodeRepo := repositories.NewPasswordResetCodeRepository(tx)
+		if err := resetCodeRepo.InvalidateForUser(user.ID); err != nil {
+			return err
+		}
+		return resetCodeRepo.Create(&models.PasswordResetCode{
+			UserID:    user.ID,
+			CodeHash:  string(codeHash),
+			IPAddress: truncate(ipAddress, 45),
+			ExpiresAt: time.Now().Add(s.settings.ResetCodeTTL),
+		})
+	})
+	if err != nil {
 		return err
 	}
 
 	// Send email with reset code
 	if s.emailService != nil {
-		if err := s.emailService.SendPasswordResetEmail(email, code); err != nil {
+		if err := s.emailService.SendPasswordResetEmail(email, code, int(s.settings.ResetCodeTTL.Minutes())); err != nil {
 			// Log error but don't fail the request
 			fmt.Printf("Failed to send reset email to %s: %v\n", email, err)
 		}
@@ -162,27 +525,19 @@ func (s *authService) SendResetCode(email string) error {
 	return nil
 }
 
-// VerifyResetCode verifies a password reset code and returns user ID
-func (s *authService) VerifyResetCode(code string) (uint, error) {
-	resetCode, err := s.resetCodeRepo.FindByCode(code)
-	if err != nil {
-		return 0, err
-	}
-
-	return resetCode.UserID, nil
-}
-
-// ResetPassword resets user password
-func (s *authService) ResetPassword(userID uint, newPassword string) error {
+// ResetPassword sets a new password using the reset code emailed to the user.
+// Every wrong code counts against the code's attempt limit; the correct one is
+// consumed and signs out every session that used the old password.
+func (s *authService) ResetPassword(email, code, newPassword string) error {
 	// Validate password length
 	if len(newPassword) < 8 {
 		return errors.New("password must be at least 8 characters")
 	}
 
 	// Find user
-	user, err := s.userRepo.FindByID(userID)
+	user, err := s.userRepo.FindByEmail(email)
 	if err != nil {
-		return err
+		return ErrInvalidResetCode
 	}
 
 	// Hash new password
@@ -191,9 +546,155 @@ func (s *authService) ResetPassword(userID uint, newPassword string) error {
 		return errors.New("failed to hash password")
 	}
 
-	// Update password
-	user.Password = hashedPassword
-	return s.userRepo.Update(user)
+	matched := false
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		resetCodeRepo := repositories.NewPasswordResetCodeRepository(tx)
+
+		resetCode, err := resetCodeRepo.FindActiveForUpdate(user.ID)
+		if err != nil || resetCode == nil {
+			return err
+		}
+		if !s.VerifyPassword(code, resetCode.CodeHash) {
+			// Commit the failed attempt
+			return resetCodeRepo.RecordFailedAttempt(resetCode.ID, s.settings.ResetCodeMaxAttempts)
+		}
+		matched = true
+
+		user.Password = hashedPassword
+		if err := repositories.NewUserRepository(tx).Update(user); err != nil {
+			return err
+		}
+		if err := resetCodeRepo.MarkAsUsed(resetCode.ID); err != nil {
+			return err
+		}
+		if err := resetCodeRepo.InvalidateForUser(user.ID); err != nil {
+			return err
+		}
+		return revokeUserTokens(tx, user.ID)
+	})
+	if err != nil {
+		return err
+	}
+	if !matched {
+		return ErrInvalidResetCode
+	}
+
+	return nil
+}
+
+// StartResetCodeCleanup periodically deletes expired password reset codes
+// until ctx is cancelled. Codes are kept for an hour after expiring so the
+// hourly request limits still see them.
+func (s *authService) StartResetCodeCleanup(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			count, err := s.resetCodeRepo.DeleteExpired(time.Now().Add(-time.Hour))
+			if err != nil {
+				log.Printf("Reset code cleanup failed: %v", err)
+				continue
+			}
+			if count > 0 {
+				log.Printf("Reset code cleanup deleted %d code(s)", count)
+			}
+		}
+	}
+}
+
+// VerifyEmail confirms a user's email address with the token from the verification email
+func (s *authService) VerifyEmail(token string) (*models.User, error) {
+	record, err := s.verificationRepo.FindByHash(hashToken(token))
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		userRepo := repositories.NewUserRepository(tx)
+		if err := userRepo.MarkVerified(record.UserID); err != nil {
+			return err
+		}
+		if err := repositories.NewEmailVerificationRepository(tx).InvalidateForUser(record.UserID); err != nil {
+			return err
+		}
+		// The address is proven now, so orders placed with it as a guest are theirs
+		user, err := userRepo.FindByID(record.UserID)
+		if err != nil {
+			return err
+		}
+		return claimGuestOrders(tx, user)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.userRepo.FindByID(record.UserID)
+}
+
+// ResendVerification sends a new verification email, at most once per cooldown
+// and a limited number of times per day
+func (s *authService) ResendVerification(userID uint) error {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return err
+	}
+	if user.VerifiedAt != nil {
+		return errors.New("email is already verified")
+	}
+
+	latest, err := s.verificationRepo.FindLatestForUser(userID)
+	if err != nil {
+		return err
+	}
+	if latest != nil {
+		if wait := s.settings.VerificationResendCooldown - time.Since(latest.CreatedAt); wait > 0 {
+			return fmt.Errorf("%w, please wait %d seconds", ErrVerificationRateLimited, int(wait.Seconds())+1)
+		}
+	}
+
+	sent, err := s.verificationRepo.CountSince(userID, time.Now().Add(-24*time.Hour))
+	if err != nil {
+		return err
+	}
+	if sent >= int64(s.settings.VerificationMaxPerDay) {
+		return fmt.Errorf("%w, please try again tomorrow", ErrVerificationRateLimited)
+	}
+
+	return s.sendVerification(user)
+}
+
+// sendVerification replaces any outstanding verification token of a user and
+// emails the new one
+func (s *authService) sendVerification(user *models.User) error {
+	token, err := generateSecureToken()
+	if err != nil {
+		return err
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		verificationRepo := repositories.NewEmailVerificationRepository(tx)
+		if err := verificationRepo.InvalidateForUser(user.ID); err != nil {
+			return err
+		}
+		return verificationRepo.Create(&models.EmailVerificationToken{
+			UserID:    user.ID,
+			TokenHash: hashToken(token),
+			ExpiresAt: time.Now().Add(s.settings.VerificationTTL),
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	if s.emailService == nil {
+		return nil
+	}
+	link := s.settings.VerificationURL + "?token=" + url.QueryEscape(token)
+	return s.emailService.SendVerificationEmail(user.Email, user.FullName, link, int(s.settings.VerificationTTL.Hours()))
 }
 
 // HashPassword hashes a password using bcrypt
@@ -208,9 +709,11 @@ func (s *authService) VerifyPassword(password, hash string) bool {
 	return err == nil
 }
 
-// generateResetCode generates a 6-digit reset code
-func (s *authService) generateResetCode() string {
-	rand.Seed(time.Now().UnixNano())
-	code := rand.Intn(900000) + 100000 // Generates 6-digit number
-	return fmt.Sprintf("%06d", code)
+// generateResetCode generates a random 6-digit reset code
+func generateResetCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
 }
@@ -1,39 +1,101 @@
 package services
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
 	"github.com/huy1235588/fashion-e-commerce/internal/models"
 	"github.com/huy1235588/fashion-e-commerce/internal/repositories"
+	"github.com/huy1235588/fashion-e-commerce/internal/utils"
 	"gorm.io/gorm"
 )
 
+// ErrGuestCartNotFound is returned for cart tokens whose cart no longer exists
+var ErrGuestCartNotFound = errors.New("cart not found, please start a new cart")
+
+// ErrCartNotFound is returned for carts that do not exist or belong to
+// another user
+var ErrCartNotFound = errors.New("cart not found")
+
+// SavedForLaterName is the name of the saved list SaveForLater moves items to
+const SavedForLaterName = "Saved for later"
+
 // CartService handles shopping cart business logic
 type CartService struct {
 	cartRepo    repositories.CartRepository
 	productRepo repositories.ProductRepository
+	cartTokens  *utils.CartTokenSigner
+	db          *gorm.DB
+
+	// reservationService gives the stock left after holds of unpaid
+	// orders, which is what checkout will find
+	reservationService ReservationService
 }
 
 // NewCartService creates a new cart service
-func NewCartService(cartRepo repositories.CartRepository, productRepo repositories.ProductRepository) *CartService {
+func NewCartService(cartRepo repositories.CartRepository, productRepo repositories.ProductRepository, reservationService ReservationService, cartTokens *utils.CartTokenSigner, db *gorm.DB) *CartService {
 	return &CartService{
 		cartRepo:    cartRepo,
 		productRepo: productRepo,
+		cartTokens:  cartTokens,
+		db:          db,
+
+		reservationService: reservationService,
 	}
 }
 
+// Reasons a cart line was changed when it was re-validated
+const (
+	CartAdjustmentUnavailable     = "unavailable"      // product deactivated or variant deleted; line removed
+	CartAdjustmentOutOfStock      = "out_of_stock"     // nothing left in stock; line removed
+	CartAdjustmentQuantityReduced = "quantity_reduced" // lowered to the stock left
+	CartAdjustmentPriceChanged    = "price_changed"
+)
+
+// CartChangedError is returned by checkout when the cart no longer matches
+// current stock and pricing. Nothing was ordered; the customer has to review
+// the cart, which applies the adjustments, and confirm it again.
+type CartChangedError struct {
+	Adjustments []CartAdjustment
+}
+
+func (e *CartChangedError) Error() string {
+	return "your cart has changed since you last reviewed it, please check it again"
+}
+
+// CartAdjustment reports a cart line that did not come through unchanged
+type CartAdjustment struct {
+	ProductID         uint    `json:"product_id"`
+	VariantID         uint    `json:"variant_id"`
+	ProductName       string  `json:"product_name,omitempty"`
+	Reason            string  `json:"reason"`
+	RequestedQuantity int     `json:"requested_quantity"`
+	Quantity          int     `json:"quantity"`
+	OldPrice          float64 `json:"old_price,omitempty"`
+	Price             float64 `json:"price,omitempty"`
+}
+
 // GetOrCreateCart gets the user's cart or creates one if it doesn't exist
 func (s *CartService) GetOrCreateCart(userID uint) (*models.Cart, error) {
-	cart, err := s.cartRepo.GetByUserID(userID)
+	return getOrCreateCart(s.cartRepo, userID)
+}
+
+func getOrCreateCart(cartRepo repositories.CartRepository, userID uint) (*models.Cart, error) {
+	cart, err := cartRepo.GetByUserID(userID)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			// Create new cart
-			cart = &models.Cart{UserID: userID}
-			if err := s.cartRepo.Create(cart); err != nil {
+			cart = &models.Cart{UserID: &userID}
+			if err := cartRepo.Create(cart); err != nil {
 				return nil, err
 			}
 			// Reload with preloaded items
-			cart, err = s.cartRepo.GetByUserID(userID)
+			cart, err = cartRepo.GetByUserID(userID)
 			if err != nil {
 				return nil, err
 			}
@@ -46,159 +108,774 @@ func (s *CartService) GetOrCreateCart(userID uint) (*models.Cart, error) {
 
 // AddToCart adds a product variant to the cart
 func (s *CartService) AddToCart(userID uint, productID uint, variantID uint, quantity int) (*models.Cart, error) {
-	if quantity <= 0 {
-		return nil, errors.New("quantity must be greater than 0")
+	// Get or create cart
+	cart, err := s.GetOrCreateCart(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.addItem(cart, productID, variantID, quantity); err != nil {
+		return nil, err
+	}
+
+	// Reload cart with updated items
+	return s.cartRepo.GetByUserID(userID)
+}
+
+// UpdateCartItem updates the quantity of a cart item
+func (s *CartService) UpdateCartItem(userID uint, itemID uint, quantity int) (*models.Cart, error) {
+	// Get cart
+	cart, err := s.cartRepo.GetByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.updateItem(cart, itemID, quantity); err != nil {
+		return nil, err
+	}
+
+	// Reload cart
+	return s.cartRepo.GetByUserID(userID)
+}
+
+// RemoveCartItem removes an item from the cart
+func (s *CartService) RemoveCartItem(userID uint, itemID uint) (*models.Cart, error) {
+	// Get cart
+	cart, err := s.cartRepo.GetByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.removeItem(cart, itemID); err != nil {
+		return nil, err
+	}
+
+	// Reload cart
+	return s.cartRepo.GetByUserID(userID)
+}
+
+// ClearCart removes all items from the cart
+func (s *CartService) ClearCart(userID uint) (*models.Cart, error) {
+	cart, err := s.cartRepo.GetByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.cartRepo.ClearCart(cart.ID); err != nil {
+		return nil, err
+	}
+
+	// Reload cart
+	return s.cartRepo.GetByUserID(userID)
+}
+
+// ListCarts returns the user's primary cart followed by their saved lists
+func (s *CartService) ListCarts(userID uint) ([]models.Cart, error) {
+	if _, err := s.GetOrCreateCart(userID); err != nil {
+		return nil, err
+	}
+	return s.cartRepo.ListByUserID(userID)
+}
+
+// GetUserCart returns one of the user's carts
+func (s *CartService) GetUserCart(userID, cartID uint) (*models.Cart, error) {
+	return s.findUserCart(userID, cartID)
+}
+
+// CreateSavedCart creates a named saved list next to the user's cart
+func (s *CartService) CreateSavedCart(userID uint, name string) (*models.Cart, error) {
+	name, err := s.checkCartName(userID, 0, name)
+	if err != nil {
+		return nil, err
+	}
+
+	cart := &models.Cart{UserID: &userID, Name: name}
+	if err := s.cartRepo.Create(cart); err != nil {
+		return nil, err
+	}
+	return s.cartRepo.GetByID(cart.ID)
+}
+
+// RenameCart renames one of the user's saved lists
+func (s *CartService) RenameCart(userID, cartID uint, name string) (*models.Cart, error) {
+	cart, err := s.findUserCart(userID, cartID)
+	if err != nil {
+		return nil, err
+	}
+	if cart.IsPrimary() {
+		return nil, errors.New("the main cart cannot be renamed")
+	}
+
+	name, err = s.checkCartName(userID, cart.ID, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.cartRepo.Rename(cart.ID, name); err != nil {
+		return nil, err
+	}
+	return s.cartRepo.GetByID(cart.ID)
+}
+
+// DeleteCart deletes one of the user's saved lists with its items
+func (s *CartService) DeleteCart(userID, cartID uint) error {
+	cart, err := s.findUserCart(userID, cartID)
+	if err != nil {
+		return err
+	}
+	if cart.IsPrimary() {
+		return errors.New("the main cart cannot be deleted")
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		return repositories.NewCartRepository(tx).Delete(cart.ID)
+	})
+}
+
+// AddToUserCart adds a product variant to one of the user's carts
+func (s *CartService) AddToUserCart(userID, cartID uint, productID uint, variantID uint, quantity int) (*models.Cart, error) {
+	cart, err := s.findUserCart(userID, cartID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.addItem(cart, productID, variantID, quantity); err != nil {
+		return nil, err
+	}
+	return s.cartRepo.GetByID(cart.ID)
+}
+
+// UpdateUserCartItem updates the quantity of an item in one of the user's carts
+func (s *CartService) UpdateUserCartItem(userID, cartID uint, itemID uint, quantity int) (*models.Cart, error) {
+	cart, err := s.findUserCart(userID, cartID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.updateItem(cart, itemID, quantity); err != nil {
+		return nil, err
+	}
+	return s.cartRepo.GetByID(cart.ID)
+}
+
+// RemoveUserCartItem removes an item from one of the user's carts
+func (s *CartService) RemoveUserCartItem(userID, cartID uint, itemID uint) (*models.Cart, error) {
+	cart, err := s.findUserCart(userID, cartID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.removeItem(cart, itemID); err != nil {
+		return nil, err
+	}
+	return s.cartRepo.GetByID(cart.ID)
+}
+
+// MoveItem moves a line from one of the user's carts to another and returns
+// both carts. A line for the same variant in the target cart gets the
+// quantity added. Stock is not checked, so sold-out items can be parked;
+// checkout revalidates the cart anyway.
+func (s *CartService) MoveItem(userID, itemID, toCartID uint) (*models.Cart, *models.Cart, error) {
+	item, err := s.cartRepo.FindItemByID(itemID)
+	if err != nil {
+		return nil, nil, errors.New("cart item not found")
+	}
+
+	from, err := s.findUserCart(userID, item.CartID)
+	if err != nil {
+		return nil, nil, errors.New("cart item not found")
+	}
+
+	to, err := s.findUserCart(userID, toCartID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if from.ID == to.ID {
+		return nil, nil, errors.New("item is already in this cart")
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		cartRepo := repositories.NewCartRepository(tx)
+
+		existing, err := cartRepo.FindItemByVariant(to.ID, item.VariantID)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		if existing != nil {
+			existing.Quantity += item.Quantity
+			if err := cartRepo.UpdateItem(existing); err != nil {
+				return err
+			}
+			if err := cartRepo.RemoveItem(item.ID); err != nil {
+				return err
+			}
+		} else if err := cartRepo.MoveItem(item.ID, to.ID); err != nil {
+			return err
+		}
+
+		if err := cartRepo.Touch(from.ID); err != nil {
+			return err
+		}
+		return cartRepo.Touch(to.ID)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if from, err = s.cartRepo.GetByID(from.ID); err != nil {
+		return nil, nil, err
+	}
+	if to, err = s.cartRepo.GetByID(to.ID); err != nil {
+		return nil, nil, err
+	}
+	return from, to, nil
+}
+
+// SaveForLater moves a line to the user's "Saved for later" list, creating
+// the list the first time
+func (s *CartService) SaveForLater(userID, itemID uint) (*models.Cart, *models.Cart, error) {
+	list, err := s.cartRepo.FindByName(userID, SavedForLaterName)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		list, err = s.CreateSavedCart(userID, SavedForLaterName)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return s.MoveItem(userID, itemID, list.ID)
+}
+
+// findUserCart loads a cart and checks it belongs to the user
+func (s *CartService) findUserCart(userID, cartID uint) (*models.Cart, error) {
+	cart, err := s.cartRepo.GetByID(cartID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCartNotFound
+		}
+		return nil, err
+	}
+	if !cart.BelongsTo(userID) {
+		return nil, ErrCartNotFound
+	}
+	return cart, nil
+}
+
+// checkCartName trims a saved list name and checks that no other list of
+// the user, other than cartID, already has it
+func (s *CartService) checkCartName(userID, cartID uint, name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", errors.New("name is required")
+	}
+
+	existing, err := s.cartRepo.FindByName(userID, name)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", err
+	}
+	if existing != nil && existing.ID != cartID {
+		return "", errors.New("a saved list with this name already exists")
+	}
+	return name, nil
+}
+
+// CreateGuestCart creates an anonymous cart and returns it with the token
+// the visitor must send to use it
+func (s *CartService) CreateGuestCart() (*models.Cart, string, error) {
+	guestKey := uuid.New().String()
+	cart := &models.Cart{GuestKey: &guestKey}
+	if err := s.cartRepo.Create(cart); err != nil {
+		return nil, "", err
+	}
+
+	cart, err := s.cartRepo.GetByID(cart.ID)
+	if err != nil {
+		return nil, "", err
+	}
+	return cart, s.cartTokens.Sign(guestKey), nil
+}
+
+// GetGuestCart returns the anonymous cart a cart token was issued for
+func (s *CartService) GetGuestCart(token string) (*models.Cart, error) {
+	guestKey, err := s.cartTokens.Verify(token)
+	if err != nil {
+		return nil, err
+	}
+
+	cart, err := s.cartRepo.GetByGuestKey(guestKey)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrGuestCartNotFound
+		}
+		return nil, err
+	}
+	return cart, nil
+}
+
+// AddToGuestCart adds a product variant to an anonymous cart
+func (s *CartService) AddToGuestCart(token string, productID uint, variantID uint, quantity int) (*models.Cart, error) {
+	cart, err := s.GetGuestCart(token)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.addItem(cart, productID, variantID, quantity); err != nil {
+		return nil, err
+	}
+	return s.cartRepo.GetByID(cart.ID)
+}
+
+// UpdateGuestCartItem updates the quantity of an item in an anonymous cart
+func (s *CartService) UpdateGuestCartItem(token string, itemID uint, quantity int) (*models.Cart, error) {
+	cart, err := s.GetGuestCart(token)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.updateItem(cart, itemID, quantity); err != nil {
+		return nil, err
+	}
+	return s.cartRepo.GetByID(cart.ID)
+}
+
+// RemoveGuestCartItem removes an item from an anonymous cart
+func (s *CartService) RemoveGuestCartItem(token string, itemID uint) (*models.Cart, error) {
+	cart, err := s.GetGuestCart(token)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.removeItem(cart, itemID); err != nil {
+		return nil, err
+	}
+	return s.cartRepo.GetByID(cart.ID)
+}
+
+// ClearGuestCart removes all items from an anonymous cart
+func (s *CartService) ClearGuestCart(token string) (*models.Cart, error) {
+	cart, err := s.GetGuestCart(token)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.cartRepo.ClearCart(cart.ID); err != nil {
+		return nil, err
+	}
+	return s.cartRepo.GetByID(cart.ID)
+}
+
+// MergeGuestCart moves the items of an anonymous cart into the user's cart
+// after they log in. Quantities of the same variant are added up, then each
+// merged line is checked against current stock and pricing; the returned
+// adjustments list the lines that were changed or dropped. The anonymous
+// cart is deleted, so its token cannot be merged twice.
+func (s *CartService) MergeGuestCart(userID uint, token string) (*models.Cart, []CartAdjustment, error) {
+	guest, err := s.GetGuestCart(token)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	adjustments := []CartAdjustment{}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		cartRepo := repositories.NewCartRepository(tx)
+
+		// Deleting first also makes a concurrent merge of the same cart wait
+		// for this one and then find nothing to merge
+		claimed, err := cartRepo.DeleteGuestCart(guest.ID)
+		if err != nil || !claimed {
+			return err
+		}
+
+		cart, err := getOrCreateCart(cartRepo, userID)
+		if err != nil {
+			return err
+		}
+
+		for _, item := range guest.Items {
+			lineAdjustments, err := s.mergeItem(tx, cart, item)
+			if err != nil {
+				return err
+			}
+			adjustments = append(adjustments, lineAdjustments...)
+		}
+		return cartRepo.Touch(cart.ID)
+	})
+	if err != nil {
+		return nil, nil, err
 	}
 
-	// Get or create cart
 	cart, err := s.GetOrCreateCart(userID)
 	if err != nil {
+		return nil, nil, err
+	}
+	return cart, adjustments, nil
+}
+
+// mergeItem adds an anonymous cart line to a user's cart, limited to the
+// stock left and at the current price
+func (s *CartService) mergeItem(tx *gorm.DB, cart *models.Cart, item models.CartItem) ([]CartAdjustment, error) {
+	cartRepo := repositories.NewCartRepository(tx)
+	existing, err := cartRepo.FindItemByVariant(cart.ID, item.VariantID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
 		return nil, err
 	}
 
-	// Validate product and variant
-	product, err := s.productRepo.FindByID(productID)
+	// The price to compare is the one on the anonymous line, the last one
+	// the customer was shown for this variant
+	quantity := item.Quantity
+	if existing != nil {
+		quantity += existing.Quantity
+	}
+
+	review, err := reviewCartLine(tx, s.productRepo, s.reservationService, item.ProductID, item.VariantID, quantity, item.Price)
 	if err != nil {
-		return nil, errors.New("product not found")
+		return nil, err
 	}
 
-	if !product.IsActive {
-		return nil, errors.New("product is not available")
+	if review.quantity == 0 {
+		if existing != nil {
+			return review.adjustments, cartRepo.RemoveItem(existing.ID)
+		}
+		return review.adjustments, nil
 	}
+	if existing != nil {
+		existing.Quantity = review.quantity
+		existing.Price = review.price
+		return review.adjustments, cartRepo.UpdateItem(existing)
+	}
+	return review.adjustments, cartRepo.AddItem(&models.CartItem{
+		CartID:    cart.ID,
+		ProductID: item.ProductID,
+		VariantID: item.VariantID,
+		Quantity:  review.quantity,
+		Price:     review.price,
+	})
+}
 
-	// Find variant
-	var variant *models.ProductVariant
-	for i := range product.Variants {
-		if product.Variants[i].ID == variantID {
-			variant = &product.Variants[i]
-			break
+// ValidateCart checks the user's cart against current products, stock and
+// prices. Lines are brought up to date: prices are refreshed, quantities
+// lowered to the stock left, and unavailable lines removed. The adjustments
+// tell the customer what changed before they confirm the order.
+func (s *CartService) ValidateCart(userID uint) (*models.Cart, []CartAdjustment, error) {
+	cart, err := s.GetOrCreateCart(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	adjustments, err := s.validate(cart)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cart, err = s.cartRepo.GetByUserID(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cart, adjustments, nil
+}
+
+// ValidateUserCart is ValidateCart for one of the user's carts
+func (s *CartService) ValidateUserCart(userID, cartID uint) (*models.Cart, []CartAdjustment, error) {
+	cart, err := s.findUserCart(userID, cartID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	adjustments, err := s.validate(cart)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cart, err = s.cartRepo.GetByID(cart.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cart, adjustments, nil
+}
+
+// ValidateGuestCart is ValidateCart for an anonymous cart
+func (s *CartService) ValidateGuestCart(token string) (*models.Cart, []CartAdjustment, error) {
+	cart, err := s.GetGuestCart(token)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	adjustments, err := s.validate(cart)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cart, err = s.cartRepo.GetByID(cart.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cart, adjustments, nil
+}
+
+// validate reviews every line of a cart and saves the adjusted lines
+func (s *CartService) validate(cart *models.Cart) ([]CartAdjustment, error) {
+	adjustments := []CartAdjustment{}
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		cartRepo := repositories.NewCartRepository(tx)
+		for _, item := range cart.Items {
+			review, err := reviewCartLine(tx, s.productRepo, s.reservationService, item.ProductID, item.VariantID, item.Quantity, item.Price)
+			if err != nil {
+				return err
+			}
+			if len(review.adjustments) == 0 {
+				continue
+			}
+			adjustments = append(adjustments, review.adjustments...)
+
+			if review.quantity == 0 {
+				if err := cartRepo.RemoveItem(item.ID); err != nil {
+					return err
+				}
+				continue
+			}
+			err = tx.Model(&models.CartItem{}).Where("id = ?", item.ID).Updates(map[string]interface{}{
+				"quantity": review.quantity,
+				"price":    review.price,
+			}).Error
+			if err != nil {
+				return err
+			}
+		}
+		if len(adjustments) == 0 {
+			return nil
+		}
+		return cartRepo.Touch(cart.ID)
+	})
+	return adjustments, err
+}
+
+// StartGuestCartCleanup periodically deletes anonymous carts that have not
+// changed for maxAge until ctx is cancelled
+func (s *CartService) StartGuestCartCleanup(ctx context.Context, interval, maxAge time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			count, err := s.cartRepo.DeleteGuestCartsBefore(time.Now().Add(-maxAge))
+			if err != nil {
+				log.Printf("Guest cart cleanup failed: %v", err)
+				continue
+			}
+			if count > 0 {
+				log.Printf("Guest cart cleanup deleted %d cart(s)", count)
+			}
 		}
 	}
+}
+
+// addItem adds a product variant to a cart, or raises the quantity of the
+// line already holding it
+func (s *CartService) addItem(cart *models.Cart, productID uint, variantID uint, quantity int) error {
+	if quantity <= 0 {
+		return errors.New("quantity must be greater than 0")
+	}
+
+	// Validate product and variant
+	product, variant := findCartVariant(s.productRepo, productID, variantID)
+	if product == nil {
+		return errors.New("product not found")
+	}
+
+	if !product.IsActive {
+		return errors.New("product is not available")
+	}
 
 	if variant == nil {
-		return nil, errors.New("product variant not found")
+		return errors.New("product variant not found")
 	}
 
 	// Check if item already exists in cart
 	existingItem, err := s.cartRepo.FindItemByVariant(cart.ID, variantID)
 	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
-		return nil, err
+		return err
+	}
+
+	// Stock held for unpaid orders cannot go into a cart
+	available, err := s.reservationService.AvailableStock(s.db, variant.ID)
+	if err != nil {
+		return err
 	}
 
 	if existingItem != nil {
 		// Update quantity
 		newQuantity := existingItem.Quantity + quantity
-		if newQuantity > variant.StockQuantity {
-			return nil, fmt.Errorf("not enough stock (available: %d)", variant.StockQuantity)
+		if newQuantity > available {
+			return fmt.Errorf("not enough stock (available: %d)", available)
 		}
 		existingItem.Quantity = newQuantity
 		if err := s.cartRepo.UpdateItem(existingItem); err != nil {
-			return nil, err
+			return err
 		}
 	} else {
 		// Validate stock
-		if quantity > variant.StockQuantity {
-			return nil, fmt.Errorf("not enough stock (available: %d)", variant.StockQuantity)
+		if quantity > available {
+			return fmt.Errorf("not enough stock (available: %d)", available)
 		}
 
 		// Add new item
-		price := product.Price
-		if product.DiscountPrice != nil && *product.DiscountPrice < product.Price {
-			price = *product.DiscountPrice
-		}
-
 		item := &models.CartItem{
 			CartID:    cart.ID,
 			ProductID: productID,
 			VariantID: variantID,
 			Quantity:  quantity,
-			Price:     price,
+			Price:     cartPrice(product),
 		}
 
 		if err := s.cartRepo.AddItem(item); err != nil {
-			return nil, err
+			return err
 		}
 	}
 
-	// Reload cart with updated items
-	return s.cartRepo.GetByUserID(userID)
+	return s.cartRepo.Touch(cart.ID)
 }
 
-// UpdateCartItem updates the quantity of a cart item
-func (s *CartService) UpdateCartItem(userID uint, itemID uint, quantity int) (*models.Cart, error) {
+// updateItem sets the quantity of an item in a cart
+func (s *CartService) updateItem(cart *models.Cart, itemID uint, quantity int) error {
 	if quantity <= 0 {
-		return nil, errors.New("quantity must be greater than 0")
-	}
-
-	// Get cart
-	cart, err := s.cartRepo.GetByUserID(userID)
-	if err != nil {
-		return nil, err
+		return errors.New("quantity must be greater than 0")
 	}
 
 	// Find item
 	item, err := s.cartRepo.FindItemByID(itemID)
 	if err != nil {
-		return nil, errors.New("cart item not found")
+		return errors.New("cart item not found")
 	}
 
 	if item.CartID != cart.ID {
-		return nil, errors.New("cart item does not belong to this cart")
+		return errors.New("cart item does not belong to this cart")
 	}
 
-	// Validate stock
-	if item.Variant != nil && quantity > item.Variant.StockQuantity {
-		return nil, fmt.Errorf("not enough stock (available: %d)", item.Variant.StockQuantity)
+	// Validate stock, leaving out what unpaid orders hold
+	available, err := s.reservationService.AvailableStock(s.db, item.VariantID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return errors.New("product variant not found")
+	}
+	if err != nil {
+		return err
+	}
+	if quantity > available {
+		return fmt.Errorf("not enough stock (available: %d)", available)
 	}
 
 	// Update quantity
 	item.Quantity = quantity
 	if err := s.cartRepo.UpdateItem(item); err != nil {
-		return nil, err
+		return err
 	}
-
-	// Reload cart
-	return s.cartRepo.GetByUserID(userID)
+	return s.cartRepo.Touch(cart.ID)
 }
 
-// RemoveCartItem removes an item from the cart
-func (s *CartService) RemoveCartItem(userID uint, itemID uint) (*models.Cart, error) {
-	// Get cart
-	cart, err := s.cartRepo.GetByUserID(userID)
-	if err != nil {
-		return nil, err
-	}
-
+// removeItem removes an item from a cart
+func (s *CartService) removeItem(cart *models.Cart, itemID uint) error {
 	// Find item
 	item, err := s.cartRepo.FindItemByID(itemID)
 	if err != nil {
-		return nil, errors.New("cart item not found")
+		return errors.New("cart item not found")
 	}
 
 	if item.CartID != cart.ID {
-		return nil, errors.New("cart item does not belong to this cart")
+		return errors.New("cart item does not belong to this cart")
 	}
 
 	// Remove item
 	if err := s.cartRepo.RemoveItem(itemID); err != nil {
-		return nil, err
+		return err
 	}
+	return s.cartRepo.Touch(cart.ID)
+}
 
-	// Reload cart
-	return s.cartRepo.GetByUserID(userID)
+// cartPrice returns the price a product currently sells at, taking a
+// lower discount price into account
+func cartPrice(product *models.Product) float64 {
+	if product.DiscountPrice != nil && *product.DiscountPrice < product.Price {
+		return *product.DiscountPrice
+	}
+	return product.Price
 }
 
-// ClearCart removes all items from the cart
-func (s *CartService) ClearCart(userID uint) (*models.Cart, error) {
-	cart, err := s.cartRepo.GetByUserID(userID)
+// cartLineReview is the outcome of checking a cart line against the
+// current product, stock and price
+type cartLineReview struct {
+	product     *models.Product
+	variant     *models.ProductVariant
+	quantity    int // what the line may keep; 0 when it must be removed
+	price       float64
+	adjustments []CartAdjustment
+}
+
+// reviewCartLine checks that quantity of a variant, shown to the customer at
+// price, can still be bought as it is. Stock is counted after the holds of
+// unpaid orders and the variant row is locked, so tx should be the
+// transaction that acts on the result.
+func reviewCartLine(tx *gorm.DB, productRepo repositories.ProductRepository, reservationService ReservationService, productID, variantID uint, quantity int, price float64) (*cartLineReview, error) {
+	adjustment := CartAdjustment{
+		ProductID:         productID,
+		VariantID:         variantID,
+		RequestedQuantity: quantity,
+	}
+
+	product, variant := findCartVariant(productRepo, productID, variantID)
+	if product != nil {
+		adjustment.ProductName = product.Name
+	}
+	if product == nil || !product.IsActive || variant == nil {
+		adjustment.Reason = CartAdjustmentUnavailable
+		return &cartLineReview{product: product, variant: variant, adjustments: []CartAdjustment{adjustment}}, nil
+	}
+
+	available, err := reservationService.AvailableStock(tx, variant.ID)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to check stock for product %s", product.Name)
+	}
+	if available <= 0 {
+		adjustment.Reason = CartAdjustmentOutOfStock
+		return &cartLineReview{product: product, variant: variant, adjustments: []CartAdjustment{adjustment}}, nil
 	}
 
-	if err := s.cartRepo.ClearCart(cart.ID); err != nil {
-		return nil, err
+	review := &cartLineReview{
+		product:  product,
+		variant:  variant,
+		quantity: quantity,
+		price:    cartPrice(product),
 	}
+	if quantity > available {
+		review.quantity = available
+		reduced := adjustment
+		reduced.Reason = CartAdjustmentQuantityReduced
+		reduced.Quantity = available
+		review.adjustments = append(review.adjustments, reduced)
+	}
+	if price != review.price {
+		changed := adjustment
+		changed.Reason = CartAdjustmentPriceChanged
+		changed.Quantity = review.quantity
+		changed.OldPrice = price
+		changed.Price = review.price
+		review.adjustments = append(review.adjustments, changed)
+	}
+	return review, nil
+}
 
-	// Reload cart
-	return s.cartRepo.GetByUserID(userID)
+// findCartVariant returns a product and one of its variants; the product is
+// nil when it is gone and the variant is nil when it was deleted
+func findCartVariant(productRepo repositories.ProductRepository, productID, variantID uint) (*models.Product, *models.ProductVariant) {
+	product, err := productRepo.FindByID(productID)
+	if err != nil {
+		return nil, nil
+	}
+	for i := range product.Variants {
+		if product.Variants[i].ID == variantID {
+			return product, &product.Variants[i]
+		}
+	}
+	return product, nil
 }
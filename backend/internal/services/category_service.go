@@ -7,14 +7,23 @@ import (
 	"gorm.io/gorm"
 )
 
+// ErrCategoryInUse is returned when deleting a category that still has
+// subcategories, products or promotions without saying where to move them
+var ErrCategoryInUse = errors.New("category has subcategories, products or promotions; choose a category to move them to")
+
+// ErrInvalidParentCategory is returned when a category would be placed
+// under itself or one of its subcategories
+var ErrInvalidParentCategory = errors.New("a category cannot be placed under itself or its subcategories")
+
 // CategoryService handles category business logic
 type CategoryService struct {
-	repo repositories.CategoryRepository
+	repo        repositories.CategoryRepository
+	productRepo repositories.ProductRepository
 }
 
 // NewCategoryService creates a new category service
-func NewCategoryService(repo repositories.CategoryRepository) *CategoryService {
-	return &CategoryService{repo: repo}
+func NewCategoryService(repo repositories.CategoryRepository, productRepo repositories.ProductRepository) *CategoryService {
+	return &CategoryService{repo: repo, productRepo: productRepo}
 }
 
 // CreateCategory creates a new category
@@ -28,6 +37,12 @@ func (s *CategoryService) CreateCategory(category *models.Category) error {
 		return errors.New("category with this slug already exists")
 	}
 
+	if category.ParentID != nil {
+		if err := s.checkParent(0, *category.ParentID); err != nil {
+			return err
+		}
+	}
+
 	return s.repo.Create(category)
 }
 
@@ -59,19 +74,122 @@ func (s *CategoryService) UpdateCategory(id uint, updates *models.Category) erro
 		}
 	}
 
+	if updates.ParentID != nil {
+		if err := s.checkParent(id, *updates.ParentID); err != nil {
+			return err
+		}
+	}
+
 	category.Name = updates.Name
 	category.Description = updates.Description
 	category.Slug = updates.Slug
+	category.ParentID = updates.ParentID
+	category.Parent = nil
+	category.Position = updates.Position
 
-	return s.repo.Update(category)
+	if err := s.repo.Update(category); err != nil {
+		return err
+	}
+
+	// Category names are part of the product search documents
+	return s.productRepo.RefreshCategorySearchVectors(category.ID)
 }
 
-// DeleteCategory deletes a category
-func (s *CategoryService) DeleteCategory(id uint) error {
-	return s.repo.Delete(id)
+// DeleteCategory deletes a category. A category with subcategories, products
+// or promotions scoped to it is only deleted when reassignTo names the
+// category to move them to, which must not be one of its own subcategories.
+func (s *CategoryService) DeleteCategory(id uint, reassignTo *uint) error {
+	if _, err := s.repo.FindByID(id); err != nil {
+		return err
+	}
+
+	if reassignTo == nil {
+		deleted, err := s.repo.DeleteIfUnused(id)
+		if err != nil {
+			return err
+		}
+		if !deleted {
+			return ErrCategoryInUse
+		}
+		return nil
+	}
+
+	if _, err := s.repo.FindByID(*reassignTo); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("category to move to not found")
+		}
+		return err
+	}
+	inside, err := s.inSubtree(id, *reassignTo)
+	if err != nil {
+		return err
+	}
+	if inside {
+		return errors.New("cannot move into the deleted category or its subcategories")
+	}
+
+	if err := s.repo.DeleteAndReassign(id, *reassignTo); err != nil {
+		return err
+	}
+
+	// The moved products are now searchable by their new category's name
+	return s.productRepo.RefreshCategorySearchVectors(*reassignTo)
 }
 
 // ListCategories retrieves all categories
 func (s *CategoryService) ListCategories() ([]models.Category, error) {
 	return s.repo.List()
 }
+
+// GetCategoryTree retrieves all categories nested under their parents
+func (s *CategoryService) GetCategoryTree() ([]models.CategoryTreeResponse, error) {
+	categories, err := s.repo.List()
+	if err != nil {
+		return nil, err
+	}
+	return models.CategoryTree(categories), nil
+}
+
+// GetBreadcrumbs retrieves the path from the root category down to a
+// category
+func (s *CategoryService) GetBreadcrumbs(id uint) ([]models.Category, error) {
+	return s.repo.Ancestors(id)
+}
+
+// checkParent checks that parentID exists and, for an existing category
+// id, is not the category itself or one of its subcategories
+func (s *CategoryService) checkParent(id, parentID uint) error {
+	if _, err := s.repo.FindByID(parentID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("parent category not found")
+		}
+		return err
+	}
+	if id == 0 {
+		return nil
+	}
+
+	inside, err := s.inSubtree(id, parentID)
+	if err != nil {
+		return err
+	}
+	if inside {
+		return ErrInvalidParentCategory
+	}
+	return nil
+}
+
+// inSubtree reports whether otherID is the category id or one of its
+// subcategories
+func (s *CategoryService) inSubtree(id, otherID uint) (bool, error) {
+	subtree, err := s.repo.SubtreeIDs(id)
+	if err != nil {
+		return false, err
+	}
+	for _, subtreeID := range subtree {
+		if subtreeID == otherID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
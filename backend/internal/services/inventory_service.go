@@ -0,0 +1,217 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/huy1235588/fashion-e-commerce/internal/models"
+	"github.com/huy1235588/fashion-e-commerce/internal/repositories"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrInsufficientStock is returned when a movement would take stock below zero
+var ErrInsufficientStock = errors.New("insufficient stock for this movement")
+
+// ErrStockBelowReserved is returned when a manual change would leave less
+// stock than unpaid orders are holding
+var ErrStockBelowReserved = errors.New("stock cannot go below the quantity reserved by pending orders")
+
+// AdjustStockRequest represents a manual stock movement made by an admin
+type AdjustStockRequest struct {
+	Delta  int                   `json:"delta" binding:"required"`
+	Reason models.MovementReason `json:"reason" binding:"required,oneof=restock adjustment"`
+	Note   string                `json:"note"`
+}
+
+// InventoryService writes every stock change together with its ledger entry.
+// Methods that take a *gorm.DB run inside the caller's transaction.
+type InventoryService interface {
+	ApplyMovement(tx *gorm.DB, movement *models.InventoryMovement) error
+	RecordInitialStock(tx *gorm.DB, variant *models.ProductVariant, userID *uint) error
+	SetStock(tx *gorm.DB, variantID uint, quantity int, userID *uint, note string) error
+	AdjustStock(variantID uint, userID uint, req AdjustStockRequest) (*models.InventoryMovement, error)
+	GetMovements(variantID uint, limit, offset int) ([]models.InventoryMovement, int64, error)
+	Reconcile(variantID *uint, mismatchedOnly bool) ([]repositories.InventoryReconciliation, error)
+	RecordReconciliation(variantID uint, userID uint, note string) (*models.InventoryMovement, error)
+}
+
+type inventoryService struct {
+	inventoryRepo repositories.InventoryRepository
+	db            *gorm.DB
+}
+
+// NewInventoryService creates a new inventory service
+func NewInventoryService(inventoryRepo repositories.InventoryRepository, db *gorm.DB) InventoryService {
+	return &inventoryService{
+		inventoryRepo: inventoryRepo,
+		db:            db,
+	}
+}
+
+// ApplyMovement changes the variant's stock by movement.Delta and appends the ledger entry
+func (s *inventoryService) ApplyMovement(tx *gorm.DB, movement *models.InventoryMovement) error {
+	result := tx.Model(&models.ProductVariant{}).
+		Where("id = ? AND stock_quantity + ? >= 0", movement.VariantID, movement.Delta).
+		UpdateColumn("stock_quantity", gorm.Expr("stock_quantity + ?", movement.Delta))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		var count int64
+		if err := tx.Model(&models.ProductVariant{}).Where("id = ?", movement.VariantID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return errors.New("variant not found")
+		}
+		return ErrInsufficientStock
+	}
+
+	if err := tx.Model(&models.ProductVariant{}).
+		Where("id = ?", movement.VariantID).
+		Select("stock_quantity").
+		Scan(&movement.StockAfter).Error; err != nil {
+		return err
+	}
+
+	return repositories.NewInventoryRepository(tx).CreateMovement(movement)
+}
+
+// RecordInitialStock writes the opening ledger entry for a newly created variant
+func (s *inventoryService) RecordInitialStock(tx *gorm.DB, variant *models.ProductVariant, userID *uint) error {
+	if variant.StockQuantity == 0 {
+		return nil
+	}
+
+	return repositories.NewInventoryRepository(tx).CreateMovement(&models.InventoryMovement{
+		VariantID:  variant.ID,
+		Delta:      variant.StockQuantity,
+		StockAfter: variant.StockQuantity,
+		Reason:     models.MovementReasonInitial,
+		UserID:     userID,
+	})
+}
+
+// SetStock moves a variant to an absolute quantity, recording the difference as an adjustment
+func (s *inventoryService) SetStock(tx *gorm.DB, variantID uint, quantity int, userID *uint, note string) error {
+	var variant models.ProductVariant
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&variant, variantID).Error; err != nil {
+		return err
+	}
+
+	delta := quantity - variant.StockQuantity
+	if delta == 0 {
+		return nil
+	}
+	if delta < 0 {
+		if err := checkReservedStock(tx, variantID, quantity); err != nil {
+			return err
+		}
+	}
+
+	return s.ApplyMovement(tx, &models.InventoryMovement{
+		VariantID: variantID,
+		Delta:     delta,
+		Reason:    models.MovementReasonAdjustment,
+		UserID:    userID,
+		Note:      note,
+	})
+}
+
+// AdjustStock applies a manual restock or adjustment made by an admin
+func (s *inventoryService) AdjustStock(variantID uint, userID uint, req AdjustStockRequest) (*models.InventoryMovement, error) {
+	if req.Delta == 0 {
+		return nil, errors.New("delta must not be zero")
+	}
+	if req.Reason == models.MovementReasonRestock && req.Delta < 0 {
+		return nil, errors.New("restock delta must be positive")
+	}
+
+	movement := &models.InventoryMovement{
+		VariantID: variantID,
+		Delta:     req.Delta,
+		Reason:    req.Reason,
+		UserID:    &userID,
+		Note:      req.Note,
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if req.Delta < 0 {
+			var variant models.ProductVariant
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&variant, variantID).Error; err != nil {
+				return errors.New("variant not found")
+			}
+			if err := checkReservedStock(tx, variantID, variant.StockQuantity+req.Delta); err != nil {
+				return err
+			}
+		}
+		return s.ApplyMovement(tx, movement)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return movement, nil
+}
+
+// checkReservedStock rejects a manual stock level that would not cover the
+// active holds, since paying for a held order must always be able to deduct
+// its stock. The caller must hold the variant row lock.
+func checkReservedStock(tx *gorm.DB, variantID uint, quantity int) error {
+	reserved, err := repositories.NewStockReservationRepository(tx).SumActiveByVariant(variantID)
+	if err != nil {
+		return err
+	}
+	if quantity < reserved {
+		return fmt.Errorf("%w (%d reserved)", ErrStockBelowReserved, reserved)
+	}
+	return nil
+}
+
+// GetMovements returns the ledger for a variant, newest first
+func (s *inventoryService) GetMovements(variantID uint, limit, offset int) ([]models.InventoryMovement, int64, error) {
+	return s.inventoryRepo.FindMovementsByVariant(variantID, limit, offset)
+}
+
+// Reconcile compares ledger sums with stock_quantity
+func (s *inventoryService) Reconcile(variantID *uint, mismatchedOnly bool) ([]repositories.InventoryReconciliation, error) {
+	return s.inventoryRepo.Reconcile(variantID, mismatchedOnly)
+}
+
+// RecordReconciliation appends a ledger-only entry that closes the gap between
+// the ledger and stock_quantity (e.g. for stock that predates the ledger).
+// Stock itself is left untouched.
+func (s *inventoryService) RecordReconciliation(variantID uint, userID uint, note string) (*models.InventoryMovement, error) {
+	var movement *models.InventoryMovement
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var variant models.ProductVariant
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&variant, variantID).Error; err != nil {
+			return err
+		}
+
+		rows, err := repositories.NewInventoryRepository(tx).Reconcile(&variantID, true)
+		if err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return errors.New("ledger already matches stock")
+		}
+
+		movement = &models.InventoryMovement{
+			VariantID:  variantID,
+			Delta:      rows[0].Difference,
+			StockAfter: variant.StockQuantity,
+			Reason:     models.MovementReasonReconciliation,
+			UserID:     &userID,
+			Note:       note,
+		}
+		return repositories.NewInventoryRepository(tx).CreateMovement(movement)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return movement, nil
+}
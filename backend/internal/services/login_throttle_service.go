@@ -0,0 +1,234 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/huy1235588/fashion-e-commerce/internal/models"
+	"github.com/huy1235588/fashion-e-commerce/internal/repositories"
+	"github.com/huy1235588/fashion-e-commerce/internal/utils"
+)
+
+// LoginThrottleSettings holds the brute-force protection limits
+type LoginThrottleSettings struct {
+	MaxAccountFailures int           // failures in Window before an account is locked
+	MaxIPFailures      int           // failures in Window before an IP address is locked
+	Window             time.Duration // failures older than this are forgotten
+	LockoutDuration    time.Duration
+	DelayAfter         int           // failures before each further attempt must wait
+	MaxDelay           time.Duration // cap for the doubling delay
+	UnlockURL          string        // link sent by email; the token is appended as ?token=
+}
+
+// LoginThrottledError is returned when a login attempt is refused before the
+// password is checked
+type LoginThrottledError struct {
+	RetryAfter time.Duration
+	Locked     bool // true for a lockout, false for a progressive delay
+}
+
+func (e *LoginThrottledError) Error() string {
+	if e.Locked {
+		return "too many failed login attempts, the account is temporarily locked. Check your email to unlock it or try again later"
+	}
+	return fmt.Sprintf("too many failed login attempts, please wait %d seconds", int(e.RetryAfter.Seconds())+1)
+}
+
+// LoginThrottleService tracks failed logins per account and per IP address
+// and decides when further attempts must wait or are locked out
+type LoginThrottleService interface {
+	Check(email, ipAddress string) error
+	RecordFailure(email, ipAddress string, user *models.User) error
+	RecordSuccess(email string) error
+	Unlock(token string) error
+	ListLockouts(lockedOnly bool, scope string, limit, offset int) ([]models.LoginThrottle, int64, error)
+	ClearLockout(id uint) error
+	StartCleanup(ctx context.Context, interval time.Duration)
+}
+
+type loginThrottleService struct {
+	throttleRepo repositories.LoginThrottleRepository
+	emailService *utils.EmailService
+	settings     LoginThrottleSettings
+}
+
+// NewLoginThrottleService creates a new login throttle service
+func NewLoginThrottleService(
+	throttleRepo repositories.LoginThrottleRepository,
+	emailService *utils.EmailService,
+	settings LoginThrottleSettings,
+) LoginThrottleService {
+	return &loginThrottleService{
+		throttleRepo: throttleRepo,
+		emailService: emailService,
+		settings:     settings,
+	}
+}
+
+// Check returns a *LoginThrottledError if the account or IP address may not
+// try to log in right now
+func (s *loginThrottleService) Check(email, ipAddress string) error {
+	now := time.Now()
+	var wait time.Duration
+	locked := false
+
+	account, err := s.throttleRepo.Find(models.LoginThrottleScopeAccount, normalizeLoginEmail(email))
+	if err != nil {
+		return err
+	}
+	if account != nil {
+		if account.IsLocked(now) {
+			wait, locked = account.LockedUntil.Sub(now), true
+		} else if delay := s.delayFor(account); delay > 0 {
+			wait = account.LastFailedAt.Add(delay).Sub(now)
+		}
+	}
+
+	// IP addresses are only locked, never delayed, so one noisy client behind
+	// a shared address slows down no one else until the limit is reached
+	if ipAddress != "" {
+		ip, err := s.throttleRepo.Find(models.LoginThrottleScopeIP, ipAddress)
+		if err != nil {
+			return err
+		}
+		if ip != nil && ip.IsLocked(now) {
+			if remaining := ip.LockedUntil.Sub(now); !locked || remaining > wait {
+				wait = remaining
+			}
+			locked = true
+		}
+	}
+
+	if wait > 0 {
+		return &LoginThrottledError{RetryAfter: wait, Locked: locked}
+	}
+	return nil
+}
+
+// RecordFailure counts a failed login for the account and the IP address and
+// locks whichever reached its limit. The owner of a locked account, if it
+// exists, gets an email with an unlock link.
+func (s *loginThrottleService) RecordFailure(email, ipAddress string, user *models.User) error {
+	windowStart := time.Now().Add(-s.settings.Window)
+
+	account, err := s.throttleRepo.RecordFailure(models.LoginThrottleScopeAccount, normalizeLoginEmail(email), windowStart)
+	if err != nil {
+		return err
+	}
+	if account.FailedCount >= s.settings.MaxAccountFailures {
+		if err := s.lockAccount(account, user); err != nil {
+			return err
+		}
+	}
+
+	if ipAddress == "" {
+		return nil
+	}
+	ip, err := s.throttleRepo.RecordFailure(models.LoginThrottleScopeIP, ipAddress, windowStart)
+	if err != nil {
+		return err
+	}
+	if ip.FailedCount >= s.settings.MaxIPFailures {
+		if _, err := s.throttleRepo.Lock(ip.ID, time.Now().Add(s.settings.LockoutDuration), ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RecordSuccess forgets the failed logins of an account. IP address counts are
+// left to expire so a valid login cannot reset them.
+func (s *loginThrottleService) RecordSuccess(email string) error {
+	return s.throttleRepo.DeleteByKey(models.LoginThrottleScopeAccount, normalizeLoginEmail(email))
+}
+
+// Unlock lifts an account lockout with the token from the lockout email
+func (s *loginThrottleService) Unlock(token string) error {
+	throttle, err := s.throttleRepo.FindByUnlockToken(hashToken(token))
+	if err != nil {
+		return err
+	}
+	return s.throttleRepo.Delete(throttle.ID)
+}
+
+// ListLockouts returns tracked accounts and IP addresses for the admin area
+func (s *loginThrottleService) ListLockouts(lockedOnly bool, scope string, limit, offset int) ([]models.LoginThrottle, int64, error) {
+	return s.throttleRepo.List(lockedOnly, scope, limit, offset)
+}
+
+// ClearLockout removes the failures and lock of an account or IP address
+func (s *loginThrottleService) ClearLockout(id uint) error {
+	if _, err := s.throttleRepo.FindByID(id); err != nil {
+		return err
+	}
+	return s.throttleRepo.Delete(id)
+}
+
+// StartCleanup periodically deletes throttles that no longer matter until ctx is cancelled
+func (s *loginThrottleService) StartCleanup(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			count, err := s.throttleRepo.DeleteStale(time.Now().Add(-s.settings.Window))
+			if err != nil {
+				log.Printf("Login throttle cleanup failed: %v", err)
+				continue
+			}
+			if count > 0 {
+				log.Printf("Login throttle cleanup deleted %d record(s)", count)
+			}
+		}
+	}
+}
+
+// lockAccount locks an account throttle and emails the unlock link
+func (s *loginThrottleService) lockAccount(throttle *models.LoginThrottle, user *models.User) error {
+	token, err := generateSecureToken()
+	if err != nil {
+		return err
+	}
+
+	lockedUntil := time.Now().Add(s.settings.LockoutDuration)
+	locked, err := s.throttleRepo.Lock(throttle.ID, lockedUntil, hashToken(token))
+	if err != nil || !locked {
+		return err
+	}
+
+	if user == nil || s.emailService == nil {
+		return nil
+	}
+	link := s.settings.UnlockURL + "?token=" + url.QueryEscape(token)
+	if err := s.emailService.SendAccountLockedEmail(user.Email, user.FullName, link, int(s.settings.LockoutDuration.Minutes())); err != nil {
+		log.Printf("Failed to send lockout email to %s: %v", user.Email, err)
+	}
+	return nil
+}
+
+// delayFor returns how long an account must wait after its last failure
+func (s *loginThrottleService) delayFor(throttle *models.LoginThrottle) time.Duration {
+	if time.Since(throttle.LastFailedAt) > s.settings.Window || throttle.FailedCount < s.settings.DelayAfter {
+		return 0
+	}
+
+	delay := time.Second
+	for i := s.settings.DelayAfter; i < throttle.FailedCount && delay < s.settings.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > s.settings.MaxDelay {
+		delay = s.settings.MaxDelay
+	}
+	return delay
+}
+
+func normalizeLoginEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
@@ -0,0 +1,300 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/huy1235588/fashion-e-commerce/internal/models"
+	"github.com/huy1235588/fashion-e-commerce/internal/repositories"
+	"github.com/huy1235588/fashion-e-commerce/internal/utils"
+	"gorm.io/gorm"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// oauthStateTTL is how long a user has to finish logging in at the provider
+const oauthStateTTL = 10 * time.Minute
+
+// ErrUnknownProvider is returned for providers that are not configured
+var ErrUnknownProvider = errors.New("unknown login provider")
+
+// ErrProviderEmailNotVerified is returned when the provider cannot vouch for
+// the user's email address, so the account cannot be matched or created
+var ErrProviderEmailNotVerified = errors.New("the provider did not share a verified email address")
+
+// IdentityProvider is an external login provider. *utils.OIDCProvider
+// implements it; tests can substitute a fake.
+type IdentityProvider interface {
+	Name() string
+	AuthCodeURL(state, nonce, codeVerifier string) string
+	Identify(ctx context.Context, code, codeVerifier, nonce string) (*utils.ExternalIdentity, error)
+}
+
+// OAuthService handles login with external identity providers using the
+// authorization-code flow with PKCE
+type OAuthService interface {
+	Providers() []string
+	StartLogin(provider string) (string, error)
+	CompleteLogin(ctx context.Context, provider, code, state string, client ClientInfo) (*models.User, *TokenPair, error)
+	StartStateCleanup(ctx context.Context, interval time.Duration)
+}
+
+type oauthService struct {
+	providers    map[string]IdentityProvider
+	identityRepo repositories.UserIdentityRepository
+	authService  AuthService
+	db           *gorm.DB
+}
+
+// NewOAuthService creates a new OAuth service for the given providers
+func NewOAuthService(
+	providers []IdentityProvider,
+	identityRepo repositories.UserIdentityRepository,
+	authService AuthService,
+	db *gorm.DB,
+) OAuthService {
+	byName := make(map[string]IdentityProvider, len(providers))
+	for _, p := range providers {
+		byName[p.Name()] = p
+	}
+	return &oauthService{
+		providers:    byName,
+		identityRepo: identityRepo,
+		authService:  authService,
+		db:           db,
+	}
+}
+
+// Providers returns the names of the configured providers
+func (s *oauthService) Providers() []string {
+	names := make([]string, 0, len(s.providers))
+	for name := range s.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// StartLogin records a new login attempt and returns the provider URL the
+// user must be sent to
+func (s *oauthService) StartLogin(provider string) (string, error) {
+	p, ok := s.providers[provider]
+	if !ok {
+		return "", ErrUnknownProvider
+	}
+
+	state, err := generateSecureToken()
+	if err != nil {
+		return "", err
+	}
+	codeVerifier, err := generateSecureToken()
+	if err != nil {
+		return "", err
+	}
+	nonce, err := generateSecureToken()
+	if err != nil {
+		return "", err
+	}
+
+	err = s.identityRepo.CreateState(&models.OAuthState{
+		StateHash:    hashToken(state),
+		Provider:     provider,
+		CodeVerifier: codeVerifier,
+		Nonce:        nonce,
+		ExpiresAt:    time.Now().Add(oauthStateTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return p.AuthCodeURL(state, nonce, codeVerifier), nil
+}
+
+// CompleteLogin exchanges the code the provider sent back for the user's
+// identity and starts a session for the linked user. Unknown identities are
+// linked to the user with the same email address, or to a new account, but
+// only when the provider says it verified that address.
+func (s *oauthService) CompleteLogin(ctx context.Context, provider, code, state string, client ClientInfo) (*models.User, *TokenPair, error) {
+	p, ok := s.providers[provider]
+	if !ok {
+		return nil, nil, ErrUnknownProvider
+	}
+
+	pending, err := s.identityRepo.ConsumeState(hashToken(state))
+	if err != nil {
+		return nil, nil, err
+	}
+	if pending.Provider != provider {
+		return nil, nil, errors.New("invalid or expired login state")
+	}
+
+	identity, err := p.Identify(ctx, code, pending.CodeVerifier, pending.Nonce)
+	if err != nil {
+		log.Printf("%s login failed: %v", provider, err)
+		return nil, nil, errors.New("could not sign in with " + provider)
+	}
+
+	var user *models.User
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		user, err = s.resolveUser(tx, identity)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !user.IsActive {
+		return nil, nil, errors.New("account is deactivated")
+	}
+
+	tokens, err := s.authService.StartSession(user, client)
+	if err != nil {
+		return nil, nil, err
+	}
+	return user, tokens, nil
+}
+
+// resolveUser finds or creates the user an external identity belongs to
+func (s *oauthService) resolveUser(tx *gorm.DB, identity *utils.ExternalIdentity) (*models.User, error) {
+	identityRepo := repositories.NewUserIdentityRepository(tx)
+	userRepo := repositories.NewUserRepository(tx)
+
+	linked, err := identityRepo.FindBySubject(identity.Provider, identity.Subject)
+	if err != nil {
+		return nil, err
+	}
+	if linked != nil {
+		if identity.Email != "" && identity.Email != linked.Email {
+			if err := identityRepo.UpdateEmail(linked.ID, identity.Email); err != nil {
+				return nil, err
+			}
+		}
+		return userRepo.FindByID(linked.UserID)
+	}
+
+	if identity.Email == "" || !identity.EmailVerified {
+		return nil, ErrProviderEmailNotVerified
+	}
+
+	user, err := userRepo.FindByEmail(identity.Email)
+	if err != nil {
+		if user, err = s.createUser(userRepo, identity); err != nil {
+			return nil, err
+		}
+	} else if user.VerifiedAt == nil {
+		// Whoever registered this address never proved they own it, while the
+		// provider just did. Drop the password they chose and their sessions so
+		// an account pre-registered with someone else's email cannot be used to
+		// get into it later; the owner can set a password with a reset code.
+		if err := s.takeOverUnverified(tx, user); err != nil {
+			return nil, err
+		}
+	}
+
+	err = identityRepo.Create(&models.UserIdentity{
+		UserID:   user.ID,
+		Provider: identity.Provider,
+		Subject:  identity.Subject,
+		Email:    identity.Email,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The provider verified the address, so guest orders placed with it are theirs
+	if err := claimGuestOrders(tx, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// createUser creates a verified account for a new external identity. It gets
+// a random password, so it can only log in through the provider until the
+// user sets one with a reset code.
+func (s *oauthService) createUser(userRepo repositories.UserRepository, identity *utils.ExternalIdentity) (*models.User, error) {
+	password, err := unusablePassword()
+	if err != nil {
+		return nil, err
+	}
+
+	fullName := strings.TrimSpace(identity.Name)
+	if fullName == "" {
+		fullName = strings.SplitN(identity.Email, "@", 2)[0]
+	}
+
+	now := time.Now()
+	user := &models.User{
+		Email:      identity.Email,
+		Password:   password,
+		FullName:   fullName,
+		Role:       models.RoleCustomer,
+		IsActive:   true,
+		VerifiedAt: &now,
+	}
+	if err := userRepo.Create(user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// takeOverUnverified marks an unverified account verified and replaces its
+// password and sessions
+func (s *oauthService) takeOverUnverified(tx *gorm.DB, user *models.User) error {
+	password, err := unusablePassword()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	user.Password = password
+	user.VerifiedAt = &now
+	if err := repositories.NewUserRepository(tx).Update(user); err != nil {
+		return err
+	}
+	if err := revokeUserTokens(tx, user.ID); err != nil {
+		return err
+	}
+	// Update saved the old token version; reload so the new session uses the current one
+	reloaded, err := repositories.NewUserRepository(tx).FindByID(user.ID)
+	if err != nil {
+		return err
+	}
+	*user = *reloaded
+	return nil
+}
+
+// StartStateCleanup periodically deletes abandoned social logins until ctx is cancelled
+func (s *oauthService) StartStateCleanup(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			count, err := s.identityRepo.DeleteExpiredStates(time.Now())
+			if err != nil {
+				log.Printf("OAuth state cleanup failed: %v", err)
+				continue
+			}
+			if count > 0 {
+				log.Printf("OAuth state cleanup deleted %d login state(s)", count)
+			}
+		}
+	}
+}
+
+// unusablePassword returns the bcrypt hash of a random password nobody knows
+func unusablePassword() (string, error) {
+	secret, err := generateSecureToken()
+	if err != nil {
+		return "", err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(secret), bcrypt.DefaultCost)
+	return string(hash), err
+}
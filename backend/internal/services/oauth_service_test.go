@@ -0,0 +1,113 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/huy1235588/fashion-e-commerce/internal/models"
+	"github.com/huy1235588/fashion-e-commerce/internal/repositories"
+	"github.com/huy1235588/fashion-e-commerce/internal/utils"
+)
+
+// stubIdentityRepository keeps pending logins in memory
+type stubIdentityRepository struct {
+	repositories.UserIdentityRepository
+	states map[string]*models.OAuthState
+}
+
+func (r *stubIdentityRepository) CreateState(state *models.OAuthState) error {
+	r.states[state.StateHash] = state
+	return nil
+}
+
+func (r *stubIdentityRepository) ConsumeState(stateHash string) (*models.OAuthState, error) {
+	state, ok := r.states[stateHash]
+	if !ok {
+		return nil, errors.New("invalid or expired login state")
+	}
+	delete(r.states, stateHash)
+	return state, nil
+}
+
+// recordingProvider is an IdentityProvider that fails every code exchange
+// and counts how often it was asked
+type recordingProvider struct {
+	name       string
+	identifies int
+}
+
+func (p *recordingProvider) Name() string { return p.name }
+
+func (p *recordingProvider) AuthCodeURL(state, nonce, codeVerifier string) string {
+	return "https://idp.test/authorize?" + url.Values{"state": {state}, "nonce": {nonce}}.Encode()
+}
+
+func (p *recordingProvider) Identify(ctx context.Context, code, codeVerifier, nonce string) (*utils.ExternalIdentity, error) {
+	p.identifies++
+	return nil, errors.New("code rejected")
+}
+
+func newTestOAuthService() (OAuthService, *recordingProvider, *recordingProvider) {
+	google := &recordingProvider{name: "google"}
+	facebook := &recordingProvider{name: "facebook"}
+	repo := &stubIdentityRepository{states: make(map[string]*models.OAuthState)}
+	return NewOAuthService([]IdentityProvider{google, facebook}, repo, nil, nil), google, facebook
+}
+
+// startState begins a login and returns the state sent to the provider
+func startState(t *testing.T, service OAuthService, provider string) string {
+	t.Helper()
+	authURL, err := service.StartLogin(provider)
+	if err != nil {
+		t.Fatalf("StartLogin: %v", err)
+	}
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	return u.Query().Get("state")
+}
+
+func TestCompleteLoginRejectsUnknownState(t *testing.T) {
+	service, google, _ := newTestOAuthService()
+	startState(t, service, "google")
+
+	_, _, err := service.CompleteLogin(context.Background(), "google", "code", "forged-state", ClientInfo{})
+	if err == nil {
+		t.Fatal("a forged state must be rejected")
+	}
+	if google.identifies != 0 {
+		t.Error("the code must not be exchanged for a forged state")
+	}
+}
+
+func TestCompleteLoginRejectsStateOfOtherProvider(t *testing.T) {
+	service, google, facebook := newTestOAuthService()
+	state := startState(t, service, "facebook")
+
+	_, _, err := service.CompleteLogin(context.Background(), "google", "code", state, ClientInfo{})
+	if err == nil {
+		t.Fatal("a state issued for another provider must be rejected")
+	}
+	if google.identifies != 0 || facebook.identifies != 0 {
+		t.Error("the code must not be exchanged")
+	}
+}
+
+func TestCompleteLoginStateIsSingleUse(t *testing.T) {
+	service, google, _ := newTestOAuthService()
+	state := startState(t, service, "google")
+
+	// The first attempt consumes the state even though the exchange fails
+	if _, _, err := service.CompleteLogin(context.Background(), "google", "code", state, ClientInfo{}); err == nil {
+		t.Fatal("expected the stub provider to fail the exchange")
+	}
+	if _, _, err := service.CompleteLogin(context.Background(), "google", "code", state, ClientInfo{}); err == nil {
+		t.Fatal("a used state must be rejected")
+	}
+	if google.identifies != 1 {
+		t.Errorf("Identify called %d times, want 1", google.identifies)
+	}
+}
@@ -3,21 +3,51 @@ package services
 import (
 	"errors"
 	"fmt"
+	"log"
+	"math"
+	"strings"
 
 	"github.com/huy1235588/fashion-e-commerce/internal/models"
 	"github.com/huy1235588/fashion-e-commerce/internal/repositories"
 	"github.com/huy1235588/fashion-e-commerce/internal/utils"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// ErrEmailNotVerified is returned when checkout requires a verified email address
+var ErrEmailNotVerified = errors.New("please verify your email address before placing an order")
+
+// ErrClaimRequiresVerifiedEmail is returned when an account that has not
+// proven it owns its email address tries to claim guest orders
+var ErrClaimRequiresVerifiedEmail = errors.New("please verify your email address to claim orders placed as a guest")
+
 type CreateOrderRequest struct {
 	AddressID     uint                  `json:"address_id" binding:"required"`
 	PaymentMethod models.PaymentMethod  `json:"payment_method" binding:"required"`
 	Note          string                `json:"note"`
+	PromotionCode string                `json:"promotion_code"`
+}
+
+// GuestCheckoutRequest places an order from an anonymous cart. The address
+// is given inline because guests have no address book; coupons need an
+// account, as their limits are counted per user.
+type GuestCheckoutRequest struct {
+	Email         string               `json:"email" binding:"required,email"`
+	FullName      string               `json:"full_name" binding:"required"`
+	Phone         string               `json:"phone" binding:"required"`
+	Province      string               `json:"province" binding:"required"`
+	District      string               `json:"district" binding:"required"`
+	Ward          string               `json:"ward" binding:"required"`
+	DetailAddress string               `json:"detail_address" binding:"required"`
+	PaymentMethod models.PaymentMethod `json:"payment_method" binding:"required"`
+	Note          string               `json:"note"`
 }
 
 type OrderService interface {
-	CreateFromCart(userID uint, req CreateOrderRequest) (*models.Order, error)
+	CreateFromCart(userID uint, cartID *uint, req CreateOrderRequest) (*models.Order, error)
+	CreateGuestOrder(cartID uint, req GuestCheckoutRequest) (*models.Order, error)
+	LookupGuestOrder(orderCode, email string) (*models.Order, error)
+	ClaimGuestOrders(userID uint) (int64, error)
 	GetOrderByID(id uint, userID uint) (*models.Order, error)
 	GetOrderByCode(orderCode string, userID uint) (*models.Order, error)
 	GetUserOrders(userID uint, limit, offset int) ([]models.Order, int64, error)
@@ -28,12 +58,22 @@ type OrderService interface {
 }
 
 type orderService struct {
-	orderRepo    repositories.OrderRepository
-	cartRepo     repositories.CartRepository
-	addressRepo  repositories.AddressRepository
-	productRepo  repositories.ProductRepository
-	db           *gorm.DB
-	emailService *utils.EmailService
+	orderRepo          repositories.OrderRepository
+	cartRepo           repositories.CartRepository
+	addressRepo        repositories.AddressRepository
+	productRepo        repositories.ProductRepository
+	userRepo           repositories.UserRepository
+	inventoryService   InventoryService
+	reservationService ReservationService
+	promotionService   PromotionService
+	shippingService    ShippingService
+	refundService      RefundService
+	db                 *gorm.DB
+	emailService       *utils.EmailService
+
+	// requireVerifiedEmail rejects checkout from accounts that have not
+	// confirmed their email address
+	requireVerifiedEmail bool
 }
 
 func NewOrderService(
@@ -41,34 +81,55 @@ func NewOrderService(
 	cartRepo repositories.CartRepository,
 	addressRepo repositories.AddressRepository,
 	productRepo repositories.ProductRepository,
+	userRepo repositories.UserRepository,
+	inventoryService InventoryService,
+	reservationService ReservationService,
+	promotionService PromotionService,
+	shippingService ShippingService,
+	refundService RefundService,
 	db *gorm.DB,
 	emailService *utils.EmailService,
+	requireVerifiedEmail bool,
 ) OrderService {
 	return &orderService{
-		orderRepo:    orderRepo,
-		cartRepo:     cartRepo,
-		addressRepo:  addressRepo,
-		productRepo:  productRepo,
-		db:           db,
-		emailService: emailService,
+		orderRepo:          orderRepo,
+		cartRepo:           cartRepo,
+		addressRepo:        addressRepo,
+		productRepo:        productRepo,
+		userRepo:           userRepo,
+		inventoryService:   inventoryService,
+		reservationService: reservationService,
+		promotionService:   promotionService,
+		shippingService:    shippingService,
+		refundService:      refundService,
+		db:                 db,
+		emailService:       emailService,
+
+		requireVerifiedEmail: requireVerifiedEmail,
 	}
 }
 
-func (s *orderService) CreateFromCart(userID uint, req CreateOrderRequest) (*models.Order, error) {
-	// Validate payment method
-	validPaymentMethods := map[models.PaymentMethod]bool{
-		models.PaymentMethodCOD:   true,
-		models.PaymentMethodVNPay: true,
-		models.PaymentMethodMoMo:  true,
+// CreateFromCart places an order from one of the user's carts, or from
+// their primary cart when cartID is nil
+func (s *orderService) CreateFromCart(userID uint, cartID *uint, req CreateOrderRequest) (*models.Order, error) {
+	if err := validatePaymentMethod(req.PaymentMethod); err != nil {
+		return nil, err
 	}
-	if !validPaymentMethods[req.PaymentMethod] {
-		return nil, errors.New("invalid payment method")
+
+	if s.requireVerifiedEmail {
+		user, err := s.userRepo.FindByID(userID)
+		if err != nil {
+			return nil, err
+		}
+		if user.VerifiedAt == nil {
+			return nil, ErrEmailNotVerified
+		}
 	}
 
-	// Get user's cart
-	cart, err := s.cartRepo.GetByUserID(userID)
+	// Get the cart to check out
+	cart, err := s.findCheckoutCart(userID, cartID)
 	if err != nil {
-		return nil, errors.New("cart not found")
+		return nil, err
 	}
 
 	if len(cart.Items) == 0 {
@@ -86,56 +147,113 @@ func (s *orderService) CreateFromCart(userID uint, req CreateOrderRequest) (*mod
 		return nil, errors.New("unauthorized access to address")
 	}
 
+	return s.placeOrder(checkout{
+		cart:          cart,
+		address:       address,
+		userID:        &userID,
+		paymentMethod: req.PaymentMethod,
+		note:          req.Note,
+		promotionCode: req.PromotionCode,
+	})
+}
+
+// findCheckoutCart loads the user's cart with the given ID, or their
+// primary cart when cartID is nil
+func (s *orderService) findCheckoutCart(userID uint, cartID *uint) (*models.Cart, error) {
+	if cartID == nil {
+		cart, err := s.cartRepo.GetByUserID(userID)
+		if err != nil {
+			return nil, errors.New("cart not found")
+		}
+		return cart, nil
+	}
+
+	cart, err := s.cartRepo.GetByID(*cartID)
+	if err != nil || !cart.BelongsTo(userID) {
+		return nil, errors.New("cart not found")
+	}
+	return cart, nil
+}
+
+// CreateGuestOrder places an order from an anonymous cart for a visitor
+// without an account. Emails about the order go to req.Email, which is also
+// how the guest looks the order up and later claims it.
+func (s *orderService) CreateGuestOrder(cartID uint, req GuestCheckoutRequest) (*models.Order, error) {
+	if err := validatePaymentMethod(req.PaymentMethod); err != nil {
+		return nil, err
+	}
+
+	cart, err := s.cartRepo.GetByID(cartID)
+	if err != nil || !cart.IsGuest() {
+		return nil, errors.New("cart not found")
+	}
+
+	if len(cart.Items) == 0 {
+		return nil, errors.New("cart is empty")
+	}
+
+	return s.placeOrder(checkout{
+		cart: cart,
+		address: &models.Address{
+			FullName:      req.FullName,
+			Phone:         req.Phone,
+			Province:      req.Province,
+			District:      req.District,
+			Ward:          req.Ward,
+			DetailAddress: req.DetailAddress,
+		},
+		guestEmail:    strings.ToLower(strings.TrimSpace(req.Email)),
+		paymentMethod: req.PaymentMethod,
+		note:          req.Note,
+	})
+}
+
+// checkout is an order about to be placed from a cart, either by a user
+// or, when userID is nil, by a guest
+type checkout struct {
+	cart          *models.Cart
+	address       *models.Address // shipping destination, not necessarily saved
+	userID        *uint
+	guestEmail    string
+	paymentMethod models.PaymentMethod
+	note          string
+	promotionCode string
+}
+
+// placeOrder turns a cart into an order: it prices the items, takes the
+// stock, redeems the coupon and empties the cart in one transaction
+func (s *orderService) placeOrder(req checkout) (*models.Order, error) {
+	cart, address := req.cart, req.address
+
+	// Online payments only hold stock until the gateway confirms;
+	// COD orders take it out of inventory right away
+	holdStock := req.paymentMethod != models.PaymentMethodCOD
+
 	// Start transaction
 	var order *models.Order
-	err = s.db.Transaction(func(tx *gorm.DB) error {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
 		// Validate stock and prepare order items
 		orderItems := make([]models.OrderItem, 0, len(cart.Items))
+		promotionLines := make([]PromotionLine, 0, len(cart.Items))
 		var subtotal float64
+		var totalWeight int
+		var changes []CartAdjustment
 
 		for _, cartItem := range cart.Items {
-			// Get product with variant
-			product, err := s.productRepo.FindByID(cartItem.ProductID)
+			// Check the line still matches current stock and pricing
+			review, err := reviewCartLine(tx, s.productRepo, s.reservationService, cartItem.ProductID, cartItem.VariantID, cartItem.Quantity, cartItem.Price)
 			if err != nil {
-				return fmt.Errorf("product %d not found", cartItem.ProductID)
+				return err
 			}
-
-			// Check stock
-			var availableStock int
-			var price float64
-			variantName := ""
-
-			// Find variant (always required)
-			var variant *models.ProductVariant
-			for i := range product.Variants {
-				if product.Variants[i].ID == cartItem.VariantID {
-					variant = &product.Variants[i]
-					break
-				}
-			}
-
-			if variant == nil {
-				return fmt.Errorf("variant %d not found for product %s", cartItem.VariantID, product.Name)
-			}
-
-			availableStock = variant.StockQuantity
-			price = product.Price
-			variantName = variant.Size
-
-			// Check if enough stock
-			if availableStock < cartItem.Quantity {
-				return fmt.Errorf("insufficient stock for product %s", product.Name)
+			if len(review.adjustments) > 0 {
+				changes = append(changes, review.adjustments...)
+				continue
 			}
+			product, variant := review.product, review.variant
 
-			// Deduct stock from variant
-			err = tx.Model(&models.ProductVariant{}).
-				Where("id = ?", cartItem.VariantID).
-				UpdateColumn("stock_quantity", gorm.Expr("stock_quantity - ?", cartItem.Quantity)).
-				Error
-
-			if err != nil {
-				return fmt.Errorf("failed to update stock for product %s", product.Name)
-			}
+			// Charge the price the customer confirmed in the cart
+			price := cartItem.Price
+			variantName := variant.Size
 
 			// Create order item
 			itemSubtotal := price * float64(cartItem.Quantity)
@@ -151,12 +269,43 @@ func (s *orderService) CreateFromCart(userID uint, req CreateOrderRequest) (*mod
 			}
 
 			orderItems = append(orderItems, orderItem)
+			promotionLines = append(promotionLines, PromotionLine{
+				ProductID:  product.ID,
+				CategoryID: product.CategoryID,
+				Subtotal:   itemSubtotal,
+			})
 			subtotal += itemSubtotal
+			totalWeight += variant.Weight * cartItem.Quantity
+		}
+
+		// Nothing is ordered until the customer has seen the changes
+		if len(changes) > 0 {
+			return &CartChangedError{Adjustments: changes}
+		}
+
+		// Calculate shipping fee for the destination and parcel weight
+		shippingFee, err := s.shippingService.Calculate(ShippingParcel{
+			Province:    address.Province,
+			District:    address.District,
+			Subtotal:    subtotal,
+			TotalWeight: totalWeight,
+		})
+		if err != nil {
+			return err
 		}
 
-		// Calculate shipping fee (simple fixed rate for now)
-		shippingFee := 30000.0 // 30,000 VND
-		totalAmount := subtotal + shippingFee
+		// Apply coupon code, if any
+		var quote *PromotionQuote
+		var discountAmount float64
+		if req.promotionCode != "" && req.userID != nil {
+			quote, err = s.promotionService.Quote(tx, *req.userID, req.promotionCode, promotionLines, shippingFee)
+			if err != nil {
+				return err
+			}
+			discountAmount = quote.DiscountAmount
+		}
+
+		totalAmount := math.Max(subtotal+shippingFee-discountAmount, 0)
 
 		// Generate order code
 		orderCode := utils.GenerateOrderCode()
@@ -164,14 +313,16 @@ func (s *orderService) CreateFromCart(userID uint, req CreateOrderRequest) (*mod
 		// Create order
 		order = &models.Order{
 			OrderCode:             orderCode,
-			UserID:                userID,
+			UserID:                req.userID,
+			GuestEmail:            req.guestEmail,
 			Status:                models.OrderStatusPending,
-			PaymentMethod:         req.PaymentMethod,
+			PaymentMethod:         req.paymentMethod,
 			PaymentStatus:         models.PaymentStatusPending,
 			SubtotalAmount:        subtotal,
 			ShippingFee:           shippingFee,
+			DiscountAmount:        discountAmount,
 			TotalAmount:           totalAmount,
-			Note:                  req.Note,
+			Note:                  req.note,
 			ShippingFullName:      address.FullName,
 			ShippingPhone:         address.Phone,
 			ShippingProvince:      address.Province,
@@ -181,10 +332,40 @@ func (s *orderService) CreateFromCart(userID uint, req CreateOrderRequest) (*mod
 			OrderItems:            orderItems,
 		}
 
+		if quote != nil {
+			order.PromotionID = &quote.Promotion.ID
+			order.PromotionCode = quote.Promotion.Code
+		}
+
 		if err := tx.Create(order).Error; err != nil {
 			return err
 		}
 
+		if quote != nil {
+			if err := s.promotionService.Redeem(tx, quote, *req.userID, order.ID); err != nil {
+				return err
+			}
+		}
+
+		// Hold stock until the payment gateway confirms, or deduct it now for COD
+		if holdStock {
+			if err := s.reservationService.ReserveOrder(tx, order); err != nil {
+				return err
+			}
+		} else {
+			for _, item := range order.OrderItems {
+				err := s.inventoryService.ApplyMovement(tx, &models.InventoryMovement{
+					VariantID: *item.VariantID,
+					Delta:     -item.Quantity,
+					Reason:    models.MovementReasonSale,
+					OrderID:   &order.ID,
+				})
+				if err != nil {
+					return fmt.Errorf("failed to update stock for product %s", item.ProductName)
+				}
+			}
+		}
+
 		// Clear cart
 		if err := tx.Where("cart_id = ?", cart.ID).Delete(&models.CartItem{}).Error; err != nil {
 			return err
@@ -220,7 +401,7 @@ func (s *orderService) GetOrderByID(id uint, userID uint) (*models.Order, error)
 	}
 
 	// Verify ownership (unless admin - will be handled by handler)
-	if order.UserID != userID {
+	if !order.BelongsTo(userID) {
 		return nil, errors.New("unauthorized access to order")
 	}
 
@@ -234,13 +415,37 @@ func (s *orderService) GetOrderByCode(orderCode string, userID uint) (*models.Or
 	}
 
 	// Verify ownership
-	if order.UserID != userID {
+	if !order.BelongsTo(userID) {
 		return nil, errors.New("unauthorized access to order")
 	}
 
 	return order, nil
 }
 
+// LookupGuestOrder finds a guest order by its code and the email address
+// given at checkout
+func (s *orderService) LookupGuestOrder(orderCode, email string) (*models.Order, error) {
+	order, err := s.orderRepo.FindGuestOrder(strings.TrimSpace(orderCode), strings.TrimSpace(email))
+	if err != nil {
+		return nil, errors.New("order not found")
+	}
+	return order, nil
+}
+
+// ClaimGuestOrders links the orders placed as a guest with the user's email
+// address to their account. Only verified accounts may claim, so nobody can
+// collect another person's orders by registering with their address.
+func (s *orderService) ClaimGuestOrders(userID uint) (int64, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return 0, err
+	}
+	if user.VerifiedAt == nil {
+		return 0, ErrClaimRequiresVerifiedEmail
+	}
+	return s.orderRepo.ClaimGuestOrders(user.ID, user.Email)
+}
+
 func (s *orderService) GetUserOrders(userID uint, limit, offset int) ([]models.Order, int64, error) {
 	return s.orderRepo.FindByUserID(userID, limit, offset)
 }
@@ -249,6 +454,9 @@ func (s *orderService) GetAllOrders(filters map[string]interface{}, limit, offse
 	return s.orderRepo.List(filters, limit, offset)
 }
 
+// UpdateOrderStatus moves an order to a new status on behalf of staff.
+// Cancelling goes through the same path as a customer cancel, so stock,
+// coupon use and payment are given back as well.
 func (s *orderService) UpdateOrderStatus(id uint, status models.OrderStatus) error {
 	// Get current order
 	order, err := s.orderRepo.FindByID(id)
@@ -256,6 +464,10 @@ func (s *orderService) UpdateOrderStatus(id uint, status models.OrderStatus) err
 		return err
 	}
 
+	if status == models.OrderStatusCancelled {
+		return s.cancelOrder(order, nil, "Cancelled by staff")
+	}
+
 	// Validate status transition
 	if err := s.ValidateStatusTransition(order.Status, status); err != nil {
 		return err
@@ -272,47 +484,110 @@ func (s *orderService) CancelOrder(id uint, userID uint, reason string) error {
 	}
 
 	// Verify ownership
-	if order.UserID != userID {
+	if !order.BelongsTo(userID) {
 		return errors.New("unauthorized access to order")
 	}
 
-	// Check if order can be cancelled (only pending and processing orders)
-	if order.Status != models.OrderStatusPending && order.Status != models.OrderStatusProcessing {
-		return errors.New("order cannot be cancelled")
-	}
+	return s.cancelOrder(order, &userID, reason)
+}
+
+// cancelOrder cancels a pending or processing order: held stock is released
+// and deducted stock restocked, the coupon use is given back, an unfinished
+// online payment is closed and a paid one refunded. cancelledBy is nil when
+// staff cancel the order.
+func (s *orderService) cancelOrder(order *models.Order, cancelledBy *uint, reason string) error {
+	id := order.ID
 
 	// Transaction to restore stock and update order
-	err = s.db.Transaction(func(tx *gorm.DB) error {
-		// Restore stock for each order item
-		for _, item := range order.OrderItems {
-			if item.VariantID != nil {
-				// Restore variant stock
-				err := tx.Model(&models.ProductVariant{}).
-					Where("id = ?", *item.VariantID).
-					UpdateColumn("stock", gorm.Expr("stock + ?", item.Quantity)).
-					Error
-				if err != nil {
-					return err
-				}
-			} else {
-				// Restore product stock
-				err := tx.Model(&models.Product{}).
-					Where("id = ?", item.ProductID).
-					UpdateColumn("stock", gorm.Expr("stock + ?", item.Quantity)).
-					Error
-				if err != nil {
+	refundDue := false
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// Lock the payment before the order, in the same order as the gateway
+		// callbacks, so a paid IPN either settles first or sees the cancel
+		var payment models.Payment
+		hasPayment := true
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("order_id = ?", id).
+			First(&payment).Error; err != nil {
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+			hasPayment = false
+		}
+
+		var current models.Order
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Preload("OrderItems").
+			First(&current, id).Error; err != nil {
+			return err
+		}
+
+		// Check if order can be cancelled (only pending and processing orders)
+		if err := s.ValidateStatusTransition(current.Status, models.OrderStatusCancelled); err != nil {
+			return errors.New("order cannot be cancelled")
+		}
+
+		// Release held stock and restock anything already deducted
+		if err := s.reservationService.RestoreOrderStock(tx, &current, ReservationReasonCancelled); err != nil {
+			return err
+		}
+
+		// Give the coupon use back
+		if err := releasePromotionUsage(tx, current.ID); err != nil {
+			return err
+		}
+
+		updates := map[string]interface{}{
+			"status":        models.OrderStatusCancelled,
+			"cancel_reason": reason,
+		}
+		if hasPayment && current.PaymentMethod != models.PaymentMethodCOD {
+			switch payment.PaymentStatus {
+			case models.PaymentStatusPending:
+				// Close a payment the customer never completed, so a late
+				// paid callback is flagged for refund instead of settling the order
+				updates["payment_status"] = models.PaymentStatusFailed
+				if err := tx.Model(&payment).Update("payment_status", models.PaymentStatusFailed).Error; err != nil {
 					return err
 				}
+			case models.PaymentStatusPaid:
+				refundDue = true
 			}
 		}
 
 		// Update order status
-		return tx.Model(order).Updates(map[string]interface{}{
-			"status":        models.OrderStatusCancelled,
-			"cancel_reason": reason,
-		}).Error
+		return tx.Model(&current).Updates(updates).Error
 	})
+	if err != nil {
+		return err
+	}
+
+	// Send the money of a paid online order back to the customer. The
+	// cancellation stands even if the gateway refuses; the failed refund
+	// is recorded and can be retried by an admin, and one with an unknown
+	// outcome is settled by the reconciliation worker.
+	if refundDue {
+		refundReq := RefundRequest{Reason: "Order cancelled: " + reason}
+		if _, err := s.refundService.RequestRefund(order.ID, cancelledBy, refundReq, ""); err != nil {
+			log.Printf("Failed to refund cancelled order %s: %v", order.OrderCode, err)
+		}
+	}
+
+	return nil
+}
+
+// validatePaymentMethod rejects payment methods the shop does not offer
+func validatePaymentMethod(method models.PaymentMethod) error {
+	switch method {
+	case models.PaymentMethodCOD, models.PaymentMethodVNPay, models.PaymentMethodMoMo:
+		return nil
+	}
+	return errors.New("invalid payment method")
+}
 
+// claimGuestOrders gives a user the guest orders placed with their email
+// address. Call it only once the user has proven they own the address.
+func claimGuestOrders(tx *gorm.DB, user *models.User) error {
+	_, err := repositories.NewOrderRepository(tx).ClaimGuestOrders(user.ID, user.Email)
 	return err
 }
 
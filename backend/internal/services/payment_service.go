@@ -4,6 +4,9 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
+	"math"
+	"strconv"
 	"time"
 
 	"github.com/google/uuid"
@@ -11,6 +14,7 @@ import (
 	"github.com/huy1235588/fashion-e-commerce/internal/repositories"
 	"github.com/huy1235588/fashion-e-commerce/internal/utils"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type InitiatePaymentRequest struct {
@@ -18,8 +22,17 @@ type InitiatePaymentRequest struct {
 	IPAddr  string `json:"ip_addr"`
 }
 
+// InitiatePaymentResponse holds where to send the customer to pay.
+// All fields are empty for COD orders.
+type InitiatePaymentResponse struct {
+	PaymentURL string `json:"payment_url,omitempty"`
+	Deeplink   string `json:"deeplink,omitempty"`
+	QRCodeURL  string `json:"qr_code_url,omitempty"`
+}
+
 type PaymentService interface {
-	InitiatePayment(userID uint, req InitiatePaymentRequest) (string, error)
+	InitiatePayment(userID uint, req InitiatePaymentRequest) (*InitiatePaymentResponse, error)
+	InitiateGuestPayment(orderCode, email, ipAddr string) (*InitiatePaymentResponse, error)
 	ProcessVNPayCallback(queryParams map[string]string) error
 	ProcessMoMoCallback(params map[string]interface{}) error
 	ConfirmCODPayment(orderID uint) error
@@ -27,49 +40,68 @@ type PaymentService interface {
 }
 
 type paymentService struct {
-	paymentRepo repositories.PaymentRepository
-	orderRepo   repositories.OrderRepository
-	vnpayHelper *utils.VNPayHelper
-	momoHelper  *utils.MoMoHelper
-	db          *gorm.DB
+	paymentRepo        repositories.PaymentRepository
+	orderRepo          repositories.OrderRepository
+	reservationService ReservationService
+	vnpayHelper        *utils.VNPayHelper
+	momoHelper         *utils.MoMoHelper
+	db                 *gorm.DB
 }
 
 func NewPaymentService(
 	paymentRepo repositories.PaymentRepository,
 	orderRepo repositories.OrderRepository,
+	reservationService ReservationService,
 	vnpayHelper *utils.VNPayHelper,
 	momoHelper *utils.MoMoHelper,
 	db *gorm.DB,
 ) PaymentService {
 	return &paymentService{
-		paymentRepo: paymentRepo,
-		orderRepo:   orderRepo,
-		vnpayHelper: vnpayHelper,
-		momoHelper:  momoHelper,
-		db:          db,
+		paymentRepo:        paymentRepo,
+		orderRepo:          orderRepo,
+		reservationService: reservationService,
+		vnpayHelper:        vnpayHelper,
+		momoHelper:         momoHelper,
+		db:                 db,
 	}
 }
 
-func (s *paymentService) InitiatePayment(userID uint, req InitiatePaymentRequest) (string, error) {
+func (s *paymentService) InitiatePayment(userID uint, req InitiatePaymentRequest) (*InitiatePaymentResponse, error) {
 	// Get order
 	order, err := s.orderRepo.FindByID(req.OrderID)
 	if err != nil {
-		return "", errors.New("order not found")
+		return nil, errors.New("order not found")
 	}
 
 	// Verify ownership
-	if order.UserID != userID {
-		return "", errors.New("unauthorized access to order")
+	if !order.BelongsTo(userID) {
+		return nil, errors.New("unauthorized access to order")
+	}
+
+	return s.initiate(order, req.IPAddr)
+}
+
+// InitiateGuestPayment starts paying for a guest order, identified by its
+// code and the email address given at checkout
+func (s *paymentService) InitiateGuestPayment(orderCode, email, ipAddr string) (*InitiatePaymentResponse, error) {
+	order, err := s.orderRepo.FindGuestOrder(orderCode, email)
+	if err != nil {
+		return nil, errors.New("order not found")
 	}
+	return s.initiate(order, ipAddr)
+}
 
+// initiate creates the payment record of an order and asks its gateway for
+// a payment link
+func (s *paymentService) initiate(order *models.Order, ipAddr string) (*InitiatePaymentResponse, error) {
 	// Check if order is already paid
 	if order.PaymentStatus == models.PaymentStatusPaid {
-		return "", errors.New("order is already paid")
+		return nil, errors.New("order is already paid")
 	}
 
 	// Check if order is cancelled
 	if order.Status == models.OrderStatusCancelled {
-		return "", errors.New("cannot pay for cancelled order")
+		return nil, errors.New("cannot pay for cancelled order")
 	}
 
 	// Create or update payment record
@@ -83,35 +115,107 @@ func (s *paymentService) InitiatePayment(userID uint, req InitiatePaymentRequest
 			Amount:        order.TotalAmount,
 		}
 		if err := s.paymentRepo.Create(payment); err != nil {
-			return "", err
+			return nil, err
 		}
 	}
 
 	// Generate payment URL based on method
 	switch order.PaymentMethod {
 	case models.PaymentMethodVNPay:
-		return s.generateVNPayURL(order, req.IPAddr)
+		paymentURL, err := s.generateVNPayURL(order, payment, ipAddr)
+		if err != nil {
+			return nil, err
+		}
+		return &InitiatePaymentResponse{PaymentURL: paymentURL}, nil
 	case models.PaymentMethodMoMo:
-		return s.generateMoMoURL(order)
+		return s.createMoMoPayment(order, payment)
 	case models.PaymentMethodCOD:
 		// COD doesn't need payment URL
-		return "", nil
+		return &InitiatePaymentResponse{}, nil
 	default:
-		return "", errors.New("invalid payment method")
+		return nil, errors.New("invalid payment method")
 	}
 }
 
-func (s *paymentService) generateVNPayURL(order *models.Order, ipAddr string) (string, error) {
+func (s *paymentService) generateVNPayURL(order *models.Order, payment *models.Payment, ipAddr string) (string, error) {
 	orderInfo := fmt.Sprintf("Thanh toán đơn hàng %s", order.OrderCode)
 	amount := int64(order.TotalAmount)
 
-	return s.vnpayHelper.GeneratePaymentURL(order.OrderCode, amount, orderInfo, ipAddr)
+	// Remember vnp_CreateDate so the transaction can be looked up with querydr
+	now := time.Now()
+	payment.InitiatedAt = &now
+	if err := s.paymentRepo.Update(payment); err != nil {
+		return "", err
+	}
+
+	return s.vnpayHelper.GeneratePaymentURL(order.OrderCode, amount, orderInfo, ipAddr, now)
 }
 
-func (s *paymentService) generateMoMoURL(order *models.Order) (string, error) {
-	// MoMo requires API call to generate payment URL
-	// For now, return placeholder
-	return "", errors.New("MoMo integration not fully implemented")
+// createMoMoPayment requests payment links from MoMo and records the request ID on the payment.
+// MoMo rejects a second create request for the same orderId, so links from an
+// earlier successful request are returned again instead.
+func (s *paymentService) createMoMoPayment(order *models.Order, payment *models.Payment) (*InitiatePaymentResponse, error) {
+	if payment.RequestID != "" && payment.PaymentStatus == models.PaymentStatusPending {
+		var previous utils.MoMoCreatePaymentResponse
+		if err := json.Unmarshal([]byte(payment.GatewayResponse), &previous); err == nil && previous.PayURL != "" {
+			return momoInitiateResponse(&previous), nil
+		}
+	}
+
+	requestID := uuid.New().String()
+	orderInfo := fmt.Sprintf("Thanh toán đơn hàng %s", order.OrderCode)
+	amount := int64(order.TotalAmount)
+
+	result, err := s.momoHelper.CreatePayment(order.OrderCode, requestID, amount, orderInfo)
+
+	// Keep the request ID and whatever MoMo answered, even on failure, for support lookups
+	now := time.Now()
+	payment.RequestID = requestID
+	payment.InitiatedAt = &now
+	if result != nil {
+		responseJSON, _ := json.Marshal(result)
+		payment.GatewayResponse = string(responseJSON)
+	}
+	if updateErr := s.paymentRepo.Update(payment); updateErr != nil {
+		return nil, updateErr
+	}
+
+	if err != nil {
+		var momoErr *utils.MoMoError
+		if errors.As(err, &momoErr) {
+			return nil, fmt.Errorf("MoMo payment could not be created: %s", momoErr.Message)
+		}
+		return nil, errors.New("MoMo is temporarily unavailable, please try again")
+	}
+
+	return momoInitiateResponse(result), nil
+}
+
+func momoInitiateResponse(result *utils.MoMoCreatePaymentResponse) *InitiatePaymentResponse {
+	return &InitiatePaymentResponse{
+		PaymentURL: result.PayURL,
+		Deeplink:   result.Deeplink,
+		QRCodeURL:  result.QRCodeURL,
+	}
+}
+
+// Errors returned for gateway callbacks, mapped to gateway response codes by the handler
+var (
+	ErrCallbackInvalidSignature = errors.New("invalid signature")
+	ErrCallbackOrderNotFound    = errors.New("order not found")
+	ErrCallbackAlreadyConfirmed = errors.New("order already confirmed")
+	ErrCallbackInvalidAmount    = errors.New("invalid amount")
+)
+
+// gatewayCallback is a signature-checked IPN from VNPay or MoMo
+type gatewayCallback struct {
+	Gateway       models.PaymentMethod
+	OrderCode     string
+	TransactionID string
+	ResultCode    string
+	Amount        float64
+	Status        gatewayPaymentStatus // paid, failed or pending (not final yet)
+	Payload       interface{}
 }
 
 func (s *paymentService) ProcessVNPayCallback(queryParams map[string]string) error {
@@ -123,57 +227,34 @@ func (s *paymentService) ProcessVNPayCallback(queryParams map[string]string) err
 
 	// Verify signature
 	if !s.vnpayHelper.VerifyCallback(values) {
-		return errors.New("invalid signature")
+		return ErrCallbackInvalidSignature
 	}
 
-	// Get transaction info
-	orderCode := queryParams["vnp_TxnRef"]
-	responseCode := queryParams["vnp_ResponseCode"]
-	transactionID := queryParams["vnp_TransactionNo"]
-
-	// Find order by code
-	order, err := s.orderRepo.FindByOrderCode(orderCode)
+	amount, err := strconv.ParseFloat(queryParams["vnp_Amount"], 64)
 	if err != nil {
-		return errors.New("order not found")
+		return ErrCallbackInvalidAmount
 	}
 
-	// Get payment
-	payment, err := s.paymentRepo.FindByOrderID(order.ID)
-	if err != nil {
-		return errors.New("payment not found")
+	callback := gatewayCallback{
+		Gateway:       models.PaymentMethodVNPay,
+		OrderCode:     queryParams["vnp_TxnRef"],
+		TransactionID: queryParams["vnp_TransactionNo"],
+		ResultCode:    queryParams["vnp_ResponseCode"] + "/" + queryParams["vnp_TransactionStatus"],
+		Amount:        amount / 100, // VNPay sends the smallest unit
+		Payload:       queryParams,
 	}
 
-	// Transaction to update payment and order
-	return s.db.Transaction(func(tx *gorm.DB) error {
-		// Update payment
-		now := time.Now()
-		payment.TransactionID = transactionID
-		responseJSON, _ := json.Marshal(queryParams)
-		payment.GatewayResponse = string(responseJSON)
-
-		if responseCode == "00" {
-			// Payment successful
-			payment.PaymentStatus = models.PaymentStatusPaid
-			payment.PaidAt = &now
-
-			// Update order
-			if err := s.orderRepo.UpdatePaymentStatus(order.ID, models.PaymentStatusPaid); err != nil {
-				return err
-			}
-
-			// Update order status to processing
-			if order.Status == models.OrderStatusPending {
-				if err := s.orderRepo.UpdateStatus(order.ID, models.OrderStatusProcessing); err != nil {
-					return err
-				}
-			}
-		} else {
-			// Payment failed
-			payment.PaymentStatus = models.PaymentStatusFailed
-		}
+	switch {
+	case queryParams["vnp_ResponseCode"] == "00" && queryParams["vnp_TransactionStatus"] == "00":
+		callback.Status = gatewayStatusPaid
+	case queryParams["vnp_ResponseCode"] == "07" || queryParams["vnp_TransactionStatus"] == "01" || queryParams["vnp_TransactionStatus"] == "07":
+		// Money taken but held for review, or not finished; wait for the final result
+		callback.Status = gatewayStatusPending
+	default:
+		callback.Status = gatewayStatusFailed
+	}
 
-		return s.paymentRepo.Update(payment)
-	})
+	return s.applyCallback(callback)
 }
 
 func (s *paymentService) ProcessMoMoCallback(params map[string]interface{}) error {
@@ -184,57 +265,220 @@ func (s *paymentService) ProcessMoMoCallback(params map[string]interface{}) erro
 
 	// Verify signature
 	if !s.momoHelper.VerifyIPNSignature(params, signature) {
-		return errors.New("invalid signature")
+		return ErrCallbackInvalidSignature
 	}
 
-	// Get transaction info
-	orderID, _ := params["orderId"].(string)
-	resultCode, _ := params["resultCode"].(float64)
-	transID, _ := params["transId"].(string)
+	// Numbers must be decoded as json.Number so they print exactly as signed
+	resultCode, err := strconv.Atoi(fmt.Sprint(params["resultCode"]))
+	if err != nil {
+		return errors.New("invalid resultCode")
+	}
+	amount, err := strconv.ParseFloat(fmt.Sprint(params["amount"]), 64)
+	if err != nil {
+		return ErrCallbackInvalidAmount
+	}
 
-	// Find order by code
-	order, err := s.orderRepo.FindByOrderCode(orderID)
+	callback := gatewayCallback{
+		Gateway:       models.PaymentMethodMoMo,
+		OrderCode:     fmt.Sprint(params["orderId"]),
+		TransactionID: fmt.Sprint(params["transId"]),
+		ResultCode:    strconv.Itoa(resultCode),
+		Amount:        amount,
+		Payload:       params,
+	}
+
+	switch {
+	case resultCode == 0:
+		callback.Status = gatewayStatusPaid
+	case utils.MoMoPendingResultCodes[resultCode]:
+		callback.Status = gatewayStatusPending
+	default:
+		callback.Status = gatewayStatusFailed
+	}
+
+	return s.applyCallback(callback)
+}
+
+// applyCallback applies a verified callback exactly once. Redeliveries and
+// callbacks for payments that are already settled return
+// ErrCallbackAlreadyConfirmed without side effects; callbacks whose amount
+// does not match the order are recorded and rejected. A paid callback for an
+// order that was cancelled or expired meanwhile is not applied; it is flagged
+// for a refund like the reconciliation worker does.
+func (s *paymentService) applyCallback(callback gatewayCallback) error {
+	payload, _ := json.Marshal(callback.Payload)
+	event := &models.PaymentCallbackEvent{
+		Gateway:       callback.Gateway,
+		OrderCode:     callback.OrderCode,
+		TransactionID: callback.TransactionID,
+		ResultCode:    callback.ResultCode,
+		Amount:        callback.Amount,
+		Payload:       string(payload),
+	}
+
+	order, err := s.orderRepo.FindByOrderCode(callback.OrderCode)
 	if err != nil {
-		return errors.New("order not found")
+		return ErrCallbackOrderNotFound
+	}
+	if order.PaymentMethod != callback.Gateway {
+		return ErrCallbackOrderNotFound
 	}
 
-	// Get payment
-	payment, err := s.paymentRepo.FindByOrderID(order.ID)
+	if math.Round(callback.Amount) != math.Round(order.TotalAmount) {
+		event.Outcome = models.CallbackOutcomeRejected
+		event.Detail = fmt.Sprintf("callback amount %.0f does not match order total %.0f", callback.Amount, order.TotalAmount)
+		if err := repositories.NewPaymentCallbackRepository(s.db).Create(event); err != nil {
+			log.Printf("Failed to record rejected %s callback for order %s: %v", callback.Gateway, callback.OrderCode, err)
+		}
+		return ErrCallbackInvalidAmount
+	}
+
+	var result error
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		// Serialise deliveries for the same order on the payment row
+		var payment models.Payment
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("order_id = ?", order.ID).
+			First(&payment).Error; err != nil {
+			return ErrCallbackOrderNotFound
+		}
+		event.PaymentID = &payment.ID
+
+		callbackRepo := repositories.NewPaymentCallbackRepository(tx)
+		delivered, err := callbackRepo.IsDelivered(event.Gateway, event.OrderCode, event.TransactionID, event.ResultCode)
+		if err != nil {
+			return err
+		}
+		if delivered {
+			result = ErrCallbackAlreadyConfirmed
+			return nil
+		}
+
+		// The order may have been cancelled since it was loaded
+		if err := tx.Model(&models.Order{}).Select("status").Where("id = ?", order.ID).Scan(&order.Status).Error; err != nil {
+			return err
+		}
+		paidTooLate := callback.Status == gatewayStatusPaid &&
+			(order.Status == models.OrderStatusCancelled || payment.PaymentStatus == models.PaymentStatusFailed)
+
+		switch {
+		case paidTooLate && math.Round(callback.Amount) == math.Round(payment.Amount):
+			// The customer was charged for an order we no longer fulfil. Keep
+			// the gateway reference for the refund and flag it for finance.
+			if callback.TransactionID != "" && callback.TransactionID != "0" {
+				payment.TransactionID = callback.TransactionID
+			}
+			payment.GatewayResponse = string(payload)
+			if err := repositories.NewPaymentRepository(tx).Update(&payment); err != nil {
+				return err
+			}
+			if err := recordPaidAfterCancel(tx, order, &payment, callback); err != nil {
+				return err
+			}
+			event.Outcome = models.CallbackOutcomeIgnored
+			event.Detail = "customer paid after the order was cancelled; refund required"
+			result = ErrCallbackAlreadyConfirmed
+		case payment.PaymentStatus != models.PaymentStatusPending:
+			event.Outcome = models.CallbackOutcomeIgnored
+			event.Detail = fmt.Sprintf("payment is already %s", payment.PaymentStatus)
+			result = ErrCallbackAlreadyConfirmed
+		case math.Round(callback.Amount) != math.Round(payment.Amount):
+			event.Outcome = models.CallbackOutcomeRejected
+			event.Detail = fmt.Sprintf("callback amount %.0f does not match payment amount %.0f", callback.Amount, payment.Amount)
+			result = ErrCallbackInvalidAmount
+		case callback.Status == gatewayStatusPending:
+			event.Outcome = models.CallbackOutcomePending
+		default:
+			// VNPay sends transaction number 0 for payments that never reached a bank
+			if callback.TransactionID != "" && callback.TransactionID != "0" {
+				payment.TransactionID = callback.TransactionID
+			}
+			payment.GatewayResponse = string(payload)
+
+			paid := callback.Status == gatewayStatusPaid
+			if err := settlePayment(tx, s.reservationService, order, &payment, paid); err != nil {
+				return err
+			}
+			event.Outcome = models.CallbackOutcomeFailed
+			if paid {
+				event.Outcome = models.CallbackOutcomePaid
+			}
+		}
+
+		return callbackRepo.Create(event)
+	})
 	if err != nil {
-		return errors.New("payment not found")
+		return err
+	}
+
+	return result
+}
+
+// recordPaidAfterCancel raises the same paid_after_cancel item the
+// reconciliation worker records, unless one is already open for the payment
+func recordPaidAfterCancel(tx *gorm.DB, order *models.Order, payment *models.Payment, callback gatewayCallback) error {
+	reconciliationRepo := repositories.NewReconciliationRepository(tx)
+	exists, err := reconciliationRepo.HasUnresolvedItem(payment.ID, models.ReconciliationIssuePaidCanceled)
+	if err != nil || exists {
+		return err
 	}
 
-	// Transaction to update payment and order
-	return s.db.Transaction(func(tx *gorm.DB) error {
-		// Update payment
+	payment.Order = *order
+	item := newReconciliationItem(models.ReconciliationSourceCallback, payment, &gatewayResult{
+		Status: gatewayStatusPaid,
+		Code:   callback.ResultCode,
+		Amount: callback.Amount,
+	})
+	item.Issue = models.ReconciliationIssuePaidCanceled
+	item.Detail = "customer paid after the order was cancelled; refund required"
+	return reconciliationRepo.CreateItem(item)
+}
+
+// settlePayment applies the final gateway result to a payment and its order.
+// A paid order moves to processing and its stock hold becomes a deduction;
+// otherwise the hold is released and the unpaid order cancelled. It is shared
+// by the IPN callbacks and the reconciliation worker.
+func settlePayment(tx *gorm.DB, reservationService ReservationService, order *models.Order, payment *models.Payment, paid bool) error {
+	orderRepo := repositories.NewOrderRepository(tx)
+
+	if paid {
 		now := time.Now()
-		payment.TransactionID = fmt.Sprintf("%v", transID)
-		responseJSON, _ := json.Marshal(params)
-		payment.GatewayResponse = string(responseJSON)
+		payment.PaymentStatus = models.PaymentStatusPaid
+		payment.PaidAt = &now
 
-		if resultCode == 0 {
-			// Payment successful
-			payment.PaymentStatus = models.PaymentStatusPaid
-			payment.PaidAt = &now
+		// Update order
+		if err := orderRepo.UpdatePaymentStatus(order.ID, models.PaymentStatusPaid); err != nil {
+			return err
+		}
 
-			// Update order
-			if err := s.orderRepo.UpdatePaymentStatus(order.ID, models.PaymentStatusPaid); err != nil {
+		// Update order status to processing
+		if order.Status == models.OrderStatusPending {
+			if err := orderRepo.UpdateStatus(order.ID, models.OrderStatusProcessing); err != nil {
 				return err
 			}
+		}
 
-			// Update order status to processing
-			if order.Status == models.OrderStatusPending {
-				if err := s.orderRepo.UpdateStatus(order.ID, models.OrderStatusProcessing); err != nil {
-					return err
-				}
+		// Turn the stock hold into a permanent deduction
+		if err := reservationService.CommitOrder(tx, order.ID); err != nil {
+			return err
+		}
+	} else {
+		// Payment failed
+		payment.PaymentStatus = models.PaymentStatusFailed
+
+		// Give the held stock back and cancel the unpaid order
+		released, err := reservationService.ReleaseOrder(tx, order.ID, ReservationReasonPaymentFailed)
+		if err != nil {
+			return err
+		}
+		if released > 0 {
+			if err := cancelUnpaidOrder(tx, order.ID, ReservationReasonPaymentFailed); err != nil {
+				return err
 			}
-		} else {
-			// Payment failed
-			payment.PaymentStatus = models.PaymentStatusFailed
 		}
+	}
 
-		return s.paymentRepo.Update(payment)
-	})
+	return repositories.NewPaymentRepository(tx).Update(payment)
 }
 
 func (s *paymentService) ConfirmCODPayment(orderID uint) error {
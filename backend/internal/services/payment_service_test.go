@@ -0,0 +1,121 @@
+package services
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/huy1235588/fashion-e-commerce/internal/models"
+	"github.com/huy1235588/fashion-e-commerce/internal/repositories"
+	"github.com/huy1235588/fashion-e-commerce/internal/utils"
+)
+
+// stubPaymentRepository keeps the last updated payment in memory
+type stubPaymentRepository struct {
+	repositories.PaymentRepository
+	updated *models.Payment
+}
+
+func (r *stubPaymentRepository) Update(payment *models.Payment) error {
+	copied := *payment
+	r.updated = &copied
+	return nil
+}
+
+// newMoMoPaymentService points a payment service at a fake MoMo API that
+// answers with resultCode
+func newMoMoPaymentService(t *testing.T, resultCode int) (*paymentService, *stubPaymentRepository, *int) {
+	t.Helper()
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		var req utils.MoMoCreatePaymentRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode request: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		response := utils.MoMoCreatePaymentResponse{
+			PartnerCode: req.PartnerCode,
+			RequestID:   req.RequestID,
+			OrderID:     req.OrderID,
+			Amount:      req.Amount,
+			ResultCode:  resultCode,
+		}
+		if resultCode == 0 {
+			response.PayURL = "https://test-payment.momo.vn/pay/" + req.OrderID
+			response.QRCodeURL = "https://test-payment.momo.vn/qr/" + req.OrderID
+		}
+		json.NewEncoder(w).Encode(response)
+	}))
+	t.Cleanup(server.Close)
+
+	repo := &stubPaymentRepository{}
+	service := &paymentService{
+		paymentRepo: repo,
+		momoHelper: utils.NewMoMoHelper(utils.MoMoConfig{
+			PartnerCode: "MOMOTEST",
+			AccessKey:   "access-key",
+			SecretKey:   "secret-key",
+			PaymentURL:  server.URL,
+		}),
+	}
+	return service, repo, &calls
+}
+
+func TestCreateMoMoPayment(t *testing.T) {
+	service, repo, _ := newMoMoPaymentService(t, 0)
+	order := &models.Order{OrderCode: "ORD100", TotalAmount: 350000}
+	payment := &models.Payment{PaymentMethod: models.PaymentMethodMoMo, PaymentStatus: models.PaymentStatusPending}
+
+	response, err := service.createMoMoPayment(order, payment)
+	if err != nil {
+		t.Fatalf("createMoMoPayment: %v", err)
+	}
+	if response.PaymentURL != "https://test-payment.momo.vn/pay/ORD100" || response.QRCodeURL == "" {
+		t.Errorf("unexpected response %+v", response)
+	}
+	if repo.updated == nil || repo.updated.RequestID == "" || repo.updated.InitiatedAt == nil {
+		t.Fatalf("request ID and initiation time should be saved, got %+v", repo.updated)
+	}
+	if !strings.Contains(repo.updated.GatewayResponse, "ORD100") {
+		t.Errorf("gateway response not saved: %q", repo.updated.GatewayResponse)
+	}
+}
+
+func TestCreateMoMoPaymentReusesPendingLink(t *testing.T) {
+	service, _, calls := newMoMoPaymentService(t, 0)
+	order := &models.Order{OrderCode: "ORD101", TotalAmount: 120000}
+	payment := &models.Payment{PaymentMethod: models.PaymentMethodMoMo, PaymentStatus: models.PaymentStatusPending}
+
+	first, err := service.createMoMoPayment(order, payment)
+	if err != nil {
+		t.Fatalf("createMoMoPayment: %v", err)
+	}
+	second, err := service.createMoMoPayment(order, payment)
+	if err != nil {
+		t.Fatalf("createMoMoPayment again: %v", err)
+	}
+	if *calls != 1 {
+		t.Errorf("MoMo called %d times, want 1", *calls)
+	}
+	if first.PaymentURL != second.PaymentURL {
+		t.Errorf("payment URL changed from %q to %q", first.PaymentURL, second.PaymentURL)
+	}
+}
+
+func TestCreateMoMoPaymentResultCode(t *testing.T) {
+	service, repo, _ := newMoMoPaymentService(t, 1005)
+	order := &models.Order{OrderCode: "ORD102", TotalAmount: 80000}
+	payment := &models.Payment{PaymentMethod: models.PaymentMethodMoMo, PaymentStatus: models.PaymentStatusPending}
+
+	_, err := service.createMoMoPayment(order, payment)
+	if err == nil || !strings.Contains(err.Error(), "payment link or QR code has expired") {
+		t.Fatalf("err = %v, want the MoMo result message", err)
+	}
+	if repo.updated == nil || !strings.Contains(repo.updated.GatewayResponse, "1005") {
+		t.Errorf("failed response should still be saved, got %+v", repo.updated)
+	}
+}
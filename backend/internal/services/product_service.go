@@ -1,10 +1,13 @@
 package services
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"log"
 	"mime/multipart"
 	"strings"
+	"time"
 
 	"github.com/huy1235588/fashion-e-commerce/internal/models"
 	"github.com/huy1235588/fashion-e-commerce/internal/repositories"
@@ -12,24 +15,35 @@ import (
 	"gorm.io/gorm"
 )
 
+// ErrSearchQueryRequired is returned for searches without any search text
+var ErrSearchQueryRequired = errors.New("search query is required")
+
 // ProductService handles product business logic
 type ProductService struct {
-	productRepo  repositories.ProductRepository
-	categoryRepo repositories.CategoryRepository
-	uploadService *utils.UploadService
+	productRepo      repositories.ProductRepository
+	categoryRepo     repositories.CategoryRepository
+	inventoryService InventoryService
+	uploadService    *utils.UploadService
+	wishlistService  WishlistService
+	searchService    SearchService
+	db               *gorm.DB
 }
 
 // NewProductService creates a new product service
-func NewProductService(productRepo repositories.ProductRepository, categoryRepo repositories.CategoryRepository, uploadService *utils.UploadService) *ProductService {
+func NewProductService(productRepo repositories.ProductRepository, categoryRepo repositories.CategoryRepository, inventoryService InventoryService, uploadService *utils.UploadService, wishlistService WishlistService, searchService SearchService, db *gorm.DB) *ProductService {
 	return &ProductService{
-		productRepo:  productRepo,
-		categoryRepo: categoryRepo,
-		uploadService: uploadService,
+		productRepo:      productRepo,
+		categoryRepo:     categoryRepo,
+		inventoryService: inventoryService,
+		uploadService:    uploadService,
+		wishlistService:  wishlistService,
+		searchService:    searchService,
+		db:               db,
 	}
 }
 
 // CreateProduct creates a new product with variants and images
-func (s *ProductService) CreateProduct(product *models.Product, variants []models.ProductVariant, images []models.ProductImage) error {
+func (s *ProductService) CreateProduct(product *models.Product, variants []models.ProductVariant, images []models.ProductImage, userID uint) error {
 	// Validate category exists
 	_, err := s.categoryRepo.FindByID(product.CategoryID)
 	if err != nil {
@@ -56,7 +70,7 @@ func (s *ProductService) CreateProduct(product *models.Product, variants []model
 	// Create variants
 	for i := range variants {
 		variants[i].ProductID = product.ID
-		if err := s.productRepo.CreateVariant(&variants[i]); err != nil {
+		if err := s.createVariant(&variants[i], userID); err != nil {
 			return fmt.Errorf("failed to create variant: %w", err)
 		}
 	}
@@ -69,17 +83,35 @@ func (s *ProductService) CreateProduct(product *models.Product, variants []model
 		}
 	}
 
-	return nil
+	return s.productRepo.RefreshSearchVectors(product.ID)
 }
 
 // GetProduct retrieves a product by ID
 func (s *ProductService) GetProduct(id uint) (*models.Product, error) {
-	return s.productRepo.FindByID(id)
+	product, err := s.productRepo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	return s.withBreadcrumbs(product)
 }
 
 // GetProductBySlug retrieves a product by slug
 func (s *ProductService) GetProductBySlug(slug string) (*models.Product, error) {
-	return s.productRepo.FindBySlug(slug)
+	product, err := s.productRepo.FindBySlug(slug)
+	if err != nil {
+		return nil, err
+	}
+	return s.withBreadcrumbs(product)
+}
+
+// withBreadcrumbs loads the category path of a product for its detail page
+func (s *ProductService) withBreadcrumbs(product *models.Product) (*models.Product, error) {
+	breadcrumbs, err := s.categoryRepo.Ancestors(product.CategoryID)
+	if err != nil {
+		return nil, err
+	}
+	product.Breadcrumbs = breadcrumbs
+	return product, nil
 }
 
 // UpdateProduct updates a product
@@ -120,7 +152,10 @@ func (s *ProductService) UpdateProduct(id uint, updates *models.Product) error {
 	product.Slug = updates.Slug
 	product.IsActive = updates.IsActive
 
-	return s.productRepo.Update(product)
+	if err := s.productRepo.Update(product); err != nil {
+		return err
+	}
+	return s.productRepo.RefreshSearchVectors(product.ID)
 }
 
 // DeleteProduct deletes a product
@@ -131,9 +166,83 @@ func (s *ProductService) DeleteProduct(id uint) error {
 	return s.productRepo.Delete(id)
 }
 
-// ListProducts retrieves products with filters
-func (s *ProductService) ListProducts(filters repositories.ProductFilters) ([]models.Product, int64, error) {
-	return s.productRepo.List(filters)
+// ListProducts retrieves a page of products with filters. The first page
+// of a search is logged.
+func (s *ProductService) ListProducts(filters repositories.ProductFilters) ([]repositories.ProductListItem, int64, error) {
+	items, total, err := s.productRepo.List(filters)
+	if err == nil && filters.Page <= 1 {
+		s.logSearch(filters.SearchQuery, total)
+	}
+	return items, total, err
+}
+
+// ListProductsByCursor retrieves the products after cursor for infinite
+// scroll and returns the cursor of the next page, empty on the last one.
+// The first page of a search is logged.
+func (s *ProductService) ListProductsByCursor(filters repositories.ProductFilters, cursor string) ([]repositories.ProductListItem, string, error) {
+	items, nextCursor, err := s.productRepo.ListByCursor(filters, cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if cursor == "" && strings.TrimSpace(filters.SearchQuery) != "" {
+		total := int64(len(items))
+		if nextCursor != "" {
+			if total, err = s.productRepo.Count(filters); err != nil {
+				return nil, "", err
+			}
+		}
+		s.logSearch(filters.SearchQuery, total)
+	}
+
+	return items, nextCursor, nil
+}
+
+// StartSalesStatsRefresher periodically recomputes the sold counts and
+// ratings the best-selling and top-rated sorts use until ctx is cancelled
+func (s *ProductService) StartSalesStatsRefresher(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.productRepo.RefreshSalesStats(); err != nil {
+				log.Printf("Product sales stats refresh failed: %v", err)
+			}
+		}
+	}
+}
+
+// GetProductFacets counts the products matching filters per size, color,
+// category and price range for the storefront filter sidebar
+func (s *ProductService) GetProductFacets(filters repositories.ProductFilters) (*repositories.ProductFacets, error) {
+	return s.productRepo.Facets(filters)
+}
+
+// SearchProducts runs a full-text search for filters.SearchQuery and returns
+// the matching products, most relevant first, with highlighted snippets
+func (s *ProductService) SearchProducts(filters repositories.ProductFilters) ([]repositories.ProductSearchHit, int64, error) {
+	if strings.TrimSpace(filters.SearchQuery) == "" {
+		return nil, 0, ErrSearchQueryRequired
+	}
+
+	hits, total, err := s.productRepo.Search(filters)
+	if err == nil && filters.Page <= 1 {
+		s.logSearch(filters.SearchQuery, total)
+	}
+	return hits, total, err
+}
+
+// logSearch records a search shoppers ran, in the background so that it
+// does not slow the response down
+func (s *ProductService) logSearch(query string, total int64) {
+	if s.searchService == nil || strings.TrimSpace(query) == "" {
+		return
+	}
+	go s.searchService.LogSearch(query, total)
 }
 
 // AddProductImage adds an image to a product
@@ -195,7 +304,7 @@ func (s *ProductService) DeleteProductImage(imageID uint) error {
 }
 
 // AddProductVariant adds a variant to a product
-func (s *ProductService) AddProductVariant(variant *models.ProductVariant) error {
+func (s *ProductService) AddProductVariant(variant *models.ProductVariant, userID uint) error {
 	// Verify product exists
 	_, err := s.productRepo.FindByID(variant.ProductID)
 	if err != nil {
@@ -211,11 +320,26 @@ func (s *ProductService) AddProductVariant(variant *models.ProductVariant) error
 		return errors.New("variant with this SKU already exists")
 	}
 
-	return s.productRepo.CreateVariant(variant)
+	if err := s.createVariant(variant, userID); err != nil {
+		return err
+	}
+	return s.productRepo.RefreshSearchVectors(variant.ProductID)
+}
+
+// createVariant creates a variant and records its opening stock in the ledger
+func (s *ProductService) createVariant(variant *models.ProductVariant, userID uint) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := repositories.NewProductRepository(tx).CreateVariant(variant); err != nil {
+			return err
+		}
+		return s.inventoryService.RecordInitialStock(tx, variant, &userID)
+	})
 }
 
-// UpdateProductVariant updates a product variant
-func (s *ProductService) UpdateProductVariant(id uint, updates *models.ProductVariant) error {
+// UpdateProductVariant updates a product variant.
+// A changed stock quantity is recorded in the ledger as a manual adjustment by userID.
+// Bringing a sold-out variant back in stock emails the users waiting for it.
+func (s *ProductService) UpdateProductVariant(id uint, updates *models.ProductVariant, userID uint) error {
 	variants, err := s.productRepo.GetProductVariants(updates.ProductID)
 	if err != nil {
 		return err
@@ -232,6 +356,9 @@ func (s *ProductService) UpdateProductVariant(id uint, updates *models.ProductVa
 	if variant == nil {
 		return errors.New("variant not found")
 	}
+	if updates.Weight < 0 {
+		return errors.New("weight must not be negative")
+	}
 
 	// Check SKU uniqueness if changed
 	if updates.SKU != variant.SKU {
@@ -244,17 +371,47 @@ func (s *ProductService) UpdateProductVariant(id uint, updates *models.ProductVa
 		}
 	}
 
-	variant.Size = updates.Size
-	variant.Color = updates.Color
-	variant.StockQuantity = updates.StockQuantity
-	variant.SKU = updates.SKU
+	restocked := variant.StockQuantity <= 0 && updates.StockQuantity > 0
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(variant).Updates(map[string]interface{}{
+			"size":   updates.Size,
+			"color":  updates.Color,
+			"sku":    updates.SKU,
+			"weight": updates.Weight,
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		if err := s.inventoryService.SetStock(tx, id, updates.StockQuantity, &userID, "Variant updated"); err != nil {
+			return err
+		}
+
+		// Colors and SKUs are searchable
+		return repositories.NewProductRepository(tx).RefreshSearchVectors(variant.ProductID)
+	})
+	if err != nil {
+		return err
+	}
 
-	return s.productRepo.UpdateVariant(variant)
+	if restocked {
+		go s.wishlistService.NotifyRestocked(id)
+	}
+	return nil
 }
 
 // DeleteProductVariant deletes a product variant
 func (s *ProductService) DeleteProductVariant(id uint) error {
-	return s.productRepo.DeleteVariant(id)
+	variant, err := s.productRepo.FindVariantByID(id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.productRepo.DeleteVariant(id); err != nil {
+		return err
+	}
+	return s.productRepo.RefreshSearchVectors(variant.ProductID)
 }
 
 // isExternalURL detects if the image path points to an external URL
@@ -0,0 +1,361 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/huy1235588/fashion-e-commerce/internal/models"
+	"github.com/huy1235588/fashion-e-commerce/internal/repositories"
+	"gorm.io/gorm"
+)
+
+// ErrPromotionCodeExists is returned when another promotion already uses a code
+var ErrPromotionCodeExists = errors.New("promotion with this code already exists")
+
+// PromotionRequest represents the admin payload for creating or updating a promotion
+type PromotionRequest struct {
+	Code              string               `json:"code" binding:"required,max=50"`
+	Name              string               `json:"name" binding:"required"`
+	Description       string               `json:"description"`
+	Type              models.PromotionType `json:"type" binding:"required,oneof=percentage fixed_amount free_shipping"`
+	Value             float64              `json:"value" binding:"min=0"`
+	MaxDiscountAmount *float64             `json:"max_discount_amount" binding:"omitempty,gt=0"`
+	MinOrderAmount    float64              `json:"min_order_amount" binding:"min=0"`
+	UsageLimit        *int                 `json:"usage_limit" binding:"omitempty,min=1"`
+	UsageLimitPerUser *int                 `json:"usage_limit_per_user" binding:"omitempty,min=1"`
+	StartsAt          *time.Time           `json:"starts_at"`
+	EndsAt            *time.Time           `json:"ends_at"`
+	IsActive          *bool                `json:"is_active"`
+	CategoryIDs       []uint               `json:"category_ids"`
+	ProductIDs        []uint               `json:"product_ids"`
+}
+
+// PromotionLine is a priced order line checked against a promotion's scope
+type PromotionLine struct {
+	ProductID  uint
+	CategoryID uint
+	Subtotal   float64
+}
+
+// PromotionQuote is the discount a promotion gives a particular order
+type PromotionQuote struct {
+	Promotion        *models.Promotion
+	EligibleSubtotal float64
+	DiscountAmount   float64
+}
+
+// PromotionService validates coupon codes at checkout and manages promotions.
+// Methods that take a *gorm.DB run inside the caller's transaction.
+type PromotionService interface {
+	Quote(tx *gorm.DB, userID uint, code string, lines []PromotionLine, shippingFee float64) (*PromotionQuote, error)
+	Redeem(tx *gorm.DB, quote *PromotionQuote, userID uint, orderID uint) error
+	CreatePromotion(req PromotionRequest) (*models.Promotion, error)
+	UpdatePromotion(id uint, req PromotionRequest) (*models.Promotion, error)
+	DeletePromotion(id uint) error
+	GetPromotion(id uint) (*models.Promotion, error)
+	ListPromotions(filters map[string]interface{}, limit, offset int) ([]models.Promotion, int64, error)
+}
+
+type promotionService struct {
+	promotionRepo repositories.PromotionRepository
+	db            *gorm.DB
+}
+
+// NewPromotionService creates a new promotion service
+func NewPromotionService(promotionRepo repositories.PromotionRepository, db *gorm.DB) PromotionService {
+	return &promotionService{
+		promotionRepo: promotionRepo,
+		db:            db,
+	}
+}
+
+// Quote validates a coupon code for the given order lines and computes its discount.
+// The promotion row stays locked until the caller's transaction ends, so usage
+// limits cannot be exceeded by concurrent checkouts.
+func (s *promotionService) Quote(tx *gorm.DB, userID uint, code string, lines []PromotionLine, shippingFee float64) (*PromotionQuote, error) {
+	repo := repositories.NewPromotionRepository(tx)
+
+	promotion, err := repo.FindByCodeForUpdate(strings.TrimSpace(code))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("invalid promotion code")
+		}
+		return nil, err
+	}
+
+	now := time.Now()
+	if !promotion.IsActive {
+		return nil, errors.New("promotion is not active")
+	}
+	if promotion.StartsAt != nil && now.Before(*promotion.StartsAt) {
+		return nil, errors.New("promotion has not started yet")
+	}
+	if promotion.EndsAt != nil && now.After(*promotion.EndsAt) {
+		return nil, errors.New("promotion has expired")
+	}
+	if promotion.UsageLimit != nil && promotion.UsedCount >= *promotion.UsageLimit {
+		return nil, errors.New("promotion usage limit reached")
+	}
+	if promotion.UsageLimitPerUser != nil {
+		used, err := repo.CountUsagesByUser(promotion.ID, userID)
+		if err != nil {
+			return nil, err
+		}
+		if used >= int64(*promotion.UsageLimitPerUser) {
+			return nil, errors.New("you have reached the usage limit for this promotion")
+		}
+	}
+
+	categoryIDs, err := promotionCategoryIDs(tx, promotion)
+	if err != nil {
+		return nil, err
+	}
+	eligible := eligibleSubtotal(promotion, categoryIDs, lines)
+	if eligible == 0 {
+		return nil, errors.New("promotion does not apply to any item in your cart")
+	}
+	if eligible < promotion.MinOrderAmount {
+		return nil, fmt.Errorf("order must be at least %.0f to use this promotion", promotion.MinOrderAmount)
+	}
+
+	var discount float64
+	switch promotion.Type {
+	case models.PromotionTypePercentage:
+		discount = eligible * promotion.Value / 100
+	case models.PromotionTypeFixedAmount:
+		discount = math.Min(promotion.Value, eligible)
+	case models.PromotionTypeFreeShipping:
+		discount = shippingFee
+	}
+	if promotion.MaxDiscountAmount != nil {
+		discount = math.Min(discount, *promotion.MaxDiscountAmount)
+	}
+
+	return &PromotionQuote{
+		Promotion:        promotion,
+		EligibleSubtotal: eligible,
+		DiscountAmount:   math.Round(discount),
+	}, nil
+}
+
+// Redeem records the promotion as used by an order
+func (s *promotionService) Redeem(tx *gorm.DB, quote *PromotionQuote, userID uint, orderID uint) error {
+	repo := repositories.NewPromotionRepository(tx)
+
+	if err := repo.CreateUsage(&models.PromotionUsage{
+		PromotionID:    quote.Promotion.ID,
+		UserID:         userID,
+		OrderID:        orderID,
+		DiscountAmount: quote.DiscountAmount,
+	}); err != nil {
+		return err
+	}
+
+	return repo.IncrementUsedCount(quote.Promotion.ID, 1)
+}
+
+// CreatePromotion creates a new promotion
+func (s *promotionService) CreatePromotion(req PromotionRequest) (*models.Promotion, error) {
+	if err := validatePromotionRequest(&req); err != nil {
+		return nil, err
+	}
+
+	existing, err := s.promotionRepo.FindByCode(req.Code)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, ErrPromotionCodeExists
+	}
+
+	promotion := &models.Promotion{IsActive: true}
+	applyPromotionRequest(promotion, req)
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		repo := repositories.NewPromotionRepository(tx)
+		if err := repo.Create(promotion); err != nil {
+			return err
+		}
+		return repo.ReplaceScope(promotion, req.CategoryIDs, req.ProductIDs)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.promotionRepo.FindByID(promotion.ID)
+}
+
+// UpdatePromotion updates a promotion and replaces its scope
+func (s *promotionService) UpdatePromotion(id uint, req PromotionRequest) (*models.Promotion, error) {
+	if err := validatePromotionRequest(&req); err != nil {
+		return nil, err
+	}
+
+	promotion, err := s.promotionRepo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check if code is being changed and if it conflicts
+	if !strings.EqualFold(req.Code, promotion.Code) {
+		existing, err := s.promotionRepo.FindByCode(req.Code)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		if existing != nil && existing.ID != id {
+			return nil, ErrPromotionCodeExists
+		}
+	}
+
+	applyPromotionRequest(promotion, req)
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		repo := repositories.NewPromotionRepository(tx)
+		if err := repo.Update(promotion); err != nil {
+			return err
+		}
+		return repo.ReplaceScope(promotion, req.CategoryIDs, req.ProductIDs)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.promotionRepo.FindByID(id)
+}
+
+// DeletePromotion deletes a promotion. Orders keep their recorded discount.
+func (s *promotionService) DeletePromotion(id uint) error {
+	if _, err := s.promotionRepo.FindByID(id); err != nil {
+		return err
+	}
+	return s.promotionRepo.Delete(id)
+}
+
+// GetPromotion retrieves a promotion by ID
+func (s *promotionService) GetPromotion(id uint) (*models.Promotion, error) {
+	return s.promotionRepo.FindByID(id)
+}
+
+// ListPromotions lists promotions with filters and pagination
+func (s *promotionService) ListPromotions(filters map[string]interface{}, limit, offset int) ([]models.Promotion, int64, error) {
+	return s.promotionRepo.List(filters, limit, offset)
+}
+
+// releasePromotionUsage gives a cancelled order's promotion use back
+func releasePromotionUsage(tx *gorm.DB, orderID uint) error {
+	repo := repositories.NewPromotionRepository(tx)
+
+	usage, err := repo.FindUsageByOrderID(orderID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	if err := repo.DeleteUsage(usage.ID); err != nil {
+		return err
+	}
+	return repo.IncrementUsedCount(usage.PromotionID, -1)
+}
+
+// promotionCategoryIDs returns the categories a promotion covers: the ones it
+// is scoped to and every category below them
+func promotionCategoryIDs(tx *gorm.DB, promotion *models.Promotion) (map[uint]bool, error) {
+	categoryRepo := repositories.NewCategoryRepository(tx)
+	categoryIDs := make(map[uint]bool, len(promotion.Categories))
+	for _, category := range promotion.Categories {
+		subtree, err := categoryRepo.SubtreeIDs(category.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range subtree {
+			categoryIDs[id] = true
+		}
+	}
+	return categoryIDs, nil
+}
+
+// eligibleSubtotal sums the lines covered by the promotion's category/product scope.
+// A promotion without scope covers every line.
+func eligibleSubtotal(promotion *models.Promotion, categoryIDs map[uint]bool, lines []PromotionLine) float64 {
+	if len(promotion.Categories) == 0 && len(promotion.Products) == 0 {
+		var total float64
+		for _, line := range lines {
+			total += line.Subtotal
+		}
+		return total
+	}
+
+	productIDs := make(map[uint]bool, len(promotion.Products))
+	for _, product := range promotion.Products {
+		productIDs[product.ID] = true
+	}
+
+	var total float64
+	for _, line := range lines {
+		if categoryIDs[line.CategoryID] || productIDs[line.ProductID] {
+			total += line.Subtotal
+		}
+	}
+	return total
+}
+
+func validatePromotionRequest(req *PromotionRequest) error {
+	req.Code = strings.ToUpper(strings.TrimSpace(req.Code))
+	if req.Code == "" {
+		return errors.New("code is required")
+	}
+
+	switch req.Type {
+	case models.PromotionTypePercentage:
+		if req.Value <= 0 || req.Value > 100 {
+			return errors.New("percentage value must be between 0 and 100")
+		}
+	case models.PromotionTypeFixedAmount:
+		if req.Value <= 0 {
+			return errors.New("fixed amount value must be greater than 0")
+		}
+	}
+
+	if req.StartsAt != nil && req.EndsAt != nil && !req.EndsAt.After(*req.StartsAt) {
+		return errors.New("ends_at must be after starts_at")
+	}
+
+	req.CategoryIDs = uniqueIDs(req.CategoryIDs)
+	req.ProductIDs = uniqueIDs(req.ProductIDs)
+
+	return nil
+}
+
+func uniqueIDs(ids []uint) []uint {
+	seen := make(map[uint]bool, len(ids))
+	result := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			result = append(result, id)
+		}
+	}
+	return result
+}
+
+func applyPromotionRequest(promotion *models.Promotion, req PromotionRequest) {
+	promotion.Code = req.Code
+	promotion.Name = req.Name
+	promotion.Description = req.Description
+	promotion.Type = req.Type
+	promotion.Value = req.Value
+	promotion.MaxDiscountAmount = req.MaxDiscountAmount
+	promotion.MinOrderAmount = req.MinOrderAmount
+	promotion.UsageLimit = req.UsageLimit
+	promotion.UsageLimitPerUser = req.UsageLimitPerUser
+	promotion.StartsAt = req.StartsAt
+	promotion.EndsAt = req.EndsAt
+	if req.IsActive != nil {
+		promotion.IsActive = *req.IsActive
+	}
+}
@@ -0,0 +1,450 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/huy1235588/fashion-e-commerce/internal/models"
+	"github.com/huy1235588/fashion-e-commerce/internal/repositories"
+	"github.com/huy1235588/fashion-e-commerce/internal/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// gatewayPaymentStatus is a payment result as reported by a gateway query API
+type gatewayPaymentStatus string
+
+const (
+	gatewayStatusPaid     gatewayPaymentStatus = "paid"
+	gatewayStatusFailed   gatewayPaymentStatus = "failed"
+	gatewayStatusPending  gatewayPaymentStatus = "pending"
+	gatewayStatusNotFound gatewayPaymentStatus = "not_found"
+)
+
+// gatewayResult is the normalised answer of a VNPay querydr or MoMo query call
+type gatewayResult struct {
+	Status        gatewayPaymentStatus
+	Code          string // gateway specific status code, kept for the report
+	TransactionID string
+	Amount        float64
+	Response      interface{}
+}
+
+// ReconciliationService compares online payments with the gateways. It
+// settles payments whose IPN never arrived and builds a daily report of
+// every difference it finds.
+type ReconciliationService interface {
+	ReconcilePending() (int, error)
+	BuildDailyReport(day time.Time) (*models.ReconciliationReport, error)
+	GetReport(day time.Time) (*models.ReconciliationReport, error)
+	ListReports(limit, offset int) ([]models.ReconciliationReport, int64, error)
+	StartWorker(ctx context.Context, interval time.Duration, reportHour int)
+}
+
+type reconciliationService struct {
+	reconciliationRepo repositories.ReconciliationRepository
+	paymentRepo        repositories.PaymentRepository
+	reservationService ReservationService
+	refundService      RefundService
+	vnpayHelper        *utils.VNPayHelper
+	momoHelper         *utils.MoMoHelper
+	db                 *gorm.DB
+	pendingAfter       time.Duration
+}
+
+// NewReconciliationService creates a new reconciliation service. Payments
+// are only queried once they have been pending for longer than pendingAfter.
+func NewReconciliationService(
+	reconciliationRepo repositories.ReconciliationRepository,
+	paymentRepo repositories.PaymentRepository,
+	reservationService ReservationService,
+	refundService RefundService,
+	vnpayHelper *utils.VNPayHelper,
+	momoHelper *utils.MoMoHelper,
+	db *gorm.DB,
+	pendingAfter time.Duration,
+) ReconciliationService {
+	return &reconciliationService{
+		reconciliationRepo: reconciliationRepo,
+		paymentRepo:        paymentRepo,
+		reservationService: reservationService,
+		refundService:      refundService,
+		vnpayHelper:        vnpayHelper,
+		momoHelper:         momoHelper,
+		db:                 db,
+		pendingAfter:       pendingAfter,
+	}
+}
+
+// ReconcilePending asks the gateway about online payments that have been
+// pending for too long and applies the final result, as the IPN would have.
+// It returns the number of payments settled.
+func (s *reconciliationService) ReconcilePending() (int, error) {
+	payments, err := s.paymentRepo.FindStalePending(time.Now().Add(-s.pendingAfter), 100)
+	if err != nil {
+		return 0, err
+	}
+
+	settled := 0
+	for i := range payments {
+		payment := &payments[i]
+
+		result, err := s.queryGateway(payment)
+		if err != nil {
+			log.Printf("Reconciliation: failed to query %s payment for order %s: %v", payment.PaymentMethod, payment.Order.OrderCode, err)
+			continue
+		}
+
+		ok, err := s.reconcilePendingPayment(payment, result)
+		if err != nil {
+			log.Printf("Reconciliation: failed to settle payment for order %s: %v", payment.Order.OrderCode, err)
+			continue
+		}
+		if ok {
+			settled++
+		}
+	}
+
+	return settled, nil
+}
+
+// reconcilePendingPayment settles one pending payment from a gateway result.
+// Results finance has to look at (wrong amount, paid after the order was
+// cancelled) are recorded but not applied.
+func (s *reconciliationService) reconcilePendingPayment(payment *models.Payment, result *gatewayResult) (bool, error) {
+	order := &payment.Order
+	item := newReconciliationItem(models.ReconciliationSourcePending, payment, result)
+
+	switch result.Status {
+	case gatewayStatusPaid:
+		if result.Amount != payment.Amount {
+			item.Issue = models.ReconciliationIssueAmount
+			item.Detail = "gateway amount differs from the payment; not applied"
+			return false, s.recordOnce(item)
+		}
+		if order.Status == models.OrderStatusCancelled {
+			item.Issue = models.ReconciliationIssuePaidCanceled
+			item.Detail = "customer paid after the order was cancelled; refund required"
+			return false, s.recordOnce(item)
+		}
+	case gatewayStatusFailed:
+	case gatewayStatusNotFound:
+		// The customer never reached the gateway. Only close the payment once
+		// the order itself is gone, e.g. after the stock hold expired.
+		if order.Status != models.OrderStatusCancelled {
+			return false, nil
+		}
+	default:
+		return false, nil
+	}
+
+	paid := result.Status == gatewayStatusPaid
+	applied := false
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// The IPN may have arrived while we were asking the gateway
+		var locked models.Payment
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&locked, payment.ID).Error; err != nil {
+			return err
+		}
+		if locked.PaymentStatus != models.PaymentStatusPending {
+			return nil
+		}
+
+		if result.TransactionID != "" {
+			locked.TransactionID = result.TransactionID
+		}
+		responseJSON, _ := json.Marshal(result.Response)
+		locked.GatewayResponse = string(responseJSON)
+
+		if err := settlePayment(tx, s.reservationService, order, &locked, paid); err != nil {
+			return err
+		}
+
+		item.Issue = models.ReconciliationIssueStatus
+		item.Resolved = true
+		item.Detail = fmt.Sprintf("IPN not received; payment marked %s from the gateway query", locked.PaymentStatus)
+		applied = true
+		return repositories.NewReconciliationRepository(tx).CreateItem(item)
+	})
+
+	return applied, err
+}
+
+// recordOnce records an unresolved item unless the same problem is already open
+func (s *reconciliationService) recordOnce(item *models.ReconciliationItem) error {
+	exists, err := s.reconciliationRepo.HasUnresolvedItem(item.PaymentID, item.Issue)
+	if err != nil || exists {
+		return err
+	}
+	return s.reconciliationRepo.CreateItem(item)
+}
+
+// BuildDailyReport compares every online payment created on day with the
+// gateway and stores the mismatches, together with the payments settled by
+// the pending check since the last report. Building a report again replaces
+// its daily findings.
+func (s *reconciliationService) BuildDailyReport(day time.Time) (*models.ReconciliationReport, error) {
+	from := startOfDay(day)
+	to := from.AddDate(0, 0, 1)
+
+	payments, err := s.paymentRepo.FindOnlineCreatedBetween(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	report, err := s.reconciliationRepo.FindReportByDate(from)
+	if err != nil {
+		return nil, err
+	}
+	if report == nil {
+		report = &models.ReconciliationReport{ReportDate: from}
+	}
+	report.CheckedCount = len(payments)
+	if err := s.reconciliationRepo.SaveReport(report); err != nil {
+		return nil, err
+	}
+	if err := s.reconciliationRepo.DeleteReportItems(report.ID, models.ReconciliationSourceDaily); err != nil {
+		return nil, err
+	}
+
+	for i := range payments {
+		item := s.comparePayment(&payments[i])
+		if item == nil {
+			continue
+		}
+		item.ReportID = &report.ID
+		if err := s.reconciliationRepo.CreateItem(item); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.reconciliationRepo.AttachUnreportedItems(report.ID, to); err != nil {
+		return nil, err
+	}
+
+	count, err := s.reconciliationRepo.CountReportItems(report.ID)
+	if err != nil {
+		return nil, err
+	}
+	report.MismatchCount = int(count)
+	if err := s.reconciliationRepo.SaveReport(report); err != nil {
+		return nil, err
+	}
+
+	return s.reconciliationRepo.FindReportByDate(from)
+}
+
+// comparePayment returns a daily item if our payment disagrees with the gateway
+func (s *reconciliationService) comparePayment(payment *models.Payment) *models.ReconciliationItem {
+	result, err := s.queryGateway(payment)
+	if err != nil {
+		item := newReconciliationItem(models.ReconciliationSourceDaily, payment, &gatewayResult{})
+		item.Issue = models.ReconciliationIssueGatewayError
+		item.Detail = err.Error()
+		return item
+	}
+
+	item := newReconciliationItem(models.ReconciliationSourceDaily, payment, result)
+
+	localPaid := payment.PaymentStatus == models.PaymentStatusPaid ||
+		payment.PaymentStatus == models.PaymentStatusPartiallyRefunded ||
+		payment.PaymentStatus == models.PaymentStatusRefunded
+	gatewayPaid := result.Status == gatewayStatusPaid
+
+	switch {
+	case localPaid != gatewayPaid:
+		item.Issue = models.ReconciliationIssueStatus
+		item.Detail = fmt.Sprintf("payment is %s here but %s at the gateway", payment.PaymentStatus, result.Status)
+	case gatewayPaid && result.Amount != payment.Amount:
+		item.Issue = models.ReconciliationIssueAmount
+		item.Detail = fmt.Sprintf("payment amount %.0f, gateway amount %.0f", payment.Amount, result.Amount)
+	default:
+		return nil
+	}
+
+	return item
+}
+
+// GetReport returns the report for a day, or nil if it has not been built
+func (s *reconciliationService) GetReport(day time.Time) (*models.ReconciliationReport, error) {
+	return s.reconciliationRepo.FindReportByDate(startOfDay(day))
+}
+
+// ListReports lists reports, newest first
+func (s *reconciliationService) ListReports(limit, offset int) ([]models.ReconciliationReport, int64, error) {
+	return s.reconciliationRepo.ListReports(limit, offset)
+}
+
+// StartWorker reconciles stale pending payments every interval and, once
+// per day after reportHour, builds the report for the previous day. It runs
+// until ctx is cancelled.
+func (s *reconciliationService) StartWorker(ctx context.Context, interval time.Duration, reportHour int) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var lastReport time.Time
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			count, err := s.ReconcilePending()
+			if err != nil {
+				log.Printf("Payment reconciliation failed: %v", err)
+			} else if count > 0 {
+				log.Printf("Payment reconciliation settled %d payment(s)", count)
+			}
+
+			refunds, err := s.refundService.ReconcilePending(time.Now().Add(-s.pendingAfter))
+			if err != nil {
+				log.Printf("Refund reconciliation failed: %v", err)
+			} else if refunds > 0 {
+				log.Printf("Refund reconciliation settled %d refund(s)", refunds)
+			}
+
+			now := time.Now()
+			yesterday := startOfDay(now).AddDate(0, 0, -1)
+			if now.Hour() < reportHour || lastReport.Equal(yesterday) {
+				continue
+			}
+
+			existing, err := s.GetReport(yesterday)
+			if err != nil {
+				log.Printf("Failed to load reconciliation report: %v", err)
+				continue
+			}
+			if existing == nil {
+				report, err := s.BuildDailyReport(yesterday)
+				if err != nil {
+					log.Printf("Failed to build reconciliation report for %s: %v", yesterday.Format("2006-01-02"), err)
+					continue
+				}
+				log.Printf("Reconciliation report for %s: %d payment(s) checked, %d mismatch(es)",
+					yesterday.Format("2006-01-02"), report.CheckedCount, report.MismatchCount)
+			}
+			lastReport = yesterday
+		}
+	}
+}
+
+// queryGateway asks the payment's gateway for its current result
+func (s *reconciliationService) queryGateway(payment *models.Payment) (*gatewayResult, error) {
+	switch payment.PaymentMethod {
+	case models.PaymentMethodVNPay:
+		return s.queryVNPay(payment)
+	case models.PaymentMethodMoMo:
+		return s.queryMoMo(payment)
+	default:
+		return nil, fmt.Errorf("payment method %s has no gateway", payment.PaymentMethod)
+	}
+}
+
+func (s *reconciliationService) queryVNPay(payment *models.Payment) (*gatewayResult, error) {
+	createdAt := payment.CreatedAt
+	if payment.InitiatedAt != nil {
+		createdAt = *payment.InitiatedAt
+	}
+
+	response, err := s.vnpayHelper.QueryTransaction(utils.VNPayQueryRequest{
+		RequestID:       newGatewayRequestID(),
+		OrderCode:       payment.Order.OrderCode,
+		TransactionDate: utils.VNPayTime(createdAt),
+		OrderInfo:       fmt.Sprintf("Kiểm tra giao dịch %s", payment.Order.OrderCode),
+		IPAddr:          "127.0.0.1",
+	})
+	if err != nil {
+		var vnpayErr *utils.VNPayError
+		if errors.As(err, &vnpayErr) && vnpayErr.ResponseCode == "91" {
+			return &gatewayResult{Status: gatewayStatusNotFound, Code: vnpayErr.ResponseCode, Response: response}, nil
+		}
+		return nil, err
+	}
+
+	amount, _ := strconv.ParseFloat(response.Amount, 64)
+	result := &gatewayResult{
+		Code:          response.TransactionStatus,
+		TransactionID: response.TransactionNo,
+		Amount:        amount / 100,
+		Response:      response,
+	}
+
+	switch response.TransactionStatus {
+	case "00", "05", "06", "09":
+		// Captured; 05, 06 and 09 are refunds of a captured payment
+		result.Status = gatewayStatusPaid
+	case "01", "07":
+		// Not finished yet, or held by VNPay for a fraud check
+		result.Status = gatewayStatusPending
+	default:
+		result.Status = gatewayStatusFailed
+	}
+
+	return result, nil
+}
+
+func (s *reconciliationService) queryMoMo(payment *models.Payment) (*gatewayResult, error) {
+	response, err := s.momoHelper.QueryTransaction(payment.Order.OrderCode, uuid.New().String())
+	if err != nil {
+		return nil, err
+	}
+
+	result := &gatewayResult{
+		Code:     strconv.Itoa(response.ResultCode),
+		Amount:   float64(response.Amount),
+		Response: response,
+	}
+	if response.TransID != 0 {
+		result.TransactionID = strconv.FormatInt(response.TransID, 10)
+	}
+
+	switch {
+	case response.ResultCode == 0:
+		result.Status = gatewayStatusPaid
+	case utils.MoMoPendingResultCodes[response.ResultCode]:
+		result.Status = gatewayStatusPending
+	case response.ResultCode == 42:
+		result.Status = gatewayStatusNotFound
+	default:
+		result.Status = gatewayStatusFailed
+	}
+
+	return result, nil
+}
+
+func newReconciliationItem(source models.ReconciliationSource, payment *models.Payment, result *gatewayResult) *models.ReconciliationItem {
+	gatewayStatus := string(result.Status)
+	if result.Code != "" {
+		gatewayStatus = fmt.Sprintf("%s (%s)", result.Status, result.Code)
+	}
+
+	return &models.ReconciliationItem{
+		Source:        source,
+		PaymentID:     payment.ID,
+		OrderID:       payment.OrderID,
+		OrderCode:     payment.Order.OrderCode,
+		PaymentMethod: payment.PaymentMethod,
+		LocalStatus:   payment.PaymentStatus,
+		GatewayStatus: gatewayStatus,
+		LocalAmount:   payment.Amount,
+		GatewayAmount: result.Amount,
+	}
+}
+
+// newGatewayRequestID returns a unique request ID that fits VNPay's 32 character limit
+func newGatewayRequestID() string {
+	return strings.ReplaceAll(uuid.New().String(), "-", "")
+}
+
+// startOfDay returns midnight (server time) of t's day
+func startOfDay(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.Local)
+}
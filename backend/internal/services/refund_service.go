@@ -0,0 +1,461 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/huy1235588/fashion-e-commerce/internal/models"
+	"github.com/huy1235588/fashion-e-commerce/internal/repositories"
+	"github.com/huy1235588/fashion-e-commerce/internal/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RefundRequest represents a request to refund a paid order
+type RefundRequest struct {
+	// Amount to refund; nil refunds everything not yet refunded
+	Amount *float64 `json:"amount" binding:"omitempty,gt=0"`
+	Reason string   `json:"reason" binding:"required"`
+}
+
+// SettleRefundRequest records how a COD refund was paid back to the customer
+type SettleRefundRequest struct {
+	Reference string `json:"reference" binding:"required"`
+	Note      string `json:"note"`
+}
+
+// ErrRefundPending is returned when a gateway refund was sent but its outcome
+// is unknown; the refund stays pending until reconciliation settles it
+var ErrRefundPending = errors.New("refund outcome is unknown and will be confirmed with the gateway")
+
+// errRefundNotSent marks refunds rejected before reaching the gateway
+var errRefundNotSent = errors.New("refund not sent")
+
+// RefundService refunds paid orders through the original payment method.
+// Gateway refunds usually complete immediately; COD refunds stay pending
+// until an admin records the manual settlement.
+type RefundService interface {
+	RequestRefund(orderID uint, requestedBy *uint, req RefundRequest, ipAddr string) (*models.Refund, error)
+	ReconcilePending(before time.Time) (int, error)
+	SettleRefund(refundID uint, adminID uint, req SettleRefundRequest) (*models.Refund, error)
+	GetOrderRefunds(orderID uint) ([]models.Refund, error)
+	ListRefunds(filters map[string]interface{}, limit, offset int) ([]models.Refund, int64, error)
+}
+
+type refundService struct {
+	refundRepo  repositories.RefundRepository
+	orderRepo   repositories.OrderRepository
+	vnpayHelper *utils.VNPayHelper
+	momoHelper  *utils.MoMoHelper
+	db          *gorm.DB
+}
+
+// NewRefundService creates a new refund service
+func NewRefundService(
+	refundRepo repositories.RefundRepository,
+	orderRepo repositories.OrderRepository,
+	vnpayHelper *utils.VNPayHelper,
+	momoHelper *utils.MoMoHelper,
+	db *gorm.DB,
+) RefundService {
+	return &refundService{
+		refundRepo:  refundRepo,
+		orderRepo:   orderRepo,
+		vnpayHelper: vnpayHelper,
+		momoHelper:  momoHelper,
+		db:          db,
+	}
+}
+
+// RequestRefund creates a refund for a paid order and, for online payments,
+// sends it to the gateway right away
+func (s *refundService) RequestRefund(orderID uint, requestedBy *uint, req RefundRequest, ipAddr string) (*models.Refund, error) {
+	order, err := s.orderRepo.FindByID(orderID)
+	if err != nil {
+		return nil, errors.New("order not found")
+	}
+
+	var refund *models.Refund
+	var payment models.Payment
+
+	// Reserve the refund amount against the payment first, so concurrent
+	// requests cannot refund more than was paid
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("order_id = ?", orderID).
+			First(&payment).Error; err != nil {
+			return errors.New("payment not found")
+		}
+
+		if payment.PaymentStatus != models.PaymentStatusPaid && payment.PaymentStatus != models.PaymentStatusPartiallyRefunded {
+			return errors.New("only paid orders can be refunded")
+		}
+
+		committed, err := repositories.NewRefundRepository(tx).SumCommittedByPayment(payment.ID)
+		if err != nil {
+			return err
+		}
+
+		remaining := payment.Amount - committed
+		amount := remaining
+		if req.Amount != nil {
+			amount = math.Round(*req.Amount)
+		}
+		if amount <= 0 || remaining <= 0 {
+			return errors.New("nothing left to refund for this order")
+		}
+		if amount > remaining {
+			return fmt.Errorf("refund amount exceeds the refundable amount of %.0f", remaining)
+		}
+
+		refund = &models.Refund{
+			OrderID:       order.ID,
+			PaymentID:     payment.ID,
+			PaymentMethod: payment.PaymentMethod,
+			Amount:        amount,
+			Reason:        req.Reason,
+			Status:        models.RefundStatusPending,
+			RequestID:     newGatewayRequestID(),
+			RequestedBy:   requestedBy,
+		}
+		return repositories.NewRefundRepository(tx).Create(refund)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if payment.PaymentMethod == models.PaymentMethodCOD {
+		// Cash is handed back outside the system; wait for SettleRefund
+		return refund, nil
+	}
+
+	if err := s.submitRefund(order, &payment, refund, requestedBy, ipAddr); err != nil {
+		return refund, err
+	}
+	return refund, nil
+}
+
+// submitRefund sends a refund to the gateway and records the outcome. Only a
+// definite rejection fails the refund. When the outcome is unknown, e.g. after
+// a timeout, the gateway may still have paid it, so it stays pending for
+// ReconcilePending to settle.
+func (s *refundService) submitRefund(order *models.Order, payment *models.Payment, refund *models.Refund, requestedBy *uint, ipAddr string) error {
+	var err error
+	switch payment.PaymentMethod {
+	case models.PaymentMethodVNPay:
+		err = s.refundVNPay(order, payment, refund, requestedBy, ipAddr)
+	case models.PaymentMethodMoMo:
+		err = s.refundMoMo(order, payment, refund)
+	default:
+		err = fmt.Errorf("%w: unsupported payment method", errRefundNotSent)
+	}
+
+	if err == nil {
+		refund.FailureReason = ""
+		return s.finishRefund(refund, models.RefundStatusSucceeded)
+	}
+
+	refund.FailureReason = err.Error()
+	if !refundRejected(err) {
+		if updateErr := s.refundRepo.Update(refund); updateErr != nil {
+			return updateErr
+		}
+		return fmt.Errorf("%w: %w", ErrRefundPending, err)
+	}
+
+	if updateErr := s.finishRefund(refund, models.RefundStatusFailed); updateErr != nil {
+		return updateErr
+	}
+	return fmt.Errorf("refund failed: %w", err)
+}
+
+// refundRejected reports whether err means the gateway definitely did not
+// refund. Duplicate request and unknown error codes say nothing about an
+// earlier attempt, and transport or signature errors leave the outcome open.
+func refundRejected(err error) bool {
+	if errors.Is(err, errRefundNotSent) {
+		return true
+	}
+
+	var vnpayErr *utils.VNPayError
+	if errors.As(err, &vnpayErr) {
+		return vnpayErr.ResponseCode != "94" && vnpayErr.ResponseCode != "99"
+	}
+
+	var momoErr *utils.MoMoError
+	if errors.As(err, &momoErr) {
+		switch {
+		case utils.MoMoPendingResultCodes[momoErr.ResultCode]:
+			return false
+		case momoErr.ResultCode == 40, momoErr.ResultCode == 41, momoErr.ResultCode == 99:
+			return false
+		}
+		return true
+	}
+
+	return false
+}
+
+// ReconcilePending settles gateway refunds left pending since before. MoMo
+// refunds are looked up in the payment's transaction status; VNPay refunds
+// are sent again with the same request ID. Refunds the gateway never
+// received are sent again under their original request and order IDs, so
+// an attempt that did arrive is rejected as a duplicate instead of paid
+// twice. It returns the number of refunds settled.
+func (s *refundService) ReconcilePending(before time.Time) (int, error) {
+	refunds, err := s.refundRepo.FindStalePending(before, 100)
+	if err != nil {
+		return 0, err
+	}
+
+	settled := 0
+	for i := range refunds {
+		refund := &refunds[i]
+
+		var status models.RefundStatus
+		switch refund.PaymentMethod {
+		case models.PaymentMethodVNPay:
+			status, err = s.reconcileVNPayRefund(refund)
+		case models.PaymentMethodMoMo:
+			status, err = s.reconcileMoMoRefund(refund)
+		default:
+			continue
+		}
+		if err != nil {
+			log.Printf("Failed to reconcile refund %d: %v", refund.ID, err)
+			continue
+		}
+		if status != models.RefundStatusPending {
+			settled++
+		}
+	}
+
+	return settled, nil
+}
+
+// reconcileVNPayRefund sends the refund again with its original request ID.
+// VNPay answers a request it has already seen with 94, in which case querydr
+// tells whether the refund went through.
+func (s *refundService) reconcileVNPayRefund(refund *models.Refund) (models.RefundStatus, error) {
+	err := s.submitRefund(&refund.Order, &refund.Payment, refund, refund.RequestedBy, "")
+	var vnpayErr *utils.VNPayError
+	if !errors.As(err, &vnpayErr) || vnpayErr.ResponseCode != "94" {
+		return refundOutcome(refund, err)
+	}
+
+	response, err := s.vnpayHelper.QueryTransaction(utils.VNPayQueryRequest{
+		RequestID:       newGatewayRequestID(),
+		OrderCode:       refund.Order.OrderCode,
+		TransactionDate: vnpayPayDate(&refund.Payment),
+		OrderInfo:       fmt.Sprintf("Kiểm tra hoàn tiền đơn hàng %s", refund.Order.OrderCode),
+		IPAddr:          "127.0.0.1",
+	})
+	if err != nil {
+		return models.RefundStatusPending, err
+	}
+	if response.TransactionType != "02" && response.TransactionType != "03" {
+		return models.RefundStatusPending, nil
+	}
+
+	// 05: VNPay is processing the refund, 06: sent to the bank, 09: rejected
+	switch response.TransactionStatus {
+	case "06":
+		refund.FailureReason = ""
+		return models.RefundStatusSucceeded, s.finishRefund(refund, models.RefundStatusSucceeded)
+	case "09":
+		refund.FailureReason = "VNPay rejected the refund"
+		return models.RefundStatusFailed, s.finishRefund(refund, models.RefundStatusFailed)
+	}
+	return models.RefundStatusPending, nil
+}
+
+// reconcileMoMoRefund looks the refund up by its orderId among the refunds
+// MoMo lists for the payment, and sends it again if MoMo has none
+func (s *refundService) reconcileMoMoRefund(refund *models.Refund) (models.RefundStatus, error) {
+	response, err := s.momoHelper.QueryTransaction(refund.Order.OrderCode, newGatewayRequestID())
+	if err != nil {
+		return models.RefundStatusPending, err
+	}
+
+	refundOrderID := momoRefundOrderID(refund.Order.OrderCode, refund.ID)
+	for _, trans := range response.RefundTrans {
+		if trans.OrderID != refundOrderID {
+			continue
+		}
+
+		switch {
+		case trans.ResultCode == 0:
+			if trans.TransID != 0 {
+				refund.GatewayTransactionID = strconv.FormatInt(trans.TransID, 10)
+			}
+			refund.FailureReason = ""
+			return models.RefundStatusSucceeded, s.finishRefund(refund, models.RefundStatusSucceeded)
+		case utils.MoMoPendingResultCodes[trans.ResultCode]:
+			return models.RefundStatusPending, nil
+		default:
+			refund.FailureReason = utils.MoMoResultMessage(trans.ResultCode, "MoMo rejected the refund")
+			return models.RefundStatusFailed, s.finishRefund(refund, models.RefundStatusFailed)
+		}
+	}
+
+	err = s.submitRefund(&refund.Order, &refund.Payment, refund, refund.RequestedBy, "")
+	return refundOutcome(refund, err)
+}
+
+// refundOutcome turns the result of submitRefund into the refund's status,
+// treating a refund left pending as no error
+func refundOutcome(refund *models.Refund, err error) (models.RefundStatus, error) {
+	if errors.Is(err, ErrRefundPending) {
+		return models.RefundStatusPending, nil
+	}
+	if err != nil && refund.Status == models.RefundStatusPending {
+		return models.RefundStatusPending, err
+	}
+	return refund.Status, nil
+}
+
+func (s *refundService) refundVNPay(order *models.Order, payment *models.Payment, refund *models.Refund, requestedBy *uint, ipAddr string) error {
+	createdBy := "system"
+	if requestedBy != nil {
+		createdBy = strconv.FormatUint(uint64(*requestedBy), 10)
+	}
+	if ipAddr == "" {
+		ipAddr = "127.0.0.1"
+	}
+
+	result, err := s.vnpayHelper.Refund(utils.VNPayRefundRequest{
+		RequestID:       refund.RequestID,
+		OrderCode:       order.OrderCode,
+		Amount:          int64(refund.Amount),
+		Full:            refund.Amount == payment.Amount,
+		TransactionNo:   payment.TransactionID,
+		TransactionDate: vnpayPayDate(payment),
+		CreatedBy:       createdBy,
+		OrderInfo:       fmt.Sprintf("Hoàn tiền đơn hàng %s", order.OrderCode),
+		IPAddr:          ipAddr,
+	})
+	if result != nil {
+		responseJSON, _ := json.Marshal(result)
+		refund.GatewayResponse = string(responseJSON)
+		refund.GatewayTransactionID = result.TransactionNo
+	}
+	return err
+}
+
+func (s *refundService) refundMoMo(order *models.Order, payment *models.Payment, refund *models.Refund) error {
+	// Older IPNs stored transId as a float, e.g. "4.088878653e+09"
+	transIDFloat, err := strconv.ParseFloat(payment.TransactionID, 64)
+	if err != nil || transIDFloat <= 0 {
+		return fmt.Errorf("%w: payment has no MoMo transaction ID", errRefundNotSent)
+	}
+	transID := int64(transIDFloat)
+
+	result, err := s.momoHelper.Refund(momoRefundOrderID(order.OrderCode, refund.ID), refund.RequestID, int64(refund.Amount), transID, refund.Reason)
+	if result != nil {
+		responseJSON, _ := json.Marshal(result)
+		refund.GatewayResponse = string(responseJSON)
+		if result.TransID != 0 {
+			refund.GatewayTransactionID = strconv.FormatInt(result.TransID, 10)
+		}
+	}
+	return err
+}
+
+// momoRefundOrderID is the orderId of a refund transaction. MoMo needs one
+// per refund, and it stays the same across retries so MoMo rejects a
+// refund sent twice.
+func momoRefundOrderID(orderCode string, refundID uint) string {
+	return fmt.Sprintf("%s-RF%d", orderCode, refundID)
+}
+
+// SettleRefund records that a pending COD refund was paid back to the customer
+func (s *refundService) SettleRefund(refundID uint, adminID uint, req SettleRefundRequest) (*models.Refund, error) {
+	refund, err := s.refundRepo.FindByID(refundID)
+	if err != nil {
+		return nil, errors.New("refund not found")
+	}
+
+	if refund.PaymentMethod != models.PaymentMethodCOD {
+		return nil, errors.New("only COD refunds are settled manually")
+	}
+	if refund.Status != models.RefundStatusPending {
+		return nil, errors.New("refund is not pending")
+	}
+
+	refund.SettledBy = &adminID
+	refund.SettlementReference = req.Reference
+	refund.SettlementNote = req.Note
+
+	if err := s.finishRefund(refund, models.RefundStatusSucceeded); err != nil {
+		return nil, err
+	}
+	return refund, nil
+}
+
+// finishRefund moves a pending refund to its final status and, on success,
+// updates the payment and order payment status
+func (s *refundService) finishRefund(refund *models.Refund, status models.RefundStatus) error {
+	now := time.Now()
+	refund.Status = status
+	refund.ProcessedAt = &now
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := repositories.NewRefundRepository(tx).Update(refund); err != nil {
+			return err
+		}
+		if status != models.RefundStatusSucceeded {
+			return nil
+		}
+
+		var payment models.Payment
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&payment, refund.PaymentID).Error; err != nil {
+			return err
+		}
+
+		payment.RefundedAmount += refund.Amount
+		payment.PaymentStatus = models.PaymentStatusPartiallyRefunded
+		if payment.RefundedAmount >= payment.Amount {
+			payment.PaymentStatus = models.PaymentStatusRefunded
+		}
+
+		if err := tx.Model(&payment).Updates(map[string]interface{}{
+			"refunded_amount": payment.RefundedAmount,
+			"payment_status":  payment.PaymentStatus,
+		}).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&models.Order{}).
+			Where("id = ?", refund.OrderID).
+			Update("payment_status", payment.PaymentStatus).Error
+	})
+}
+
+// GetOrderRefunds returns all refunds of an order, newest first
+func (s *refundService) GetOrderRefunds(orderID uint) ([]models.Refund, error) {
+	return s.refundRepo.FindByOrderID(orderID)
+}
+
+// ListRefunds lists refunds with filters and pagination
+func (s *refundService) ListRefunds(filters map[string]interface{}, limit, offset int) ([]models.Refund, int64, error) {
+	return s.refundRepo.List(filters, limit, offset)
+}
+
+// vnpayPayDate returns the vnp_PayDate VNPay reported for a payment,
+// falling back to when we recorded it as paid. The gateway response is either
+// the flat IPN parameters or a querydr response; both use the vnp_ field names.
+func vnpayPayDate(payment *models.Payment) string {
+	var response utils.VNPayAPIResponse
+	if err := json.Unmarshal([]byte(payment.GatewayResponse), &response); err == nil && response.PayDate != "" {
+		return response.PayDate
+	}
+	if payment.PaidAt != nil {
+		return utils.VNPayTime(*payment.PaidAt)
+	}
+	return utils.VNPayTime(payment.UpdatedAt)
+}
@@ -0,0 +1,265 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/huy1235588/fashion-e-commerce/internal/models"
+	"github.com/huy1235588/fashion-e-commerce/internal/repositories"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	ReservationReasonPaid          = "payment succeeded"
+	ReservationReasonPaymentFailed = "payment failed"
+	ReservationReasonExpired       = "payment timed out"
+	ReservationReasonCancelled     = "order cancelled"
+)
+
+// ReservationService manages stock holds for orders waiting on online payment.
+// Methods that take a *gorm.DB run inside the caller's transaction.
+type ReservationService interface {
+	AvailableStock(tx *gorm.DB, variantID uint) (int, error)
+	ReserveOrder(tx *gorm.DB, order *models.Order) error
+	CommitOrder(tx *gorm.DB, orderID uint) error
+	ReleaseOrder(tx *gorm.DB, orderID uint, reason string) (int, error)
+	RestoreOrderStock(tx *gorm.DB, order *models.Order, reason string) error
+	ReleaseExpired() (int, error)
+	StartSweeper(ctx context.Context, interval time.Duration)
+}
+
+type reservationService struct {
+	inventoryService InventoryService
+	db               *gorm.DB
+	ttl              time.Duration
+}
+
+// NewReservationService creates a new reservation service
+func NewReservationService(inventoryService InventoryService, db *gorm.DB, ttl time.Duration) ReservationService {
+	return &reservationService{
+		inventoryService: inventoryService,
+		db:               db,
+		ttl:              ttl,
+	}
+}
+
+// AvailableStock locks the variant row and returns its stock minus active holds
+func (s *reservationService) AvailableStock(tx *gorm.DB, variantID uint) (int, error) {
+	var variant models.ProductVariant
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&variant, variantID).Error; err != nil {
+		return 0, err
+	}
+
+	reserved, err := repositories.NewStockReservationRepository(tx).SumActiveByVariant(variantID)
+	if err != nil {
+		return 0, err
+	}
+
+	return variant.StockQuantity - reserved, nil
+}
+
+// ReserveOrder creates one hold per order item, expiring after the configured TTL
+func (s *reservationService) ReserveOrder(tx *gorm.DB, order *models.Order) error {
+	repo := repositories.NewStockReservationRepository(tx)
+	expiresAt := time.Now().Add(s.ttl)
+
+	for _, item := range order.OrderItems {
+		if item.VariantID == nil {
+			continue
+		}
+		reservation := &models.StockReservation{
+			OrderID:     order.ID,
+			OrderItemID: item.ID,
+			VariantID:   *item.VariantID,
+			Quantity:    item.Quantity,
+			Status:      models.ReservationStatusActive,
+			ExpiresAt:   expiresAt,
+		}
+		if err := repo.Create(reservation); err != nil {
+			return fmt.Errorf("failed to reserve stock for %s: %w", item.ProductName, err)
+		}
+	}
+
+	return nil
+}
+
+// CommitOrder turns the order's active holds into a permanent stock deduction
+func (s *reservationService) CommitOrder(tx *gorm.DB, orderID uint) error {
+	repo := repositories.NewStockReservationRepository(tx)
+	reservations, err := repo.FindByOrderID(orderID)
+	if err != nil {
+		return err
+	}
+
+	for _, reservation := range reservations {
+		if reservation.Status != models.ReservationStatusActive {
+			continue
+		}
+
+		committed, err := repo.Resolve(reservation.ID, models.ReservationStatusCommitted, ReservationReasonPaid)
+		if err != nil {
+			return err
+		}
+		if !committed {
+			// Released concurrently by the sweeper; nothing to deduct
+			continue
+		}
+
+		if err := s.inventoryService.ApplyMovement(tx, &models.InventoryMovement{
+			VariantID: reservation.VariantID,
+			Delta:     -reservation.Quantity,
+			Reason:    models.MovementReasonSale,
+			OrderID:   &orderID,
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ReleaseOrder releases the order's active holds and returns how many were released
+func (s *reservationService) ReleaseOrder(tx *gorm.DB, orderID uint, reason string) (int, error) {
+	repo := repositories.NewStockReservationRepository(tx)
+	reservations, err := repo.FindByOrderID(orderID)
+	if err != nil {
+		return 0, err
+	}
+
+	released := 0
+	for _, reservation := range reservations {
+		if reservation.Status != models.ReservationStatusActive {
+			continue
+		}
+		ok, err := repo.Resolve(reservation.ID, models.ReservationStatusReleased, reason)
+		if err != nil {
+			return released, err
+		}
+		if ok {
+			released++
+		}
+	}
+
+	return released, nil
+}
+
+// RestoreOrderStock gives back everything an order took from inventory.
+// Active holds are released; items already deducted (COD or paid) are restocked.
+func (s *reservationService) RestoreOrderStock(tx *gorm.DB, order *models.Order, reason string) error {
+	repo := repositories.NewStockReservationRepository(tx)
+	reservations, err := repo.FindByOrderID(order.ID)
+	if err != nil {
+		return err
+	}
+
+	byItem := make(map[uint]models.StockReservation, len(reservations))
+	for _, reservation := range reservations {
+		byItem[reservation.OrderItemID] = reservation
+	}
+
+	for _, item := range order.OrderItems {
+		if item.VariantID == nil {
+			continue
+		}
+
+		reservation, hasReservation := byItem[item.ID]
+		if hasReservation {
+			switch reservation.Status {
+			case models.ReservationStatusActive:
+				if _, err := repo.Resolve(reservation.ID, models.ReservationStatusReleased, reason); err != nil {
+					return err
+				}
+				continue
+			case models.ReservationStatusReleased:
+				continue
+			}
+		}
+
+		if err := s.inventoryService.ApplyMovement(tx, &models.InventoryMovement{
+			VariantID: *item.VariantID,
+			Delta:     item.Quantity,
+			Reason:    models.MovementReasonCancel,
+			OrderID:   &order.ID,
+			Note:      reason,
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ReleaseExpired releases holds past their expiry and cancels the unpaid orders behind them
+func (s *reservationService) ReleaseExpired() (int, error) {
+	orderIDs, err := repositories.NewStockReservationRepository(s.db).FindExpiredOrderIDs(time.Now(), 100)
+	if err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	for _, orderID := range orderIDs {
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			released, err := s.ReleaseOrder(tx, orderID, ReservationReasonExpired)
+			if err != nil || released == 0 {
+				return err
+			}
+			return cancelUnpaidOrder(tx, orderID, ReservationReasonExpired)
+		})
+		if err != nil {
+			log.Printf("Failed to release expired reservations for order %d: %v", orderID, err)
+			continue
+		}
+		expired++
+	}
+
+	return expired, nil
+}
+
+// StartSweeper periodically releases expired holds until ctx is cancelled
+func (s *reservationService) StartSweeper(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			count, err := s.ReleaseExpired()
+			if err != nil {
+				log.Printf("Reservation sweeper failed: %v", err)
+				continue
+			}
+			if count > 0 {
+				log.Printf("Reservation sweeper released holds for %d order(s)", count)
+			}
+		}
+	}
+}
+
+// cancelUnpaidOrder cancels a pending order whose payment never completed
+func cancelUnpaidOrder(tx *gorm.DB, orderID uint, reason string) error {
+	result := tx.Model(&models.Order{}).
+		Where("id = ? AND status = ? AND payment_status <> ?", orderID, models.OrderStatusPending, models.PaymentStatusPaid).
+		Updates(map[string]interface{}{
+			"status":         models.OrderStatusCancelled,
+			"payment_status": models.PaymentStatusFailed,
+			"cancel_reason":  reason,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected > 0 {
+		if err := releasePromotionUsage(tx, orderID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Model(&models.Payment{}).
+		Where("order_id = ? AND payment_status = ?", orderID, models.PaymentStatusPending).
+		Update("payment_status", models.PaymentStatusFailed).Error
+}
@@ -2,24 +2,29 @@ package services
 
 import (
 	"errors"
+	"log"
+
 	"github.com/huy1235588/fashion-e-commerce/internal/models"
 	"github.com/huy1235588/fashion-e-commerce/internal/repositories"
 )
 
 // ReviewService handles business logic for reviews
 type ReviewService struct {
-	reviewRepo repositories.ReviewRepository
-	orderRepo  repositories.OrderRepository
+	reviewRepo  repositories.ReviewRepository
+	orderRepo   repositories.OrderRepository
+	productRepo repositories.ProductRepository
 }
 
 // NewReviewService creates a new ReviewService
 func NewReviewService(
 	reviewRepo repositories.ReviewRepository,
 	orderRepo repositories.OrderRepository,
+	productRepo repositories.ProductRepository,
 ) *ReviewService {
 	return &ReviewService{
-		reviewRepo: reviewRepo,
-		orderRepo:  orderRepo,
+		reviewRepo:  reviewRepo,
+		orderRepo:   orderRepo,
+		productRepo: productRepo,
 	}
 }
 
@@ -39,7 +44,7 @@ func (s *ReviewService) CreateReview(userID uint, req CreateReviewRequest) (*mod
 	if err != nil {
 		return nil, errors.New("order not found")
 	}
-	if order.UserID != userID {
+	if !order.BelongsTo(userID) {
 		return nil, errors.New("unauthorized: order does not belong to user")
 	}
 
@@ -82,6 +87,7 @@ func (s *ReviewService) CreateReview(userID uint, req CreateReviewRequest) (*mod
 	if err := s.reviewRepo.Create(review); err != nil {
 		return nil, err
 	}
+	s.refreshRating(review.ProductID)
 
 	return review, nil
 }
@@ -115,7 +121,11 @@ func (s *ReviewService) DeleteReview(reviewID, userID uint) error {
 		return errors.New("unauthorized: you can only delete your own reviews")
 	}
 
-	return s.reviewRepo.Delete(reviewID)
+	if err := s.reviewRepo.Delete(reviewID); err != nil {
+		return err
+	}
+	s.refreshRating(review.ProductID)
+	return nil
 }
 
 // UpdateReviewStatus updates review status (admin only)
@@ -124,5 +134,23 @@ func (s *ReviewService) UpdateReviewStatus(reviewID uint, status string) error {
 		return errors.New("invalid status")
 	}
 
-	return s.reviewRepo.UpdateStatus(reviewID, status)
+	review, err := s.reviewRepo.FindByID(reviewID)
+	if err != nil {
+		return errors.New("review not found")
+	}
+
+	if err := s.reviewRepo.UpdateStatus(reviewID, status); err != nil {
+		return err
+	}
+	s.refreshRating(review.ProductID)
+	return nil
+}
+
+// refreshRating updates the product's stored rating, which listings filter
+// and sort on. A failure is only logged; the periodic stats refresh
+// catches up.
+func (s *ReviewService) refreshRating(productID uint) {
+	if err := s.productRepo.RefreshSalesStats(productID); err != nil {
+		log.Printf("Failed to refresh rating of product %d: %v", productID, err)
+	}
 }
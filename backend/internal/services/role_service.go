@@ -0,0 +1,228 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/huy1235588/fashion-e-commerce/internal/models"
+	"github.com/huy1235588/fashion-e-commerce/internal/repositories"
+	"gorm.io/gorm"
+)
+
+// rolePermissionCacheTTL bounds how long other instances keep serving
+// permissions of a role that was edited elsewhere
+const rolePermissionCacheTTL = time.Minute
+
+// CreateRoleRequest represents the admin payload for creating a role
+type CreateRoleRequest struct {
+	Name        string   `json:"name" binding:"required,max=50"`
+	DisplayName string   `json:"display_name" binding:"required,max=100"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+// UpdateRoleRequest represents the admin payload for updating a role
+type UpdateRoleRequest struct {
+	DisplayName string   `json:"display_name" binding:"required,max=100"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+// RoleService manages roles and answers permission checks for the auth middleware
+type RoleService interface {
+	ListRoles() ([]models.Role, error)
+	ListPermissions() ([]models.Permission, error)
+	CreateRole(req CreateRoleRequest) (*models.Role, error)
+	UpdateRole(id uint, req UpdateRoleRequest) (*models.Role, error)
+	DeleteRole(id uint) error
+	HasPermission(role string, permission string) (bool, error)
+}
+
+type cachedPermissions struct {
+	codes    map[string]bool
+	loadedAt time.Time
+}
+
+type roleService struct {
+	roleRepo repositories.RoleRepository
+	db       *gorm.DB
+
+	mu    sync.RWMutex
+	cache map[string]cachedPermissions
+}
+
+// NewRoleService creates a new role service
+func NewRoleService(roleRepo repositories.RoleRepository, db *gorm.DB) RoleService {
+	return &roleService{
+		roleRepo: roleRepo,
+		db:       db,
+		cache:    make(map[string]cachedPermissions),
+	}
+}
+
+// ListRoles returns every role with its permissions
+func (s *roleService) ListRoles() ([]models.Role, error) {
+	return s.roleRepo.List()
+}
+
+// ListPermissions returns every permission that can be granted
+func (s *roleService) ListPermissions() ([]models.Permission, error) {
+	return s.roleRepo.ListPermissions()
+}
+
+// CreateRole creates a custom role with the given permissions
+func (s *roleService) CreateRole(req CreateRoleRequest) (*models.Role, error) {
+	if !validRoleName(req.Name) {
+		return nil, errors.New("role name may only contain lowercase letters, digits and underscores")
+	}
+	if _, err := s.roleRepo.FindByName(req.Name); err == nil {
+		return nil, errors.New("role already exists")
+	}
+	if err := validatePermissionCodes(req.Permissions); err != nil {
+		return nil, err
+	}
+
+	role := &models.Role{
+		Name:        req.Name,
+		DisplayName: req.DisplayName,
+		Description: req.Description,
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		roleRepo := repositories.NewRoleRepository(tx)
+		if err := roleRepo.Create(role); err != nil {
+			return err
+		}
+		return roleRepo.ReplacePermissions(role, uniqueStrings(req.Permissions))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.invalidate(role.Name)
+	return role, nil
+}
+
+// UpdateRole updates a custom role and replaces its permissions
+func (s *roleService) UpdateRole(id uint, req UpdateRoleRequest) (*models.Role, error) {
+	role, err := s.roleRepo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if role.IsSystem {
+		return nil, errors.New("system roles cannot be modified")
+	}
+	if err := validatePermissionCodes(req.Permissions); err != nil {
+		return nil, err
+	}
+
+	role.DisplayName = req.DisplayName
+	role.Description = req.Description
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		roleRepo := repositories.NewRoleRepository(tx)
+		if err := roleRepo.Update(role); err != nil {
+			return err
+		}
+		return roleRepo.ReplacePermissions(role, uniqueStrings(req.Permissions))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.invalidate(role.Name)
+	return role, nil
+}
+
+// DeleteRole deletes a custom role that is not assigned to anyone
+func (s *roleService) DeleteRole(id uint) error {
+	role, err := s.roleRepo.FindByID(id)
+	if err != nil {
+		return err
+	}
+	if role.IsSystem {
+		return errors.New("system roles cannot be deleted")
+	}
+
+	count, err := s.roleRepo.CountUsers(role.Name)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("role is assigned to %d user(s)", count)
+	}
+
+	if err := s.roleRepo.Delete(role.ID); err != nil {
+		return err
+	}
+
+	s.invalidate(role.Name)
+	return nil
+}
+
+// HasPermission reports whether a role grants a permission. Permissions are
+// cached per role for a short time since this runs on every admin request.
+func (s *roleService) HasPermission(role string, permission string) (bool, error) {
+	s.mu.RLock()
+	cached, ok := s.cache[role]
+	s.mu.RUnlock()
+
+	if !ok || time.Since(cached.loadedAt) > rolePermissionCacheTTL {
+		codes, err := s.roleRepo.FindPermissionCodes(role)
+		if err != nil {
+			return false, err
+		}
+
+		cached = cachedPermissions{codes: make(map[string]bool, len(codes)), loadedAt: time.Now()}
+		for _, code := range codes {
+			cached.codes[code] = true
+		}
+
+		s.mu.Lock()
+		s.cache[role] = cached
+		s.mu.Unlock()
+	}
+
+	return cached.codes[permission], nil
+}
+
+func (s *roleService) invalidate(role string) {
+	s.mu.Lock()
+	delete(s.cache, role)
+	s.mu.Unlock()
+}
+
+func validRoleName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+func validatePermissionCodes(codes []string) error {
+	for _, code := range codes {
+		if _, ok := models.PermissionDescriptions[code]; !ok {
+			return fmt.Errorf("unknown permission: %s", code)
+		}
+	}
+	return nil
+}
+
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	result := make([]string, 0, len(values))
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			result = append(result, value)
+		}
+	}
+	return result
+}
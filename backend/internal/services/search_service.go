@@ -0,0 +1,159 @@
+package services
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/huy1235588/fashion-e-commerce/internal/models"
+	"github.com/huy1235588/fashion-e-commerce/internal/repositories"
+)
+
+const (
+	// minSuggestPrefix is the shortest prefix worth completing
+	minSuggestPrefix = 2
+	// maxSearchQueryLength caps the length of a logged query, in characters
+	maxSearchQueryLength = 255
+	// popularQueryWindow is how far back popular query suggestions look
+	popularQueryWindow = 30 * 24 * time.Hour
+)
+
+// SearchSuggestions are the completions offered for a search prefix
+type SearchSuggestions struct {
+	Products   []repositories.ProductSuggestion  `json:"products"`
+	Categories []repositories.CategorySuggestion `json:"categories"`
+	Queries    []repositories.QuerySuggestion    `json:"queries"`
+}
+
+// SearchService handles search autocomplete and the search log
+type SearchService interface {
+	Suggest(prefix string, limit int) (*SearchSuggestions, error)
+	LogSearch(query string, resultCount int64)
+	GetTopQueries(days, limit int) ([]repositories.SearchQueryStat, error)
+	GetZeroResultQueries(days, limit int) ([]repositories.SearchQueryStat, error)
+	StartLogCleanup(ctx context.Context, interval, retention time.Duration)
+}
+
+type searchService struct {
+	searchRepo repositories.SearchRepository
+}
+
+// NewSearchService creates a new search service
+func NewSearchService(searchRepo repositories.SearchRepository) SearchService {
+	return &searchService{searchRepo: searchRepo}
+}
+
+// Suggest returns product names, categories and popular past searches
+// completing a prefix, tolerating typos and missing accents. Prefixes
+// shorter than two characters get no suggestions.
+func (s *searchService) Suggest(prefix string, limit int) (*SearchSuggestions, error) {
+	if limit <= 0 {
+		limit = 5
+	}
+	if limit > 10 {
+		limit = 10
+	}
+
+	suggestions := &SearchSuggestions{
+		Products:   []repositories.ProductSuggestion{},
+		Categories: []repositories.CategorySuggestion{},
+		Queries:    []repositories.QuerySuggestion{},
+	}
+
+	prefix = normalizeSearchQuery(prefix)
+	if utf8.RuneCountInString(prefix) < minSuggestPrefix {
+		return suggestions, nil
+	}
+
+	var err error
+	if suggestions.Products, err = s.searchRepo.SuggestProducts(prefix, limit); err != nil {
+		return nil, err
+	}
+	if suggestions.Categories, err = s.searchRepo.SuggestCategories(prefix, limit); err != nil {
+		return nil, err
+	}
+	if suggestions.Queries, err = s.searchRepo.SuggestQueries(prefix, time.Now().Add(-popularQueryWindow), limit); err != nil {
+		return nil, err
+	}
+
+	return suggestions, nil
+}
+
+// LogSearch records a search a shopper ran and how many products it found.
+// Failures are only logged so they never fail the search itself.
+func (s *searchService) LogSearch(query string, resultCount int64) {
+	query = normalizeSearchQuery(query)
+	if query == "" {
+		return
+	}
+
+	entry := &models.SearchLog{Query: query, ResultCount: resultCount}
+	if err := s.searchRepo.CreateLog(entry); err != nil {
+		log.Printf("Failed to log search %q: %v", query, err)
+	}
+}
+
+// GetTopQueries retrieves the queries searched most often in the last days
+func (s *searchService) GetTopQueries(days, limit int) ([]repositories.SearchQueryStat, error) {
+	since, limit := searchReportRange(days, limit)
+	return s.searchRepo.TopQueries(since, limit)
+}
+
+// GetZeroResultQueries retrieves the queries searched most often in the
+// last days that still find no products
+func (s *searchService) GetZeroResultQueries(days, limit int) ([]repositories.SearchQueryStat, error) {
+	since, limit := searchReportRange(days, limit)
+	return s.searchRepo.ZeroResultQueries(since, limit)
+}
+
+// StartLogCleanup periodically deletes searches logged more than retention
+// ago until ctx is cancelled
+func (s *searchService) StartLogCleanup(ctx context.Context, interval, retention time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			count, err := s.searchRepo.DeleteLogsBefore(time.Now().Add(-retention))
+			if err != nil {
+				log.Printf("Search log cleanup failed: %v", err)
+				continue
+			}
+			if count > 0 {
+				log.Printf("Search log cleanup deleted %d search(es)", count)
+			}
+		}
+	}
+}
+
+// searchReportRange clamps the period and size of a search report
+func searchReportRange(days, limit int) (time.Time, int) {
+	if days <= 0 {
+		days = 30
+	}
+	if days > 365 {
+		days = 365
+	}
+	if limit <= 0 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+	return time.Now().AddDate(0, 0, -days), limit
+}
+
+// normalizeSearchQuery lowercases a query and collapses its spaces so the
+// same search is always logged the same way
+func normalizeSearchQuery(query string) string {
+	query = strings.Join(strings.Fields(strings.ToLower(query)), " ")
+	if utf8.RuneCountInString(query) > maxSearchQueryLength {
+		query = string([]rune(query)[:maxSearchQueryLength])
+	}
+	return query
+}
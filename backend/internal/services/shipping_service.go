@@ -0,0 +1,293 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/huy1235588/fashion-e-commerce/internal/models"
+	"github.com/huy1235588/fashion-e-commerce/internal/repositories"
+)
+
+// ShippingParcel describes what is being shipped and where
+type ShippingParcel struct {
+	Province    string
+	District    string
+	Subtotal    float64
+	TotalWeight int // grams
+}
+
+// ShippingCalculator computes the shipping fee for a parcel
+type ShippingCalculator interface {
+	Calculate(parcel ShippingParcel) (float64, error)
+}
+
+// FlatRateCalculator charges the same fee for every parcel
+type FlatRateCalculator struct {
+	Fee float64
+}
+
+// NewFlatRateCalculator creates a flat rate calculator
+func NewFlatRateCalculator(fee float64) *FlatRateCalculator {
+	return &FlatRateCalculator{Fee: fee}
+}
+
+func (c *FlatRateCalculator) Calculate(parcel ShippingParcel) (float64, error) {
+	return c.Fee, nil
+}
+
+// ZoneCalculator charges by destination using the shipping_zones table.
+// District zones take precedence over province zones; addresses outside
+// every zone pay DefaultFee.
+type ZoneCalculator struct {
+	zoneRepo   repositories.ShippingZoneRepository
+	DefaultFee float64
+}
+
+// NewZoneCalculator creates a zone calculator
+func NewZoneCalculator(zoneRepo repositories.ShippingZoneRepository, defaultFee float64) *ZoneCalculator {
+	return &ZoneCalculator{zoneRepo: zoneRepo, DefaultFee: defaultFee}
+}
+
+func (c *ZoneCalculator) Calculate(parcel ShippingParcel) (float64, error) {
+	zone, err := c.zoneRepo.FindMatch(parcel.Province, parcel.District)
+	if err != nil {
+		return 0, err
+	}
+	if zone == nil {
+		return c.DefaultFee, nil
+	}
+	return zone.Fee, nil
+}
+
+// WeightTier is the fee for parcels up to MaxWeight grams
+type WeightTier struct {
+	MaxWeight int
+	Fee       float64
+}
+
+// WeightTierCalculator charges by total parcel weight. Parcels heavier than
+// the last tier pay its fee plus ExtraFeePerKg for every started kilogram above it.
+type WeightTierCalculator struct {
+	Tiers         []WeightTier
+	ExtraFeePerKg float64
+}
+
+// NewWeightTierCalculator creates a weight tier calculator
+func NewWeightTierCalculator(tiers []WeightTier, extraFeePerKg float64) (*WeightTierCalculator, error) {
+	if len(tiers) == 0 {
+		return nil, errors.New("at least one weight tier is required")
+	}
+
+	sorted := make([]WeightTier, len(tiers))
+	copy(sorted, tiers)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].MaxWeight < sorted[j].MaxWeight })
+
+	return &WeightTierCalculator{Tiers: sorted, ExtraFeePerKg: extraFeePerKg}, nil
+}
+
+func (c *WeightTierCalculator) Calculate(parcel ShippingParcel) (float64, error) {
+	for _, tier := range c.Tiers {
+		if parcel.TotalWeight <= tier.MaxWeight {
+			return tier.Fee, nil
+		}
+	}
+
+	last := c.Tiers[len(c.Tiers)-1]
+	extraKg := math.Ceil(float64(parcel.TotalWeight-last.MaxWeight) / 1000)
+	return last.Fee + extraKg*c.ExtraFeePerKg, nil
+}
+
+// FreeShippingCalculator waives the fee of the wrapped calculator once the
+// subtotal reaches Threshold
+type FreeShippingCalculator struct {
+	Threshold float64
+	Next      ShippingCalculator
+}
+
+// NewFreeShippingCalculator wraps next with a free shipping threshold
+func NewFreeShippingCalculator(threshold float64, next ShippingCalculator) *FreeShippingCalculator {
+	return &FreeShippingCalculator{Threshold: threshold, Next: next}
+}
+
+func (c *FreeShippingCalculator) Calculate(parcel ShippingParcel) (float64, error) {
+	if c.Threshold > 0 && parcel.Subtotal >= c.Threshold {
+		return 0, nil
+	}
+	return c.Next.Calculate(parcel)
+}
+
+// ParseWeightTiers parses "maxGrams:fee" pairs such as "500:25000,1000:30000"
+func ParseWeightTiers(spec string) ([]WeightTier, error) {
+	var tiers []WeightTier
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		parts := strings.SplitN(pair, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid weight tier %q", pair)
+		}
+		maxWeight, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+		if err != nil || maxWeight <= 0 {
+			return nil, fmt.Errorf("invalid weight in tier %q", pair)
+		}
+		fee, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+		if err != nil || fee < 0 {
+			return nil, fmt.Errorf("invalid fee in tier %q", pair)
+		}
+
+		tiers = append(tiers, WeightTier{MaxWeight: maxWeight, Fee: fee})
+	}
+	return tiers, nil
+}
+
+// ShippingQuoteRequest represents a request for a shipping fee quote.
+// Either AddressID or Province/District must be given. CartID picks one of
+// the user's saved lists instead of the primary cart.
+type ShippingQuoteRequest struct {
+	AddressID *uint  `json:"address_id"`
+	Province  string `json:"province"`
+	District  string `json:"district"`
+	CartID    *uint  `json:"cart_id"`
+}
+
+// ShippingQuote is the shipping fee for the user's current cart
+type ShippingQuote struct {
+	ShippingFee           float64 `json:"shipping_fee"`
+	Subtotal              float64 `json:"subtotal"`
+	TotalWeight           int     `json:"total_weight"`
+	FreeShippingThreshold float64 `json:"free_shipping_threshold,omitempty"`
+}
+
+// ShippingService quotes shipping fees for carts and orders
+type ShippingService interface {
+	Calculate(parcel ShippingParcel) (float64, error)
+	QuoteCart(userID uint, req ShippingQuoteRequest) (*ShippingQuote, error)
+	ListZones() ([]models.ShippingZone, error)
+	SaveZone(zone *models.ShippingZone) error
+	DeleteZone(id uint) error
+}
+
+type shippingService struct {
+	calculator            ShippingCalculator
+	freeShippingThreshold float64
+	zoneRepo              repositories.ShippingZoneRepository
+	cartRepo              repositories.CartRepository
+	addressRepo           repositories.AddressRepository
+}
+
+// NewShippingService creates a new shipping service.
+// freeShippingThreshold is only reported to clients; apply it through the calculator.
+func NewShippingService(
+	calculator ShippingCalculator,
+	freeShippingThreshold float64,
+	zoneRepo repositories.ShippingZoneRepository,
+	cartRepo repositories.CartRepository,
+	addressRepo repositories.AddressRepository,
+) ShippingService {
+	return &shippingService{
+		calculator:            calculator,
+		freeShippingThreshold: freeShippingThreshold,
+		zoneRepo:              zoneRepo,
+		cartRepo:              cartRepo,
+		addressRepo:           addressRepo,
+	}
+}
+
+// Calculate returns the shipping fee for a parcel
+func (s *shippingService) Calculate(parcel ShippingParcel) (float64, error) {
+	fee, err := s.calculator.Calculate(parcel)
+	if err != nil {
+		return 0, fmt.Errorf("failed to calculate shipping fee: %w", err)
+	}
+	return math.Round(fee), nil
+}
+
+// QuoteCart returns the shipping fee for the user's cart without creating an order
+func (s *shippingService) QuoteCart(userID uint, req ShippingQuoteRequest) (*ShippingQuote, error) {
+	parcel := ShippingParcel{
+		Province: req.Province,
+		District: req.District,
+	}
+
+	if req.AddressID != nil {
+		address, err := s.addressRepo.FindByID(*req.AddressID)
+		if err != nil {
+			return nil, errors.New("address not found")
+		}
+		if address.UserID != userID {
+			return nil, errors.New("unauthorized access to address")
+		}
+		parcel.Province = address.Province
+		parcel.District = address.District
+	}
+
+	if parcel.Province == "" {
+		return nil, errors.New("address_id or province is required")
+	}
+
+	var cart *models.Cart
+	var err error
+	if req.CartID != nil {
+		cart, err = s.cartRepo.GetByID(*req.CartID)
+		if err == nil && !cart.BelongsTo(userID) {
+			err = errors.New("cart belongs to another user")
+		}
+	} else {
+		cart, err = s.cartRepo.GetByUserID(userID)
+	}
+	if err != nil {
+		return nil, errors.New("cart not found")
+	}
+	if len(cart.Items) == 0 {
+		return nil, errors.New("cart is empty")
+	}
+
+	for _, item := range cart.Items {
+		if item.Product == nil || item.Variant == nil {
+			continue
+		}
+		// Checkout charges the price the customer confirmed in the cart
+		parcel.Subtotal += item.Price * float64(item.Quantity)
+		parcel.TotalWeight += item.Variant.Weight * item.Quantity
+	}
+
+	fee, err := s.Calculate(parcel)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ShippingQuote{
+		ShippingFee:           fee,
+		Subtotal:              parcel.Subtotal,
+		TotalWeight:           parcel.TotalWeight,
+		FreeShippingThreshold: s.freeShippingThreshold,
+	}, nil
+}
+
+// ListZones returns all shipping zones
+func (s *shippingService) ListZones() ([]models.ShippingZone, error) {
+	return s.zoneRepo.List()
+}
+
+// SaveZone creates a zone or updates the fee of the existing zone for the same area
+func (s *shippingService) SaveZone(zone *models.ShippingZone) error {
+	zone.ID = 0
+	zone.Province = strings.TrimSpace(zone.Province)
+	zone.District = strings.TrimSpace(zone.District)
+	if zone.Province == "" {
+		return errors.New("province is required")
+	}
+	return s.zoneRepo.Upsert(zone)
+}
+
+// DeleteZone deletes a shipping zone
+func (s *shippingService) DeleteZone(id uint) error {
+	return s.zoneRepo.Delete(id)
+}
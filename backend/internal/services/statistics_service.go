@@ -14,6 +14,7 @@ type StatisticsService interface {
 	GetOrderStatsByStatus() ([]repositories.OrderStatusStats, error)
 	GetCustomerGrowth(period string, limit int) ([]repositories.CustomerGrowth, error)
 	GetRevenueByCategory() ([]repositories.CategoryRevenue, error)
+	GetMostWishlistedProducts(limit int) ([]repositories.ProductWishlistCount, error)
 }
 
 type statisticsService struct {
@@ -99,6 +100,17 @@ func (s *statisticsService) GetTopSellingProducts(limit int) ([]repositories.Pro
 	return s.statsRepo.GetTopSellingProducts(limit)
 }
 
+// GetMostWishlistedProducts retrieves the products saved to the most wishlists
+func (s *statisticsService) GetMostWishlistedProducts(limit int) ([]repositories.ProductWishlistCount, error) {
+	if limit <= 0 {
+		limit = 10
+	}
+	if limit > 100 {
+		limit = 100
+	}
+	return s.statsRepo.GetMostWishlistedProducts(limit)
+}
+
 // GetOrderStatsByStatus retrieves order statistics grouped by status
 func (s *statisticsService) GetOrderStatsByStatus() ([]repositories.OrderStatusStats, error) {
 	return s.statsRepo.GetOrderStatsByStatus()
@@ -0,0 +1,439 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"log"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/huy1235588/fashion-e-commerce/internal/models"
+	"github.com/huy1235588/fashion-e-commerce/internal/repositories"
+	"github.com/huy1235588/fashion-e-commerce/internal/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	backupCodeCount    = 10
+	backupCodeLength   = 10
+	backupCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789" // no 0/O or 1/I to misread
+)
+
+// ErrInvalidTwoFactorCode is returned for wrong, reused or expired second-factor codes
+var ErrInvalidTwoFactorCode = errors.New("invalid two-factor code")
+
+// TwoFactorRequiredError is returned instead of tokens when a user with
+// two-factor authentication enabled passed the first login step. The client
+// finishes the login by sending ChallengeToken with a code.
+type TwoFactorRequiredError struct {
+	ChallengeToken string
+	ExpiresIn      time.Duration
+}
+
+func (e *TwoFactorRequiredError) Error() string {
+	return "two-factor authentication required"
+}
+
+// TwoFactorSettings holds the two-factor authentication policy
+type TwoFactorSettings struct {
+	Issuer               string // name shown in authenticator apps
+	ChallengeTTL         time.Duration
+	MaxChallengeAttempts int
+	RequiredForStaff     bool // roles with any admin permission must use two-factor authentication
+}
+
+// TwoFactorSetup is what the user needs to add the account to an authenticator app
+type TwoFactorSetup struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"` // otpauth:// URI to render as a QR code
+}
+
+// TwoFactorStatus describes a user's two-factor authentication
+type TwoFactorStatus struct {
+	Enabled              bool       `json:"enabled"`
+	EnabledAt            *time.Time `json:"enabled_at,omitempty"`
+	Required             bool       `json:"required"`
+	BackupCodesRemaining int64      `json:"backup_codes_remaining"`
+}
+
+// TwoFactorService handles TOTP enrolment, backup codes and the second login step
+type TwoFactorService interface {
+	Status(userID uint) (*TwoFactorStatus, error)
+	BeginSetup(userID uint) (*TwoFactorSetup, error)
+	Enable(userID uint, code string) ([]string, error)
+	Disable(userID uint, code string) error
+	RegenerateBackupCodes(userID uint, code string) ([]string, error)
+	Reset(userID uint) error
+	IsEnabled(userID uint) (bool, error)
+	CreateChallenge(userID uint) (*TwoFactorRequiredError, error)
+	VerifyChallenge(token, code string) (uint, error)
+	StartCleanup(ctx context.Context, interval time.Duration)
+}
+
+type twoFactorService struct {
+	twoFactorRepo repositories.TwoFactorRepository
+	userRepo      repositories.UserRepository
+	roleRepo      repositories.RoleRepository
+	encryptor     *utils.Encryptor
+	db            *gorm.DB
+	settings      TwoFactorSettings
+}
+
+// NewTwoFactorService creates a new two-factor service
+func NewTwoFactorService(
+	twoFactorRepo repositories.TwoFactorRepository,
+	userRepo repositories.UserRepository,
+	roleRepo repositories.RoleRepository,
+	encryptor *utils.Encryptor,
+	db *gorm.DB,
+	settings TwoFactorSettings,
+) TwoFactorService {
+	return &twoFactorService{
+		twoFactorRepo: twoFactorRepo,
+		userRepo:      userRepo,
+		roleRepo:      roleRepo,
+		encryptor:     encryptor,
+		db:            db,
+		settings:      settings,
+	}
+}
+
+// Status returns whether a user has two-factor authentication and whether
+// their role requires it
+func (s *twoFactorService) Status(userID uint) (*TwoFactorStatus, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &TwoFactorStatus{}
+	if s.settings.RequiredForStaff {
+		codes, err := s.roleRepo.FindPermissionCodes(user.Role)
+		if err != nil {
+			return nil, err
+		}
+		status.Required = len(codes) > 0
+	}
+
+	auth, err := s.twoFactorRepo.FindByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if auth != nil && auth.EnabledAt != nil {
+		status.Enabled = true
+		status.EnabledAt = auth.EnabledAt
+		if status.BackupCodesRemaining, err = s.twoFactorRepo.CountUnusedBackupCodes(userID); err != nil {
+			return nil, err
+		}
+	}
+	return status, nil
+}
+
+// BeginSetup generates a new secret for the user to add to their
+// authenticator app. It only takes effect once confirmed with Enable.
+func (s *twoFactorService) BeginSetup(userID uint) (*TwoFactorSetup, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	auth, err := s.twoFactorRepo.FindByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if auth != nil && auth.EnabledAt != nil {
+		return nil, errors.New("two-factor authentication is already enabled")
+	}
+	if auth == nil {
+		auth = &models.TwoFactorAuth{UserID: userID}
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	if auth.Secret, err = s.encryptor.Encrypt(secret); err != nil {
+		return nil, err
+	}
+	auth.LastUsedStep = 0
+	if err := s.twoFactorRepo.Save(auth); err != nil {
+		return nil, err
+	}
+
+	return &TwoFactorSetup{
+		Secret:          secret,
+		ProvisioningURI: utils.TOTPProvisioningURI(s.settings.Issuer, user.Email, secret),
+	}, nil
+}
+
+// Enable confirms enrolment with a code from the authenticator app and
+// returns the backup codes, which are shown only this once
+func (s *twoFactorService) Enable(userID uint, code string) ([]string, error) {
+	var backupCodes []string
+	verified := false
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		twoFactorRepo := repositories.NewTwoFactorRepository(tx)
+
+		auth, err := twoFactorRepo.FindByUserForUpdate(userID)
+		if err != nil {
+			return err
+		}
+		if auth == nil {
+			return errors.New("start two-factor setup first")
+		}
+		if auth.EnabledAt != nil {
+			return errors.New("two-factor authentication is already enabled")
+		}
+
+		step, ok, err := s.checkTOTP(auth, code)
+		if err != nil || !ok {
+			return err
+		}
+		verified = true
+
+		now := time.Now()
+		auth.EnabledAt = &now
+		auth.LastUsedStep = step
+		if err := twoFactorRepo.Save(auth); err != nil {
+			return err
+		}
+
+		backupCodes, err = s.replaceBackupCodes(twoFactorRepo, userID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !verified {
+		return nil, ErrInvalidTwoFactorCode
+	}
+	return backupCodes, nil
+}
+
+// Disable turns two-factor authentication off after checking a current code
+func (s *twoFactorService) Disable(userID uint, code string) error {
+	return s.withVerifiedCode(userID, code, func(tx *gorm.DB) error {
+		return repositories.NewTwoFactorRepository(tx).DeleteForUser(userID)
+	})
+}
+
+// RegenerateBackupCodes replaces the backup codes after checking a current code
+func (s *twoFactorService) RegenerateBackupCodes(userID uint, code string) ([]string, error) {
+	var backupCodes []string
+	err := s.withVerifiedCode(userID, code, func(tx *gorm.DB) error {
+		var err error
+		backupCodes, err = s.replaceBackupCodes(repositories.NewTwoFactorRepository(tx), userID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return backupCodes, nil
+}
+
+// Reset removes a user's two-factor authentication without a code, for
+// admins helping a user who lost their authenticator and backup codes. The
+// user's sessions are revoked.
+func (s *twoFactorService) Reset(userID uint) error {
+	if _, err := s.userRepo.FindByID(userID); err != nil {
+		return err
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := repositories.NewTwoFactorRepository(tx).DeleteForUser(userID); err != nil {
+			return err
+		}
+		return revokeUserTokens(tx, userID)
+	})
+}
+
+// IsEnabled reports whether logging in requires a second factor
+func (s *twoFactorService) IsEnabled(userID uint) (bool, error) {
+	auth, err := s.twoFactorRepo.FindByUser(userID)
+	if err != nil {
+		return false, err
+	}
+	return auth != nil && auth.EnabledAt != nil, nil
+}
+
+// CreateChallenge starts the second login step for a user
+func (s *twoFactorService) CreateChallenge(userID uint) (*TwoFactorRequiredError, error) {
+	token, err := generateSecureToken()
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.twoFactorRepo.CreateChallenge(&models.TwoFactorChallenge{
+		UserID:    userID,
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().Add(s.settings.ChallengeTTL),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &TwoFactorRequiredError{ChallengeToken: token, ExpiresIn: s.settings.ChallengeTTL}, nil
+}
+
+// VerifyChallenge checks the code for a pending login and returns the user
+// it belongs to. Each challenge accepts one correct code and a limited number
+// of wrong ones; after that the user has to log in again. A wrong code still
+// returns the user ID, with ErrInvalidTwoFactorCode, so the caller can count
+// the failure against the account.
+func (s *twoFactorService) VerifyChallenge(token, code string) (uint, error) {
+	var userID uint
+	verified := false
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		twoFactorRepo := repositories.NewTwoFactorRepository(tx)
+
+		challenge, err := twoFactorRepo.FindChallengeForUpdate(hashToken(token))
+		if err != nil {
+			return err
+		}
+		userID = challenge.UserID
+
+		ok, err := s.verifyCode(tx, challenge.UserID, code)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			// Commit the failed attempt
+			if challenge.Attempts+1 >= s.settings.MaxChallengeAttempts {
+				return twoFactorRepo.DeleteChallenge(challenge.ID)
+			}
+			return twoFactorRepo.RecordChallengeFailure(challenge.ID)
+		}
+
+		verified = true
+		return twoFactorRepo.DeleteChallenge(challenge.ID)
+	})
+	if err != nil {
+		return 0, err
+	}
+	if !verified {
+		return userID, ErrInvalidTwoFactorCode
+	}
+	return userID, nil
+}
+
+// StartCleanup periodically deletes expired login challenges until ctx is cancelled
+func (s *twoFactorService) StartCleanup(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			count, err := s.twoFactorRepo.DeleteExpiredChallenges(time.Now())
+			if err != nil {
+				log.Printf("Two-factor challenge cleanup failed: %v", err)
+				continue
+			}
+			if count > 0 {
+				log.Printf("Two-factor challenge cleanup deleted %d challenge(s)", count)
+			}
+		}
+	}
+}
+
+// withVerifiedCode runs fn in the transaction that accepted a code of a user
+// with two-factor authentication enabled
+func (s *twoFactorService) withVerifiedCode(userID uint, code string, fn func(tx *gorm.DB) error) error {
+	verified := false
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		ok, err := s.verifyCode(tx, userID, code)
+		if err != nil || !ok {
+			return err
+		}
+		verified = true
+		return fn(tx)
+	})
+	if err != nil {
+		return err
+	}
+	if !verified {
+		return ErrInvalidTwoFactorCode
+	}
+	return nil
+}
+
+// verifyCode accepts a TOTP code or an unused backup code and consumes it
+func (s *twoFactorService) verifyCode(tx *gorm.DB, userID uint, code string) (bool, error) {
+	twoFactorRepo := repositories.NewTwoFactorRepository(tx)
+
+	auth, err := twoFactorRepo.FindByUserForUpdate(userID)
+	if err != nil {
+		return false, err
+	}
+	if auth == nil || auth.EnabledAt == nil {
+		return false, errors.New("two-factor authentication is not enabled")
+	}
+
+	code = normalizeTwoFactorCode(code)
+	if len(code) != backupCodeLength {
+		step, ok, err := s.checkTOTP(auth, code)
+		if err != nil || !ok {
+			return false, err
+		}
+		return true, twoFactorRepo.UpdateLastUsedStep(auth.ID, step)
+	}
+	return twoFactorRepo.UseBackupCode(userID, hashToken(code))
+}
+
+// checkTOTP validates a TOTP code that is newer than the last accepted one
+func (s *twoFactorService) checkTOTP(auth *models.TwoFactorAuth, code string) (int64, bool, error) {
+	secret, err := s.encryptor.Decrypt(auth.Secret)
+	if err != nil {
+		return 0, false, err
+	}
+	step, ok := utils.ValidateTOTP(secret, normalizeTwoFactorCode(code), time.Now())
+	if !ok || step <= auth.LastUsedStep {
+		return 0, false, nil
+	}
+	return step, true, nil
+}
+
+// replaceBackupCodes generates new backup codes, storing only their hashes
+func (s *twoFactorService) replaceBackupCodes(twoFactorRepo repositories.TwoFactorRepository, userID uint) ([]string, error) {
+	codes := make([]string, backupCodeCount)
+	hashes := make([]string, backupCodeCount)
+	for i := range codes {
+		code, err := generateBackupCode()
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = code[:backupCodeLength/2] + "-" + code[backupCodeLength/2:]
+		hashes[i] = hashToken(code)
+	}
+
+	if err := twoFactorRepo.ReplaceBackupCodes(userID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// generateBackupCode returns a random backup code
+func generateBackupCode() (string, error) {
+	buf := make([]byte, backupCodeLength)
+	max := big.NewInt(int64(len(backupCodeAlphabet)))
+	for i := range buf {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		buf[i] = backupCodeAlphabet[n.Int64()]
+	}
+	return string(buf), nil
+}
+
+// normalizeTwoFactorCode strips the separators users type or copy along with a code
+func normalizeTwoFactorCode(code string) string {
+	code = strings.NewReplacer(" ", "", "-", "").Replace(code)
+	return strings.ToUpper(code)
+}
@@ -0,0 +1,289 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/huy1235588/fashion-e-commerce/internal/models"
+	"github.com/huy1235588/fashion-e-commerce/internal/repositories"
+	"github.com/huy1235588/fashion-e-commerce/internal/utils"
+)
+
+// restockBatchSize caps how many back-in-stock emails one run sends
+const restockBatchSize = 100
+
+// ErrVariantRequired is returned when a wishlist item without a chosen
+// variant is moved to the cart and the request does not name one either
+var ErrVariantRequired = errors.New("please choose a size and color first")
+
+// AddToWishlistRequest represents saving a product to the wishlist
+type AddToWishlistRequest struct {
+	ProductID uint  `json:"product_id" binding:"required"`
+	VariantID *uint `json:"variant_id"`
+}
+
+// MoveToCartRequest represents moving a wishlist item to the cart. VariantID
+// overrides the variant saved with the item.
+type MoveToCartRequest struct {
+	VariantID *uint `json:"variant_id"`
+	Quantity  int   `json:"quantity" binding:"omitempty,min=1"`
+}
+
+// WishlistService manages saved products and back-in-stock alerts
+type WishlistService interface {
+	List(userID uint) ([]models.Wishlist, error)
+	Add(userID uint, req AddToWishlistRequest) (*models.Wishlist, error)
+	Remove(userID, id uint) error
+	MoveToCart(userID, id uint, req MoveToCartRequest) (*models.Cart, error)
+
+	ListRestockAlerts(userID uint) ([]models.RestockSubscription, error)
+	SubscribeRestock(userID, variantID uint) (*models.RestockSubscription, error)
+	UnsubscribeRestock(userID, variantID uint) error
+	NotifyRestocked(variantID uint)
+	StartRestockNotifier(ctx context.Context, interval time.Duration)
+}
+
+type wishlistService struct {
+	wishlistRepo repositories.WishlistRepository
+	productRepo  repositories.ProductRepository
+	cartService  *CartService
+	emailService *utils.EmailService
+	productURL   string
+
+	// notifyMu keeps the worker and NotifyRestocked from mailing the same
+	// subscription twice
+	notifyMu sync.Mutex
+}
+
+// NewWishlistService creates a new wishlist service. productURL is the
+// frontend address products are linked from, e.g. https://shop.example/products.
+func NewWishlistService(
+	wishlistRepo repositories.WishlistRepository,
+	productRepo repositories.ProductRepository,
+	cartService *CartService,
+	emailService *utils.EmailService,
+	productURL string,
+) WishlistService {
+	return &wishlistService{
+		wishlistRepo: wishlistRepo,
+		productRepo:  productRepo,
+		cartService:  cartService,
+		emailService: emailService,
+		productURL:   strings.TrimRight(productURL, "/"),
+	}
+}
+
+// List returns a user's wishlist
+func (s *wishlistService) List(userID uint) ([]models.Wishlist, error) {
+	return s.wishlistRepo.ListByUser(userID)
+}
+
+// Add saves a product to the wishlist. Saving a product that is already
+// there only updates the chosen variant.
+func (s *wishlistService) Add(userID uint, req AddToWishlistRequest) (*models.Wishlist, error) {
+	product, err := s.productRepo.FindByID(req.ProductID)
+	if err != nil || !product.IsActive {
+		return nil, errors.New("product not found")
+	}
+
+	if req.VariantID != nil {
+		if _, variant := findCartVariant(s.productRepo, product.ID, *req.VariantID); variant == nil {
+			return nil, errors.New("variant not found")
+		}
+	}
+
+	item, err := s.wishlistRepo.FindByUserAndProduct(userID, req.ProductID)
+	if err != nil {
+		return nil, err
+	}
+
+	if item != nil {
+		item.VariantID = req.VariantID
+		if err := s.wishlistRepo.Update(item); err != nil {
+			return nil, err
+		}
+	} else {
+		item = &models.Wishlist{
+			UserID:    userID,
+			ProductID: req.ProductID,
+			VariantID: req.VariantID,
+		}
+		if err := s.wishlistRepo.Create(item); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.wishlistRepo.FindByID(item.ID)
+}
+
+// Remove deletes an item from the user's wishlist
+func (s *wishlistService) Remove(userID, id uint) error {
+	if _, err := s.findOwned(userID, id); err != nil {
+		return err
+	}
+	return s.wishlistRepo.Delete(id)
+}
+
+// MoveToCart adds a wishlist item to the user's cart and removes it from
+// the wishlist
+func (s *wishlistService) MoveToCart(userID, id uint, req MoveToCartRequest) (*models.Cart, error) {
+	item, err := s.findOwned(userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	variantID := item.VariantID
+	if req.VariantID != nil {
+		variantID = req.VariantID
+	}
+	if variantID == nil {
+		return nil, ErrVariantRequired
+	}
+
+	quantity := req.Quantity
+	if quantity == 0 {
+		quantity = 1
+	}
+
+	cart, err := s.cartService.AddToCart(userID, item.ProductID, *variantID, quantity)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.wishlistRepo.Delete(item.ID); err != nil {
+		return nil, err
+	}
+
+	return cart, nil
+}
+
+// ListRestockAlerts returns the variants a user asked to be told about
+func (s *wishlistService) ListRestockAlerts(userID uint) ([]models.RestockSubscription, error) {
+	return s.wishlistRepo.ListSubscriptions(userID)
+}
+
+// SubscribeRestock asks for an email when an out-of-stock variant is back
+func (s *wishlistService) SubscribeRestock(userID, variantID uint) (*models.RestockSubscription, error) {
+	variant, err := s.productRepo.FindVariantByID(variantID)
+	if err != nil {
+		return nil, err
+	}
+	if variant.StockQuantity > 0 {
+		return nil, errors.New("variant is in stock")
+	}
+
+	existing, err := s.wishlistRepo.FindSubscription(userID, variantID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		existing.Variant = variant
+		return existing, nil
+	}
+
+	subscription := &models.RestockSubscription{
+		UserID:    userID,
+		VariantID: variantID,
+	}
+	if err := s.wishlistRepo.CreateSubscription(subscription); err != nil {
+		return nil, err
+	}
+	subscription.Variant = variant
+
+	return subscription, nil
+}
+
+// UnsubscribeRestock cancels a back-in-stock alert
+func (s *wishlistService) UnsubscribeRestock(userID, variantID uint) error {
+	return s.wishlistRepo.DeleteSubscription(userID, variantID)
+}
+
+// NotifyRestocked emails the users waiting for a variant that has stock again
+func (s *wishlistService) NotifyRestocked(variantID uint) {
+	s.notifyDue(&variantID)
+}
+
+// StartRestockNotifier periodically emails subscribers of every variant
+// that has stock again until ctx is cancelled. It picks up restocks made
+// outside UpdateProductVariant, such as ledger adjustments and cancelled
+// orders, and retries emails that failed before.
+func (s *wishlistService) StartRestockNotifier(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.notifyDue(nil)
+		}
+	}
+}
+
+// notifyDue sends the back-in-stock emails that are due and deletes the
+// subscriptions that were mailed. Failed emails stay for the next run.
+func (s *wishlistService) notifyDue(variantID *uint) {
+	s.notifyMu.Lock()
+	defer s.notifyMu.Unlock()
+
+	subscriptions, err := s.wishlistRepo.FindDueSubscriptions(variantID, restockBatchSize)
+	if err != nil {
+		log.Printf("Failed to load restock subscriptions: %v", err)
+		return
+	}
+
+	products := make(map[uint]*models.Product)
+	sent := 0
+	for _, subscription := range subscriptions {
+		if subscription.User == nil || subscription.Variant == nil {
+			continue
+		}
+
+		product, ok := products[subscription.Variant.ProductID]
+		if !ok {
+			product, err = s.productRepo.FindByID(subscription.Variant.ProductID)
+			if err != nil {
+				product = nil
+			}
+			products[subscription.Variant.ProductID] = product
+		}
+		if product == nil || !product.IsActive {
+			continue
+		}
+
+		variantName := fmt.Sprintf("%s / %s", subscription.Variant.Size, subscription.Variant.Color)
+		link := fmt.Sprintf("%s/%d", s.productURL, product.ID)
+		if err := s.emailService.SendBackInStockEmail(subscription.User.Email, subscription.User.FullName, product.Name, variantName, link); err != nil {
+			log.Printf("Failed to send back-in-stock email for subscription %d: %v", subscription.ID, err)
+			continue
+		}
+
+		if err := s.wishlistRepo.DeleteSubscriptionByID(subscription.ID); err != nil {
+			log.Printf("Failed to delete restock subscription %d: %v", subscription.ID, err)
+			continue
+		}
+		sent++
+	}
+
+	if sent > 0 {
+		log.Printf("Sent %d back-in-stock email(s)", sent)
+	}
+}
+
+// findOwned loads a wishlist item and checks it belongs to the user
+func (s *wishlistService) findOwned(userID, id uint) (*models.Wishlist, error) {
+	item, err := s.wishlistRepo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if item.UserID != userID {
+		return nil, errors.New("wishlist item not found")
+	}
+	return item, nil
+}
@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+// ErrInvalidCartToken is returned for cart tokens that were not issued by
+// this server
+var ErrInvalidCartToken = errors.New("invalid cart token")
+
+// CartTokenSigner issues and checks the tokens that identify anonymous
+// carts. A token is the cart's guest key followed by an HMAC of it, so
+// clients cannot guess or forge the key of somebody else's cart.
+type CartTokenSigner struct {
+	secret []byte
+}
+
+// NewCartTokenSigner creates a signer using the given secret
+func NewCartTokenSigner(secret string) *CartTokenSigner {
+	return &CartTokenSigner{secret: []byte(secret)}
+}
+
+// Sign returns the token for a guest key
+func (s *CartTokenSigner) Sign(guestKey string) string {
+	return guestKey + "." + base64.RawURLEncoding.EncodeToString(s.mac(guestKey))
+}
+
+// Verify checks a token and returns the guest key it was issued for
+func (s *CartTokenSigner) Verify(token string) (string, error) {
+	guestKey, signature, ok := strings.Cut(token, ".")
+	if !ok || guestKey == "" {
+		return "", ErrInvalidCartToken
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(sig, s.mac(guestKey)) {
+		return "", ErrInvalidCartToken
+	}
+	return guestKey, nil
+}
+
+func (s *CartTokenSigner) mac(guestKey string) []byte {
+	h := hmac.New(sha256.New, s.secret)
+	h.Write([]byte("cart:" + guestKey))
+	return h.Sum(nil)
+}
@@ -51,16 +51,79 @@ func (e *EmailService) SendEmail(to, subject, htmlBody string) error {
 }
 
 // SendPasswordResetEmail sends a password reset email with OTP code
-func (e *EmailService) SendPasswordResetEmail(email, code string) error {
+func (e *EmailService) SendPasswordResetEmail(email, code string, expiresInMinutes int) error {
 	subject := "Đặt lại mật khẩu - Fashion E-Commerce"
 
 	data := map[string]any{
-		"Code": code,
+		"Code":             code,
+		"ExpiresInMinutes": expiresInMinutes,
 	}
 
 	htmlBody, err := e.renderTemplate("password_reset.html", data)
 	if err != nil {
-		htmlBody = fmt.Sprintf(passwordResetFallbackTemplate, code)
+		htmlBody = fmt.Sprintf(passwordResetFallbackTemplate, code, expiresInMinutes)
+	}
+
+	return e.SendEmail(email, subject, htmlBody)
+}
+
+// SendVerificationEmail sends the link a new user follows to confirm their email address
+func (e *EmailService) SendVerificationEmail(email, fullName, link string, expiresInHours int) error {
+	subject := "Xác nhận địa chỉ email - Fashion E-Commerce"
+
+	data := map[string]any{
+		"FullName":       fullName,
+		"Link":           link,
+		"ExpiresInHours": expiresInHours,
+	}
+
+	htmlBody, err := e.renderTemplate("email_verification.html", data)
+	if err != nil {
+		htmlBody = fmt.Sprintf(emailVerificationFallbackTemplate, template.HTMLEscapeString(fullName), link, link, expiresInHours)
+	}
+
+	return e.SendEmail(email, subject, htmlBody)
+}
+
+// SendAccountLockedEmail tells a user their account was locked after repeated
+// failed logins and sends the link that unlocks it
+func (e *EmailService) SendAccountLockedEmail(email, fullName, link string, lockedMinutes int) error {
+	subject := "Tài khoản tạm thời bị khóa - Fashion E-Commerce"
+
+	data := map[string]any{
+		"FullName":      fullName,
+		"Link":          link,
+		"LockedMinutes": lockedMinutes,
+	}
+
+	htmlBody, err := e.renderTemplate("account_locked.html", data)
+	if err != nil {
+		htmlBody = fmt.Sprintf(accountLockedFallbackTemplate, template.HTMLEscapeString(fullName), lockedMinutes, link, link)
+	}
+
+	return e.SendEmail(email, subject, htmlBody)
+}
+
+// SendBackInStockEmail tells a user that a product variant they asked about
+// can be ordered again
+func (e *EmailService) SendBackInStockEmail(email, fullName, productName, variantName, link string) error {
+	subject := "Sản phẩm đã có hàng trở lại - Fashion E-Commerce"
+
+	data := map[string]any{
+		"FullName":    fullName,
+		"ProductName": productName,
+		"VariantName": variantName,
+		"Link":        link,
+	}
+
+	htmlBody, err := e.renderTemplate("back_in_stock.html", data)
+	if err != nil {
+		htmlBody = fmt.Sprintf(backInStockFallbackTemplate,
+			template.HTMLEscapeString(fullName),
+			template.HTMLEscapeString(productName),
+			template.HTMLEscapeString(variantName),
+			link,
+		)
 	}
 
 	return e.SendEmail(email, subject, htmlBody)
@@ -111,7 +174,7 @@ func (e *EmailService) SendOrderConfirmationEmail(order *models.Order) error {
 	}
 
 	data := map[string]any{
-		"FullName":        order.User.FullName,
+		"FullName":        order.ContactName(),
 		"OrderCode":       order.OrderCode,
 		"Status":          statusText[string(order.Status)],
 		"PaymentMethod":   paymentMethod,
@@ -120,13 +183,16 @@ func (e *EmailService) SendOrderConfirmationEmail(order *models.Order) error {
 		"ItemsHTML":       template.HTML(itemsHTML.String()),
 		"Subtotal":        formatCurrency(order.SubtotalAmount),
 		"ShippingFee":     formatCurrency(order.ShippingFee),
+		"HasDiscount":     order.DiscountAmount > 0,
+		"PromotionCode":   order.PromotionCode,
+		"DiscountAmount":  formatCurrency(order.DiscountAmount),
 		"TotalAmount":     formatCurrency(order.TotalAmount),
 	}
 
 	htmlBody, err := e.renderTemplate("order_confirmation.html", data)
 	if err != nil {
 		htmlBody = fmt.Sprintf(orderConfirmationFallbackTemplate,
-			order.User.FullName,
+			order.ContactName(),
 			order.OrderCode,
 			statusText[string(order.Status)],
 			paymentMethod,
@@ -137,7 +203,7 @@ func (e *EmailService) SendOrderConfirmationEmail(order *models.Order) error {
 		)
 	}
 
-	return e.SendEmail(order.User.Email, subject, htmlBody)
+	return e.SendEmail(order.ContactEmail(), subject, htmlBody)
 }
 
 // formatCurrency formats a float64 as Vietnamese currency
@@ -199,7 +265,7 @@ const passwordResetFallbackTemplate = `
 			<p>Bạn nhận được email này vì đã yêu cầu đặt lại mật khẩu cho tài khoản Fashion E-Commerce của bạn.</p>
 			<p>Mã xác thực của bạn là:</p>
 			<div class="code">%s</div>
-			<p>Mã này sẽ hết hạn sau <strong>15 phút</strong>.</p>
+			<p>Mã này sẽ hết hạn sau <strong>%d phút</strong>.</p>
 			<p class="warning">Nếu bạn không yêu cầu đặt lại mật khẩu, vui lòng bỏ qua email này.</p>
 		</div>
 		<div class="footer">
@@ -210,6 +276,109 @@ const passwordResetFallbackTemplate = `
 </html>
 `
 
+// emailVerificationFallbackTemplate is used when template files are not available
+const emailVerificationFallbackTemplate = `
+<!DOCTYPE html>
+<html>
+<head>
+	<meta charset="UTF-8">
+	<style>
+		body { font-family: Arial, sans-serif; line-height: 1.6; color: #333; }
+		.container { max-width: 600px; margin: 0 auto; padding: 20px; background-color: #f9f9f9; }
+		.content { background-color: white; padding: 30px; border-radius: 5px; }
+		.button { display: inline-block; background-color: #2563eb; color: white !important; padding: 12px 30px; text-decoration: none; font-weight: bold; border-radius: 5px; margin: 20px 0; }
+		.link { word-break: break-all; font-size: 13px; color: #2563eb; }
+		.warning { color: #dc2626; font-size: 14px; margin-top: 20px; }
+		.footer { text-align: center; margin-top: 30px; font-size: 12px; color: #666; }
+	</style>
+</head>
+<body>
+	<div class="container">
+		<div class="content">
+			<h2>Xác nhận địa chỉ email</h2>
+			<p>Xin chào <strong>%s</strong>,</p>
+			<p>Cảm ơn bạn đã đăng ký tài khoản Fashion E-Commerce. Vui lòng xác nhận địa chỉ email của bạn bằng cách nhấn vào nút bên dưới:</p>
+			<p style="text-align: center;"><a class="button" href="%s">Xác nhận email</a></p>
+			<p>Hoặc sao chép liên kết sau vào trình duyệt:</p>
+			<p class="link">%s</p>
+			<p>Liên kết này sẽ hết hạn sau <strong>%d giờ</strong>.</p>
+			<p class="warning">Nếu bạn không đăng ký tài khoản, vui lòng bỏ qua email này.</p>
+		</div>
+		<div class="footer">
+			<p>© 2024 Fashion E-Commerce. All rights reserved.</p>
+		</div>
+	</div>
+</body>
+</html>
+`
+
+// accountLockedFallbackTemplate is used when template files are not available
+const accountLockedFallbackTemplate = `
+<!DOCTYPE html>
+<html>
+<head>
+	<meta charset="UTF-8">
+	<style>
+		body { font-family: Arial, sans-serif; line-height: 1.6; color: #333; }
+		.container { max-width: 600px; margin: 0 auto; padding: 20px; background-color: #f9f9f9; }
+		.content { background-color: white; padding: 30px; border-radius: 5px; }
+		.button { display: inline-block; background-color: #2563eb; color: white !important; padding: 12px 30px; text-decoration: none; font-weight: bold; border-radius: 5px; margin: 20px 0; }
+		.link { word-break: break-all; font-size: 13px; color: #2563eb; }
+		.warning { color: #dc2626; font-size: 14px; margin-top: 20px; }
+		.footer { text-align: center; margin-top: 30px; font-size: 12px; color: #666; }
+	</style>
+</head>
+<body>
+	<div class="container">
+		<div class="content">
+			<h2>Tài khoản tạm thời bị khóa</h2>
+			<p>Xin chào <strong>%s</strong>,</p>
+			<p>Chúng tôi đã tạm khóa đăng nhập vào tài khoản Fashion E-Commerce của bạn sau nhiều lần nhập sai mật khẩu liên tiếp.</p>
+			<p>Tài khoản sẽ tự động được mở lại sau <strong>%d phút</strong>. Nếu chính bạn đã nhập sai, bạn có thể mở khóa ngay:</p>
+			<p style="text-align: center;"><a class="button" href="%s">Mở khóa tài khoản</a></p>
+			<p>Hoặc sao chép liên kết sau vào trình duyệt:</p>
+			<p class="link">%s</p>
+			<p class="warning">Nếu bạn không thực hiện các lần đăng nhập này, hãy đổi mật khẩu ngay sau khi mở khóa.</p>
+		</div>
+		<div class="footer">
+			<p>© 2024 Fashion E-Commerce. All rights reserved.</p>
+		</div>
+	</div>
+</body>
+</html>
+`
+
+// backInStockFallbackTemplate is used when template files are not available
+const backInStockFallbackTemplate = `
+<!DOCTYPE html>
+<html>
+<head>
+	<meta charset="UTF-8">
+	<style>
+		body { font-family: Arial, sans-serif; line-height: 1.6; color: #333; }
+		.container { max-width: 600px; margin: 0 auto; padding: 20px; background-color: #f9f9f9; }
+		.content { background-color: white; padding: 30px; border-radius: 5px; }
+		.button { display: inline-block; background-color: #2563eb; color: white !important; padding: 12px 30px; text-decoration: none; font-weight: bold; border-radius: 5px; margin: 20px 0; }
+		.footer { text-align: center; margin-top: 30px; font-size: 12px; color: #666; }
+	</style>
+</head>
+<body>
+	<div class="container">
+		<div class="content">
+			<h2>Sản phẩm đã có hàng trở lại</h2>
+			<p>Xin chào <strong>%s</strong>,</p>
+			<p>Sản phẩm <strong>%s</strong> (%s) mà bạn đăng ký nhận thông báo đã có hàng trở lại.</p>
+			<p>Số lượng có hạn, hãy đặt hàng sớm để không bỏ lỡ.</p>
+			<p style="text-align: center;"><a class="button" href="%s">Xem sản phẩm</a></p>
+		</div>
+		<div class="footer">
+			<p>© 2024 Fashion E-Commerce. All rights reserved.</p>
+		</div>
+	</div>
+</body>
+</html>
+`
+
 // orderConfirmationFallbackTemplate is used when template files are not available
 const orderConfirmationFallbackTemplate = `
 <!DOCTYPE html>
@@ -9,9 +9,12 @@ import (
 
 // JWTClaims represents the JWT claims structure
 type JWTClaims struct {
-	UserID uint   `json:"user_id"`
-	Email  string `json:"email"`
-	Role   string `json:"role"`
+	UserID       uint   `json:"user_id"`
+	Email        string `json:"email"`
+	Role         string `json:"role"`
+	SessionID    string `json:"sid"` // refresh token session the access token belongs to
+	TokenVersion int    `json:"ver"`
+	TwoFactor    bool   `json:"mfa,omitempty"` // the session passed two-factor authentication
 	jwt.RegisteredClaims
 }
 
@@ -29,12 +32,15 @@ func NewJWTUtil(secretKey string, expiresIn time.Duration) *JWTUtil {
 	}
 }
 
-// GenerateToken generates a new JWT token for a user
-func (j *JWTUtil) GenerateToken(userID uint, email, role string) (string, error) {
+// GenerateToken generates a new short-lived access token for a user session
+func (j *JWTUtil) GenerateToken(userID uint, email, role, sessionID string, tokenVersion int, twoFactor bool) (string, error) {
 	claims := JWTClaims{
-		UserID: userID,
-		Email:  email,
-		Role:   role,
+		UserID:       userID,
+		Email:        email,
+		Role:         role,
+		SessionID:    sessionID,
+		TokenVersion: tokenVersion,
+		TwoFactor:    twoFactor,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(j.expiresIn)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -46,6 +52,11 @@ func (j *JWTUtil) GenerateToken(userID uint, email, role string) (string, error)
 	return token.SignedString(j.secretKey)
 }
 
+// ExpiresIn returns the lifetime of access tokens
+func (j *JWTUtil) ExpiresIn() time.Duration {
+	return j.expiresIn
+}
+
 // ValidateToken validates a JWT token and returns the claims
 func (j *JWTUtil) ValidateToken(tokenString string) (*JWTClaims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
@@ -0,0 +1,218 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// fakeMoMo is a local stand-in for the MoMo create-payment API. It records
+// the last request and answers with the configured result code.
+type fakeMoMo struct {
+	t          *testing.T
+	resultCode int
+	lastBody   MoMoCreatePaymentRequest
+}
+
+func (f *fakeMoMo) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		f.t.Errorf("method = %s, want POST", r.Method)
+	}
+	if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+		f.t.Errorf("Content-Type = %q, want application/json", ct)
+	}
+	if err := json.NewDecoder(r.Body).Decode(&f.lastBody); err != nil {
+		f.t.Errorf("decode request: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	response := MoMoCreatePaymentResponse{
+		PartnerCode: f.lastBody.PartnerCode,
+		RequestID:   f.lastBody.RequestID,
+		OrderID:     f.lastBody.OrderID,
+		Amount:      f.lastBody.Amount,
+		ResultCode:  f.resultCode,
+		Message:     "Thành công.",
+	}
+	if f.resultCode == 0 {
+		response.PayURL = "https://test-payment.momo.vn/pay/" + f.lastBody.OrderID
+		response.Deeplink = "momo://pay/" + f.lastBody.OrderID
+	} else {
+		response.Message = "Giao dịch bị từ chối."
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+func newTestMoMo(t *testing.T, resultCode int) (*MoMoHelper, *fakeMoMo) {
+	t.Helper()
+	fake := &fakeMoMo{t: t, resultCode: resultCode}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	helper := NewMoMoHelper(MoMoConfig{
+		PartnerCode: "MOMOTEST",
+		AccessKey:   "access-key",
+		SecretKey:   "secret-key",
+		PaymentURL:  server.URL,
+		IPNUrl:      "https://shop.test/api/v1/payments/momo/ipn",
+		ReturnURL:   "https://shop.test/payment/result",
+	})
+	return helper, fake
+}
+
+func TestMoMoCreatePaymentSignature(t *testing.T) {
+	helper, fake := newTestMoMo(t, 0)
+
+	if _, err := helper.CreatePayment("ORD001", "req-1", 150000, "Thanh toán đơn hàng ORD001"); err != nil {
+		t.Fatalf("CreatePayment: %v", err)
+	}
+
+	raw := fmt.Sprintf("accessKey=%s&amount=%d&extraData=&ipnUrl=%s&orderId=%s&orderInfo=%s&partnerCode=%s&redirectUrl=%s&requestId=%s&requestType=captureWallet",
+		"access-key", 150000, "https://shop.test/api/v1/payments/momo/ipn", "ORD001",
+		"Thanh toán đơn hàng ORD001", "MOMOTEST", "https://shop.test/payment/result", "req-1")
+	mac := hmac.New(sha256.New, []byte("secret-key"))
+	mac.Write([]byte(raw))
+	want := hex.EncodeToString(mac.Sum(nil))
+
+	if fake.lastBody.Signature != want {
+		t.Errorf("signature = %s, want %s", fake.lastBody.Signature, want)
+	}
+}
+
+func TestMoMoCreatePaymentRequestBody(t *testing.T) {
+	helper, fake := newTestMoMo(t, 0)
+
+	if _, err := helper.CreatePayment("ORD002", "req-2", 99000, "Thanh toán đơn hàng ORD002"); err != nil {
+		t.Fatalf("CreatePayment: %v", err)
+	}
+
+	body := fake.lastBody
+	checks := []struct {
+		field, got, want string
+	}{
+		{"partnerCode", body.PartnerCode, "MOMOTEST"},
+		{"requestId", body.RequestID, "req-2"},
+		{"orderId", body.OrderID, "ORD002"},
+		{"orderInfo", body.OrderInfo, "Thanh toán đơn hàng ORD002"},
+		{"redirectUrl", body.RedirectURL, "https://shop.test/payment/result"},
+		{"ipnUrl", body.IpnURL, "https://shop.test/api/v1/payments/momo/ipn"},
+		{"requestType", body.RequestType, "captureWallet"},
+		{"lang", body.Lang, "vi"},
+	}
+	for _, c := range checks {
+		if c.got != c.want {
+			t.Errorf("%s = %q, want %q", c.field, c.got, c.want)
+		}
+	}
+	if body.Amount != 99000 {
+		t.Errorf("amount = %d, want 99000", body.Amount)
+	}
+}
+
+func TestMoMoCreatePaymentSuccess(t *testing.T) {
+	helper, _ := newTestMoMo(t, 0)
+
+	result, err := helper.CreatePayment("ORD003", "req-3", 200000, "Thanh toán đơn hàng ORD003")
+	if err != nil {
+		t.Fatalf("CreatePayment: %v", err)
+	}
+	if result.PayURL != "https://test-payment.momo.vn/pay/ORD003" {
+		t.Errorf("payUrl = %q", result.PayURL)
+	}
+	if result.Deeplink != "momo://pay/ORD003" {
+		t.Errorf("deeplink = %q", result.Deeplink)
+	}
+}
+
+func TestMoMoCreatePaymentResultCode(t *testing.T) {
+	helper, _ := newTestMoMo(t, 22)
+
+	result, err := helper.CreatePayment("ORD004", "req-4", 10, "Thanh toán đơn hàng ORD004")
+	var momoErr *MoMoError
+	if !errors.As(err, &momoErr) {
+		t.Fatalf("err = %v, want *MoMoError", err)
+	}
+	if momoErr.ResultCode != 22 {
+		t.Errorf("resultCode = %d, want 22", momoErr.ResultCode)
+	}
+	if momoErr.Message != "amount is out of the allowed range" {
+		t.Errorf("message = %q", momoErr.Message)
+	}
+	if result == nil || result.ResultCode != 22 {
+		t.Errorf("response should be returned with the error for support lookups, got %+v", result)
+	}
+}
+
+// newSignedMoMo serves a fixed query and refund response, signed with
+// secretKey the way MoMo signs them
+func newSignedMoMo(t *testing.T, secretKey string) *MoMoHelper {
+	t.Helper()
+	sign := func(raw string) string {
+		mac := hmac.New(sha256.New, []byte(secretKey))
+		mac.Write([]byte(raw))
+		return hex.EncodeToString(mac.Sum(nil))
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/query", func(w http.ResponseWriter, r *http.Request) {
+		response := MoMoQueryResponse{
+			PartnerCode: "MOMOTEST", OrderID: "ORD005", RequestID: "req-5", Amount: 150000,
+			TransID: 4088878653, PayType: "qr", Message: "Thành công.", ResponseTime: 1700000000000,
+		}
+		response.Signature = sign(fmt.Sprintf("accessKey=access-key&amount=%d&extraData=&message=%s&orderId=%s&partnerCode=MOMOTEST&payType=%s&requestId=%s&responseTime=%d&resultCode=0&transId=%d",
+			response.Amount, response.Message, response.OrderID, response.PayType, response.RequestID, response.ResponseTime, response.TransID))
+		json.NewEncoder(w).Encode(response)
+	})
+	mux.HandleFunc("/refund", func(w http.ResponseWriter, r *http.Request) {
+		response := MoMoRefundResponse{
+			PartnerCode: "MOMOTEST", OrderID: "ORD005-RF1", RequestID: "req-6", Amount: 50000,
+			TransID: 4088878700, Message: "Thành công.", ResponseTime: 1700000000000,
+		}
+		response.Signature = sign(fmt.Sprintf("accessKey=access-key&amount=%d&message=%s&orderId=%s&partnerCode=MOMOTEST&requestId=%s&responseTime=%d&resultCode=0&transId=%d",
+			response.Amount, response.Message, response.OrderID, response.RequestID, response.ResponseTime, response.TransID))
+		json.NewEncoder(w).Encode(response)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	return NewMoMoHelper(MoMoConfig{
+		PartnerCode: "MOMOTEST",
+		AccessKey:   "access-key",
+		SecretKey:   "secret-key",
+		QueryURL:    server.URL + "/query",
+		RefundURL:   server.URL + "/refund",
+	})
+}
+
+func TestMoMoResponseSignature(t *testing.T) {
+	helper := newSignedMoMo(t, "secret-key")
+
+	query, err := helper.QueryTransaction("ORD005", "req-5")
+	if err != nil {
+		t.Fatalf("QueryTransaction: %v", err)
+	}
+	if query.TransID != 4088878653 {
+		t.Errorf("transId = %d", query.TransID)
+	}
+	if _, err := helper.Refund("ORD005-RF1", "req-6", 50000, 4088878653, "Hoàn tiền"); err != nil {
+		t.Fatalf("Refund: %v", err)
+	}
+}
+
+func TestMoMoResponseSignatureMismatch(t *testing.T) {
+	helper := newSignedMoMo(t, "someone-elses-key")
+
+	if _, err := helper.QueryTransaction("ORD005", "req-5"); err == nil {
+		t.Error("a query response with a bad signature must be rejected")
+	}
+	if _, err := helper.Refund("ORD005-RF1", "req-6", 50000, 4088878653, "Hoàn tiền"); err == nil {
+		t.Error("a refund response with a bad signature must be rejected")
+	}
+}
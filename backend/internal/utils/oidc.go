@@ -0,0 +1,377 @@
+package utils
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// OIDCProviderConfig describes an OAuth2 / OpenID Connect identity provider.
+// Providers that issue ID tokens are verified against JWKSURL; providers that
+// don't (Facebook) are identified through UserInfoURL instead.
+type OIDCProviderConfig struct {
+	Name         string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	AuthURL      string
+	TokenURL     string
+	UserInfoURL  string
+	JWKSURL      string
+	Issuer       string
+	Scopes       []string
+	SubjectField string // userinfo field holding the account ID, "sub" if empty
+	TrustEmail   bool   // the provider only returns confirmed emails but no email_verified claim
+}
+
+// ExternalIdentity is the account a user proved they own at an identity provider
+type ExternalIdentity struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// OIDCProvider runs the authorization-code flow with PKCE against one provider
+type OIDCProvider struct {
+	config     OIDCProviderConfig
+	httpClient *http.Client
+
+	mu        sync.Mutex
+	keys      map[string]*rsa.PublicKey
+	keysFetch time.Time
+}
+
+// NewOIDCProvider creates a provider client. A nil httpClient uses a client
+// with a 15 second timeout.
+func NewOIDCProvider(config OIDCProviderConfig, httpClient *http.Client) *OIDCProvider {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 15 * time.Second}
+	}
+	if config.SubjectField == "" {
+		config.SubjectField = "sub"
+	}
+	return &OIDCProvider{config: config, httpClient: httpClient}
+}
+
+// Name returns the provider name used in URLs and stored identities
+func (p *OIDCProvider) Name() string {
+	return p.config.Name
+}
+
+// AuthCodeURL returns the provider login page the user is sent to
+func (p *OIDCProvider) AuthCodeURL(state, nonce, codeVerifier string) string {
+	params := url.Values{}
+	params.Set("response_type", "code")
+	params.Set("client_id", p.config.ClientID)
+	params.Set("redirect_uri", p.config.RedirectURL)
+	params.Set("scope", strings.Join(p.config.Scopes, " "))
+	params.Set("state", state)
+	params.Set("code_challenge", PKCEChallenge(codeVerifier))
+	params.Set("code_challenge_method", "S256")
+	if p.config.JWKSURL != "" {
+		params.Set("nonce", nonce)
+	}
+
+	separator := "?"
+	if strings.Contains(p.config.AuthURL, "?") {
+		separator = "&"
+	}
+	return p.config.AuthURL + separator + params.Encode()
+}
+
+// oidcTokenResponse is the token endpoint response
+type oidcTokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	IDToken          string `json:"id_token"`
+	TokenType        string `json:"token_type"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// Identify exchanges an authorization code for tokens and returns the
+// identity they prove
+func (p *OIDCProvider) Identify(ctx context.Context, code, codeVerifier, nonce string) (*ExternalIdentity, error) {
+	tokens, err := p.exchange(ctx, code, codeVerifier)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.config.JWKSURL != "" {
+		if tokens.IDToken == "" {
+			return nil, errors.New("provider did not return an ID token")
+		}
+		return p.verifyIDToken(ctx, tokens.IDToken, nonce)
+	}
+	if tokens.AccessToken == "" {
+		return nil, errors.New("provider did not return an access token")
+	}
+	return p.fetchUserInfo(ctx, tokens.AccessToken)
+}
+
+// exchange redeems an authorization code at the token endpoint
+func (p *OIDCProvider) exchange(ctx context.Context, code, codeVerifier string) (*oidcTokenResponse, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.config.RedirectURL)
+	form.Set("client_id", p.config.ClientID)
+	form.Set("client_secret", p.config.ClientSecret)
+	form.Set("code_verifier", codeVerifier)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.config.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	var tokens oidcTokenResponse
+	status, err := p.doJSON(req, &tokens)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK || tokens.Error != "" {
+		return nil, fmt.Errorf("token exchange failed (HTTP %d): %s %s", status, tokens.Error, tokens.ErrorDescription)
+	}
+	return &tokens, nil
+}
+
+// oidcIDTokenClaims are the ID token claims we rely on
+type oidcIDTokenClaims struct {
+	Email         string      `json:"email"`
+	EmailVerified interface{} `json:"email_verified"` // some providers send "true" as a string
+	Name          string      `json:"name"`
+	Nonce         string      `json:"nonce"`
+	jwt.RegisteredClaims
+}
+
+// verifyIDToken checks the signature, issuer, audience, expiry and nonce of an ID token
+func (p *OIDCProvider) verifyIDToken(ctx context.Context, rawToken, nonce string) (*ExternalIdentity, error) {
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{"RS256"}),
+		jwt.WithAudience(p.config.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Minute),
+	}
+
+	var claims oidcIDTokenClaims
+	_, err := jwt.ParseWithClaims(rawToken, &claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.signingKey(ctx, kid)
+	}, options...)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ID token: %w", err)
+	}
+
+	if !p.validIssuer(claims.Issuer) {
+		return nil, errors.New("invalid ID token: unexpected issuer")
+	}
+	if nonce == "" || claims.Nonce != nonce {
+		return nil, errors.New("invalid ID token: nonce mismatch")
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("invalid ID token: missing subject")
+	}
+
+	return &ExternalIdentity{
+		Provider:      p.config.Name,
+		Subject:       claims.Subject,
+		Email:         strings.ToLower(strings.TrimSpace(claims.Email)),
+		EmailVerified: claimIsTrue(claims.EmailVerified),
+		Name:          claims.Name,
+	}, nil
+}
+
+// validIssuer compares issuers, accepting them with or without the https://
+// scheme since Google uses both forms
+func (p *OIDCProvider) validIssuer(issuer string) bool {
+	if p.config.Issuer == "" {
+		return true
+	}
+	return strings.TrimPrefix(issuer, "https://") == strings.TrimPrefix(p.config.Issuer, "https://")
+}
+
+// fetchUserInfo identifies the user with the access token
+func (p *OIDCProvider) fetchUserInfo(ctx context.Context, accessToken string) (*ExternalIdentity, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.config.UserInfoURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/json")
+
+	var info map[string]interface{}
+	status, err := p.doJSON(req, &info)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("userinfo request failed (HTTP %d)", status)
+	}
+
+	subject := fmt.Sprint(info[p.config.SubjectField])
+	if info[p.config.SubjectField] == nil || subject == "" {
+		return nil, errors.New("userinfo response has no subject")
+	}
+	email, _ := info["email"].(string)
+	name, _ := info["name"].(string)
+
+	return &ExternalIdentity{
+		Provider:      p.config.Name,
+		Subject:       subject,
+		Email:         strings.ToLower(strings.TrimSpace(email)),
+		EmailVerified: email != "" && (p.config.TrustEmail || claimIsTrue(info["email_verified"])),
+		Name:          name,
+	}, nil
+}
+
+// signingKey returns the provider key with the given ID, refreshing the key
+// set when it is unknown (providers rotate keys) at most once a minute
+func (p *OIDCProvider) signingKey(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	if time.Since(p.keysFetch) < time.Minute {
+		return nil, errors.New("unknown signing key")
+	}
+
+	keys, err := p.fetchKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	p.keys, p.keysFetch = keys, time.Now()
+
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, errors.New("unknown signing key")
+}
+
+// fetchKeys downloads the provider's RSA signing keys
+func (p *OIDCProvider) fetchKeys(ctx context.Context) (map[string]*rsa.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.config.JWKSURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var set struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	status, err := p.doJSON(req, &set)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("JWKS request failed (HTTP %d)", status)
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			continue
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	return keys, nil
+}
+
+// doJSON sends a request and decodes the JSON response into out
+func (p *OIDCProvider) doJSON(req *http.Request, out interface{}) (int, error) {
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return resp.StatusCode, fmt.Errorf("failed to read response: %w", err)
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return resp.StatusCode, fmt.Errorf("unexpected response (HTTP %d): %w", resp.StatusCode, err)
+	}
+	return resp.StatusCode, nil
+}
+
+// PKCEChallenge returns the S256 code challenge for a PKCE code verifier
+func PKCEChallenge(codeVerifier string) string {
+	sum := sha256.Sum256([]byte(codeVerifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func claimIsTrue(value interface{}) bool {
+	switch v := value.(type) {
+	case bool:
+		return v
+	case string:
+		return v == "true"
+	}
+	return false
+}
+
+// GoogleProviderConfig returns the Google OpenID Connect configuration
+func GoogleProviderConfig(clientID, clientSecret, redirectURL string) OIDCProviderConfig {
+	return OIDCProviderConfig{
+		Name:         "google",
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		RedirectURL:  redirectURL,
+		AuthURL:      "https://accounts.google.com/o/oauth2/v2/auth",
+		TokenURL:     "https://oauth2.googleapis.com/token",
+		JWKSURL:      "https://www.googleapis.com/oauth2/v3/certs",
+		Issuer:       "https://accounts.google.com",
+		Scopes:       []string{"openid", "email", "profile"},
+	}
+}
+
+// FacebookProviderConfig returns the Facebook Login configuration. Facebook
+// issues no ID token in the web flow, so the user is read from the Graph API;
+// it only returns email addresses the user has confirmed.
+func FacebookProviderConfig(clientID, clientSecret, redirectURL string) OIDCProviderConfig {
+	return OIDCProviderConfig{
+		Name:         "facebook",
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		RedirectURL:  redirectURL,
+		AuthURL:      "https://www.facebook.com/v19.0/dialog/oauth",
+		TokenURL:     "https://graph.facebook.com/v19.0/oauth/access_token",
+		UserInfoURL:  "https://graph.facebook.com/v19.0/me?fields=id,name,email",
+		Scopes:       []string{"email", "public_profile"},
+		SubjectField: "id",
+		TrustEmail:   true,
+	}
+}
@@ -0,0 +1,303 @@
+package utils
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testClientID = "test-client"
+
+// fakeOIDCProvider is a local identity provider serving discovery, token,
+// JWKS and userinfo endpoints. Codes are handed out by authorize, which
+// plays the part of the user logging in at the provider.
+type fakeOIDCProvider struct {
+	t      *testing.T
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu       sync.Mutex
+	codes    map[string]fakeAuthorization
+	tokenKid string        // kid put in issued ID tokens
+	tokenTTL time.Duration // lifetime of issued ID tokens
+}
+
+type fakeAuthorization struct {
+	challenge string
+	nonce     string
+}
+
+func newFakeOIDCProvider(t *testing.T) *fakeOIDCProvider {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+
+	f := &fakeOIDCProvider{
+		t:        t,
+		key:      key,
+		codes:    make(map[string]fakeAuthorization),
+		tokenKid: "test-key",
+		tokenTTL: time.Hour,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", f.discovery)
+	mux.HandleFunc("/token", f.token)
+	mux.HandleFunc("/jwks", f.jwks)
+	mux.HandleFunc("/userinfo", f.userinfo)
+	f.server = httptest.NewServer(mux)
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+func (f *fakeOIDCProvider) discovery(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(map[string]string{
+		"issuer":                 f.server.URL,
+		"authorization_endpoint": f.server.URL + "/authorize",
+		"token_endpoint":         f.server.URL + "/token",
+		"jwks_uri":               f.server.URL + "/jwks",
+		"userinfo_endpoint":      f.server.URL + "/userinfo",
+	})
+}
+
+// authorize reads the login URL the way the provider would and returns the
+// code it redirects back with
+func (f *fakeOIDCProvider) authorize(authURL string) string {
+	u, err := url.Parse(authURL)
+	if err != nil {
+		f.t.Fatalf("parse auth URL: %v", err)
+	}
+	query := u.Query()
+	if query.Get("code_challenge_method") != "S256" {
+		f.t.Fatalf("code_challenge_method = %q, want S256", query.Get("code_challenge_method"))
+	}
+
+	code := "code-" + query.Get("state")
+	f.mu.Lock()
+	f.codes[code] = fakeAuthorization{challenge: query.Get("code_challenge"), nonce: query.Get("nonce")}
+	f.mu.Unlock()
+	return code
+}
+
+func (f *fakeOIDCProvider) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		f.t.Errorf("parse token form: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	f.mu.Lock()
+	auth, ok := f.codes[r.PostForm.Get("code")]
+	delete(f.codes, r.PostForm.Get("code"))
+	kid, ttl := f.tokenKid, f.tokenTTL
+	f.mu.Unlock()
+
+	if !ok || r.PostForm.Get("client_id") != testClientID {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+	if PKCEChallenge(r.PostForm.Get("code_verifier")) != auth.challenge {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant", "error_description": "PKCE verification failed"})
+		return
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":            f.server.URL,
+		"aud":            testClientID,
+		"sub":            "user-42",
+		"email":          "Buyer@Example.com",
+		"email_verified": true,
+		"name":           "Test Buyer",
+		"nonce":          auth.nonce,
+		"iat":            now.Unix(),
+		"exp":            now.Add(ttl).Unix(),
+	}
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	idToken.Header["kid"] = kid
+	signed, err := idToken.SignedString(f.key)
+	if err != nil {
+		f.t.Errorf("sign ID token: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{
+		"access_token": "access-user-42",
+		"id_token":     signed,
+		"token_type":   "Bearer",
+	})
+}
+
+func (f *fakeOIDCProvider) jwks(w http.ResponseWriter, r *http.Request) {
+	public := f.key.PublicKey
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"keys": []map[string]string{{
+			"kid": "test-key",
+			"kty": "RSA",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(public.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes()),
+		}},
+	})
+}
+
+func (f *fakeOIDCProvider) userinfo(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer access-user-42" {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_token"})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":    "fb-42",
+		"name":  "Test Buyer",
+		"email": "buyer@example.com",
+	})
+}
+
+// providerConfig builds the client configuration from the discovery document
+func (f *fakeOIDCProvider) providerConfig() OIDCProviderConfig {
+	resp, err := http.Get(f.server.URL + "/.well-known/openid-configuration")
+	if err != nil {
+		f.t.Fatalf("discovery: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var doc map[string]string
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		f.t.Fatalf("decode discovery: %v", err)
+	}
+	return OIDCProviderConfig{
+		Name:        "fake",
+		ClientID:    testClientID,
+		RedirectURL: "https://shop.test/api/v1/auth/oauth/fake/callback",
+		AuthURL:     doc["authorization_endpoint"],
+		TokenURL:    doc["token_endpoint"],
+		JWKSURL:     doc["jwks_uri"],
+		Issuer:      doc["issuer"],
+		Scopes:      []string{"openid", "email", "profile"},
+	}
+}
+
+func TestOIDCIdentify(t *testing.T) {
+	fake := newFakeOIDCProvider(t)
+	provider := NewOIDCProvider(fake.providerConfig(), nil)
+
+	code := fake.authorize(provider.AuthCodeURL("state-1", "nonce-1", "verifier-1"))
+	identity, err := provider.Identify(context.Background(), code, "verifier-1", "nonce-1")
+	if err != nil {
+		t.Fatalf("Identify: %v", err)
+	}
+	if identity.Provider != "fake" || identity.Subject != "user-42" {
+		t.Errorf("identity = %+v", identity)
+	}
+	if identity.Email != "buyer@example.com" || !identity.EmailVerified {
+		t.Errorf("email = %q verified = %v", identity.Email, identity.EmailVerified)
+	}
+}
+
+func TestOIDCAuthCodeURL(t *testing.T) {
+	fake := newFakeOIDCProvider(t)
+	provider := NewOIDCProvider(fake.providerConfig(), nil)
+
+	u, err := url.Parse(provider.AuthCodeURL("state-2", "nonce-2", "verifier-2"))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	query := u.Query()
+	if query.Get("state") != "state-2" || query.Get("nonce") != "nonce-2" {
+		t.Errorf("state = %q nonce = %q", query.Get("state"), query.Get("nonce"))
+	}
+	if query.Get("code_challenge") != PKCEChallenge("verifier-2") {
+		t.Errorf("code_challenge = %q", query.Get("code_challenge"))
+	}
+	if strings.Contains(u.RawQuery, "verifier-2") {
+		t.Error("the code verifier must not be sent to the provider's login page")
+	}
+}
+
+func TestOIDCWrongCodeVerifier(t *testing.T) {
+	fake := newFakeOIDCProvider(t)
+	provider := NewOIDCProvider(fake.providerConfig(), nil)
+
+	code := fake.authorize(provider.AuthCodeURL("state-3", "nonce-3", "verifier-3"))
+	_, err := provider.Identify(context.Background(), code, "someone-elses-verifier", "nonce-3")
+	if err == nil || !strings.Contains(err.Error(), "token exchange failed") {
+		t.Fatalf("err = %v, want a failed token exchange", err)
+	}
+}
+
+func TestOIDCNonceMismatch(t *testing.T) {
+	fake := newFakeOIDCProvider(t)
+	provider := NewOIDCProvider(fake.providerConfig(), nil)
+
+	code := fake.authorize(provider.AuthCodeURL("state-4", "nonce-4", "verifier-4"))
+	_, err := provider.Identify(context.Background(), code, "verifier-4", "replayed-nonce")
+	if err == nil || !strings.Contains(err.Error(), "nonce mismatch") {
+		t.Fatalf("err = %v, want nonce mismatch", err)
+	}
+}
+
+func TestOIDCExpiredIDToken(t *testing.T) {
+	fake := newFakeOIDCProvider(t)
+	fake.tokenTTL = -10 * time.Minute // well past the one minute leeway
+	provider := NewOIDCProvider(fake.providerConfig(), nil)
+
+	code := fake.authorize(provider.AuthCodeURL("state-5", "nonce-5", "verifier-5"))
+	_, err := provider.Identify(context.Background(), code, "verifier-5", "nonce-5")
+	if err == nil || !strings.Contains(err.Error(), "expired") {
+		t.Fatalf("err = %v, want an expired token error", err)
+	}
+}
+
+func TestOIDCUnknownKeyID(t *testing.T) {
+	fake := newFakeOIDCProvider(t)
+	fake.tokenKid = "rotated-key"
+	provider := NewOIDCProvider(fake.providerConfig(), nil)
+
+	code := fake.authorize(provider.AuthCodeURL("state-6", "nonce-6", "verifier-6"))
+	_, err := provider.Identify(context.Background(), code, "verifier-6", "nonce-6")
+	if err == nil || !strings.Contains(err.Error(), "unknown signing key") {
+		t.Fatalf("err = %v, want unknown signing key", err)
+	}
+}
+
+func TestOIDCUserInfo(t *testing.T) {
+	fake := newFakeOIDCProvider(t)
+	config := fake.providerConfig()
+	config.JWKSURL = ""
+	config.UserInfoURL = fake.server.URL + "/userinfo"
+	config.SubjectField = "id"
+	config.TrustEmail = true
+	provider := NewOIDCProvider(config, nil)
+
+	authURL := provider.AuthCodeURL("state-7", "nonce-7", "verifier-7")
+	if strings.Contains(authURL, "nonce=") {
+		t.Error("providers without ID tokens should not get a nonce")
+	}
+
+	code := fake.authorize(authURL)
+	identity, err := provider.Identify(context.Background(), code, "verifier-7", "")
+	if err != nil {
+		t.Fatalf("Identify: %v", err)
+	}
+	if identity.Subject != "fb-42" || identity.Email != "buyer@example.com" || !identity.EmailVerified {
+		t.Errorf("identity = %+v", identity)
+	}
+}
@@ -1,11 +1,15 @@
 package utils
 
 import (
+	"bytes"
 	"crypto/hmac"
 	"crypto/sha256"
 	"crypto/sha512"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"io"
+	"net/http"
 	"net/url"
 	"sort"
 	"strings"
@@ -18,6 +22,7 @@ type VNPayConfig struct {
 	HashSecret string
 	PaymentURL string
 	ReturnURL  string
+	APIURL     string
 }
 
 // MoMoConfig holds MoMo configuration
@@ -26,21 +31,36 @@ type MoMoConfig struct {
 	AccessKey   string
 	SecretKey   string
 	PaymentURL  string
+	RefundURL   string
+	QueryURL    string
 	IPNUrl      string
 	ReturnURL   string
 }
 
 // VNPayHelper provides VNPay integration utilities
 type VNPayHelper struct {
-	config VNPayConfig
+	config     VNPayConfig
+	httpClient *http.Client
 }
 
 func NewVNPayHelper(config VNPayConfig) *VNPayHelper {
-	return &VNPayHelper{config: config}
+	return &VNPayHelper{
+		config:     config,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
 }
 
-// GeneratePaymentURL generates VNPay payment URL
-func (h *VNPayHelper) GeneratePaymentURL(orderCode string, amount int64, orderInfo string, ipAddr string) (string, error) {
+// vnpayLocation is the timezone VNPay expects for all timestamps (GMT+7)
+var vnpayLocation = time.FixedZone("GMT+7", 7*60*60)
+
+// VNPayTime formats t the way VNPay expects (yyyyMMddHHmmss, GMT+7)
+func VNPayTime(t time.Time) string {
+	return t.In(vnpayLocation).Format("20060102150405")
+}
+
+// GeneratePaymentURL generates VNPay payment URL. createdAt becomes
+// vnp_CreateDate, which querydr needs again later to find the transaction.
+func (h *VNPayHelper) GeneratePaymentURL(orderCode string, amount int64, orderInfo string, ipAddr string, createdAt time.Time) (string, error) {
 	params := url.Values{}
 	params.Set("vnp_Version", "2.1.0")
 	params.Set("vnp_Command", "pay")
@@ -53,7 +73,7 @@ func (h *VNPayHelper) GeneratePaymentURL(orderCode string, amount int64, orderIn
 	params.Set("vnp_Locale", "vn")
 	params.Set("vnp_ReturnUrl", h.config.ReturnURL)
 	params.Set("vnp_IpAddr", ipAddr)
-	params.Set("vnp_CreateDate", time.Now().Format("20060102150405"))
+	params.Set("vnp_CreateDate", VNPayTime(createdAt))
 
 	// Create signature
 	signature := h.createSignature(params)
@@ -72,6 +92,181 @@ func (h *VNPayHelper) VerifyCallback(queryParams url.Values) bool {
 	return secureHash == expectedSignature
 }
 
+// VNPayRefundRequest describes a refund of a paid VNPay transaction
+type VNPayRefundRequest struct {
+	RequestID       string
+	OrderCode       string
+	Amount          int64
+	Full            bool
+	TransactionNo   string
+	TransactionDate string // vnp_PayDate of the original payment
+	CreatedBy       string
+	OrderInfo       string
+	IPAddr          string
+}
+
+// VNPayAPIResponse is the response of the VNPay merchant API (refund and querydr)
+type VNPayAPIResponse struct {
+	ResponseID        string `json:"vnp_ResponseId"`
+	Command           string `json:"vnp_Command"`
+	ResponseCode      string `json:"vnp_ResponseCode"`
+	Message           string `json:"vnp_Message"`
+	TmnCode           string `json:"vnp_TmnCode"`
+	TxnRef            string `json:"vnp_TxnRef"`
+	Amount            string `json:"vnp_Amount"`
+	OrderInfo         string `json:"vnp_OrderInfo"`
+	BankCode          string `json:"vnp_BankCode"`
+	PayDate           string `json:"vnp_PayDate"`
+	TransactionNo     string `json:"vnp_TransactionNo"`
+	TransactionType   string `json:"vnp_TransactionType"`
+	TransactionStatus string `json:"vnp_TransactionStatus"`
+	PromotionCode     string `json:"vnp_PromotionCode"`
+	PromotionAmount   string `json:"vnp_PromotionAmount"`
+	SecureHash        string `json:"vnp_SecureHash"`
+}
+
+// VNPayError is a non-success vnp_ResponseCode returned by the VNPay merchant API
+type VNPayError struct {
+	ResponseCode string
+	Message      string
+}
+
+func (e *VNPayError) Error() string {
+	return fmt.Sprintf("vnpay error %s: %s", e.ResponseCode, e.Message)
+}
+
+// Refund calls the VNPay merchant API with vnp_Command=refund.
+// A response code other than "00" is returned as *VNPayError.
+func (h *VNPayHelper) Refund(req VNPayRefundRequest) (*VNPayAPIResponse, error) {
+	transactionType := "03" // partial refund
+	if req.Full {
+		transactionType = "02"
+	}
+
+	body := map[string]string{
+		"vnp_RequestId":       req.RequestID,
+		"vnp_Version":         "2.1.0",
+		"vnp_Command":         "refund",
+		"vnp_TmnCode":         h.config.TmnCode,
+		"vnp_TransactionType": transactionType,
+		"vnp_TxnRef":          req.OrderCode,
+		"vnp_Amount":          fmt.Sprintf("%d", req.Amount*100),
+		"vnp_OrderInfo":       req.OrderInfo,
+		"vnp_TransactionNo":   req.TransactionNo,
+		"vnp_TransactionDate": req.TransactionDate,
+		"vnp_CreateBy":        req.CreatedBy,
+		"vnp_CreateDate":      VNPayTime(time.Now()),
+		"vnp_IpAddr":          req.IPAddr,
+	}
+	body["vnp_SecureHash"] = h.hashPipe(
+		body["vnp_RequestId"], body["vnp_Version"], body["vnp_Command"], body["vnp_TmnCode"],
+		body["vnp_TransactionType"], body["vnp_TxnRef"], body["vnp_Amount"], body["vnp_TransactionNo"],
+		body["vnp_TransactionDate"], body["vnp_CreateBy"], body["vnp_CreateDate"], body["vnp_IpAddr"],
+		body["vnp_OrderInfo"],
+	)
+
+	var result VNPayAPIResponse
+	if err := postJSON(h.httpClient, h.config.APIURL, body, &result); err != nil {
+		return nil, fmt.Errorf("failed to call VNPay: %w", err)
+	}
+
+	expected := h.hashPipe(
+		result.ResponseID, result.Command, result.ResponseCode, result.Message, result.TmnCode,
+		result.TxnRef, result.Amount, result.BankCode, result.PayDate, result.TransactionNo,
+		result.TransactionType, result.TransactionStatus, result.OrderInfo,
+	)
+	if result.SecureHash != expected {
+		return nil, fmt.Errorf("invalid VNPay response signature")
+	}
+
+	if result.ResponseCode != "00" {
+		return &result, &VNPayError{ResponseCode: result.ResponseCode, Message: VNPayAPIMessage(result.ResponseCode, result.Message)}
+	}
+
+	return &result, nil
+}
+
+// VNPayQueryRequest describes a querydr lookup of a payment
+type VNPayQueryRequest struct {
+	RequestID       string
+	OrderCode       string
+	TransactionDate string // vnp_CreateDate of the payment URL
+	OrderInfo       string
+	IPAddr          string
+}
+
+// QueryTransaction calls the VNPay merchant API with vnp_Command=querydr.
+// A response code other than "00" is returned as *VNPayError; the payment
+// result itself is in TransactionStatus.
+func (h *VNPayHelper) QueryTransaction(req VNPayQueryRequest) (*VNPayAPIResponse, error) {
+	body := map[string]string{
+		"vnp_RequestId":       req.RequestID,
+		"vnp_Version":         "2.1.0",
+		"vnp_Command":         "querydr",
+		"vnp_TmnCode":         h.config.TmnCode,
+		"vnp_TxnRef":          req.OrderCode,
+		"vnp_OrderInfo":       req.OrderInfo,
+		"vnp_TransactionDate": req.TransactionDate,
+		"vnp_CreateDate":      VNPayTime(time.Now()),
+		"vnp_IpAddr":          req.IPAddr,
+	}
+	body["vnp_SecureHash"] = h.hashPipe(
+		body["vnp_RequestId"], body["vnp_Version"], body["vnp_Command"], body["vnp_TmnCode"],
+		body["vnp_TxnRef"], body["vnp_TransactionDate"], body["vnp_CreateDate"], body["vnp_IpAddr"],
+		body["vnp_OrderInfo"],
+	)
+
+	var result VNPayAPIResponse
+	if err := postJSON(h.httpClient, h.config.APIURL, body, &result); err != nil {
+		return nil, fmt.Errorf("failed to call VNPay: %w", err)
+	}
+
+	expected := h.hashPipe(
+		result.ResponseID, result.Command, result.ResponseCode, result.Message, result.TmnCode,
+		result.TxnRef, result.Amount, result.BankCode, result.PayDate, result.TransactionNo,
+		result.TransactionType, result.TransactionStatus, result.OrderInfo,
+		result.PromotionCode, result.PromotionAmount,
+	)
+	if result.SecureHash != expected {
+		return nil, fmt.Errorf("invalid VNPay response signature")
+	}
+
+	if result.ResponseCode != "00" {
+		return &result, &VNPayError{ResponseCode: result.ResponseCode, Message: VNPayAPIMessage(result.ResponseCode, result.Message)}
+	}
+
+	return &result, nil
+}
+
+// vnpayAPIMessages maps VNPay merchant API response codes to readable messages
+var vnpayAPIMessages = map[string]string{
+	"02": "invalid merchant",
+	"03": "invalid request format",
+	"91": "transaction not found",
+	"94": "duplicate request",
+	"95": "transaction cannot be refunded",
+	"97": "invalid checksum",
+	"99": "unknown error",
+}
+
+// VNPayAPIMessage returns a readable message for a VNPay merchant API response code
+func VNPayAPIMessage(responseCode, fallback string) string {
+	if message, ok := vnpayAPIMessages[responseCode]; ok {
+		return message
+	}
+	if fallback != "" {
+		return fallback
+	}
+	return "payment gateway error"
+}
+
+// hashPipe signs pipe-separated values, as used by the VNPay merchant API
+func (h *VNPayHelper) hashPipe(values ...string) string {
+	mac := hmac.New(sha512.New, []byte(h.config.HashSecret))
+	mac.Write([]byte(strings.Join(values, "|")))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
 func (h *VNPayHelper) createSignature(params url.Values) string {
 	// Sort parameters
 	keys := make([]string, 0, len(params))
@@ -97,11 +292,293 @@ func (h *VNPayHelper) createSignature(params url.Values) string {
 
 // MoMoHelper provides MoMo integration utilities
 type MoMoHelper struct {
-	config MoMoConfig
+	config     MoMoConfig
+	httpClient *http.Client
 }
 
 func NewMoMoHelper(config MoMoConfig) *MoMoHelper {
-	return &MoMoHelper{config: config}
+	return &MoMoHelper{
+		config:     config,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// MoMoCreatePaymentRequest is the body of the MoMo create-payment API
+type MoMoCreatePaymentRequest struct {
+	PartnerCode string `json:"partnerCode"`
+	RequestID   string `json:"requestId"`
+	Amount      int64  `json:"amount"`
+	OrderID     string `json:"orderId"`
+	OrderInfo   string `json:"orderInfo"`
+	RedirectURL string `json:"redirectUrl"`
+	IpnURL      string `json:"ipnUrl"`
+	RequestType string `json:"requestType"`
+	ExtraData   string `json:"extraData"`
+	Lang        string `json:"lang"`
+	Signature   string `json:"signature"`
+}
+
+// MoMoCreatePaymentResponse is the response of the MoMo create-payment API
+type MoMoCreatePaymentResponse struct {
+	PartnerCode  string `json:"partnerCode"`
+	RequestID    string `json:"requestId"`
+	OrderID      string `json:"orderId"`
+	Amount       int64  `json:"amount"`
+	ResponseTime int64  `json:"responseTime"`
+	Message      string `json:"message"`
+	ResultCode   int    `json:"resultCode"`
+	PayURL       string `json:"payUrl"`
+	Deeplink     string `json:"deeplink,omitempty"`
+	QRCodeURL    string `json:"qrCodeUrl,omitempty"`
+}
+
+// MoMoError is a non-zero resultCode returned by MoMo
+type MoMoError struct {
+	ResultCode int
+	Message    string
+}
+
+func (e *MoMoError) Error() string {
+	return fmt.Sprintf("momo error %d: %s", e.ResultCode, e.Message)
+}
+
+// momoResultMessages maps MoMo result codes to messages safe to show customers
+var momoResultMessages = map[int]string{
+	11:   "access denied",
+	12:   "unsupported API version",
+	13:   "merchant authentication failed",
+	20:   "invalid request format",
+	21:   "invalid amount",
+	22:   "amount is out of the allowed range",
+	40:   "duplicated request ID",
+	41:   "duplicated order ID",
+	42:   "invalid or unknown order ID",
+	43:   "a conflicting transaction is in progress",
+	47:   "request contains inapplicable information",
+	98:   "QR code could not be generated",
+	99:   "unknown error",
+	1001: "insufficient funds in MoMo wallet",
+	1002: "payment rejected by the issuer",
+	1004: "amount exceeds the payment limit",
+	1005: "payment link or QR code has expired",
+	1006: "payment was denied by the user",
+	1007: "MoMo account is inactive",
+	1080: "refund amount exceeds the refundable amount",
+	1081: "refund was rejected because the transaction was already refunded",
+}
+
+// MoMoResultMessage returns a readable message for a MoMo result code
+func MoMoResultMessage(resultCode int, fallback string) string {
+	if message, ok := momoResultMessages[resultCode]; ok {
+		return message
+	}
+	if fallback != "" {
+		return fallback
+	}
+	return "payment gateway error"
+}
+
+// CreatePayment calls the MoMo create-payment API (captureWallet) and returns
+// the payment links. A non-zero resultCode is returned as *MoMoError.
+func (h *MoMoHelper) CreatePayment(orderID, requestID string, amount int64, orderInfo string) (*MoMoCreatePaymentResponse, error) {
+	request := MoMoCreatePaymentRequest{
+		PartnerCode: h.config.PartnerCode,
+		RequestID:   requestID,
+		Amount:      amount,
+		OrderID:     orderID,
+		OrderInfo:   orderInfo,
+		RedirectURL: h.config.ReturnURL,
+		IpnURL:      h.config.IPNUrl,
+		RequestType: "captureWallet",
+		ExtraData:   "",
+		Lang:        "vi",
+		Signature:   h.GeneratePaymentSignature(orderID, requestID, amount, orderInfo),
+	}
+
+	var result MoMoCreatePaymentResponse
+	if err := postJSON(h.httpClient, h.config.PaymentURL, request, &result); err != nil {
+		return nil, fmt.Errorf("failed to call MoMo: %w", err)
+	}
+
+	if result.ResultCode != 0 {
+		return &result, &MoMoError{
+			ResultCode: result.ResultCode,
+			Message:    MoMoResultMessage(result.ResultCode, result.Message),
+		}
+	}
+	if result.OrderID != orderID || result.RequestID != requestID {
+		return nil, fmt.Errorf("MoMo response does not match request %s", requestID)
+	}
+	if result.PayURL == "" {
+		return nil, fmt.Errorf("MoMo response has no payUrl")
+	}
+
+	return &result, nil
+}
+
+// MoMoRefundResponse is the response of the MoMo refund API
+type MoMoRefundResponse struct {
+	PartnerCode  string `json:"partnerCode"`
+	OrderID      string `json:"orderId"`
+	RequestID    string `json:"requestId"`
+	Amount       int64  `json:"amount"`
+	TransID      int64  `json:"transId"`
+	ResultCode   int    `json:"resultCode"`
+	Message      string `json:"message"`
+	ResponseTime int64  `json:"responseTime"`
+	Signature    string `json:"signature"`
+}
+
+// Refund calls the MoMo refund API for a captured transaction. refundOrderID
+// must be a new, unique ID for the refund itself. A non-zero resultCode is
+// returned as *MoMoError.
+func (h *MoMoHelper) Refund(refundOrderID, requestID string, amount int64, transID int64, description string) (*MoMoRefundResponse, error) {
+	rawSignature := fmt.Sprintf("accessKey=%s&amount=%d&description=%s&orderId=%s&partnerCode=%s&requestId=%s&transId=%d",
+		h.config.AccessKey,
+		amount,
+		description,
+		refundOrderID,
+		h.config.PartnerCode,
+		requestID,
+		transID,
+	)
+
+	request := map[string]interface{}{
+		"partnerCode": h.config.PartnerCode,
+		"orderId":     refundOrderID,
+		"requestId":   requestID,
+		"amount":      amount,
+		"transId":     transID,
+		"lang":        "vi",
+		"description": description,
+		"signature":   h.sign(rawSignature),
+	}
+
+	var result MoMoRefundResponse
+	if err := postJSON(h.httpClient, h.config.RefundURL, request, &result); err != nil {
+		return nil, fmt.Errorf("failed to call MoMo: %w", err)
+	}
+
+	expected := h.sign(fmt.Sprintf("accessKey=%s&amount=%d&message=%s&orderId=%s&partnerCode=%s&requestId=%s&responseTime=%d&resultCode=%d&transId=%d",
+		h.config.AccessKey, result.Amount, result.Message, result.OrderID, result.PartnerCode,
+		result.RequestID, result.ResponseTime, result.ResultCode, result.TransID,
+	))
+	if result.Signature != expected {
+		return nil, fmt.Errorf("invalid MoMo response signature")
+	}
+
+	if result.ResultCode != 0 {
+		return &result, &MoMoError{
+			ResultCode: result.ResultCode,
+			Message:    MoMoResultMessage(result.ResultCode, result.Message),
+		}
+	}
+
+	return &result, nil
+}
+
+// MoMoQueryResponse is the response of the MoMo transaction status API
+type MoMoQueryResponse struct {
+	PartnerCode  string `json:"partnerCode"`
+	OrderID      string `json:"orderId"`
+	RequestID    string `json:"requestId"`
+	ExtraData    string `json:"extraData"`
+	Amount       int64  `json:"amount"`
+	TransID      int64  `json:"transId"`
+	PayType      string `json:"payType"`
+	ResultCode   int    `json:"resultCode"`
+	Message      string `json:"message"`
+	ResponseTime int64  `json:"responseTime"`
+	LastUpdated  int64  `json:"lastUpdated"`
+	Signature    string `json:"signature"`
+
+	// Refunds made against the payment, each under its own orderId
+	RefundTrans []MoMoRefundTransaction `json:"refundTrans,omitempty"`
+}
+
+// MoMoRefundTransaction is a refund listed in a MoMo transaction status response
+type MoMoRefundTransaction struct {
+	OrderID     string `json:"orderId"`
+	Amount      int64  `json:"amount"`
+	ResultCode  int    `json:"resultCode"`
+	TransID     int64  `json:"transId"`
+	CreatedTime int64  `json:"createdTime"`
+}
+
+// MoMoPendingResultCodes are result codes of transactions that are not final yet
+var MoMoPendingResultCodes = map[int]bool{
+	1000: true, // initiated, waiting for the user to confirm
+	7000: true, // being processed
+	7002: true, // being processed by the issuer
+	9000: true, // authorized, not captured yet
+}
+
+// QueryTransaction calls the MoMo transaction status API for orderID.
+// Unlike the other calls, the payment result is returned in ResultCode
+// without being turned into an error.
+func (h *MoMoHelper) QueryTransaction(orderID, requestID string) (*MoMoQueryResponse, error) {
+	rawSignature := fmt.Sprintf("accessKey=%s&orderId=%s&partnerCode=%s&requestId=%s",
+		h.config.AccessKey,
+		orderID,
+		h.config.PartnerCode,
+		requestID,
+	)
+
+	request := map[string]interface{}{
+		"partnerCode": h.config.PartnerCode,
+		"requestId":   requestID,
+		"orderId":     orderID,
+		"lang":        "vi",
+		"signature":   h.sign(rawSignature),
+	}
+
+	var result MoMoQueryResponse
+	if err := postJSON(h.httpClient, h.config.QueryURL, request, &result); err != nil {
+		return nil, fmt.Errorf("failed to call MoMo: %w", err)
+	}
+
+	expected := h.sign(fmt.Sprintf("accessKey=%s&amount=%d&extraData=%s&message=%s&orderId=%s&partnerCode=%s&payType=%s&requestId=%s&responseTime=%d&resultCode=%d&transId=%d",
+		h.config.AccessKey, result.Amount, result.ExtraData, result.Message, result.OrderID, result.PartnerCode,
+		result.PayType, result.RequestID, result.ResponseTime, result.ResultCode, result.TransID,
+	))
+	if result.Signature != expected {
+		return nil, fmt.Errorf("invalid MoMo response signature")
+	}
+	if result.OrderID != "" && result.OrderID != orderID {
+		return nil, fmt.Errorf("MoMo response does not match order %s", orderID)
+	}
+
+	return &result, nil
+}
+
+func (h *MoMoHelper) sign(rawSignature string) string {
+	hmacHash := hmac.New(sha256.New, []byte(h.config.SecretKey))
+	hmacHash.Write([]byte(rawSignature))
+	return hex.EncodeToString(hmacHash.Sum(nil))
+}
+
+// postJSON posts body as JSON and decodes the JSON response into out
+func postJSON(client *http.Client, endpoint string, body interface{}, out interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Post(endpoint, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return fmt.Errorf("unexpected response (HTTP %d): %w", resp.StatusCode, err)
+	}
+	return nil
 }
 
 // GeneratePaymentSignature generates MoMo payment signature
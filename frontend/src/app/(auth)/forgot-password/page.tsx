@@ -47,7 +47,7 @@ export default function ForgotPasswordPage() {
                             Chúng tôi đã gửi mã xác thực đến <strong>{email}</strong>. Vui lòng kiểm tra hộp thư của bạn.
                         </p>
                         <Link
-                            href="/reset-password"
+                            href={`/reset-password?email=${encodeURIComponent(email)}`}
                             className="inline-block bg-blue-600 text-white px-6 py-3 rounded-md hover:bg-blue-700 font-semibold"
                         >
                             Đặt lại mật khẩu
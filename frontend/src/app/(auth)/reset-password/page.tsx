@@ -1,15 +1,17 @@
 'use client';
 
-import { useState } from 'react';
-import { useRouter } from 'next/navigation';
+import { Suspense, useState } from 'react';
+import { useRouter, useSearchParams } from 'next/navigation';
 import Link from 'next/link';
 import { authService } from '@/services/auth.service';
 import { toast } from '@/components/common/Toast';
 import ErrorMessage from '@/components/common/ErrorMessage';
 
-export default function ResetPasswordPage() {
+function ResetPasswordContent() {
     const router = useRouter();
+    const searchParams = useSearchParams();
     const [formData, setFormData] = useState({
+        email: searchParams.get('email') || '',
         code: '',
         password: '',
         confirmPassword: '',
@@ -38,7 +40,7 @@ export default function ResetPasswordPage() {
         setIsLoading(true);
 
         try {
-            await authService.resetPassword(formData.code, formData.password);
+            await authService.resetPassword(formData.email, formData.code, formData.password);
             toast.success('Đặt lại mật khẩu thành công');
             router.push('/login');
         } catch (err: any) {
@@ -53,12 +55,27 @@ export default function ResetPasswordPage() {
             <div className="max-w-md mx-auto bg-white rounded-lg shadow-md p-8">
                 <h1 className="text-3xl font-bold text-gray-900 mb-2 text-center">Đặt lại mật khẩu</h1>
                 <p className="text-gray-600 text-center mb-6">
-                    Nhập mã xác thực và mật khẩu mới của bạn
+                    Nhập email, mã xác thực và mật khẩu mới của bạn
                 </p>
 
                 <ErrorMessage message={error} className="mb-4" />
 
                 <form onSubmit={handleSubmit} className="space-y-4">
+                    <div>
+                        <label htmlFor="email" className="block text-sm font-medium text-gray-700 mb-1">
+                            Email
+                        </label>
+                        <input
+                            type="email"
+                            id="email"
+                            name="email"
+                            value={formData.email}
+                            onChange={handleChange}
+                            className="w-full px-4 py-2 border border-gray-300 rounded-md focus:ring-blue-500 focus:border-blue-500"
+                            required
+                        />
+                    </div>
+
                     <div>
                         <label htmlFor="code" className="block text-sm font-medium text-gray-700 mb-1">
                             Mã xác thực
@@ -127,3 +144,18 @@ export default function ResetPasswordPage() {
         </div>
     );
 }
+
+export default function ResetPasswordPage() {
+    return (
+        <Suspense fallback={
+            <div className="min-h-screen flex items-center justify-center bg-gray-50">
+                <div className="text-center">
+                    <div className="animate-spin rounded-full h-12 w-12 border-b-2 border-blue-600 mx-auto"></div>
+                    <p className="mt-4 text-gray-600">Đang tải...</p>
+                </div>
+            </div>
+        }>
+            <ResetPasswordContent />
+        </Suspense>
+    );
+}
@@ -74,6 +74,8 @@ export default function EditCategoryPage() {
                 name: formData.name.trim(),
                 slug: formData.slug.trim(),
                 description: formData.description.trim() || undefined,
+                parent_id: category?.parent_id ?? null,
+                position: category?.position ?? 0,
             });
             toast.success('Cập nhật danh mục thành công');
             router.push('/admin/categories');
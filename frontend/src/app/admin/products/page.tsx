@@ -166,8 +166,8 @@ export default function AdminProductsPage() {
                                         </tr>
                                     ) : (
                                         products.map((product) => {
-                                            const totalStock = product.variants?.reduce((sum, v) => sum + v.stock_quantity, 0) || 0;
-                                            const primaryImage = product.images?.find(img => img.is_primary)?.image_url || product.images?.[0]?.image_url;
+                                            const totalStock = product.total_stock ?? product.variants?.reduce((sum, v) => sum + v.stock_quantity, 0) ?? 0;
+                                            const primaryImage = product.image_url || product.images?.find(img => img.is_primary)?.image_url || product.images?.[0]?.image_url;
                                             
                                             return (
                                                 <tr key={product.id} className="hover:bg-gray-50">
@@ -300,7 +300,7 @@ export default function AdminProductsPage() {
                             <div className="text-sm text-gray-600">Hết hàng</div>
                             <div className="text-2xl font-bold text-red-600 mt-1">
                                 {products.filter(p => {
-                                    const stock = p.variants?.reduce((sum, v) => sum + v.stock_quantity, 0) || 0;
+                                    const stock = p.total_stock ?? p.variants?.reduce((sum, v) => sum + v.stock_quantity, 0) ?? 0;
                                     return stock === 0;
                                 }).length}
                             </div>
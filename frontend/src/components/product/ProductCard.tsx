@@ -22,8 +22,8 @@ export default function ProductCard({
     const [isWishlisted, setIsWishlisted] = useState(false);
     const [imageError, setImageError] = useState(false);
 
-    const primaryImage = product.images?.find((img) => img.is_primary);
-    const imageUrl = primaryImage ? getImageUrl(primaryImage.image_url) : '/placeholder.png';
+    const primaryImage = product.image_url ?? product.images?.find((img) => img.is_primary)?.image_url;
+    const imageUrl = primaryImage ? getImageUrl(primaryImage) : '/placeholder.png';
     const hasDiscount = product.discount_price && product.discount_price < product.price;
     
     // Calculate discount percentage
@@ -37,14 +37,13 @@ export default function ProductCard({
         : false;
 
     // Stock status - check variants if available
-    const totalStock = product.variants?.reduce((sum, v) => sum + (v.stock_quantity || 0), 0) ?? 0;
-    const hasVariants = product.variants && product.variants.length > 0;
+    const totalStock = product.total_stock ?? product.variants?.reduce((sum, v) => sum + (v.stock_quantity || 0), 0) ?? 0;
+    const hasVariants = product.total_stock !== undefined || (product.variants && product.variants.length > 0);
     const isOutOfStock = hasVariants && totalStock === 0;
     const isLowStock = hasVariants && totalStock > 0 && totalStock <= 5;
 
-    // Mock rating (in real app, this would come from product data)
-    const rating = 4.5;
-    const reviewCount = 12;
+    const rating = product.rating_average ?? 0;
+    const reviewCount = product.review_count ?? 0;
 
     const handleWishlistClick = (e: React.MouseEvent) => {
         e.preventDefault();
@@ -29,6 +29,7 @@ interface VariantFormData {
     color: string;
     stock_quantity: string;
     sku: string;
+    weight: string;
 }
 
 export default function ProductForm({ product, mode }: ProductFormProps) {
@@ -58,8 +59,9 @@ export default function ProductForm({ product, mode }: ProductFormProps) {
             color: v.color,
             stock_quantity: v.stock_quantity.toString(),
             sku: v.sku,
+            weight: (v.weight ?? 0).toString(),
         })) || [
-            { size: 'M', color: 'Đen', stock_quantity: '10', sku: '' }
+            { size: 'M', color: 'Đen', stock_quantity: '10', sku: '', weight: '0' }
         ]
     );
 
@@ -87,7 +89,7 @@ export default function ProductForm({ product, mode }: ProductFormProps) {
     };
 
     const handleAddVariant = () => {
-        setVariants([...variants, { size: 'M', color: 'Đen', stock_quantity: '10', sku: '' }]);
+        setVariants([...variants, { size: 'M', color: 'Đen', stock_quantity: '10', sku: '', weight: '0' }]);
     };
 
     const handleRemoveVariant = (index: number) => {
@@ -139,6 +141,10 @@ export default function ProductForm({ product, mode }: ProductFormProps) {
                 toast.error(`Biến thể ${i + 1}: Số lượng tồn kho không hợp lệ`);
                 return false;
             }
+            if (v.weight && parseInt(v.weight) < 0) {
+                toast.error(`Biến thể ${i + 1}: Khối lượng không hợp lệ`);
+                return false;
+            }
         }
         return true;
     };
@@ -178,6 +184,7 @@ export default function ProductForm({ product, mode }: ProductFormProps) {
                         color: v.color,
                         stock_quantity: parseInt(v.stock_quantity),
                         sku: v.sku || `${slug}-${v.size}-${v.color}`.toUpperCase(),
+                        weight: parseInt(v.weight) || 0,
                     })),
                     images: images.map((url, index) => ({
                         image_url: url,
@@ -244,6 +251,7 @@ export default function ProductForm({ product, mode }: ProductFormProps) {
                         color: variant.color,
                         stock_quantity: parseInt(variant.stock_quantity),
                         sku: variant.sku || `${slug}-${variant.size}-${variant.color}`.toUpperCase(),
+                        weight: parseInt(variant.weight) || 0,
                     };
                     
                     if (existingVariant) {
@@ -426,7 +434,7 @@ export default function ProductForm({ product, mode }: ProductFormProps) {
                                 )}
                             </div>
 
-                            <div className="grid grid-cols-1 md:grid-cols-4 gap-3">
+                            <div className="grid grid-cols-1 md:grid-cols-5 gap-3">
                                 <div>
                                     <label className="block text-sm font-medium text-gray-700 mb-1">
                                         Kích thước
@@ -473,6 +481,20 @@ export default function ProductForm({ product, mode }: ProductFormProps) {
                                     />
                                 </div>
 
+                                <div>
+                                    <label className="block text-sm font-medium text-gray-700 mb-1">
+                                        Khối lượng (g)
+                                    </label>
+                                    <input
+                                        type="number"
+                                        value={variant.weight}
+                                        onChange={(e) => handleVariantChange(index, 'weight', e.target.value)}
+                                        min="0"
+                                        className="w-full px-3 py-2 border border-gray-300 rounded-md focus:ring-blue-500 focus:border-blue-500"
+                                        placeholder="300"
+                                    />
+                                </div>
+
                                 <div>
                                     <label className="block text-sm font-medium text-gray-700 mb-1">
                                         SKU (tùy chọn)
@@ -37,8 +37,8 @@ export const authService = {
         await apiClient.post(API_ENDPOINTS.FORGOT_PASSWORD, { email });
     },
 
-    async resetPassword(code: string, password: string): Promise<void> {
-        await apiClient.post(API_ENDPOINTS.RESET_PASSWORD, { code, new_password: password });
+    async resetPassword(email: string, code: string, password: string): Promise<void> {
+        await apiClient.post(API_ENDPOINTS.RESET_PASSWORD, { email, code, new_password: password });
     },
 
     async getProfile(): Promise<User> {
@@ -1,17 +1,23 @@
 import { apiClient } from '@/lib/api';
 import { API_ENDPOINTS } from '@/lib/constants';
-import type { Category } from '@/types';
+import type { Category, CategoryTreeNode } from '@/types';
 
 export interface CreateCategoryInput {
     name: string;
     description?: string;
     slug: string;
+    parent_id?: number | null;
+    position?: number;
 }
 
+// Updates replace the whole category, so send parent_id and position back
+// unchanged to keep the category where it is
 export interface UpdateCategoryInput {
     name?: string;
     description?: string;
     slug?: string;
+    parent_id?: number | null;
+    position?: number;
 }
 
 class CategoryService {
@@ -45,9 +51,18 @@ class CategoryService {
         return response.data;
     }
 
-    // Delete category (admin)
-    async deleteCategory(id: number): Promise<void> {
-        await apiClient.delete(`${API_ENDPOINTS.ADMIN_CATEGORIES}/${id}`);
+    // Get categories nested under their parents (public)
+    async getCategoryTree(): Promise<CategoryTreeNode[]> {
+        const response = await apiClient.get<{ data: CategoryTreeNode[] }>(`${API_ENDPOINTS.CATEGORIES}/tree`);
+        return response.data;
+    }
+
+    // Delete category (admin); a category with subcategories or products
+    // needs reassignTo, the category to move them to
+    async deleteCategory(id: number, reassignTo?: number): Promise<void> {
+        await apiClient.delete(`${API_ENDPOINTS.ADMIN_CATEGORIES}/${id}`, {
+            params: reassignTo ? { reassign_to: reassignTo } : undefined,
+        });
     }
 }
 
@@ -4,10 +4,12 @@ import type { Product, ProductListParams, ProductListResponse, Category } from '
 
 // Backend response types
 interface BackendPagination {
-    page: number;
+    page?: number;
     page_size: number;
-    total: number;
-    pages: number;
+    total?: number;
+    pages?: number;
+    next_cursor?: string;
+    has_more?: boolean;
 }
 
 interface BackendProductListResponse {
@@ -24,6 +26,13 @@ export const productService = {
         if (params?.search) queryParams.search = params.search;
         if (params?.min_price) queryParams.min_price = params.min_price;
         if (params?.max_price) queryParams.max_price = params.max_price;
+        if (params?.sizes) queryParams.sizes = params.sizes;
+        if (params?.colors) queryParams.colors = params.colors;
+        if (params?.in_stock) queryParams.in_stock = true;
+        if (params?.on_sale) queryParams.on_sale = true;
+        if (params?.min_rating) queryParams.min_rating = params.min_rating;
+        if (params?.sort) queryParams.sort = params.sort;
+        if (params?.cursor !== undefined) queryParams.cursor = params.cursor;
 
         const response = await apiClient.get<BackendProductListResponse>(API_ENDPOINTS.PRODUCTS, {
             params: queryParams,
@@ -36,6 +45,8 @@ export const productService = {
             page: response.pagination?.page || 1,
             limit: response.pagination?.page_size || 20,
             total_pages: response.pagination?.pages || 0,
+            next_cursor: response.pagination?.next_cursor,
+            has_more: response.pagination?.has_more,
         };
     },
 
@@ -95,6 +106,7 @@ export const productService = {
         color: string;
         stock_quantity: number;
         sku: string;
+        weight: number;
     }): Promise<void> {
         await apiClient.post(`${API_ENDPOINTS.ADMIN_PRODUCTS}/${productId}/variants`, variant);
     },
@@ -104,6 +116,7 @@ export const productService = {
         color: string;
         stock_quantity: number;
         sku: string;
+        weight: number;
     }): Promise<void> {
         await apiClient.put(`${API_ENDPOINTS.ADMIN_PRODUCTS}/${productId}/variants/${variantId}`, variant);
     },
@@ -129,6 +142,7 @@ export interface CreateProductRequest {
         color: string;
         stock_quantity: number;
         sku: string;
+        weight: number;
     }>;
     images: Array<{
         image_url: string;
@@ -25,7 +25,9 @@ export interface CartItem {
 
 export interface Cart {
     id: number;
-    user_id: number;
+    user_id?: number; // absent for guest carts
+    name?: string; // saved lists only
+    is_primary: boolean;
     items: CartItem[];
     subtotal: number;
     total: number;
@@ -6,7 +6,8 @@ export type PaymentStatus = 'pending' | 'paid' | 'failed' | 'refunded';
 export interface Order {
     id: number;
     order_code: string;
-    user_id: number;
+    user_id: number | null; // null for guest orders
+    guest_email?: string;
     status: OrderStatus;
     payment_method: PaymentMethod;
     payment_status: PaymentStatus;
@@ -13,6 +13,14 @@ export interface Product {
     images?: ProductImage[];
     variants?: ProductVariant[];
     category?: Category;
+    breadcrumbs?: Breadcrumb[];
+    sold_count?: number;
+    rating_average?: number;
+    review_count?: number;
+    // Listings return these instead of images and variants
+    image_url?: string;
+    total_stock?: number;
+    in_stock?: boolean;
 }
 
 export interface ProductImage {
@@ -30,19 +38,39 @@ export interface ProductVariant {
     color: string;
     stock_quantity: number;
     sku: string;
+    weight: number; // grams
     created_at: string;
     updated_at: string;
 }
 
 export interface Category {
     id: number;
+    parent_id?: number | null;
     name: string;
     description?: string;
     slug: string;
+    position?: number;
     created_at: string;
     updated_at: string;
 }
 
+export interface CategoryTreeNode extends Category {
+    children: CategoryTreeNode[];
+}
+
+export interface Breadcrumb {
+    id: number;
+    name: string;
+    slug: string;
+}
+
+// A product found by full-text search; matched words are wrapped in <mark>
+export interface ProductSearchResult extends Product {
+    rank: number;
+    name_highlight: string;
+    snippet: string;
+}
+
 export interface ProductListParams {
     page?: number;
     limit?: number;
@@ -50,7 +78,44 @@ export interface ProductListParams {
     search?: string;
     min_price?: number;
     max_price?: number;
-    sort?: 'price_asc' | 'price_desc' | 'newest' | 'popular';
+    sizes?: string;
+    colors?: string;
+    in_stock?: boolean;
+    on_sale?: boolean;
+    min_rating?: number;
+    sort?: 'price_asc' | 'price_desc' | 'newest' | 'best_selling' | 'top_rated' | 'relevance';
+    // Set (empty for the first page) to page by cursor for infinite scroll
+    cursor?: string;
+}
+
+export interface FacetValue {
+    value: string;
+    count: number;
+}
+
+export interface CategoryFacet {
+    category_id: number;
+    name: string;
+    count: number;
+}
+
+export interface PriceBucket {
+    min: number | null;
+    max: number | null;
+    count: number;
+}
+
+export interface ProductFacets {
+    sizes: FacetValue[];
+    colors: FacetValue[];
+    categories: CategoryFacet[];
+    price_ranges: PriceBucket[];
+}
+
+export interface SearchSuggestions {
+    products: { id: number; name: string; slug: string; image_url?: string }[];
+    categories: { id: number; name: string; slug: string }[];
+    queries: { query: string; search_count: number }[];
 }
 
 export interface ProductListResponse {
@@ -59,4 +124,6 @@ export interface ProductListResponse {
     page: number;
     limit: number;
     total_pages: number;
+    next_cursor?: string;
+    has_more?: boolean;
 }